@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
+)
+
+type convertBlocksCmd struct {
+	backendOptions
+
+	TenantID    string   `arg:"" help:"tenant-id within the bucket"`
+	BlockIDs    []string `arg:"" optional:"" help:"block IDs to convert, optional, defaults to all v2 blocks of the tenant"`
+	DryRun      bool     `help:"list the blocks that would be converted without converting them"`
+	Concurrency int      `default:"1" help:"number of blocks converted in parallel"`
+}
+
+func (cmd *convertBlocksCmd) Run(opts *globalOptions) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(&cmd.backendOptions, opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+	r, w, c, err := newBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	var blockIDs []uuid.UUID
+	if len(cmd.BlockIDs) > 0 {
+		for _, id := range cmd.BlockIDs {
+			blockID, err := uuid.Parse(id)
+			if err != nil {
+				return fmt.Errorf("invalid block ID %s: %w", id, err)
+			}
+			blockIDs = append(blockIDs, blockID)
+		}
+	} else {
+		blockIDs, err = r.Blocks(ctx, cmd.TenantID)
+		if err != nil {
+			return err
+		}
+	}
+
+	metas, err := loadV2Metas(ctx, r, cmd.TenantID, blockIDs)
+	if err != nil {
+		return err
+	}
+
+	totalObjects, totalSize := 0, uint64(0)
+	for _, meta := range metas {
+		totalObjects += meta.TotalObjects
+		totalSize += meta.Size
+	}
+	fmt.Println("v2 blocks:", len(metas), "objects:", totalObjects, "size:", humanize.Bytes(totalSize))
+
+	if cmd.DryRun {
+		for _, meta := range metas {
+			fmt.Println(meta.BlockID, "would be converted, objects:", meta.TotalObjects, "size:", humanize.Bytes(meta.Size))
+		}
+		return nil
+	}
+
+	var (
+		wg     = boundedwaitgroup.New(uint(cmd.Concurrency))
+		mtx    sync.Mutex
+		failed int
+	)
+
+	for _, meta := range metas {
+		wg.Add(1)
+		go func(meta *backend.BlockMeta) {
+			defer wg.Done()
+
+			newMeta, err := convertV2Block(ctx, cfg.StorageConfig.Trace.Block, meta, r, w, c)
+			if err != nil {
+				fmt.Println(meta.BlockID, "failed:", err)
+				mtx.Lock()
+				failed++
+				mtx.Unlock()
+				return
+			}
+			fmt.Println(meta.BlockID, "converted to", newMeta.BlockID, "size:", humanize.Bytes(newMeta.Size))
+		}(meta)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("failed to convert %d blocks", failed)
+	}
+	return nil
+}
+
+// loadV2Metas returns the metas of the live v2 blocks among the given blocks
+func loadV2Metas(ctx context.Context, r backend.Reader, tenantID string, blockIDs []uuid.UUID) ([]*backend.BlockMeta, error) {
+	var metas []*backend.BlockMeta
+	for _, id := range blockIDs {
+		meta, err := r.BlockMeta(ctx, id, tenantID)
+		if errors.Is(err, backend.ErrDoesNotExist) {
+			// compacted
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if meta.Version != v2.VersionString || meta.Quarantine != nil {
+			continue
+		}
+		metas = append(metas, meta)
+	}
+	return metas, nil
+}
+
+// convertV2Block writes the block as a new vParquet block and marks the original compacted once the number of
+// objects of both blocks matches. The new block is removed otherwise.
+func convertV2Block(ctx context.Context, cfg *common.BlockConfig, meta *backend.BlockMeta, r backend.Reader, w backend.Writer, c backend.Compactor) (*backend.BlockMeta, error) {
+	destMeta := *meta
+	destMeta.BlockID = uuid.New()
+
+	newMeta, err := convertBlock(ctx, cfg, meta, &destMeta, r, r, w)
+	if err != nil {
+		return nil, err
+	}
+
+	if newMeta.TotalObjects != meta.TotalObjects {
+		err := fmt.Errorf("converted block %s has %d objects instead of %d", newMeta.BlockID, newMeta.TotalObjects, meta.TotalObjects)
+		if clearErr := c.ClearBlock(newMeta.BlockID, newMeta.TenantID); clearErr != nil {
+			return nil, fmt.Errorf("%v, failed to clear it: %w", err, clearErr)
+		}
+		return nil, err
+	}
+
+	if err := c.MarkBlockCompacted(meta.BlockID, meta.TenantID); err != nil {
+		return nil, fmt.Errorf("failed to mark block compacted, both blocks are live now: %w", err)
+	}
+
+	return newMeta, nil
+}
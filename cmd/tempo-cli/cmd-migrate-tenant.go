@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
+)
+
+type migrateTenantCmd struct {
+	backendOptions
+
+	TenantID     string `arg:"" help:"tenant-id within the source bucket"`
+	DestTenantID string `name:"dest-tenant-id" help:"tenant-id at the destination, optional, defaults to the source tenant-id"`
+
+	DestConfigFile string         `name:"dest-config-file" type:"path" help:"path to tempo config file of the destination, optional, defaults to the config file of the source"`
+	Dest           backendOptions `embed:"" prefix:"dest-"`
+
+	Parquet     bool `help:"re-encode v2 blocks to vParquet"`
+	Concurrency int  `default:"4" help:"number of blocks migrated in parallel"`
+}
+
+type migratedBlock struct {
+	src  *backend.BlockMeta
+	dest *backend.BlockMeta
+}
+
+func (cmd *migrateTenantCmd) Run(opts *globalOptions) error {
+	ctx := context.Background()
+
+	srcR, _, _, err := loadBackend(&cmd.backendOptions, opts)
+	if err != nil {
+		return err
+	}
+
+	destConfigFile := cmd.DestConfigFile
+	if destConfigFile == "" {
+		destConfigFile = opts.ConfigFile
+	}
+	destCfg, err := loadConfig(&cmd.Dest, destConfigFile)
+	if err != nil {
+		return err
+	}
+	destR, destW, destC, err := newBackend(destCfg)
+	if err != nil {
+		return err
+	}
+
+	destTenantID := cmd.DestTenantID
+	if destTenantID == "" {
+		destTenantID = cmd.TenantID
+	}
+
+	blockIDs, err := srcR.Blocks(ctx, cmd.TenantID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("total blocks: ", len(blockIDs))
+
+	var (
+		wg       = boundedwaitgroup.New(uint(cmd.Concurrency))
+		mtx      sync.Mutex
+		migrated []migratedBlock
+		errs     []error
+	)
+
+	for _, id := range blockIDs {
+		wg.Add(1)
+		go func(id uuid.UUID) {
+			defer wg.Done()
+
+			src, dest, err := migrateBlock(ctx, srcR, destR, destW, id, cmd.TenantID, destTenantID, destCfg.StorageConfig.Trace.Block, cmd.Parquet)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				fmt.Println(id, "failed:", err)
+				errs = append(errs, err)
+				return
+			}
+			if src != nil {
+				migrated = append(migrated, migratedBlock{src: src, dest: dest})
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to migrate %d blocks, rerun to resume", len(errs))
+	}
+
+	if err := writeTenantIndex(ctx, destR, destW, destC, destTenantID); err != nil {
+		return fmt.Errorf("failed to write tenant index: %w", err)
+	}
+
+	return verifyMigration(ctx, destR, migrated, cmd.Concurrency)
+}
+
+// migrateBlock copies the block to the destination unless it's already there. Compacted and quarantined
+// blocks are skipped and nil metas are returned.
+func migrateBlock(ctx context.Context, srcR backend.Reader, destR backend.Reader, destW backend.Writer, id uuid.UUID, srcTenantID, destTenantID string, cfg *common.BlockConfig, toParquet bool) (*backend.BlockMeta, *backend.BlockMeta, error) {
+	src, err := srcR.BlockMeta(ctx, id, srcTenantID)
+	if errors.Is(err, backend.ErrDoesNotExist) {
+		fmt.Println(id, "skipped: compacted")
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if src.Quarantine != nil {
+		fmt.Println(id, "skipped: quarantined")
+		return nil, nil, nil
+	}
+
+	// the meta is written last, so blocks with a meta at the destination are complete
+	dest, err := destR.BlockMeta(ctx, id, destTenantID)
+	if err == nil {
+		fmt.Println(id, "skipped: exists at destination")
+		return src, dest, nil
+	}
+	if !errors.Is(err, backend.ErrDoesNotExist) {
+		return nil, nil, err
+	}
+
+	if toParquet && src.Version == v2.VersionString {
+		destMeta := *src
+		destMeta.TenantID = destTenantID
+
+		dest, err = convertBlock(ctx, cfg, src, &destMeta, srcR, destR, destW)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Println(id, "converted")
+		return src, dest, nil
+	}
+
+	enc, err := encoding.FromVersion(src.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := destW
+	if destTenantID != srcTenantID {
+		w = &tenantRenamingWriter{Writer: destW, tenantID: destTenantID}
+	}
+
+	// copy the meta, the writer records the checksums of the destination objects in the meta it writes
+	copied := *src
+	err = enc.CopyBlock(ctx, &copied, srcR, w)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Println(id, "copied")
+
+	dest, err = destR.BlockMeta(ctx, id, destTenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dest, nil
+}
+
+// writeTenantIndex writes a fresh tenant index with all blocks of the tenant at the destination
+func writeTenantIndex(ctx context.Context, r backend.Reader, w backend.Writer, c backend.Compactor, tenantID string) error {
+	blockIDs, err := r.Blocks(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var metas []*backend.BlockMeta
+	var compactedMetas []*backend.CompactedBlockMeta
+	for _, id := range blockIDs {
+		meta, err := r.BlockMeta(ctx, id, tenantID)
+		if err == nil {
+			if meta.Quarantine == nil {
+				metas = append(metas, meta)
+			}
+			continue
+		}
+		if !errors.Is(err, backend.ErrDoesNotExist) {
+			return err
+		}
+
+		compactedMeta, err := c.CompactedBlockMeta(id, tenantID)
+		if errors.Is(err, backend.ErrDoesNotExist) {
+			// incomplete block
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		compactedMetas = append(compactedMetas, compactedMeta)
+	}
+
+	fmt.Println("wrote tenant index with", len(metas), "blocks and", len(compactedMetas), "compacted blocks")
+	return w.WriteTenantIndex(ctx, tenantID, metas, compactedMetas)
+}
+
+// verifyMigration reads back all migrated blocks at the destination. The checksums and the number of objects
+// read must match the destination meta, the number of objects must match the source and copied blocks must have
+// the checksums of the source.
+func verifyMigration(ctx context.Context, destR backend.Reader, migrated []migratedBlock, concurrency int) error {
+	var (
+		wg                      = boundedwaitgroup.New(uint(concurrency))
+		mtx                     sync.Mutex
+		srcObjects, destObjects int
+		failed                  int
+	)
+
+	for _, m := range migrated {
+		wg.Add(1)
+		go func(m migratedBlock) {
+			defer wg.Done()
+
+			err := verifyMigratedBlock(ctx, destR, m)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			srcObjects += m.src.TotalObjects
+			destObjects += m.dest.TotalObjects
+			if err != nil {
+				fmt.Println(m.src.BlockID, "verification failed:", err)
+				failed++
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	fmt.Println("migrated blocks:", len(migrated), "source objects:", srcObjects, "destination objects:", destObjects)
+
+	if failed > 0 {
+		return fmt.Errorf("%d blocks failed verification at the destination, remove them and rerun to migrate them again", failed)
+	}
+	return nil
+}
+
+func verifyMigratedBlock(ctx context.Context, destR backend.Reader, m migratedBlock) error {
+	if m.src.TotalObjects != m.dest.TotalObjects {
+		return fmt.Errorf("object count mismatch: source %d, destination %d", m.src.TotalObjects, m.dest.TotalObjects)
+	}
+
+	if m.src.Version == m.dest.Version {
+		for name, sum := range m.src.Checksums {
+			if destSum, ok := m.dest.Checksums[name]; ok && destSum != sum {
+				return fmt.Errorf("checksum mismatch of object %s: source %08x, destination %08x", name, sum, destSum)
+			}
+		}
+	}
+
+	return tempodb.VerifyBlock(ctx, destR, m.dest)
+}
+
+// tenantRenamingWriter writes all block objects and metas to another tenant
+type tenantRenamingWriter struct {
+	backend.Writer
+	tenantID string
+}
+
+func (w *tenantRenamingWriter) Write(ctx context.Context, name string, blockID uuid.UUID, _ string, buffer []byte, shouldCache bool) error {
+	return w.Writer.Write(ctx, name, blockID, w.tenantID, buffer, shouldCache)
+}
+
+func (w *tenantRenamingWriter) StreamWriter(ctx context.Context, name string, blockID uuid.UUID, _ string, data io.Reader, size int64) error {
+	return w.Writer.StreamWriter(ctx, name, blockID, w.tenantID, data, size)
+}
+
+func (w *tenantRenamingWriter) Append(ctx context.Context, name string, blockID uuid.UUID, _ string, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	return w.Writer.Append(ctx, name, blockID, w.tenantID, tracker, buffer)
+}
+
+func (w *tenantRenamingWriter) WriteBlockMeta(ctx context.Context, meta *backend.BlockMeta) error {
+	meta.TenantID = w.tenantID
+	return w.Writer.WriteBlockMeta(ctx, meta)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+type simulateCompactionCmd struct {
+	backendOptions
+
+	TenantID             string          `arg:"" help:"tenant-id within the bucket"`
+	BlockSelector        string          `help:"block selector to simulate (time_window, size_tiered, trace_id_range), optional, defaults to time_window"`
+	CompactionWindow     []time.Duration `help:"compaction windows to simulate, optional, defaults to compaction_window in config file"`
+	MaxCompactionObjects []int           `help:"max compaction objects to simulate, optional, defaults to max_compaction_objects in config file"`
+	MaxBlockBytes        []uint64        `help:"max block bytes to simulate, optional, defaults to max_block_bytes in config file"`
+	MaxRounds            int             `default:"100" help:"max number of compaction cycles to simulate"`
+}
+
+func (cmd *simulateCompactionCmd) Run(opts *globalOptions) error {
+	cfg, err := loadConfig(&cmd.backendOptions, opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+	r, _, c, err := newBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	blocklist, err := loadBlocklist(r, c, cmd.TenantID)
+	if err != nil {
+		return err
+	}
+
+	compactorCfg := cfg.Compactor.Compactor
+	windows := cmd.CompactionWindow
+	if len(windows) == 0 {
+		windows = []time.Duration{compactorCfg.MaxCompactionRange}
+	}
+	maxObjects := cmd.MaxCompactionObjects
+	if len(maxObjects) == 0 {
+		maxObjects = []int{compactorCfg.MaxCompactionObjects}
+	}
+	maxBytes := cmd.MaxBlockBytes
+	if len(maxBytes) == 0 {
+		maxBytes = []uint64{compactorCfg.MaxBlockBytes}
+	}
+
+	fmt.Println("blocks:", len(blocklist))
+	fmt.Println("levels:", formatLevels(blocksByLevel(blocklist)))
+
+	out := make([][]string, 0)
+	for _, window := range windows {
+		for _, objects := range maxObjects {
+			for _, bytes := range maxBytes {
+				res, err := tempodb.SimulateCompaction(blocklist, tempodb.CompactionSimulationConfig{
+					BlockSelector:        cmd.BlockSelector,
+					MaxCompactionRange:   window,
+					MaxCompactionObjects: objects,
+					MaxBlockBytes:        bytes,
+					MaxRounds:            cmd.MaxRounds,
+				})
+				if err != nil {
+					return err
+				}
+
+				out = append(out, []string{
+					window.String(),
+					strconv.Itoa(objects),
+					humanize.Bytes(bytes),
+					strconv.Itoa(res.Rounds),
+					strconv.Itoa(res.Compactions),
+					humanize.Bytes(res.BytesRewritten),
+					strconv.Itoa(res.FinalBlocks),
+					formatLevels(res.BlocksByLevel),
+				})
+			}
+		}
+	}
+
+	w := tablewriter.NewWriter(os.Stdout)
+	w.SetHeader([]string{"window", "max objects", "max bytes", "cycles", "compactions", "rewritten", "blocks", "levels"})
+	w.AppendBulk(out)
+	w.Render()
+
+	return nil
+}
+
+// loadBlocklist returns the blocklist of the tenant from the tenant index or by listing all blocks if there is none
+func loadBlocklist(r backend.Reader, c backend.Compactor, tenantID string) ([]*backend.BlockMeta, error) {
+	index, err := r.TenantIndex(context.Background(), tenantID)
+	if err == nil {
+		fmt.Println("using tenant index created at", index.CreatedAt)
+		return index.Meta, nil
+	}
+	fmt.Println("failed to read tenant index, listing blocks:", err)
+
+	results, err := loadBucket(r, c, tenantID, time.Hour, false)
+	if err != nil {
+		return nil, err
+	}
+
+	blocklist := make([]*backend.BlockMeta, 0, len(results))
+	for _, b := range results {
+		meta := b.BlockMeta
+		blocklist = append(blocklist, &meta)
+	}
+	return blocklist, nil
+}
+
+func blocksByLevel(blocklist []*backend.BlockMeta) map[uint8]int {
+	levels := map[uint8]int{}
+	for _, m := range blocklist {
+		levels[m.CompactionLevel]++
+	}
+	return levels
+}
+
+// formatLevels formats the number of blocks by compaction level as level:count pairs
+func formatLevels(levels map[uint8]int) string {
+	keys := make([]int, 0, len(levels))
+	for l := range levels {
+		keys = append(keys, int(l))
+	}
+	sort.Ints(keys)
+
+	s := ""
+	for i, l := range keys {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%d:%d", l, levels[uint8(l)])
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+type verifyBlockCmd struct {
+	backendOptions
+
+	TenantID   string `arg:"" help:"tenant-id within the bucket"`
+	BlockID    string `arg:"" help:"block ID to verify"`
+	Quarantine bool   `help:"quarantine the block if it is corrupt"`
+}
+
+func (cmd *verifyBlockCmd) Run(ctx *globalOptions) error {
+	r, w, _, err := loadBackend(&cmd.backendOptions, ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(cmd.BlockID)
+	if err != nil {
+		return err
+	}
+
+	corrupt, err := verifyBlock(context.Background(), r, w, cmd.TenantID, id, cmd.Quarantine)
+	if err != nil {
+		return err
+	}
+	if corrupt {
+		return errors.New("block is corrupt")
+	}
+	return nil
+}
+
+type verifyTenantCmd struct {
+	backendOptions
+
+	TenantID   string `arg:"" help:"tenant-id within the bucket"`
+	Quarantine bool   `help:"quarantine blocks that are corrupt"`
+}
+
+func (cmd *verifyTenantCmd) Run(ctx *globalOptions) error {
+	r, w, _, err := loadBackend(&cmd.backendOptions, ctx)
+	if err != nil {
+		return err
+	}
+
+	blockIDs, err := r.Blocks(context.Background(), cmd.TenantID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("total blocks: ", len(blockIDs))
+
+	corruptBlocks := 0
+	for _, id := range blockIDs {
+		corrupt, err := verifyBlock(context.Background(), r, w, cmd.TenantID, id, cmd.Quarantine)
+		if err != nil {
+			return err
+		}
+		if corrupt {
+			corruptBlocks++
+		}
+	}
+
+	if corruptBlocks > 0 {
+		return fmt.Errorf("%d corrupt blocks", corruptBlocks)
+	}
+	return nil
+}
+
+// verifyBlock prints the result of verifying the block and returns true if it is corrupt
+func verifyBlock(ctx context.Context, r backend.Reader, w backend.Writer, tenantID string, id uuid.UUID, quarantine bool) (bool, error) {
+	meta, err := r.BlockMeta(ctx, id, tenantID)
+	if errors.Is(err, backend.ErrDoesNotExist) {
+		// compacted blocks are not verified
+		fmt.Println(id, "skipped: no meta")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if meta.Quarantine != nil {
+		fmt.Println(id, "quarantined:", meta.Quarantine.Reason)
+		return true, nil
+	}
+
+	if len(meta.Checksums) == 0 {
+		fmt.Println(id, "warning: no checksums recorded, verifying structure only")
+	}
+
+	err = tempodb.VerifyBlock(ctx, r, meta)
+	if err == nil {
+		fmt.Println(id, "ok")
+		return false, nil
+	}
+	if !errors.Is(err, tempodb.ErrBlockCorrupt) {
+		return false, fmt.Errorf("failed to verify block %s: %w", id, err)
+	}
+
+	fmt.Println(id, "corrupt:", err)
+	if quarantine {
+		if err := tempodb.QuarantineBlock(ctx, w, meta, err.Error()); err != nil {
+			return true, fmt.Errorf("failed to quarantine block %s: %w", id, err)
+		}
+		fmt.Println(id, "quarantined")
+	}
+
+	return true, nil
+}
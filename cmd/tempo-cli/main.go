@@ -12,6 +12,7 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/encryption"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 )
@@ -72,7 +73,21 @@ var cli struct {
 	} `cmd:""`
 
 	Parquet struct {
-		Convert convertParquet `cmd:"" help:"convert from an existing file to tempodb parquet schema"`
+		Convert       convertParquet   `cmd:"" help:"convert from an existing file to tempodb parquet schema"`
+		ConvertBlocks convertBlocksCmd `cmd:"" help:"convert v2 blocks of a tenant to vParquet blocks"`
+	} `cmd:""`
+
+	Verify struct {
+		Block  verifyBlockCmd  `cmd:"" help:"verify the checksums and structure of a block"`
+		Tenant verifyTenantCmd `cmd:"" help:"verify the checksums and structure of all blocks of a tenant"`
+	} `cmd:""`
+
+	Simulate struct {
+		Compaction simulateCompactionCmd `cmd:"" help:"simulate compaction of a tenant with different settings without touching data"`
+	} `cmd:""`
+
+	Migrate struct {
+		Tenant migrateTenantCmd `cmd:"" help:"copy all blocks of a tenant to another bucket or tenant"`
 	} `cmd:""`
 }
 
@@ -88,20 +103,30 @@ func main() {
 }
 
 func loadBackend(b *backendOptions, g *globalOptions) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	cfg, err := loadConfig(b, g.ConfigFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return newBackend(cfg)
+}
+
+// loadConfig loads the tempo config file, if any, and applies the backend options
+func loadConfig(b *backendOptions, configFile string) (*app.Config, error) {
 	// Defaults
-	cfg := app.Config{}
+	cfg := &app.Config{}
 	cfg.RegisterFlagsAndApplyDefaults("", &flag.FlagSet{})
 
 	// Existing config
-	if g.ConfigFile != "" {
-		buff, err := os.ReadFile(g.ConfigFile)
+	if configFile != "" {
+		buff, err := os.ReadFile(configFile)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to read configFile %s: %w", g.ConfigFile, err)
+			return nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
 		}
 
-		err = yaml.UnmarshalStrict(buff, &cfg)
+		err = yaml.UnmarshalStrict(buff, cfg)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to parse configFile %s: %w", g.ConfigFile, err)
+			return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
 		}
 	}
 
@@ -121,6 +146,10 @@ func loadBackend(b *backendOptions, g *globalOptions) (backend.Reader, backend.W
 		cfg.StorageConfig.Trace.S3.Endpoint = b.S3Endpoint
 	}
 
+	return cfg, nil
+}
+
+func newBackend(cfg *app.Config) (backend.Reader, backend.Writer, backend.Compactor, error) {
 	var err error
 	var r backend.RawReader
 	var w backend.RawWriter
@@ -143,5 +172,17 @@ func loadBackend(b *backendOptions, g *globalOptions) (backend.Reader, backend.W
 		return nil, nil, nil, err
 	}
 
+	if cfg.StorageConfig.Trace.Encryption.Enabled() {
+		keys, err := encryption.NewKeyProvider(cfg.StorageConfig.Trace.Encryption)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		r, w, err = encryption.NewEncryption(r, w, keys, cfg.StorageConfig.Trace.Encryption.ChunkSizeBytes)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	return backend.NewReader(r), backend.NewWriter(w), c, nil
 }
@@ -11,6 +11,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/pkg/boundedwaitgroup"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/encoding/vparquet"
 )
 
 type unifiedBlockMeta struct {
@@ -130,3 +133,20 @@ func printAsJSON(value interface{}) error {
 	fmt.Println(string(traceJSON))
 	return nil
 }
+
+// convertBlock re-encodes the block as vParquet. The ID, tenant and compaction attributes of the new block are
+// taken from destMeta.
+func convertBlock(ctx context.Context, cfg *common.BlockConfig, src *backend.BlockMeta, destMeta *backend.BlockMeta, srcR backend.Reader, destR backend.Reader, destW backend.Writer) (*backend.BlockMeta, error) {
+	srcEnc, err := encoding.FromVersion(src.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := srcEnc.TraceIterator(ctx, src, srcR)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	return vparquet.CreateBlock(ctx, cfg, destMeta, iter, destR, destW)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/go-kit/log"
 	"github.com/google/uuid"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/tempo/pkg/api"
@@ -16,6 +17,9 @@ import (
 	"github.com/grafana/tempo/tempodb"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/cache"
+	"github.com/grafana/tempo/tempodb/backend/cache/disk"
+	"github.com/grafana/tempo/tempodb/backend/encryption"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
@@ -122,36 +126,93 @@ func loadBackend() (backend.Reader, *tempodb.Config, error) {
 			return
 		}
 
-		var r backend.RawReader
-
-		// Create the backend with NewNoConfirm() to prevent an extra call to the various backends on
-		// startup. This extra call exists just to confirm the bucket is accessible and force the
-		// standard Tempo components to fail during startup. If permissions are not correct this Lambda
-		// will fail instantly anyway and in a heavy query environment the extra calls will start to add up.
-		switch cfg.Backend {
-		case "local":
-			err = fmt.Errorf("local backend not supported for serverless functions")
-		case "gcs":
-			r, _, _, err = gcs.NewNoConfirm(cfg.GCS)
-		case "s3":
-			r, _, _, err = s3.NewNoConfirm(cfg.S3)
-		case "azure":
-			r, _, _, err = azure.NewNoConfirm(cfg.Azure)
-		default:
-			err = fmt.Errorf("unknown backend %s", cfg.Backend)
-		}
+		r, err := newRawReader(cfg.Backend, cfg.GCS, cfg.S3, cfg.Azure)
 		if err != nil {
 			readerErr = err
 			return
 		}
 
+		var keys encryption.KeyProvider
+		if cfg.Encryption.Enabled() {
+			keys, err = encryption.NewKeyProvider(cfg.Encryption)
+			if err != nil {
+				readerErr = err
+				return
+			}
+		}
+
+		// the disk cache is the only cache that is useful for short lived serverless functions. it's
+		// persisted in the function's local storage and reused by warm instances.
+		if cfg.Cache == "disk" {
+			c, err := disk.NewClient(cfg.Disk, nil, log.NewNopLogger())
+			if err != nil {
+				readerErr = err
+				return
+			}
+			r, _, _ = cache.NewCache(r, nil, c)
+		}
+
+		// encryption wraps the cache so that only encrypted data is cached
+		if keys != nil {
+			r, _, err = encryption.NewEncryption(r, nil, keys, cfg.Encryption.ChunkSizeBytes)
+			if err != nil {
+				readerErr = err
+				return
+			}
+		}
+
+		rr := backend.NewReader(r)
+
+		// objects of blocks moved to the cold tier are read from the cold backend. reads of cold blocks are not cached.
+		if cfg.Tiering.Enabled && !cfg.Tiering.RewriteInPlace {
+			coldR, err := newRawReader(cfg.Tiering.Backend, cfg.Tiering.GCS, cfg.Tiering.S3, cfg.Tiering.Azure)
+			if err != nil {
+				readerErr = fmt.Errorf("error creating cold tier backend: %w", err)
+				return
+			}
+			if keys != nil {
+				coldR, _, err = encryption.NewEncryption(coldR, nil, keys, cfg.Encryption.ChunkSizeBytes)
+				if err != nil {
+					readerErr = err
+					return
+				}
+			}
+			rr = tempodb.NewTieredReader(rr, backend.NewReader(coldR))
+		}
+
 		readerConfig = cfg
-		reader = backend.NewReader(r)
+		reader = rr
 	})
 
 	return reader, readerConfig, readerErr
 }
 
+// newRawReader creates the raw reader of the named backend. The backend is created with NewNoConfirm() to prevent
+// an extra call to the various backends on startup. This extra call exists just to confirm the bucket is accessible
+// and force the standard Tempo components to fail during startup. If permissions are not correct this Lambda will
+// fail instantly anyway and in a heavy query environment the extra calls will start to add up.
+func newRawReader(name string, gcsCfg *gcs.Config, s3Cfg *s3.Config, azureCfg *azure.Config) (backend.RawReader, error) {
+	var (
+		r   backend.RawReader
+		err error
+	)
+
+	switch name {
+	case "local":
+		err = fmt.Errorf("local backend not supported for serverless functions")
+	case "gcs":
+		r, _, _, err = gcs.NewNoConfirm(gcsCfg)
+	case "s3":
+		r, _, _, err = s3.NewNoConfirm(s3Cfg)
+	case "azure":
+		r, _, _, err = azure.NewNoConfirm(azureCfg)
+	default:
+		err = fmt.Errorf("unknown backend %s", name)
+	}
+
+	return r, err
+}
+
 func loadConfig() (*tempodb.Config, error) {
 	defaultConfig := &tempodb.Config{
 		Search: &tempodb.SearchConfig{
@@ -162,6 +223,16 @@ func loadConfig() (*tempodb.Config, error) {
 		GCS:   &gcs.Config{},
 		S3:    &s3.Config{},
 		Azure: &azure.Config{},
+		Disk:  &disk.Config{},
+		Encryption: &encryption.Config{
+			File: &encryption.FileConfig{},
+		},
+		Tiering: tempodb.TieringConfig{
+			Local: &local.Config{},
+			GCS:   &gcs.Config{},
+			S3:    &s3.Config{},
+			Azure: &azure.Config{},
+		},
 	}
 
 	// horrible viper dance since it won't unmarshal to a struct from env: https://github.com/spf13/viper/issues/188
@@ -77,3 +77,46 @@ func TestLoadConfigAzure(t *testing.T) {
 		t.Error("azure max buffers should be 3", cfg.Azure.MaxBuffers)
 	}
 }
+
+func TestLoadConfigEncryption(t *testing.T) {
+	os.Setenv("TEMPO_ENCRYPTION_KEY_PROVIDER", "file")
+	os.Setenv("TEMPO_ENCRYPTION_FILE_PATH", "/keys.yaml")
+	defer os.Unsetenv("TEMPO_ENCRYPTION_KEY_PROVIDER")
+	defer os.Unsetenv("TEMPO_ENCRYPTION_FILE_PATH")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Error("failed to load config", err)
+		return
+	}
+	if !cfg.Encryption.Enabled() {
+		t.Error("encryption should be enabled")
+	}
+	if cfg.Encryption.File.Path != "/keys.yaml" {
+		t.Error("encryption file path should be /keys.yaml", cfg.Encryption.File.Path)
+	}
+}
+
+func TestLoadConfigTiering(t *testing.T) {
+	os.Setenv("TEMPO_TIERING_ENABLED", "true")
+	os.Setenv("TEMPO_TIERING_BACKEND", "gcs")
+	os.Setenv("TEMPO_TIERING_GCS_BUCKET_NAME", "cold-bucket")
+	defer os.Unsetenv("TEMPO_TIERING_ENABLED")
+	defer os.Unsetenv("TEMPO_TIERING_BACKEND")
+	defer os.Unsetenv("TEMPO_TIERING_GCS_BUCKET_NAME")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Error("failed to load config", err)
+		return
+	}
+	if !cfg.Tiering.Enabled {
+		t.Error("tiering should be enabled")
+	}
+	if cfg.Tiering.Backend != "gcs" {
+		t.Error("tiering backend should be gcs", cfg.Tiering.Backend)
+	}
+	if cfg.Tiering.GCS.BucketName != "cold-bucket" {
+		t.Error("tiering gcs bucket name should be cold-bucket", cfg.Tiering.GCS.BucketName)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/modules"
@@ -36,6 +37,7 @@ import (
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
+	"github.com/grafana/tempo/tempodb/blocklist"
 )
 
 // The various modules that make up tempo.
@@ -144,6 +146,14 @@ func (t *App) initDistributor() (services.Service, error) {
 
 func (t *App) initIngester() (services.Service, error) {
 	t.cfg.Ingester.LifecyclerConfig.ListenPort = t.cfg.Server.GRPCListenPort
+	t.cfg.Ingester.IngesterClient = t.cfg.IngesterClient
+
+	// replica aware flushing confirms flushes of other replicas in the blocklist. in the single binary the
+	// compactor takes care of polling
+	if t.cfg.Target == Ingester && t.cfg.Ingester.ReplicaAwareFlushing {
+		t.store.EnablePolling(nil)
+	}
+
 	ingester, err := ingester.New(t.cfg.Ingester, t.store, t.overrides, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ingester: %w", err)
@@ -152,8 +162,13 @@ func (t *App) initIngester() (services.Service, error) {
 
 	tempopb.RegisterPusherServer(t.Server.GRPC, t.ingester)
 	tempopb.RegisterQuerierServer(t.Server.GRPC, t.ingester)
+	tempopb.RegisterHandoffServer(t.Server.GRPC, t.ingester)
 	t.Server.HTTP.Path("/flush").Handler(http.HandlerFunc(t.ingester.FlushHandler))
 	t.Server.HTTP.Path("/shutdown").Handler(http.HandlerFunc(t.ingester.ShutdownHandler))
+	t.Server.HTTP.Path("/ingester/tenants").Handler(http.HandlerFunc(t.ingester.TenantsHandler)).Methods("GET")
+	t.Server.HTTP.Path("/ingester/tenants/{tenant}").Handler(http.HandlerFunc(t.ingester.TenantHandler)).Methods("GET")
+	t.Server.HTTP.Path("/ingester/tenants/{tenant}/flush").Handler(http.HandlerFunc(t.ingester.TenantFlushHandler)).Methods("POST")
+	t.Server.HTTP.Path("/ingester/tenants/{tenant}/blocks/{blockID}/flush").Handler(http.HandlerFunc(t.ingester.BlockFlushHandler)).Methods("POST")
 	return t.ingester, nil
 }
 
@@ -271,12 +286,28 @@ func (t *App) initCompactor() (services.Service, error) {
 	if t.compactor.Ring != nil {
 		t.Server.HTTP.Handle("/compactor/ring", t.compactor.Ring)
 	}
+	t.Server.HTTP.Handle("/compactor/deletions", t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.DeletionRequestsHandler)))
+	t.Server.HTTP.Handle("/compactor/jobs", t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.CompactionJobsHandler)))
 
 	return t.compactor, nil
 }
 
 func (t *App) initStore() (services.Service, error) {
-	store, err := tempo_storage.NewStore(t.cfg.StorageConfig, log.Logger)
+	var gossipKV kv.Client
+	if t.cfg.StorageConfig.Trace.BlocklistGossip.Enabled {
+		var err error
+		gossipKV, err = kv.NewClient(kv.Config{
+			Store: "memberlist",
+			StoreConfig: kv.StoreConfig{
+				MemberlistKV: t.MemberlistKV.GetMemberlistKV,
+			},
+		}, blocklist.EventsCodec, nil, log.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blocklist gossip client %w", err)
+		}
+	}
+
+	store, err := tempo_storage.NewStore(t.cfg.StorageConfig, gossipKV, log.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store %w", err)
 	}
@@ -292,6 +323,7 @@ func (t *App) initMemberlistKV() (services.Service, error) {
 	t.cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
 		usagestats.JSONCodec,
+		blocklist.EventsCodec,
 	}
 
 	dnsProviderReg := prometheus.WrapRegistererWithPrefix(
@@ -394,6 +426,11 @@ func (t *App) setupModuleManager() error {
 		UsageReport:          {MemberlistKV},
 	}
 
+	if t.cfg.StorageConfig.Trace.BlocklistGossip.Enabled {
+		// Blocklist events are shared over memberlist
+		deps[Store] = append(deps[Store], MemberlistKV)
+	}
+
 	if t.cfg.MetricsGeneratorEnabled {
 		// If metrics-generator is enabled, the distributor needs the metrics-generator ring
 		deps[Distributor] = append(deps[Distributor], MetricsGeneratorRing)
@@ -17,6 +17,7 @@ import (
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/util/log"
+	"github.com/grafana/tempo/tempodb"
 )
 
 const (
@@ -158,6 +159,7 @@ func (c *Compactor) starting(ctx context.Context) (err error) {
 
 func (c *Compactor) running(ctx context.Context) error {
 	level.Info(log.Logger).Log("msg", "enabling compaction")
+	c.cfg.Compactor.JobQueue.InstanceID = c.cfg.ShardingRing.InstanceID
 	c.store.EnableCompaction(&c.cfg.Compactor, c, c)
 
 	if c.subservices != nil {
@@ -252,6 +254,40 @@ func (c *Compactor) MaxBytesPerTraceForTenant(tenantID string) int {
 	return c.overrides.MaxBytesPerTrace(tenantID)
 }
 
+// BlockRetentionRulesForTenant implements CompactorOverrides
+func (c *Compactor) BlockRetentionRulesForTenant(tenantID string) []tempodb.RetentionRule {
+	limits := c.overrides.BlockRetentionRules(tenantID)
+	if len(limits) == 0 {
+		return nil
+	}
+
+	rules := make([]tempodb.RetentionRule, 0, len(limits))
+	for _, l := range limits {
+		rules = append(rules, tempodb.RetentionRule{
+			Class:     l.Class,
+			Query:     l.Query,
+			Retention: time.Duration(l.Retention),
+		})
+	}
+	return rules
+}
+
+// CompactionSamplingPolicyForTenant implements CompactorOverrides
+func (c *Compactor) CompactionSamplingPolicyForTenant(tenantID string) tempodb.SamplingPolicy {
+	return tempodb.SamplingPolicy{
+		CompactionLevel:    c.overrides.CompactionSamplingLevel(tenantID),
+		SampleRate:         c.overrides.CompactionSamplingRate(tenantID),
+		ServiceSampleRates: c.overrides.CompactionSamplingServiceRates(tenantID),
+		KeepErrors:         c.overrides.CompactionSamplingKeepErrors(tenantID),
+		LatencyThreshold:   c.overrides.CompactionSamplingLatencyThreshold(tenantID),
+	}
+}
+
+// BlockSelectorForTenant implements CompactorOverrides
+func (c *Compactor) BlockSelectorForTenant(tenantID string) string {
+	return c.overrides.CompactionBlockSelector(tenantID)
+}
+
 func (c *Compactor) isSharded() bool {
 	return c.cfg.ShardingRing.KVStore.Store != ""
 }
@@ -28,6 +28,23 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 		IteratorBufferSize:      tempodb.DefaultIteratorBufferSize,
 		MaxTimePerTenant:        tempodb.DefaultMaxTimePerTenant,
 		CompactionCycle:         tempodb.DefaultCompactionCycle,
+		TenantScheduling: tempodb.TenantSchedulingConfig{
+			Strategy:                tempodb.TenantSchedulingWeighted,
+			OutstandingBlocksWeight: tempodb.DefaultOutstandingBlocksWeight,
+			BlocklistLengthWeight:   tempodb.DefaultBlocklistLengthWeight,
+			OldestBlockAgeWeight:    tempodb.DefaultOldestBlockAgeWeight,
+			WaitWeight:              tempodb.DefaultWaitWeight,
+		},
+		JobQueue: tempodb.CompactionJobQueueConfig{
+			LeaseDuration:   tempodb.DefaultCompactionJobLeaseDuration,
+			ClaimSettleTime: tempodb.DefaultCompactionJobClaimSettle,
+		},
+		Scrubber: tempodb.ScrubberConfig{
+			Interval:          tempodb.DefaultScrubInterval,
+			MaxBlocksPerCycle: tempodb.DefaultScrubMaxBlocksPerCycle,
+			ReverifyPeriod:    tempodb.DefaultScrubReverifyPeriod,
+			Quarantine:        true,
+		},
 	}
 
 	flagext.DefaultValues(&cfg.ShardingRing)
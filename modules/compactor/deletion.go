@@ -0,0 +1,118 @@
+package compactor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/api"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	deletionTraceIDKey   = "traceID"
+	deletionAttrKeyKey   = "attrKey"
+	deletionAttrValueKey = "attrValue"
+	deletionStartKey     = "start"
+	deletionEndKey       = "end"
+)
+
+// DeletionRequestsHandler lists the deletion requests of a tenant on GET and creates a new
+// deletion request on POST. A request is created from one or more traceID parameters
+// and/or an attrKey/attrValue pair. Optional start and end parameters in unix epoch seconds
+// restrict the blocks that are considered.
+func (c *Compactor) DeletionRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reqs, err := c.store.DeletionRequests(r.Context(), tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sort.Slice(reqs, func(i, j int) bool { return reqs[i].CreatedAt.Before(reqs[j].CreatedAt) })
+		writeJSON(w, http.StatusOK, reqs)
+
+	case http.MethodPost:
+		req, err := parseDeletionRequest(r, tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = c.store.AddDeletionRequest(r.Context(), req)
+		if errors.Is(err, tempodb.ErrInvalidDeletionRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func parseDeletionRequest(r *http.Request, tenantID string) (*backend.DeletionRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	req := backend.NewDeletionRequest(tenantID)
+
+	for _, id := range r.Form[deletionTraceIDKey] {
+		byteID, err := util.HexStringToTraceID(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid traceID %s: %w", id, err)
+		}
+		req.TraceIDs = append(req.TraceIDs, hex.EncodeToString(byteID))
+	}
+
+	req.AttrKey = r.Form.Get(deletionAttrKeyKey)
+	req.AttrValue = r.Form.Get(deletionAttrValueKey)
+
+	var err error
+	if req.StartTime, err = parseUnixSeconds(r.Form.Get(deletionStartKey)); err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	if req.EndTime, err = parseUnixSeconds(r.Form.Get(deletionEndKey)); err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+
+	return req, nil
+}
+
+func parseUnixSeconds(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(api.HeaderContentType, api.HeaderAcceptJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,37 @@
+package compactor
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/tempodb"
+)
+
+// CompactionJobsHandler lists the compaction jobs of a tenant with their status. Jobs are only planned
+// if compactors are coordinated by the job queue.
+func (c *Compactor) CompactionJobsHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobs, err := c.store.CompactionJobs(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []*tempodb.CompactionJobState{}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+
+	writeJSON(w, http.StatusOK, jobs)
+}
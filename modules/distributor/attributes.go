@@ -0,0 +1,337 @@
+package distributor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/modules/overrides"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+const (
+	attributeActionDelete = "delete"
+	attributeActionRename = "rename"
+	attributeActionRedact = "redact"
+	attributeActionHash   = "hash"
+	attributeActionInsert = "insert"
+
+	defaultRedactReplacement = "<redacted>"
+)
+
+type attributeScope int
+
+const (
+	attributeScopeSpan attributeScope = iota
+	attributeScopeResource
+	attributeScopeEvent
+	attributeScopeCount
+)
+
+var attributeScopeNames = [attributeScopeCount]string{
+	attributeScopeSpan:     "span",
+	attributeScopeResource: "resource",
+	attributeScopeEvent:    "event",
+}
+
+var metricAttributesModified = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "distributor_attributes_modified_total",
+	Help:      "The total number of attributes deleted, renamed, redacted, hashed or inserted by the attribute processing of a tenant.",
+}, []string{"tenant", "action", "scope"})
+
+// attributeAction is a validated overrides.AttributeAction
+type attributeAction struct {
+	overrides.AttributeAction
+	pattern *regexp.Regexp
+	scopes  [attributeScopeCount]bool
+}
+
+func newAttributeAction(a overrides.AttributeAction) (*attributeAction, string) {
+	action := &attributeAction{AttributeAction: a}
+
+	switch a.Action {
+	case attributeActionDelete, attributeActionHash, attributeActionInsert:
+		if a.Key == "" {
+			return nil, "missing key"
+		}
+	case attributeActionRename:
+		if a.Key == "" || a.NewKey == "" {
+			return nil, "missing key or new_key"
+		}
+		if a.Key == a.NewKey {
+			return nil, "new_key equals key"
+		}
+	case attributeActionRedact:
+		if a.Pattern == "" {
+			return nil, "missing pattern"
+		}
+		pattern, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return nil, "invalid pattern: " + err.Error()
+		}
+		action.pattern = pattern
+		if action.Replacement == "" {
+			action.Replacement = defaultRedactReplacement
+		}
+	default:
+		return nil, "unknown action"
+	}
+
+	if len(a.Scopes) == 0 {
+		for s := range action.scopes {
+			action.scopes[s] = true
+		}
+		return action, ""
+	}
+
+	for _, name := range a.Scopes {
+		found := false
+		for s, n := range attributeScopeNames {
+			if n == name {
+				action.scopes[s] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, "unknown scope " + name
+		}
+	}
+
+	return action, ""
+}
+
+// apply modifies the attributes and returns them with the number of modified attributes
+func (a *attributeAction) apply(attrs []*v1_common.KeyValue) ([]*v1_common.KeyValue, int) {
+	switch a.Action {
+	case attributeActionDelete:
+		kept := attrs[:0]
+		for _, kv := range attrs {
+			if kv.Key != a.Key {
+				kept = append(kept, kv)
+			}
+		}
+		return kept, len(attrs) - len(kept)
+
+	case attributeActionRename:
+		var renamed *v1_common.KeyValue
+		for _, kv := range attrs {
+			if kv.Key == a.Key {
+				renamed = kv
+				break
+			}
+		}
+		if renamed == nil {
+			return attrs, 0
+		}
+
+		kept := attrs[:0]
+		for _, kv := range attrs {
+			if kv.Key != a.NewKey {
+				kept = append(kept, kv)
+			}
+		}
+		renamed.Key = a.NewKey
+		return kept, 1
+
+	case attributeActionRedact:
+		modified := 0
+		for _, kv := range attrs {
+			if a.Key != "" && kv.Key != a.Key {
+				continue
+			}
+			v, ok := stringValue(kv)
+			if !ok {
+				continue
+			}
+			if redacted := a.pattern.ReplaceAllString(v, a.Replacement); redacted != v {
+				kv.Value = newStringValue(redacted)
+				modified++
+			}
+		}
+		return attrs, modified
+
+	case attributeActionHash:
+		modified := 0
+		for _, kv := range attrs {
+			if kv.Key != a.Key {
+				continue
+			}
+			v, ok := stringValue(kv)
+			if !ok {
+				continue
+			}
+			kv.Value = newStringValue(a.hash(v))
+			modified++
+		}
+		return attrs, modified
+
+	case attributeActionInsert:
+		for _, kv := range attrs {
+			if kv.Key == a.Key {
+				return attrs, 0
+			}
+		}
+		return append(attrs, &v1_common.KeyValue{Key: a.Key, Value: newStringValue(a.Value)}), 1
+	}
+
+	return attrs, 0
+}
+
+// hash returns the hex encoded HMAC-SHA256 of the value with HashKey or, without a key, its SHA-256 hash
+func (a *attributeAction) hash(v string) string {
+	if a.HashKey == "" {
+		sum := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.HashKey))
+	mac.Write([]byte(v))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// attributeProcessor applies the attribute actions of a tenant to the received batches
+type attributeProcessor struct {
+	tenantID string
+	cfg      []overrides.AttributeAction
+	actions  []*attributeAction
+}
+
+// newAttributeProcessor validates the given actions. Invalid actions are logged and skipped so that a
+// misconfigured tenant can still push traces.
+func newAttributeProcessor(tenantID string, cfg []overrides.AttributeAction, logger log.Logger) *attributeProcessor {
+	p := &attributeProcessor{
+		tenantID: tenantID,
+		cfg:      cfg,
+	}
+
+	for _, a := range cfg {
+		action, reason := newAttributeAction(a)
+		if action == nil {
+			level.Warn(logger).Log("msg", "skipping invalid attribute action", "tenant", tenantID, "action", a.Action, "key", a.Key, "reason", reason)
+			continue
+		}
+		p.actions = append(p.actions, action)
+	}
+
+	return p
+}
+
+// process modifies the span, resource and event attributes of the batches in place
+func (p *attributeProcessor) process(batches []*v1.ResourceSpans) {
+	if len(p.actions) == 0 {
+		return
+	}
+
+	modified := make([][attributeScopeCount]int, len(p.actions))
+	apply := func(scope attributeScope, attrs []*v1_common.KeyValue) []*v1_common.KeyValue {
+		for i, a := range p.actions {
+			if !a.scopes[scope] {
+				continue
+			}
+			var n int
+			attrs, n = a.apply(attrs)
+			modified[i][scope] += n
+		}
+		return attrs
+	}
+
+	for _, b := range batches {
+		if b.Resource != nil {
+			b.Resource.Attributes = apply(attributeScopeResource, b.Resource.Attributes)
+		}
+
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				span.Attributes = apply(attributeScopeSpan, span.Attributes)
+
+				for _, event := range span.Events {
+					event.Attributes = apply(attributeScopeEvent, event.Attributes)
+				}
+			}
+		}
+	}
+
+	for i, a := range p.actions {
+		for scope, n := range modified[i] {
+			if n > 0 {
+				metricAttributesModified.WithLabelValues(p.tenantID, a.Action, attributeScopeNames[scope]).Add(float64(n))
+			}
+		}
+	}
+}
+
+// attributeProcessors holds the attribute processor of each tenant. A processor is rebuilt when the overrides
+// of the tenant change.
+type attributeProcessors struct {
+	actionsForTenant func(tenantID string) []overrides.AttributeAction
+	logger           log.Logger
+
+	processors map[string]*attributeProcessor
+	mtx        sync.RWMutex
+}
+
+func newAttributeProcessors(actionsForTenant func(tenantID string) []overrides.AttributeAction, logger log.Logger) *attributeProcessors {
+	return &attributeProcessors{
+		actionsForTenant: actionsForTenant,
+		logger:           logger,
+		processors:       map[string]*attributeProcessor{},
+	}
+}
+
+// forTenant returns the attribute processor of the tenant or nil if no actions are configured
+func (p *attributeProcessors) forTenant(tenantID string) *attributeProcessor {
+	cfg := p.actionsForTenant(tenantID)
+
+	p.mtx.RLock()
+	current, ok := p.processors[tenantID]
+	p.mtx.RUnlock()
+
+	if !ok && len(cfg) == 0 {
+		return nil
+	}
+	if ok && reflect.DeepEqual(current.cfg, cfg) {
+		return current
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(cfg) == 0 {
+		delete(p.processors, tenantID)
+		return nil
+	}
+
+	// check again, another push may have updated the processor in the meantime
+	if processor, ok := p.processors[tenantID]; ok && reflect.DeepEqual(processor.cfg, cfg) {
+		return processor
+	}
+
+	processor := newAttributeProcessor(tenantID, cfg, p.logger)
+	p.processors[tenantID] = processor
+	return processor
+}
+
+func stringValue(kv *v1_common.KeyValue) (string, bool) {
+	if kv.Value == nil {
+		return "", false
+	}
+	v, ok := kv.Value.Value.(*v1_common.AnyValue_StringValue)
+	if !ok {
+		return "", false
+	}
+	return v.StringValue, true
+}
+
+func newStringValue(s string) *v1_common.AnyValue {
+	return &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: s}}
+}
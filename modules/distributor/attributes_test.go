@@ -0,0 +1,110 @@
+package distributor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/modules/overrides"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+func TestAttributeProcessor(t *testing.T) {
+	batch := &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				stringKV("service.name", "test-service"),
+			},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+			Spans: []*v1.Span{{
+				Attributes: []*v1_common.KeyValue{
+					stringKV("http.request.header.cookie", "session=abc"),
+					stringKV("legacy.user", "jane"),
+					stringKV("user", "john"),
+					stringKV("message", "mail jane@example.com or john@example.com"),
+					stringKV("token", "secret"),
+					stringKV("enduser.id", "jane"),
+					{Key: "status", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 200}}},
+				},
+				Events: []*v1.Span_Event{{
+					Attributes: []*v1_common.KeyValue{
+						stringKV("exception.message", "failed for jane@example.com"),
+					},
+				}},
+			}},
+		}},
+	}
+
+	p := newAttributeProcessor("test", []overrides.AttributeAction{
+		{Action: attributeActionDelete, Key: "http.request.header.cookie"},
+		{Action: attributeActionRename, Key: "legacy.user", NewKey: "user"},
+		{Action: attributeActionRedact, Pattern: `[a-z]+@example\.com`},
+		{Action: attributeActionHash, Key: "token", Scopes: []string{"span"}},
+		{Action: attributeActionHash, Key: "enduser.id", HashKey: "tenant-key"},
+		{Action: attributeActionInsert, Key: "cluster", Value: "eu-west", Scopes: []string{"resource"}},
+		{Action: attributeActionInsert, Key: "service.name", Value: "overwritten", Scopes: []string{"resource"}},
+		// invalid actions are skipped
+		{Action: "unknown", Key: "user"},
+		{Action: attributeActionRedact, Pattern: `[`},
+		{Action: attributeActionDelete, Key: "user", Scopes: []string{"link"}},
+	}, log.NewNopLogger())
+	require.Len(t, p.actions, 7)
+
+	p.process([]*v1.ResourceSpans{batch})
+
+	token := sha256.Sum256([]byte("secret"))
+	mac := hmac.New(sha256.New, []byte("tenant-key"))
+	mac.Write([]byte("jane"))
+	span := batch.InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringKV("user", "jane"),
+		stringKV("message", "mail <redacted> or <redacted>"),
+		stringKV("token", hex.EncodeToString(token[:])),
+		stringKV("enduser.id", hex.EncodeToString(mac.Sum(nil))),
+		{Key: "status", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 200}}},
+	}, span.Attributes)
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringKV("exception.message", "failed for <redacted>"),
+	}, span.Events[0].Attributes)
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringKV("service.name", "test-service"),
+		stringKV("cluster", "eu-west"),
+	}, batch.Resource.Attributes)
+}
+
+func TestAttributeProcessorsForTenant(t *testing.T) {
+	actions := map[string][]overrides.AttributeAction{
+		"test": {{Action: attributeActionDelete, Key: "user"}},
+	}
+	processors := newAttributeProcessors(func(tenantID string) []overrides.AttributeAction {
+		return actions[tenantID]
+	}, log.NewNopLogger())
+
+	assert.Nil(t, processors.forTenant("other"))
+
+	p := processors.forTenant("test")
+	require.NotNil(t, p)
+	assert.Same(t, p, processors.forTenant("test"))
+
+	// changed overrides rebuild the processor
+	actions["test"] = []overrides.AttributeAction{{Action: attributeActionHash, Key: "user"}}
+	updated := processors.forTenant("test")
+	require.NotNil(t, updated)
+	assert.NotSame(t, p, updated)
+	assert.Equal(t, attributeActionHash, updated.actions[0].Action)
+
+	delete(actions, "test")
+	assert.Nil(t, processors.forTenant("test"))
+}
+
+func stringKV(key, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{Key: key, Value: newStringValue(value)}
+}
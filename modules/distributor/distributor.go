@@ -47,6 +47,10 @@ const (
 	reasonTraceTooLarge = "trace_too_large"
 	// reasonLiveTracesExceeded indicates that tempo is already tracking too many live traces in the ingesters for this user
 	reasonLiveTracesExceeded = "live_traces_exceeded"
+	// reasonLiveBytesExceeded indicates that the live traces of this user are too large in the ingesters
+	reasonLiveBytesExceeded = "live_bytes_exceeded"
+	// reasonHeadBlockBytesExceeded indicates that the head block of this user is too large in the ingesters
+	reasonHeadBlockBytesExceeded = "head_block_bytes_exceeded"
 	// reasonInternalError indicates an unexpected error occurred processing these spans. analogous to a 500
 	reasonInternalError = "internal_error"
 
@@ -126,6 +130,12 @@ type Distributor struct {
 	searchEnabled    bool
 	globalTagsToDrop map[string]struct{}
 
+	// per-tenant attribute processing
+	attributeProcessors *attributeProcessors
+
+	// per-tenant mirroring to external OTLP endpoints
+	mirrorForwarder *mirrorForwarder
+
 	// metrics-generator
 	metricsGeneratorEnabled bool
 	generatorClientCfg      generator_client.Config
@@ -204,6 +214,8 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		generatorClientCfg:      generatorClientCfg,
 		generatorsRing:          generatorsRing,
 		globalTagsToDrop:        tagsToDrop,
+		attributeProcessors:     newAttributeProcessors(o.AttributeProcessing, logger),
+		mirrorForwarder:         newMirrorForwarder(o.Mirrors, logger),
 		overrides:               o,
 		traceEncoder:            model.MustNewSegmentDecoder(model.CurrentEncoding),
 		logger:                  logger,
@@ -227,6 +239,8 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		subservices = append(subservices, d.generatorForwarder)
 	}
 
+	subservices = append(subservices, d.mirrorForwarder)
+
 	cfgReceivers := cfg.Receivers
 	if len(cfgReceivers) == 0 {
 		cfgReceivers = defaultReceivers
@@ -318,6 +332,11 @@ func (d *Distributor) PushBatches(ctx context.Context, batches []*v1.ResourceSpa
 			size)
 	}
 
+	// delete, rename, redact, hash and insert attributes before the traces are sent to the ingesters
+	if p := d.attributeProcessors.forTenant(userID); p != nil {
+		p.process(batches)
+	}
+
 	keys, rebatchedTraces, err := requestsByTraceID(batches, userID, spanCount)
 	if err != nil {
 		overrides.RecordDiscardedSpans(spanCount, reasonInternalError, userID)
@@ -351,6 +370,8 @@ func (d *Distributor) PushBatches(ctx context.Context, batches []*v1.ResourceSpa
 		d.generatorForwarder.SendTraces(ctx, userID, keys, rebatchedTraces)
 	}
 
+	d.mirrorForwarder.SendTraces(ctx, userID, keys, rebatchedTraces)
+
 	return nil, nil // PushRequest is ignored, so no reason to create one
 }
 
@@ -535,6 +556,10 @@ func recordDiscaredSpans(err error, userID string, spanCount int) {
 		overrides.RecordDiscardedSpans(spanCount, reasonLiveTracesExceeded, userID)
 	} else if strings.HasPrefix(desc, overrides.ErrorPrefixTraceTooLarge) {
 		overrides.RecordDiscardedSpans(spanCount, reasonTraceTooLarge, userID)
+	} else if strings.HasPrefix(desc, overrides.ErrorPrefixLiveBytesExceeded) {
+		overrides.RecordDiscardedSpans(spanCount, reasonLiveBytesExceeded, userID)
+	} else if strings.HasPrefix(desc, overrides.ErrorPrefixHeadBlockBytesExceeded) {
+		overrides.RecordDiscardedSpans(spanCount, reasonHeadBlockBytesExceeded, userID)
 	} else {
 		overrides.RecordDiscardedSpans(spanCount, reasonInternalError, userID)
 	}
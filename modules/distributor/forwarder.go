@@ -2,6 +2,7 @@ package distributor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -19,6 +20,15 @@ import (
 const (
 	defaultWorkerCount = 2
 	defaultQueueSize   = 100
+
+	forwarderMetricsGenerator = "metrics-generator"
+
+	// reasonQueueFull indicates that the queue of the forwarder was full
+	reasonQueueFull = "queue_full"
+	// reasonShutdown indicates that the queue was shut down before the request was queued
+	reasonShutdown = "shutdown"
+	// reasonSendFailed indicates that the forward function failed for good
+	reasonSendFailed = "send_failed"
 )
 
 var (
@@ -37,36 +47,74 @@ var (
 		Name:      "distributor_forwarder_queue_length",
 		Help:      "Number of queued requests for a tenant",
 	}, []string{"tenant"})
+	metricForwarderDroppedTraces = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_forwarder_dropped_traces_total",
+		Help:      "Total number of traces of a tenant dropped by the forwarder",
+	}, []string{"tenant", "reason"})
 )
 
+// queueMetrics are the metrics of the queue of a tenant
+type queueMetrics struct {
+	pushes       prometheus.Counter
+	pushFailures prometheus.Counter
+	queueLength  prometheus.Gauge
+	dropped      func(reason string) prometheus.Counter
+}
+
+// forwarderQueueMetrics returns the metrics of the queue of a tenant to the metrics-generators
+func forwarderQueueMetrics(tenantID string) queueMetrics {
+	return queueMetrics{
+		pushes:       metricForwarderPushes.WithLabelValues(tenantID),
+		pushFailures: metricForwarderPushesFailures.WithLabelValues(tenantID),
+		queueLength:  metricForwarderQueueLength.WithLabelValues(tenantID),
+		dropped: func(reason string) prometheus.Counter {
+			return metricForwarderDroppedTraces.WithLabelValues(tenantID, reason)
+		},
+	}
+}
+
 type forwardFunc func(ctx context.Context, tenantID string, keys []uint32, traces []*rebatchedTrace) error
 
+// queueConfigFunc returns the queue size and worker count of a tenant. 0 uses the defaults.
+type queueConfigFunc func(tenantID string) (queueSize, workerCount int)
+
 type request struct {
 	keys   []uint32
 	traces []*rebatchedTrace
 }
 
-// forwarder queues up traces to be sent to the metrics-generators
+// forwarder queues up traces to be sent asynchronously with per-tenant queues
 type forwarder struct {
 	services.Service
 
+	name string
+
 	// per-tenant queue managers
 	queueManagers map[string]*queueManager
 	mutex         sync.RWMutex
 
 	forwardFunc forwardFunc
+	queueConfig queueConfigFunc
 
-	o                 *overrides.Overrides
 	overridesInterval time.Duration
 	shutdown          chan interface{}
 }
 
+// newForwarder returns a forwarder to the metrics-generators with the queue settings of the overrides
 func newForwarder(fn forwardFunc, o *overrides.Overrides) *forwarder {
+	return newQueueForwarder(forwarderMetricsGenerator, fn, func(tenantID string) (int, int) {
+		return o.MetricsGeneratorForwarderQueueSize(tenantID), o.MetricsGeneratorForwarderWorkers(tenantID)
+	})
+}
+
+func newQueueForwarder(name string, fn forwardFunc, queueConfig queueConfigFunc) *forwarder {
 	rf := &forwarder{
+		name:              name,
 		queueManagers:     make(map[string]*queueManager),
 		mutex:             sync.RWMutex{},
 		forwardFunc:       fn,
-		o:                 o,
+		queueConfig:       queueConfig,
 		overridesInterval: time.Minute,
 		shutdown:          make(chan interface{}),
 	}
@@ -76,7 +124,7 @@ func newForwarder(fn forwardFunc, o *overrides.Overrides) *forwarder {
 	return rf
 }
 
-// SendTraces queues up traces to be sent to the metrics-generators
+// SendTraces queues up traces to be sent by the forward function
 func (f *forwarder) SendTraces(ctx context.Context, tenantID string, keys []uint32, traces []*rebatchedTrace) {
 	select {
 	case <-f.shutdown:
@@ -85,23 +133,15 @@ func (f *forwarder) SendTraces(ctx context.Context, tenantID string, keys []uint
 	}
 
 	qm := f.getOrCreateQueueManager(tenantID)
-	err := qm.pushToQueue(ctx, &request{keys: keys, traces: traces})
-	if err != nil {
-		level.Error(log.Logger).Log("msg", "failed to push traces to queue", "tenant", tenantID, "err", err)
-		metricForwarderPushesFailures.WithLabelValues(tenantID).Inc()
-	}
-
-	metricForwarderPushes.WithLabelValues(tenantID).Inc()
+	qm.send(ctx, &request{keys: keys, traces: traces})
 }
 
 // getQueueManagerConfig returns queueSize and workerCount for the given tenant
 func (f *forwarder) getQueueManagerConfig(tenantID string) (queueSize, workerCount int) {
-	queueSize = f.o.MetricsGeneratorForwarderQueueSize(tenantID)
+	queueSize, workerCount = f.queueConfig(tenantID)
 	if queueSize == 0 {
 		queueSize = defaultQueueSize
 	}
-
-	workerCount = f.o.MetricsGeneratorForwarderWorkers(tenantID)
 	if workerCount == 0 {
 		workerCount = defaultWorkerCount
 	}
@@ -118,7 +158,7 @@ func (f *forwarder) getOrCreateQueueManager(tenantID string) *queueManager {
 	defer f.mutex.Unlock()
 
 	queueSize, workerCount := f.getQueueManagerConfig(tenantID)
-	f.queueManagers[tenantID] = newQueueManager(tenantID, queueSize, workerCount, f.forwardFunc)
+	f.queueManagers[tenantID] = newQueueManager(f.name, tenantID, forwarderQueueMetrics(tenantID), queueSize, workerCount, f.forwardFunc)
 
 	return f.queueManagers[tenantID]
 }
@@ -178,7 +218,7 @@ func (f *forwarder) watchOverrides() {
 			// Synchronously update queue managers
 			for _, qm := range queueManagersToAdd {
 				level.Info(log.Logger).Log("msg", "Updating queue manager", "tenant", qm.tenantID)
-				f.queueManagers[qm.tenantID] = newQueueManager(qm.tenantID, qm.queueSize, qm.workerCount, f.forwardFunc)
+				f.queueManagers[qm.tenantID] = newQueueManager(f.name, qm.tenantID, forwarderQueueMetrics(qm.tenantID), qm.queueSize, qm.workerCount, f.forwardFunc)
 			}
 
 			f.mutex.Unlock()
@@ -212,7 +252,9 @@ type queueManager struct {
 	// wg is used to wait for the workers to drain the queue while stopping
 	wg sync.WaitGroup
 
+	forwarder        string
 	tenantID         string
+	metrics          queueMetrics
 	workerCount      int
 	workerAliveCount *atomic.Int32
 	queueSize        int
@@ -220,18 +262,29 @@ type queueManager struct {
 	fn               forwardFunc
 	workersCloseCh   chan struct{}
 
-	readOnly *atomic.Bool
+	// ctx is passed to the forward function and cancelled if the workers don't stop in time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// readOnlyMtx makes sure no request is queued once the workers may have stopped
+	readOnlyMtx sync.RWMutex
+	readOnly    *atomic.Bool
 }
 
-func newQueueManager(tenantID string, queueSize, workerCount int, fn forwardFunc) *queueManager {
+func newQueueManager(forwarder, tenantID string, metrics queueMetrics, queueSize, workerCount int, fn forwardFunc) *queueManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	m := &queueManager{
+		forwarder:        forwarder,
 		tenantID:         tenantID,
+		metrics:          metrics,
 		workerCount:      workerCount,
 		queueSize:        queueSize,
 		workerAliveCount: atomic.NewInt32(0),
 		reqChan:          make(chan *request, queueSize),
 		fn:               fn,
 		workersCloseCh:   make(chan struct{}),
+		ctx:              ctx,
+		cancel:           cancel,
 		readOnly:         atomic.NewBool(false),
 	}
 
@@ -240,16 +293,39 @@ func newQueueManager(tenantID string, queueSize, workerCount int, fn forwardFunc
 	return m
 }
 
+// send queues up the request and records the outcome. Requests that do not fit into the queue or are sent after
+// the queue was shut down are dropped.
+func (m *queueManager) send(ctx context.Context, req *request) {
+	err := m.pushToQueue(ctx, req)
+	if err != nil {
+		level.Error(log.Logger).Log("msg", "failed to push traces to queue", "forwarder", m.forwarder, "tenant", m.tenantID, "err", err)
+		m.metrics.pushFailures.Inc()
+
+		reason := reasonQueueFull
+		if errors.Is(err, errQueueReadOnly) {
+			reason = reasonShutdown
+		}
+		m.metrics.dropped(reason).Add(float64(len(req.traces)))
+	}
+
+	m.metrics.pushes.Inc()
+}
+
+var errQueueReadOnly = errors.New("queue is read-only")
+
 // pushToQueue a trace to the queue
 // if the queue is full, the trace is dropped
 func (m *queueManager) pushToQueue(ctx context.Context, req *request) error {
+	m.readOnlyMtx.RLock()
+	defer m.readOnlyMtx.RUnlock()
+
 	if m.readOnly.Load() {
-		return fmt.Errorf("queue is read-only")
+		return errQueueReadOnly
 	}
 
 	select {
 	case m.reqChan <- req:
-		metricForwarderQueueLength.WithLabelValues(m.tenantID).Inc()
+		m.metrics.queueLength.Inc()
 	case <-ctx.Done():
 		return fmt.Errorf("failed to pushToQueue traces to tenant %s queue: %w", m.tenantID, ctx.Err())
 	default:
@@ -279,15 +355,15 @@ func (m *queueManager) worker() {
 	for {
 		select {
 		case req := <-m.reqChan:
-			metricForwarderQueueLength.WithLabelValues(m.tenantID).Dec()
-			m.forwardRequest(context.Background(), req)
+			m.metrics.queueLength.Dec()
+			m.forwardRequest(m.ctx, req)
 		default:
 			// Forces to always trying to pull from the queue before exiting
 			// This is important during shutdown to ensure that the queue is drained
 			select {
 			case req := <-m.reqChan:
-				metricForwarderQueueLength.WithLabelValues(m.tenantID).Dec()
-				m.forwardRequest(context.Background(), req)
+				m.metrics.queueLength.Dec()
+				m.forwardRequest(m.ctx, req)
 			case <-m.workersCloseCh:
 				// If the queue isn't empty, force to start the loop from the beginning
 				if len(m.reqChan) > 0 {
@@ -301,13 +377,19 @@ func (m *queueManager) worker() {
 
 func (m *queueManager) forwardRequest(ctx context.Context, req *request) {
 	if err := m.fn(ctx, m.tenantID, req.keys, req.traces); err != nil {
-		level.Error(log.Logger).Log("msg", "pushing to metrics-generators failed", "err", err)
+		level.Error(log.Logger).Log("msg", "forwarding traces failed", "forwarder", m.forwarder, "tenant", m.tenantID, "err", err)
+		m.metrics.dropped(reasonSendFailed).Add(float64(len(req.traces)))
 	}
 }
 
 func (m *queueManager) shutdown() error {
+	// wait for requests being queued, no request is queued after this
+	m.readOnlyMtx.Lock()
+	stop := m.readOnly.CAS(false, true)
+	m.readOnlyMtx.Unlock()
+
 	// Call to stopWorkers only once
-	if m.readOnly.CAS(false, true) {
+	if stop {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
@@ -329,8 +411,11 @@ func (m *queueManager) stopWorkers(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
+		// abort requests in flight, the workers fail the remaining requests and return
+		m.cancel()
 		return fmt.Errorf("failed to stop tenant %s queueManager: %w", m.tenantID, ctx.Err())
 	case <-doneCh:
+		m.cancel()
 		return nil
 	}
 }
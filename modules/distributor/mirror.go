@@ -0,0 +1,343 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/multierr"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/model/trace"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+const (
+	defaultMirrorTimeout      = 10 * time.Second
+	defaultMirrorRetryBackoff = 100 * time.Millisecond
+	maxMirrorRetryBackoff     = 5 * time.Second
+)
+
+var (
+	metricMirrorPushes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_mirror_pushes_total",
+		Help:      "Total number of requests of a tenant queued up for a mirror",
+	}, []string{"mirror", "tenant"})
+	metricMirrorPushesFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_mirror_pushes_failures_total",
+		Help:      "Total number of requests of a tenant that could not be queued up for a mirror",
+	}, []string{"mirror", "tenant"})
+	metricMirrorQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_mirror_queue_length",
+		Help:      "Number of queued requests of a tenant for a mirror",
+	}, []string{"mirror", "tenant"})
+	metricMirrorDroppedTraces = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_mirror_dropped_traces_total",
+		Help:      "Total number of traces of a tenant dropped by a mirror",
+	}, []string{"mirror", "tenant", "reason"})
+	metricMirrorRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_mirror_retries_total",
+		Help:      "Total number of retried requests of a tenant to a mirror",
+	}, []string{"mirror", "tenant"})
+)
+
+// mirrorForwarder tees the traces of a tenant to the external OTLP endpoints configured in its overrides. Each
+// mirror has its own queue, so a slow endpoint does not delay the others.
+type mirrorForwarder struct {
+	services.Service
+
+	mirrorsForTenant func(tenantID string) []overrides.Mirror
+	logger           log.Logger
+
+	tenants map[string]*tenantMirrors
+	mtx     sync.RWMutex
+	stopped bool
+}
+
+type tenantMirrors struct {
+	cfg     []overrides.Mirror
+	mirrors []*mirror
+}
+
+func newMirrorForwarder(mirrorsForTenant func(tenantID string) []overrides.Mirror, logger log.Logger) *mirrorForwarder {
+	f := &mirrorForwarder{
+		mirrorsForTenant: mirrorsForTenant,
+		logger:           logger,
+		tenants:          map[string]*tenantMirrors{},
+	}
+
+	f.Service = services.NewIdleService(nil, f.stop)
+
+	return f
+}
+
+// SendTraces queues up the traces for each mirror of the tenant that selects them
+func (f *mirrorForwarder) SendTraces(ctx context.Context, tenantID string, keys []uint32, traces []*rebatchedTrace) {
+	for _, m := range f.getMirrors(tenantID) {
+		mirrorKeys, mirrorTraces := m.filter(keys, traces)
+		if len(mirrorTraces) == 0 {
+			continue
+		}
+
+		m.queue.send(ctx, &request{keys: mirrorKeys, traces: mirrorTraces})
+	}
+}
+
+// getMirrors returns the mirrors of the tenant. The mirrors are updated if the overrides of the tenant changed.
+func (f *mirrorForwarder) getMirrors(tenantID string) []*mirror {
+	cfg := f.mirrorsForTenant(tenantID)
+
+	f.mtx.RLock()
+	current, ok := f.tenants[tenantID]
+	stopped := f.stopped
+	f.mtx.RUnlock()
+
+	if stopped || (!ok && len(cfg) == 0) {
+		return nil
+	}
+	if ok && reflect.DeepEqual(current.cfg, cfg) {
+		return current.mirrors
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.stopped {
+		return nil
+	}
+
+	// check again, another push may have updated the mirrors in the meantime
+	current = f.tenants[tenantID]
+	if current != nil && reflect.DeepEqual(current.cfg, cfg) {
+		return current.mirrors
+	}
+
+	existing := map[string]*mirror{}
+	if current != nil {
+		for _, m := range current.mirrors {
+			existing[m.cfg.Name] = m
+		}
+	}
+
+	updated := &tenantMirrors{cfg: cfg}
+	for _, c := range cfg {
+		if m, ok := existing[c.Name]; ok && reflect.DeepEqual(m.cfg, c) {
+			updated.mirrors = append(updated.mirrors, m)
+			delete(existing, c.Name)
+			continue
+		}
+
+		m, err := newMirror(tenantID, c)
+		if err != nil {
+			level.Warn(f.logger).Log("msg", "skipping invalid mirror", "tenant", tenantID, "mirror", c.Name, "err", err)
+			continue
+		}
+		level.Info(f.logger).Log("msg", "starting mirror", "tenant", tenantID, "mirror", c.Name, "endpoint", c.Endpoint)
+		updated.mirrors = append(updated.mirrors, m)
+	}
+
+	// mirrors that were removed or changed drain their queue in the background
+	for _, m := range existing {
+		go func(m *mirror) {
+			level.Info(f.logger).Log("msg", "stopping mirror", "tenant", tenantID, "mirror", m.cfg.Name)
+			if err := m.shutdown(); err != nil {
+				level.Error(f.logger).Log("msg", "error stopping mirror", "tenant", tenantID, "mirror", m.cfg.Name, "err", err)
+			}
+		}(m)
+	}
+
+	if len(cfg) == 0 {
+		delete(f.tenants, tenantID)
+	} else {
+		f.tenants[tenantID] = updated
+	}
+
+	return updated.mirrors
+}
+
+func (f *mirrorForwarder) stop(_ error) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.stopped = true
+
+	var errs []error
+	for _, t := range f.tenants {
+		for _, m := range t.mirrors {
+			if err := m.shutdown(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// mirror sends the selected traces of a tenant to one external endpoint
+type mirror struct {
+	cfg      overrides.Mirror
+	exporter mirrorExporter
+	queue    *queueManager
+
+	services     map[string]struct{}
+	maxKey       uint32
+	timeout      time.Duration
+	retryBackoff time.Duration
+}
+
+func newMirror(tenantID string, cfg overrides.Mirror) (*mirror, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("missing endpoint")
+	}
+	if cfg.SamplingRate < 0 || cfg.SamplingRate > 1 {
+		return nil, fmt.Errorf("sampling rate %v not between 0 and 1", cfg.SamplingRate)
+	}
+
+	exporter, err := newMirrorExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mirror{
+		cfg:          cfg,
+		exporter:     exporter,
+		maxKey:       math.MaxUint32,
+		timeout:      time.Duration(cfg.Timeout),
+		retryBackoff: time.Duration(cfg.RetryBackoff),
+	}
+	if m.timeout == 0 {
+		m.timeout = defaultMirrorTimeout
+	}
+	if m.retryBackoff == 0 {
+		m.retryBackoff = defaultMirrorRetryBackoff
+	}
+	if cfg.SamplingRate > 0 && cfg.SamplingRate < 1 {
+		m.maxKey = uint32(cfg.SamplingRate * math.MaxUint32)
+	}
+	if len(cfg.Services) > 0 {
+		m.services = make(map[string]struct{}, len(cfg.Services))
+		for _, s := range cfg.Services {
+			m.services[s] = struct{}{}
+		}
+	}
+
+	queueSize, workerCount := cfg.QueueSize, cfg.Workers
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+	if workerCount == 0 {
+		workerCount = defaultWorkerCount
+	}
+	metrics := queueMetrics{
+		pushes:       metricMirrorPushes.WithLabelValues(cfg.Name, tenantID),
+		pushFailures: metricMirrorPushesFailures.WithLabelValues(cfg.Name, tenantID),
+		queueLength:  metricMirrorQueueLength.WithLabelValues(cfg.Name, tenantID),
+		dropped: func(reason string) prometheus.Counter {
+			return metricMirrorDroppedTraces.WithLabelValues(cfg.Name, tenantID, reason)
+		},
+	}
+	m.queue = newQueueManager("mirror/"+cfg.Name, tenantID, metrics, queueSize, workerCount, m.forward)
+
+	return m, nil
+}
+
+// filter returns the traces selected by the sampling rate and the services of the mirror. Sampling is based on the
+// token of the trace, so all distributors select the same traces. Only the batches of the selected services are kept.
+func (m *mirror) filter(keys []uint32, traces []*rebatchedTrace) ([]uint32, []*rebatchedTrace) {
+	if m.maxKey == math.MaxUint32 && m.services == nil {
+		return keys, traces
+	}
+
+	var (
+		filteredKeys   []uint32
+		filteredTraces []*rebatchedTrace
+	)
+	for i, t := range traces {
+		if keys[i] > m.maxKey {
+			continue
+		}
+
+		if m.services != nil {
+			var batches []*v1.ResourceSpans
+			for _, b := range t.trace.Batches {
+				if _, ok := m.services[trace.ServiceName(b)]; ok {
+					batches = append(batches, b)
+				}
+			}
+			if len(batches) == 0 {
+				continue
+			}
+			if len(batches) < len(t.trace.Batches) {
+				t = &rebatchedTrace{
+					id:    t.id,
+					trace: &tempopb.Trace{Batches: batches},
+					start: t.start,
+					end:   t.end,
+				}
+			}
+		}
+
+		filteredKeys = append(filteredKeys, keys[i])
+		filteredTraces = append(filteredTraces, t)
+	}
+
+	return filteredKeys, filteredTraces
+}
+
+// forward exports the traces as one request and retries with exponential backoff if the endpoint is unavailable
+func (m *mirror) forward(ctx context.Context, tenantID string, _ []uint32, traces []*rebatchedTrace) error {
+	req := &tempopb.Trace{}
+	for _, t := range traces {
+		req.Batches = append(req.Batches, t.trace.Batches...)
+	}
+
+	backoff := m.retryBackoff
+	for attempt := 0; ; attempt++ {
+		exportCtx, cancel := context.WithTimeout(ctx, m.timeout)
+		err := m.exporter.export(exportCtx, req)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		var retryable *retryableError
+		if attempt >= m.cfg.MaxRetries || !errors.As(err, &retryable) || ctx.Err() != nil {
+			return fmt.Errorf("failed to export traces to mirror %s after %d attempts: %w", m.cfg.Name, attempt+1, err)
+		}
+
+		metricMirrorRetries.WithLabelValues(m.cfg.Name, tenantID).Inc()
+
+		// the queue cancels the context if it is not drained in time while shutting down
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to export traces to mirror %s after %d attempts: %w", m.cfg.Name, attempt+1, ctx.Err())
+		}
+		backoff *= 2
+		if backoff > maxMirrorRetryBackoff {
+			backoff = maxMirrorRetryBackoff
+		}
+	}
+}
+
+// shutdown drains the queue and closes the exporter
+func (m *mirror) shutdown() error {
+	return multierr.Combine(m.queue.shutdown(), m.exporter.shutdown())
+}
@@ -0,0 +1,188 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/collector/model/otlpgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	mirrorProtocolGRPC = "grpc"
+	mirrorProtocolHTTP = "http"
+)
+
+// mirrorExporter sends traces to an external OTLP endpoint. The traces are passed as tempopb.Trace which is
+// wire-compatible with ExportTraceServiceRequest.
+type mirrorExporter interface {
+	export(ctx context.Context, req *tempopb.Trace) error
+	shutdown() error
+}
+
+// retryableError marks export errors worth retrying, e.g. an unavailable or overloaded endpoint
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func newMirrorExporter(cfg overrides.Mirror) (mirrorExporter, error) {
+	switch cfg.Protocol {
+	case mirrorProtocolGRPC:
+		return newGRPCMirrorExporter(cfg)
+	case mirrorProtocolHTTP:
+		return newHTTPMirrorExporter(cfg)
+	}
+
+	return nil, fmt.Errorf("unknown protocol %q", cfg.Protocol)
+}
+
+type grpcMirrorExporter struct {
+	conn    *grpc.ClientConn
+	client  otlpgrpc.TracesClient
+	headers metadata.MD
+}
+
+func newGRPCMirrorExporter(cfg overrides.Mirror) (*grpcMirrorExporter, error) {
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify})
+	if cfg.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	// the connection is established in the background and reconnects on failures
+	conn, err := grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcMirrorExporter{
+		conn:    conn,
+		client:  otlpgrpc.NewTracesClient(conn),
+		headers: metadata.New(cfg.Headers),
+	}, nil
+}
+
+func (e *grpcMirrorExporter) export(ctx context.Context, req *tempopb.Trace) error {
+	b, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	otlpReq, err := otlpgrpc.UnmarshalTracesRequest(b)
+	if err != nil {
+		return err
+	}
+
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+
+	_, err = e.client.Export(ctx, otlpReq)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+			return &retryableError{err: err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (e *grpcMirrorExporter) shutdown() error {
+	return e.conn.Close()
+}
+
+type httpMirrorExporter struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+}
+
+// newHTTPMirrorExporter returns an exporter to the endpoint URL. Unencrypted http URLs must be marked insecure,
+// so that insecure has the same meaning as for grpc.
+func newHTTPMirrorExporter(cfg overrides.Mirror) (*httpMirrorExporter, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case u.Scheme == "http" && !cfg.Insecure:
+		return nil, fmt.Errorf("endpoint %s is unencrypted but the mirror is not insecure", cfg.Endpoint)
+	case u.Scheme == "https" && cfg.Insecure:
+		return nil, fmt.Errorf("endpoint %s is encrypted but the mirror is insecure", cfg.Endpoint)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return nil, fmt.Errorf("endpoint %s is not an http or https URL", cfg.Endpoint)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &httpMirrorExporter{
+		client:   &http.Client{Transport: transport},
+		endpoint: cfg.Endpoint,
+		headers:  cfg.Headers,
+	}, nil
+}
+
+func (e *httpMirrorExporter) export(ctx context.Context, req *tempopb.Trace) error {
+	b, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected status %s from %s", resp.Status, e.endpoint)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+func (e *httpMirrorExporter) shutdown() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
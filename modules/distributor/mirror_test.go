@@ -0,0 +1,330 @@
+package distributor
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlpgrpc"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/model/trace"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestMirrorForwarderHTTP(t *testing.T) {
+	var (
+		requests atomic.Int32
+		spans    atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		// the first request is retried
+		if requests.Inc() == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := &tempopb.Trace{}
+		require.NoError(t, req.Unmarshal(b))
+		spans.Add(int32(countSpans(req.Batches...)))
+	}))
+	defer srv.Close()
+
+	f := newMirrorForwarder(func(string) []overrides.Mirror {
+		return []overrides.Mirror{{
+			Name:       "http",
+			Protocol:   mirrorProtocolHTTP,
+			Endpoint:   srv.URL + "/v1/traces",
+			Insecure:   true,
+			Headers:    map[string]string{"Authorization": "secret"},
+			MaxRetries: 1,
+		}}
+	}, log.NewNopLogger())
+	defer func() { require.NoError(t, f.stop(nil)) }()
+
+	keys, traces, err := requestsByTraceID([]*v1.ResourceSpans{test.MakeBatch(10, nil)}, tenantID, 10)
+	require.NoError(t, err)
+
+	f.SendTraces(context.Background(), tenantID, keys, traces)
+
+	require.Eventually(t, func() bool {
+		return spans.Load() == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestMirrorForwarderGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver := &mockTracesServer{}
+	srv := grpc.NewServer()
+	otlpgrpc.RegisterTracesServer(srv, receiver)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	f := newMirrorForwarder(func(string) []overrides.Mirror {
+		return []overrides.Mirror{{
+			Name:     "grpc",
+			Protocol: mirrorProtocolGRPC,
+			Endpoint: lis.Addr().String(),
+			Insecure: true,
+		}}
+	}, log.NewNopLogger())
+	defer func() { require.NoError(t, f.stop(nil)) }()
+
+	keys, traces, err := requestsByTraceID([]*v1.ResourceSpans{test.MakeBatch(10, nil)}, tenantID, 10)
+	require.NoError(t, err)
+
+	f.SendTraces(context.Background(), tenantID, keys, traces)
+
+	require.Eventually(t, func() bool {
+		return receiver.spans.Load() == 10
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMirrorForwarderOverrides(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		mirrors = []overrides.Mirror{
+			{Name: "a", Protocol: mirrorProtocolHTTP, Endpoint: "http://localhost:1/v1/traces", Insecure: true},
+			{Name: "b", Protocol: mirrorProtocolHTTP, Endpoint: "http://localhost:2/v1/traces", Insecure: true},
+			// invalid mirrors are skipped
+			{Name: "c", Protocol: "kafka", Endpoint: "localhost:3"},
+			{Name: "d", Protocol: mirrorProtocolHTTP},
+		}
+	)
+	f := newMirrorForwarder(func(tenant string) []overrides.Mirror {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if tenant != tenantID {
+			return nil
+		}
+		return mirrors
+	}, log.NewNopLogger())
+	defer func() { require.NoError(t, f.stop(nil)) }()
+
+	assert.Len(t, f.getMirrors("other"), 0)
+
+	current := f.getMirrors(tenantID)
+	require.Len(t, current, 2)
+	assert.Equal(t, current, f.getMirrors(tenantID))
+
+	// unchanged mirrors are kept when the overrides change
+	mtx.Lock()
+	mirrors = []overrides.Mirror{mirrors[0], {Name: "b", Protocol: mirrorProtocolHTTP, Endpoint: "http://localhost:4/v1/traces", Insecure: true}}
+	mtx.Unlock()
+
+	updated := f.getMirrors(tenantID)
+	require.Len(t, updated, 2)
+	assert.Same(t, current[0], updated[0])
+	assert.NotSame(t, current[1], updated[1])
+	assert.Equal(t, "http://localhost:4/v1/traces", updated[1].cfg.Endpoint)
+
+	mtx.Lock()
+	mirrors = nil
+	mtx.Unlock()
+
+	assert.Len(t, f.getMirrors(tenantID), 0)
+	assert.Len(t, f.tenants, 0)
+}
+
+func TestMirrorFilter(t *testing.T) {
+	batch := func(service string) *v1.ResourceSpans {
+		return &v1.ResourceSpans{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{
+					{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+				},
+			},
+		}
+	}
+
+	keys := []uint32{10, math.MaxUint32 / 2, math.MaxUint32}
+	traces := []*rebatchedTrace{
+		{id: []byte{1}, trace: &tempopb.Trace{Batches: []*v1.ResourceSpans{batch("frontend"), batch("db")}}},
+		{id: []byte{2}, trace: &tempopb.Trace{Batches: []*v1.ResourceSpans{batch("db")}}},
+		{id: []byte{3}, trace: &tempopb.Trace{Batches: []*v1.ResourceSpans{batch("frontend")}}},
+	}
+
+	tt := []struct {
+		name         string
+		cfg          overrides.Mirror
+		expectedKeys []uint32
+		expectedIDs  [][]byte
+	}{
+		{
+			name:         "all",
+			expectedKeys: keys,
+			expectedIDs:  [][]byte{{1}, {2}, {3}},
+		},
+		{
+			name:         "sampled",
+			cfg:          overrides.Mirror{SamplingRate: 0.6},
+			expectedKeys: []uint32{10, math.MaxUint32 / 2},
+			expectedIDs:  [][]byte{{1}, {2}},
+		},
+		{
+			name:         "services",
+			cfg:          overrides.Mirror{Services: []string{"frontend"}},
+			expectedKeys: []uint32{10, math.MaxUint32},
+			expectedIDs:  [][]byte{{1}, {3}},
+		},
+		{
+			name:         "sampled services",
+			cfg:          overrides.Mirror{Services: []string{"db"}, SamplingRate: 0.6},
+			expectedKeys: []uint32{10, math.MaxUint32 / 2},
+			expectedIDs:  [][]byte{{1}, {2}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Name = "test"
+			tc.cfg.Protocol = mirrorProtocolHTTP
+			tc.cfg.Endpoint = "http://localhost/v1/traces"
+			tc.cfg.Insecure = true
+
+			m, err := newMirror(tenantID, tc.cfg)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, m.shutdown()) }()
+
+			filteredKeys, filteredTraces := m.filter(keys, traces)
+			assert.Equal(t, tc.expectedKeys, filteredKeys)
+
+			var ids [][]byte
+			for _, tr := range filteredTraces {
+				ids = append(ids, tr.id)
+
+				// only the batches of the selected services are mirrored
+				for _, b := range tr.trace.Batches {
+					if len(tc.cfg.Services) > 0 {
+						assert.Contains(t, tc.cfg.Services, trace.ServiceName(b))
+					}
+				}
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+
+	// the original traces are not modified
+	assert.Len(t, traces[0].trace.Batches, 2)
+}
+
+func TestMirrorInsecure(t *testing.T) {
+	tt := []struct {
+		protocol string
+		endpoint string
+		insecure bool
+		valid    bool
+	}{
+		{protocol: mirrorProtocolHTTP, endpoint: "https://localhost/v1/traces", valid: true},
+		{protocol: mirrorProtocolHTTP, endpoint: "http://localhost/v1/traces", insecure: true, valid: true},
+		{protocol: mirrorProtocolHTTP, endpoint: "http://localhost/v1/traces"},
+		{protocol: mirrorProtocolHTTP, endpoint: "https://localhost/v1/traces", insecure: true},
+		{protocol: mirrorProtocolHTTP, endpoint: "localhost:4318", insecure: true},
+		{protocol: mirrorProtocolGRPC, endpoint: "localhost:4317", valid: true},
+		{protocol: mirrorProtocolGRPC, endpoint: "localhost:4317", insecure: true, valid: true},
+	}
+
+	for _, tc := range tt {
+		e, err := newMirrorExporter(overrides.Mirror{Protocol: tc.protocol, Endpoint: tc.endpoint, Insecure: tc.insecure})
+		if !tc.valid {
+			assert.Error(t, err, tc.endpoint)
+			continue
+		}
+		require.NoError(t, err, tc.endpoint)
+		require.NoError(t, e.shutdown())
+	}
+}
+
+func TestMirrorRetryBackoffCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	m, err := newMirror(tenantID, overrides.Mirror{
+		Name:         "unavailable",
+		Protocol:     mirrorProtocolHTTP,
+		Endpoint:     srv.URL,
+		Insecure:     true,
+		MaxRetries:   10,
+		RetryBackoff: model.Duration(time.Hour),
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, m.shutdown()) }()
+
+	_, traces, err := requestsByTraceID([]*v1.ResourceSpans{test.MakeBatch(1, nil)}, tenantID, 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	require.ErrorIs(t, m.forward(ctx, tenantID, nil, traces), context.Canceled)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestMirrorDropsAfterShutdown(t *testing.T) {
+	m, err := newMirror(tenantID, overrides.Mirror{
+		Name:     "stopped",
+		Protocol: mirrorProtocolHTTP,
+		Endpoint: "http://localhost:1/v1/traces",
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.shutdown())
+
+	_, traces, err := requestsByTraceID([]*v1.ResourceSpans{test.MakeBatch(1, nil)}, tenantID, 1)
+	require.NoError(t, err)
+
+	// a mirror handed out before a concurrent config change stopped it counts the request as dropped
+	m.queue.send(context.Background(), &request{traces: traces})
+	assert.Equal(t, 1.0, testutil.ToFloat64(metricMirrorDroppedTraces.WithLabelValues("stopped", tenantID, reasonShutdown)))
+	assert.Equal(t, 0, len(m.queue.reqChan))
+}
+
+type mockTracesServer struct {
+	spans atomic.Int32
+}
+
+func (s *mockTracesServer) Export(_ context.Context, req otlpgrpc.TracesRequest) (otlpgrpc.TracesResponse, error) {
+	s.spans.Add(int32(req.Traces().SpanCount()))
+	return otlpgrpc.NewTracesResponse(), nil
+}
+
+func countSpans(batches ...*v1.ResourceSpans) int {
+	n := 0
+	for _, b := range batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			n += len(ils.Spans)
+		}
+	}
+	return n
+}
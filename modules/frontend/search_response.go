@@ -69,8 +69,10 @@ func (r *searchResponse) addResponse(res *tempopb.SearchResponse) {
 
 	for _, t := range res.Traces {
 		// todo: determine a better way to combine?
-		if _, ok := r.resultsMap[t.TraceID]; !ok {
+		if existing, ok := r.resultsMap[t.TraceID]; !ok {
 			r.resultsMap[t.TraceID] = t
+		} else if t.Complete {
+			existing.Complete = true
 		}
 	}
 
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/tempodb"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/vparquet"
 	"github.com/opentracing/opentracing-go"
 	"github.com/weaveworks/common/user"
 )
@@ -114,7 +115,9 @@ func (s searchSharder) RoundTrip(r *http.Request) (*http.Response, error) {
 
 	// get block metadata of blocks in start, end duration
 	blocks := s.blockMetas(int64(start), int64(end), tenantID)
+	blocks, prunedBlocks := pruneBlockMetas(blocks, searchReq)
 	span.SetTag("block-count", len(blocks))
+	span.SetTag("pruned-block-count", prunedBlocks)
 
 	var reqs []*http.Request
 	// add backend requests if we need them
@@ -137,6 +140,7 @@ func (s searchSharder) RoundTrip(r *http.Request) (*http.Response, error) {
 	wg := boundedwaitgroup.New(uint(s.cfg.ConcurrentRequests))
 	overallResponse := newSearchResponse(ctx, int(searchReq.Limit), subCancel)
 	overallResponse.resultsMetrics.InspectedBlocks = uint32(len(blocks))
+	overallResponse.resultsMetrics.SkippedBlocks = uint32(prunedBlocks)
 
 	totalBlockBytes := uint64(0)
 	for _, b := range blocks {
@@ -261,6 +265,45 @@ func (s *searchSharder) blockMetas(start, end int64, tenantID string) []*backend
 	return metas
 }
 
+// pruneBlockMetas drops the blocks whose stats show that they can't hold a trace matching the service,
+// attribute and duration constraints of the search. Blocks without stats are kept.
+func pruneBlockMetas(metas []*backend.BlockMeta, req *tempopb.SearchRequest) ([]*backend.BlockMeta, int) {
+	minDuration := uint64(req.MinDurationMs) * uint64(time.Millisecond)
+	maxDuration := uint64(req.MaxDurationMs) * uint64(time.Millisecond)
+
+	kept := metas[:0]
+	for _, m := range metas {
+		if m.Stats == nil || mayMatch(m.Stats, req.Tags, minDuration, maxDuration) {
+			kept = append(kept, m)
+		}
+	}
+
+	return kept, len(metas) - len(kept)
+}
+
+func mayMatch(stats *backend.BlockStats, tags map[string]string, minDuration, maxDuration uint64) bool {
+	if !stats.MayContainDuration(minDuration, maxDuration) {
+		return false
+	}
+
+	for k, v := range tags {
+		switch k {
+		case vparquet.LabelServiceName, vparquet.LabelRootServiceName:
+			if !stats.MayContainService(v) {
+				return false
+			}
+		case vparquet.LabelName, vparquet.LabelRootSpanName, vparquet.LabelStatusCode, vparquet.LabelDuration:
+			// intrinsics that are not attributes
+		default:
+			if !stats.MayContainAttribute(k) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // backendRequests returns a slice of requests that cover all blocks in the store
 // that are covered by start/end.
 func (s *searchSharder) backendRequests(ctx context.Context, tenantID string, parent *http.Request, metas []*backend.BlockMeta) ([]*http.Request, error) {
@@ -39,11 +39,16 @@ func (m *mockReader) Find(ctx context.Context, tenantID string, id common.ID, bl
 func (m *mockReader) BlockMetas(tenantID string) []*backend.BlockMeta {
 	return m.metas
 }
+func (m *mockReader) CompactedBlockMetas(tenantID string) []*backend.CompactedBlockMeta {
+	return nil
+}
 func (m *mockReader) Search(ctx context.Context, meta *backend.BlockMeta, req *tempopb.SearchRequest, opts common.SearchOptions) (*tempopb.SearchResponse, error) {
 	return nil, nil
 }
 func (m *mockReader) EnablePolling(sharder blocklist.JobSharder) {}
-func (m *mockReader) Shutdown()                                  {}
+func (m *mockReader) UpdateBlocklist(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta) {
+}
+func (m *mockReader) Shutdown() {}
 
 func TestBackendRequests(t *testing.T) {
 	tests := []struct {
@@ -185,6 +190,79 @@ func TestBackendRequests(t *testing.T) {
 	}
 }
 
+func TestPruneBlockMetas(t *testing.T) {
+	withStats := &backend.BlockMeta{
+		BlockID: uuid.New(),
+		Stats: &backend.BlockStats{
+			MinTraceDurationNanos: uint64(100 * time.Millisecond),
+			MaxTraceDurationNanos: uint64(2 * time.Second),
+			ServiceNames:          []string{"frontend", "database"},
+			Attributes:            []string{"http.method", "service.name"},
+		},
+	}
+	withoutStats := &backend.BlockMeta{BlockID: uuid.New()}
+
+	tcs := []struct {
+		name     string
+		req      *tempopb.SearchRequest
+		expected []*backend.BlockMeta
+	}{
+		{
+			name:     "no constraints",
+			req:      &tempopb.SearchRequest{},
+			expected: []*backend.BlockMeta{withStats, withoutStats},
+		},
+		{
+			name:     "service substring matches",
+			req:      &tempopb.SearchRequest{Tags: map[string]string{"service.name": "front"}},
+			expected: []*backend.BlockMeta{withStats, withoutStats},
+		},
+		{
+			name:     "unknown service",
+			req:      &tempopb.SearchRequest{Tags: map[string]string{"root.service.name": "backend"}},
+			expected: []*backend.BlockMeta{withoutStats},
+		},
+		{
+			name:     "known attribute",
+			req:      &tempopb.SearchRequest{Tags: map[string]string{"http.method": "GET"}},
+			expected: []*backend.BlockMeta{withStats, withoutStats},
+		},
+		{
+			name:     "unknown attribute",
+			req:      &tempopb.SearchRequest{Tags: map[string]string{"foo": "bar"}},
+			expected: []*backend.BlockMeta{withoutStats},
+		},
+		{
+			name:     "intrinsics are not attributes",
+			req:      &tempopb.SearchRequest{Tags: map[string]string{"name": "GET /", "status.code": "error"}},
+			expected: []*backend.BlockMeta{withStats, withoutStats},
+		},
+		{
+			name:     "traces too short",
+			req:      &tempopb.SearchRequest{MinDurationMs: 3000},
+			expected: []*backend.BlockMeta{withoutStats},
+		},
+		{
+			name:     "traces too long",
+			req:      &tempopb.SearchRequest{MaxDurationMs: 50},
+			expected: []*backend.BlockMeta{withoutStats},
+		},
+		{
+			name:     "duration overlaps",
+			req:      &tempopb.SearchRequest{MinDurationMs: 1000, MaxDurationMs: 5000},
+			expected: []*backend.BlockMeta{withStats, withoutStats},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			metas, pruned := pruneBlockMetas([]*backend.BlockMeta{withStats, withoutStats}, tc.req)
+			assert.Equal(t, tc.expected, metas)
+			assert.Equal(t, 2-len(tc.expected), pruned)
+		})
+	}
+}
+
 func TestIngesterRequest(t *testing.T) {
 	now := int(time.Now().Unix())
 	tenMinutesAgo := int(time.Now().Add(-10 * time.Minute).Unix())
@@ -0,0 +1,274 @@
+package ingester
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/grafana/tempo/pkg/api"
+	"github.com/grafana/tempo/pkg/util/log"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	adminTenantKey  = "tenant"
+	adminBlockIDKey = "blockID"
+
+	blockStateHead       = "head"
+	blockStateCompleting = "completing"
+	blockStateComplete   = "complete"
+)
+
+var (
+	errAdminTenantNotFound = errors.New("tenant not found")
+	errAdminBlockNotFound  = errors.New("block not found")
+	errAdminBlockFlushed   = errors.New("block already flushed")
+)
+
+// tenantStats summarizes the data held by the ingester for a tenant
+type tenantStats struct {
+	Tenant           string `json:"tenant"`
+	LiveTraces       int    `json:"liveTraces"`
+	LiveTracesBytes  int    `json:"liveTracesBytes"`
+	HeadBlockBytes   uint64 `json:"headBlockBytes"`
+	CompletingBlocks int    `json:"completingBlocks"`
+	CompleteBlocks   int    `json:"completeBlocks"`
+}
+
+// blockStats describes a head, completing or complete block of a tenant. The age is measured from the start time
+// of the block.
+type blockStats struct {
+	BlockID     uuid.UUID  `json:"blockID"`
+	State       string     `json:"state"`
+	Objects     int        `json:"objects"`
+	Bytes       uint64     `json:"bytes"`
+	StartTime   time.Time  `json:"startTime"`
+	EndTime     time.Time  `json:"endTime"`
+	AgeSeconds  int64      `json:"ageSeconds"`
+	Retained    bool       `json:"retained,omitempty"`
+	FlushedTime *time.Time `json:"flushedTime,omitempty"`
+}
+
+type tenantDetails struct {
+	tenantStats
+	Blocks []blockStats `json:"blocks"`
+}
+
+// TenantsHandler lists the tenants of the ingester with their live traces and blocks.
+func (i *Ingester) TenantsHandler(w http.ResponseWriter, _ *http.Request) {
+	instances := i.getInstances()
+
+	stats := make([]tenantStats, 0, len(instances))
+	for _, inst := range instances {
+		stats = append(stats, inst.tenantStats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tenant < stats[j].Tenant })
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// TenantHandler returns the live traces of a tenant and its head, completing and complete blocks with their sizes
+// and ages.
+func (i *Ingester) TenantHandler(w http.ResponseWriter, r *http.Request) {
+	inst, ok := i.getInstanceByID(mux.Vars(r)[adminTenantKey])
+	if !ok {
+		http.Error(w, errAdminTenantNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tenantDetails{
+		tenantStats: inst.tenantStats(),
+		Blocks:      inst.blockStats(time.Now()),
+	})
+}
+
+// TenantFlushHandler cuts all live traces of a single tenant into its head block and flushes the head block. It is
+// the equivalent of /flush for one tenant.
+func (i *Ingester) TenantFlushHandler(w http.ResponseWriter, r *http.Request) {
+	inst, ok := i.getInstanceByID(mux.Vars(r)[adminTenantKey])
+	if !ok {
+		http.Error(w, errAdminTenantNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	level.Info(log.Logger).Log("msg", "tenant flush requested", "tenant", inst.instanceID)
+	i.sweepInstance(inst, true)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BlockFlushHandler moves a single block of a tenant towards the backend. The head block is cut, a completing block
+// is completed and a complete block is flushed.
+func (i *Ingester) BlockFlushHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	inst, ok := i.getInstanceByID(vars[adminTenantKey])
+	if !ok {
+		http.Error(w, errAdminTenantNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	blockID, err := uuid.Parse(vars[adminBlockIDKey])
+	if err != nil {
+		http.Error(w, "invalid blockID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	level.Info(log.Logger).Log("msg", "block flush requested", "tenant", inst.instanceID, "block", blockID)
+	err = i.flushBlock(inst, blockID)
+	switch {
+	case errors.Is(err, errAdminBlockNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errAdminBlockFlushed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// flushBlock enqueues the next step of the block towards the backend without jitter
+func (i *Ingester) flushBlock(inst *instance, blockID uuid.UUID) error {
+	state, flushed := inst.blockState(blockID)
+
+	kind := opKindComplete
+	switch state {
+	case blockStateHead:
+		// the head block may only be cut if it has data. cutting it again is harmless if it was cut in the meantime
+		cutID, err := inst.CutBlockIfReady(0, 0, true)
+		if err != nil {
+			return err
+		}
+		if cutID == uuid.Nil {
+			return nil
+		}
+		blockID = cutID
+	case blockStateCompleting:
+		// a retained block of replica aware flushing is completed with the traces of the other replicas
+		inst.expireRetainedBlock(blockID)
+	case blockStateComplete:
+		if flushed {
+			return errAdminBlockFlushed
+		}
+		kind = opKindFlush
+	default:
+		return errAdminBlockNotFound
+	}
+
+	i.enqueue(&flushOp{
+		kind:    kind,
+		userID:  inst.instanceID,
+		blockID: blockID,
+	}, false)
+
+	return nil
+}
+
+func (i *instance) tenantStats() tenantStats {
+	stats := tenantStats{
+		Tenant:         i.instanceID,
+		HeadBlockBytes: i.headBlockBytes.Load(),
+	}
+
+	i.blocksMtx.RLock()
+	stats.CompletingBlocks = len(i.completingBlocks)
+	stats.CompleteBlocks = len(i.completeBlocks)
+	i.blocksMtx.RUnlock()
+
+	i.tracesMtx.Lock()
+	stats.LiveTraces = len(i.traces)
+	stats.LiveTracesBytes = i.liveBytes
+	i.tracesMtx.Unlock()
+
+	return stats
+}
+
+func (i *instance) blockStats(now time.Time) []blockStats {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	stats := make([]blockStats, 0, len(i.completingBlocks)+len(i.completeBlocks)+1)
+	newBlockStats := func(meta *backend.BlockMeta, state string) blockStats {
+		return blockStats{
+			BlockID:    meta.BlockID,
+			State:      state,
+			StartTime:  meta.StartTime,
+			EndTime:    meta.EndTime,
+			AgeSeconds: int64(now.Sub(meta.StartTime).Seconds()),
+		}
+	}
+
+	if i.headBlock != nil {
+		s := newBlockStats(i.headBlock.BlockMeta(), blockStateHead)
+		s.Objects = i.headBlock.Length()
+		s.Bytes = i.headBlock.DataLength()
+		stats = append(stats, s)
+	}
+
+	for _, b := range i.completingBlocks {
+		s := newBlockStats(b.BlockMeta(), blockStateCompleting)
+		s.Objects = b.Length()
+		s.Bytes = b.DataLength()
+		_, s.Retained = i.retainedBlocks[b.BlockMeta().BlockID]
+		stats = append(stats, s)
+	}
+
+	for _, b := range i.completeBlocks {
+		meta := b.BlockMeta()
+		s := newBlockStats(meta, blockStateComplete)
+		s.Objects = meta.TotalObjects
+		s.Bytes = meta.Size
+		if flushedTime := b.FlushedTime(); !flushedTime.IsZero() {
+			s.FlushedTime = &flushedTime
+		}
+		stats = append(stats, s)
+	}
+
+	return stats
+}
+
+// blockState returns the state of the block and whether it was flushed. The state is empty if the block is unknown.
+func (i *instance) blockState(blockID uuid.UUID) (string, bool) {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	if i.headBlock != nil && i.headBlock.BlockMeta().BlockID == blockID {
+		return blockStateHead, false
+	}
+
+	for _, b := range i.completingBlocks {
+		if b.BlockMeta().BlockID == blockID {
+			return blockStateCompleting, false
+		}
+	}
+
+	for _, b := range i.completeBlocks {
+		if b.BlockMeta().BlockID == blockID {
+			return blockStateComplete, !b.FlushedTime().IsZero()
+		}
+	}
+
+	return "", false
+}
+
+// expireRetainedBlock stops waiting for the replicas to confirm the flush of a retained block
+func (i *instance) expireRetainedBlock(blockID uuid.UUID) {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	if r, ok := i.retainedBlocks[blockID]; ok {
+		r.fallback = true
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(api.HeaderContentType, api.HeaderAcceptJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
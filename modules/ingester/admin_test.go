@@ -0,0 +1,109 @@
+package ingester
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminTenants(t *testing.T) {
+	ingester, traces, _ := defaultIngester(t, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	ingester.TenantsHandler(rec, httptest.NewRequest(http.MethodGet, "/ingester/tenants", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats []tenantStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats, 1)
+	require.Equal(t, "test", stats[0].Tenant)
+	require.Equal(t, len(traces), stats[0].LiveTraces)
+	require.Greater(t, stats[0].LiveTracesBytes, 0)
+	require.Equal(t, 0, stats[0].CompletingBlocks)
+
+	rec = adminRequest(ingester.TenantHandler, http.MethodGet, map[string]string{adminTenantKey: "unknown"})
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminTenantFlush(t *testing.T) {
+	ingester, traces, _ := defaultIngester(t, t.TempDir())
+
+	rec := adminRequest(ingester.TenantFlushHandler, http.MethodPost, map[string]string{adminTenantKey: "test"})
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// wait for the cut block to be completed and flushed
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		stats := inst.blockStats(time.Now())
+		return len(stats) == 2 && stats[1].FlushedTime != nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	rec = adminRequest(ingester.TenantHandler, http.MethodGet, map[string]string{adminTenantKey: "test"})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var details struct {
+		tenantStats
+		Blocks []blockStats `json:"blocks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &details))
+	require.Equal(t, 0, details.LiveTraces)
+
+	// the new head block is empty and the cut block is complete
+	require.Len(t, details.Blocks, 2)
+	require.Equal(t, blockStateHead, details.Blocks[0].State)
+	require.Equal(t, 0, details.Blocks[0].Objects)
+	require.Equal(t, blockStateComplete, details.Blocks[1].State)
+	require.Equal(t, len(traces), details.Blocks[1].Objects)
+	require.Greater(t, details.Blocks[1].Bytes, uint64(0))
+}
+
+func TestAdminBlockFlush(t *testing.T) {
+	ingester, traces, _ := defaultIngester(t, t.TempDir())
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+
+	// push all traces into the head block
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+
+	inst.blocksMtx.RLock()
+	headID := inst.headBlock.BlockMeta().BlockID
+	inst.blocksMtx.RUnlock()
+
+	rec := adminRequest(ingester.BlockFlushHandler, http.MethodPost, map[string]string{adminTenantKey: "test", adminBlockIDKey: headID.String()})
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// the head block is cut, completed and flushed
+	require.Eventually(t, func() bool {
+		state, flushed := inst.blockState(headID)
+		return state == blockStateComplete && flushed
+	}, 10*time.Second, 100*time.Millisecond)
+
+	stats := inst.blockStats(time.Now())
+	require.Len(t, stats, 2)
+	require.Equal(t, len(traces), stats[1].Objects)
+	require.NotNil(t, stats[1].FlushedTime)
+
+	rec = adminRequest(ingester.BlockFlushHandler, http.MethodPost, map[string]string{adminTenantKey: "test", adminBlockIDKey: headID.String()})
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest(ingester.BlockFlushHandler, http.MethodPost, map[string]string{adminTenantKey: "test", adminBlockIDKey: uuid.New().String()})
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = adminRequest(ingester.BlockFlushHandler, http.MethodPost, map[string]string{adminTenantKey: "test", adminBlockIDKey: "notablock"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func adminRequest(handler http.HandlerFunc, method string, vars map[string]string) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest(method, "/ingester/tenants", nil), vars)
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
@@ -27,6 +27,7 @@ type Config struct {
 type Client struct {
 	tempopb.PusherClient
 	tempopb.QuerierClient
+	tempopb.HandoffClient
 	grpc_health_v1.HealthClient
 	io.Closer
 }
@@ -60,6 +61,7 @@ func New(addr string, cfg Config) (*Client, error) {
 	return &Client{
 		PusherClient:  tempopb.NewPusherClient(conn),
 		QuerierClient: tempopb.NewQuerierClient(conn),
+		HandoffClient: tempopb.NewHandoffClient(conn),
 		HealthClient:  grpc_health_v1.NewHealthClient(conn),
 		Closer:        conn,
 	}, nil
@@ -9,6 +9,7 @@ import (
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/ring"
 
+	"github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/pkg/util/log"
 	"github.com/grafana/tempo/tempodb"
 )
@@ -17,15 +18,25 @@ import (
 type Config struct {
 	LifecyclerConfig ring.LifecyclerConfig `yaml:"lifecycler,omitempty"`
 
-	ConcurrentFlushes    int           `yaml:"concurrent_flushes"`
-	FlushCheckPeriod     time.Duration `yaml:"flush_check_period"`
-	FlushOpTimeout       time.Duration `yaml:"flush_op_timeout"`
-	MaxTraceIdle         time.Duration `yaml:"trace_idle_period"`
-	MaxBlockDuration     time.Duration `yaml:"max_block_duration"`
-	MaxBlockBytes        uint64        `yaml:"max_block_bytes"`
-	CompleteBlockTimeout time.Duration `yaml:"complete_block_timeout"`
-	OverrideRingKey      string        `yaml:"override_ring_key"`
-	UseFlatbufferSearch  bool          `yaml:"use_flatbuffer_search"`
+	ConcurrentFlushes          int           `yaml:"concurrent_flushes"`
+	FlushCheckPeriod           time.Duration `yaml:"flush_check_period"`
+	FlushOpTimeout             time.Duration `yaml:"flush_op_timeout"`
+	MaxTraceIdle               time.Duration `yaml:"trace_idle_period"`
+	TraceCompletenessDetection bool          `yaml:"trace_completeness_detection"`
+	CompleteTraceIdle          time.Duration `yaml:"complete_trace_idle_period"`
+	MissingParentsTraceIdle    time.Duration `yaml:"missing_parents_trace_idle_period"`
+	MaxBlockDuration           time.Duration `yaml:"max_block_duration"`
+	MaxBlockBytes              uint64        `yaml:"max_block_bytes"`
+	CompleteBlockTimeout       time.Duration `yaml:"complete_block_timeout"`
+	OverrideRingKey            string        `yaml:"override_ring_key"`
+	UseFlatbufferSearch        bool          `yaml:"use_flatbuffer_search"`
+	MaxTransferRetries         int           `yaml:"max_transfer_retries"`
+	ReplicaAwareFlushing       bool          `yaml:"replica_aware_flushing"`
+	ReplicaFlushTimeout        time.Duration `yaml:"replica_flush_timeout"`
+	ReplayConcurrency          int           `yaml:"replay_concurrency"`
+
+	// IngesterClient is used to hand off to another ingester, set from the top level ingester_client config
+	IngesterClient client.Config `yaml:"-"`
 }
 
 // RegisterFlagsAndApplyDefaults registers the flags.
@@ -42,8 +53,15 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.UseFlatbufferSearch = false
 
 	f.DurationVar(&cfg.MaxTraceIdle, prefix+".trace-idle-period", 10*time.Second, "Duration after which to consider a trace complete if no spans have been received")
+	f.BoolVar(&cfg.TraceCompletenessDetection, prefix+".trace-completeness-detection", false, "Track the root span and the parents referenced by the spans of live traces to cut complete traces early and keep traces with missing parents longer.")
+	f.DurationVar(&cfg.CompleteTraceIdle, prefix+".complete-trace-idle-period", 2*time.Second, "Duration after which to cut a trace whose root span and referenced parents have all been received if no spans have been received.")
+	f.DurationVar(&cfg.MissingParentsTraceIdle, prefix+".missing-parents-trace-idle-period", 30*time.Second, "Duration after which to cut a trace with a root span but missing parent spans if no spans have been received.")
 	f.DurationVar(&cfg.MaxBlockDuration, prefix+".max-block-duration", time.Hour, "Maximum duration which the head block can be appended to before cutting it.")
 	f.Uint64Var(&cfg.MaxBlockBytes, prefix+".max-block-bytes", 1024*1024*1024, "Maximum size of the head block before cutting it.")
+	f.IntVar(&cfg.MaxTransferRetries, prefix+".max-transfer-retries", 0, "Number of times to try to hand off live traces and wal blocks to a pending ingester on shutdown. 0 disables handoff and flushes everything instead.")
+	f.BoolVar(&cfg.ReplicaAwareFlushing, prefix+".replica-aware-flushing", false, "Only flush the traces this ingester is the primary replica of and keep the others in the wal until their primary has flushed them.")
+	f.DurationVar(&cfg.ReplicaFlushTimeout, prefix+".replica-flush-timeout", 2*time.Hour, "Duration after which traces of other replicas are flushed if their flush was not confirmed in the blocklist.")
+	f.IntVar(&cfg.ReplayConcurrency, prefix+".replay-concurrency", 4, "Number of wal blocks and local blocks replayed concurrently on startup.")
 	f.DurationVar(&cfg.CompleteBlockTimeout, prefix+".complete-block-timeout", 3*tempodb.DefaultBlocklistPoll, "Duration to keep blocks in the ingester after they have been flushed.")
 
 	hostname, err := os.Hostname()
@@ -87,6 +87,7 @@ func (i *Ingester) Flush() {
 
 // ShutdownHandler handles a graceful shutdown for an ingester. It does the following things in order
 // * Stop incoming writes by exiting from the ring
+// * Hand off live traces and wal blocks to a pending ingester if transfers are enabled
 // * Flush all blocks to backend
 func (i *Ingester) ShutdownHandler(w http.ResponseWriter, _ *http.Request) {
 	go func() {
@@ -150,8 +151,8 @@ func (i *Ingester) sweepAllInstances(immediate bool) {
 }
 
 func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
-	// cut traces internally
-	err := instance.CutCompleteTraces(i.cfg.MaxTraceIdle, immediate)
+	// cut traces that have not received spans for the trace idle period
+	err := instance.CutCompleteTraces(-i.cfg.MaxTraceIdle, immediate)
 	if err != nil {
 		level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to cut traces", "err", err)
 		return
@@ -175,6 +176,10 @@ func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
 		}, !immediate)
 	}
 
+	if i.replicas != nil {
+		i.sweepRetainedBlocks(instance)
+	}
+
 	// dump any blocks that have been flushed for awhile
 	err = instance.ClearFlushedBlocks(i.cfg.CompleteBlockTimeout)
 	if err != nil {
@@ -247,7 +252,7 @@ func (i *Ingester) handleComplete(op *flushOp) (retry bool, err error) {
 		return false, err
 	}
 
-	err = instance.CompleteBlock(op.blockID)
+	completeID, err := instance.completeBlock(op.blockID)
 	level.Info(log.Logger).Log("msg", "block completed", "userid", op.userID, "blockID", op.blockID, "duration", time.Since(start))
 	if err != nil {
 		handleFailedOp(op, err)
@@ -264,9 +269,16 @@ func (i *Ingester) handleComplete(op *flushOp) (retry bool, err error) {
 		return true, nil
 	}
 
-	err = instance.ClearCompletingBlock(op.blockID)
-	if err != nil {
-		return false, errors.Wrap(err, "error clearing completing block")
+	// with replica aware flushing the wal block is kept until the other replicas have flushed their traces
+	if !instance.isRetained(op.blockID) {
+		err = instance.ClearCompletingBlock(op.blockID)
+		if err != nil {
+			return false, errors.Wrap(err, "error clearing completing block")
+		}
+	}
+
+	if completeID == uuid.Nil {
+		return false, nil
 	}
 
 	// add a flushOp for the block we just completed
@@ -274,7 +286,7 @@ func (i *Ingester) handleComplete(op *flushOp) (retry bool, err error) {
 	i.enqueue(&flushOp{
 		kind:    opKindFlush,
 		userID:  instance.instanceID,
-		blockID: op.blockID,
+		blockID: completeID,
 	}, false)
 
 	return false, nil
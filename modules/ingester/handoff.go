@@ -0,0 +1,483 @@
+package ingester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/ring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/ingester/client"
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/log"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+const (
+	// maxTransferTraces is the maximum number of traces of a wal block sent in a single transfer request
+	maxTransferTraces = 100
+)
+
+var (
+	metricTransfersSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_transfers_sent_total",
+		Help:      "The total number of handoffs of this ingester to another ingester by result.",
+	}, []string{"result"})
+	metricTransfersReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_transfers_received_total",
+		Help:      "The total number of handoffs received from another ingester by result.",
+	}, []string{"result"})
+	metricTransferTraces = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_transfer_traces_total",
+		Help:      "The total number of traces sent or received during handoffs.",
+	}, []string{"direction"})
+)
+
+// TransferOut implements ring.FlushTransferer. It hands off the live traces and wal blocks of all tenants to a
+// pending ingester, which takes over the tokens of this ingester once everything was received. Complete blocks are
+// flushed to the backend. If the handoff fails the lifecycler falls back to Flush.
+func (i *Ingester) TransferOut(ctx context.Context) error {
+	if i.cfg.MaxTransferRetries <= 0 {
+		return ring.ErrTransferDisabled
+	}
+
+	// the ingester is leaving the ring, nothing new should be accepted while its data is handed off
+	i.stopIncomingRequests()
+
+	b := backoff.New(ctx, backoff.Config{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+		MaxRetries: i.cfg.MaxTransferRetries,
+	})
+
+	for b.Ongoing() {
+		err := i.transferOut(ctx)
+		if err == nil {
+			metricTransfersSent.WithLabelValues("success").Inc()
+			return nil
+		}
+
+		level.Error(log.Logger).Log("msg", "handoff to pending ingester failed", "attempt", b.NumRetries()+1, "err", err)
+		metricTransfersSent.WithLabelValues("failure").Inc()
+		b.Wait()
+	}
+
+	return b.Err()
+}
+
+func (i *Ingester) transferOut(ctx context.Context) error {
+	target, err := i.findTransferTarget(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot find ingester to transfer to: %w", err)
+	}
+
+	level.Info(log.Logger).Log("msg", "handing off to pending ingester", "to", target.Addr)
+
+	c, err := i.handoffClientFactory(target.Addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// the org id is required by the client middleware but not by the server, every request carries its tenant
+	ctx = user.InjectOrgID(ctx, i.lifecycler.ID)
+	stream, err := c.Transfer(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transfer: %w", err)
+	}
+
+	instances := i.getInstances()
+	sent := false
+	send := func(req *tempopb.TransferRequest) error {
+		req.FromIngesterID = i.lifecycler.ID
+		sent = true
+		metricTransferTraces.WithLabelValues("sent").Add(float64(len(req.Request.Ids)))
+		return stream.Send(req)
+	}
+
+	for _, inst := range instances {
+		err = inst.transferOut(ctx, send)
+		if err != nil {
+			return fmt.Errorf("error transferring tenant %s: %w", inst.instanceID, err)
+		}
+	}
+
+	// the receiver needs to know who it takes over from even if there is no data
+	if !sent {
+		err = stream.Send(&tempopb.TransferRequest{FromIngesterID: i.lifecycler.ID, Request: &tempopb.PushBytesRequest{}})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("error finishing transfer: %w", err)
+	}
+
+	for _, inst := range instances {
+		err = inst.clearTransferred()
+		if err != nil {
+			// the data was handed off, a failure here only leaves files to be replayed on the next start
+			level.Error(log.Logger).Log("msg", "error clearing transferred data", "tenant", inst.instanceID, "err", err)
+		}
+
+		// complete blocks are already cut and are flushed as usual
+		for _, b := range inst.unflushedBlocks() {
+			_, err = i.handleFlush(ctx, inst.instanceID, b.BlockMeta().BlockID)
+			if err != nil {
+				level.Error(log.Logger).Log("msg", "error flushing complete block after handoff", "tenant", inst.instanceID, "block", b.BlockMeta().BlockID, "err", err)
+			}
+		}
+	}
+
+	level.Info(log.Logger).Log("msg", "handoff to pending ingester complete", "to", target.Addr)
+	return nil
+}
+
+// findTransferTarget returns a random pending ingester from the ring
+func (i *Ingester) findTransferTarget(ctx context.Context) (*ring.InstanceDesc, error) {
+	desc, err := i.lifecycler.KVStore.Get(ctx, i.lifecycler.RingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := ring.GetOrCreateRingDesc(desc).FindIngestersByState(ring.PENDING)
+	if len(pending) == 0 {
+		return nil, errors.New("no pending ingesters")
+	}
+
+	return &pending[rand.Intn(len(pending))], nil
+}
+
+// Transfer implements tempopb.HandoffServer. It receives the data of a leaving ingester and takes over its tokens.
+// Only pending ingesters accept transfers.
+func (i *Ingester) Transfer(stream tempopb.Handoff_TransferServer) (err error) {
+	ctx := stream.Context()
+	fromIngesterID := ""
+	traces := 0
+
+	// the received traces are pushed as they arrive. only their ids are kept to discard them if the transfer fails.
+	pushed := map[*instance][][]byte{}
+
+	defer func() {
+		if err == nil {
+			metricTransfersReceived.WithLabelValues("success").Inc()
+			return
+		}
+
+		metricTransfersReceived.WithLabelValues("failure").Inc()
+		for inst, ids := range pushed {
+			inst.discardTransferred(ids)
+		}
+		if fromIngesterID != "" {
+			// allow the next attempt of the leaving ingester
+			if stateErr := i.lifecycler.ChangeState(context.Background(), ring.PENDING); stateErr != nil {
+				level.Error(log.Logger).Log("msg", "failed to return to pending after failed transfer", "err", stateErr)
+			}
+		}
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if fromIngesterID == "" {
+			if req.FromIngesterID == "" {
+				return errors.New("transfer request is missing the id of the leaving ingester")
+			}
+
+			if state := i.lifecycler.GetState(); state != ring.PENDING {
+				return fmt.Errorf("ingester is not pending but %s", state)
+			}
+			// transfers bypass all limits, only accept them from ingesters that are actually leaving the ring
+			if err := i.checkLeaving(ctx, req.FromIngesterID); err != nil {
+				return err
+			}
+			err = i.lifecycler.ChangeState(ctx, ring.JOINING)
+			if err != nil {
+				return fmt.Errorf("error changing state to joining: %w", err)
+			}
+
+			fromIngesterID = req.FromIngesterID
+			level.Info(log.Logger).Log("msg", "receiving handoff", "from", fromIngesterID)
+		}
+
+		if req.Request == nil || len(req.Request.Ids) == 0 {
+			continue
+		}
+
+		inst, err := i.getOrCreateInstance(req.TenantID)
+		if err != nil {
+			return err
+		}
+
+		// ids are recorded first so that a partially pushed request is discarded too
+		for _, id := range req.Request.Ids {
+			pushed[inst] = append(pushed[inst], append([]byte(nil), id.Slice...))
+		}
+		err = inst.transferIn(ctx, req.Request)
+		if err != nil {
+			return fmt.Errorf("error pushing transferred traces of tenant %s: %w", inst.instanceID, err)
+		}
+		traces += len(req.Request.Ids)
+	}
+
+	if fromIngesterID == "" {
+		return errors.New("empty transfer")
+	}
+
+	err = i.lifecycler.ClaimTokensFor(ctx, fromIngesterID)
+	if err != nil {
+		return fmt.Errorf("error claiming tokens of %s: %w", fromIngesterID, err)
+	}
+
+	err = i.lifecycler.ChangeState(ctx, ring.ACTIVE)
+	if err != nil {
+		return fmt.Errorf("error changing state to active: %w", err)
+	}
+
+	metricTransferTraces.WithLabelValues("received").Add(float64(traces))
+	level.Info(log.Logger).Log("msg", "handoff received", "from", fromIngesterID, "traces", traces)
+
+	return stream.SendAndClose(&tempopb.PushResponse{})
+}
+
+// checkLeaving returns an error unless the given ingester is in the ring and LEAVING
+func (i *Ingester) checkLeaving(ctx context.Context, ingesterID string) error {
+	desc, err := i.lifecycler.KVStore.Get(ctx, i.lifecycler.RingKey)
+	if err != nil {
+		return fmt.Errorf("error reading ring: %w", err)
+	}
+
+	inst, ok := ring.GetOrCreateRingDesc(desc).Ingesters[ingesterID]
+	if !ok {
+		return fmt.Errorf("ingester %s is not in the ring", ingesterID)
+	}
+	if inst.State != ring.LEAVING {
+		return fmt.Errorf("ingester %s is not leaving but %s", ingesterID, inst.State)
+	}
+	return nil
+}
+
+// transferOut sends the live traces of the instance followed by the traces of its head and completing blocks. The
+// head block is cut first so the instance only holds completing blocks afterwards.
+func (i *instance) transferOut(ctx context.Context, send func(*tempopb.TransferRequest) error) error {
+	_, err := i.CutBlockIfReady(0, 0, true)
+	if err != nil {
+		return err
+	}
+
+	i.tracesMtx.Lock()
+	traces := make([]*liveTrace, 0, len(i.traces))
+	for _, t := range i.traces {
+		traces = append(traces, t)
+	}
+	i.tracesMtx.Unlock()
+
+	// live traces are sent as they were pushed so they stay whole on the receiver
+	for _, t := range traces {
+		req := &tempopb.PushBytesRequest{}
+		for j, b := range t.batches {
+			req.Ids = append(req.Ids, tempopb.PreallocBytes{Slice: t.traceID})
+			req.Traces = append(req.Traces, tempopb.PreallocBytes{Slice: b})
+
+			var searchData []byte
+			if j < len(t.searchData) {
+				searchData = t.searchData[j]
+			}
+			req.SearchData = append(req.SearchData, tempopb.PreallocBytes{Slice: searchData})
+		}
+
+		err = send(&tempopb.TransferRequest{TenantID: i.instanceID, Request: req})
+		if err != nil {
+			return err
+		}
+	}
+
+	i.blocksMtx.RLock()
+	blocks := make([]common.WALBlock, len(i.completingBlocks))
+	copy(blocks, i.completingBlocks)
+	i.blocksMtx.RUnlock()
+
+	for _, b := range blocks {
+		err = transferWALBlock(ctx, i.instanceID, b, send)
+		if err != nil {
+			return fmt.Errorf("error transferring wal block %s: %w", b.BlockMeta().BlockID, err)
+		}
+	}
+
+	return nil
+}
+
+// transferWALBlock sends the traces of the block re-encoded as segments
+func transferWALBlock(ctx context.Context, tenantID string, b common.WALBlock, send func(*tempopb.TransferRequest) error) error {
+	iter, err := b.Iterator()
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	dec := model.MustNewSegmentDecoder(model.CurrentEncoding)
+	req := &tempopb.PushBytesRequest{}
+
+	flush := func() error {
+		if len(req.Ids) == 0 {
+			return nil
+		}
+		err := send(&tempopb.TransferRequest{TenantID: tenantID, Request: req})
+		req = &tempopb.PushBytesRequest{}
+		return err
+	}
+
+	for {
+		id, tr, err := iter.Next(ctx)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if id == nil {
+			break
+		}
+
+		start, end := traceRangeSeconds(tr)
+		segment, err := dec.PrepareForWrite(tr, start, end)
+		if err != nil {
+			return err
+		}
+
+		req.Ids = append(req.Ids, tempopb.PreallocBytes{Slice: id})
+		req.Traces = append(req.Traces, tempopb.PreallocBytes{Slice: segment})
+
+		if len(req.Ids) >= maxTransferTraces {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// transferIn pushes transferred traces. No limits are applied, the traces were already accepted by the leaving
+// ingester.
+func (i *instance) transferIn(ctx context.Context, req *tempopb.PushBytesRequest) error {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	for j := range req.Traces {
+		var searchData []byte
+		if len(req.SearchData) > j && len(req.SearchData[j].Slice) > 0 {
+			searchData = req.SearchData[j].Slice
+		}
+
+		i.measureReceivedBytes(req.Traces[j].Slice, searchData)
+		id := req.Ids[j].Slice
+		err := i.pushTrace(ctx, id, i.tokenForTraceID(id), req.Traces[j].Slice, searchData, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardTransferred drops the live traces of a failed transfer. The leaving ingester flushes them instead.
+func (i *instance) discardTransferred(ids [][]byte) {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	for _, id := range ids {
+		tkn := i.tokenForTraceID(id)
+		if t, ok := i.traces[tkn]; ok {
+			delete(i.traces, tkn)
+			delete(i.traceSizes, tkn)
+			i.liveBytes -= t.currentBytes
+		}
+	}
+	i.traceCount.Store(int32(len(i.traces)))
+}
+
+// clearTransferred drops the live traces and clears the completing blocks after a successful handoff
+func (i *instance) clearTransferred() error {
+	i.tracesMtx.Lock()
+	i.traces = map[uint32]*liveTrace{}
+	i.traceCount.Store(0)
+	i.liveBytes = 0
+	i.tracesMtx.Unlock()
+
+	i.blocksMtx.RLock()
+	ids := make([]uuid.UUID, 0, len(i.completingBlocks))
+	for _, b := range i.completingBlocks {
+		ids = append(ids, b.BlockMeta().BlockID)
+	}
+	i.blocksMtx.RUnlock()
+
+	for _, id := range ids {
+		if err := i.ClearCompletingBlock(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unflushedBlocks returns the complete blocks that have not been flushed to the backend yet
+func (i *instance) unflushedBlocks() []*localBlock {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	var blocks []*localBlock
+	for _, b := range i.completeBlocks {
+		if b.FlushedTime().IsZero() {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// traceRangeSeconds returns the start and end of the spans of the trace in unix seconds
+func traceRangeSeconds(tr *tempopb.Trace) (uint32, uint32) {
+	var start, end uint64
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if start == 0 || s.StartTimeUnixNano < start {
+					start = s.StartTimeUnixNano
+				}
+				if s.EndTimeUnixNano > end {
+					end = s.EndTimeUnixNano
+				}
+			}
+		}
+	}
+	return uint32(start / uint64(time.Second)), uint32(end / uint64(time.Second))
+}
+
+// handoffClient is the part of the ingester client used to hand off to another ingester
+type handoffClient interface {
+	tempopb.HandoffClient
+	io.Closer
+}
+
+func newHandoffClientFactory(cfg client.Config) func(addr string) (handoffClient, error) {
+	return func(addr string) (handoffClient, error) {
+		return client.New(addr, cfg)
+	}
+}
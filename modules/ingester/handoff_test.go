@@ -0,0 +1,219 @@
+package ingester
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestTransferOut(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	kv, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	// the joining ingester waits for a claim and serves the handoff over grpc
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	joinerCfg := defaultIngesterTestConfig()
+	joinerCfg.LifecyclerConfig.RingConfig.KVStore.Mock = kv
+	joinerCfg.LifecyclerConfig.ID = "joiner"
+	joinerCfg.LifecyclerConfig.Addr = "127.0.0.1"
+	joinerCfg.LifecyclerConfig.Port = lis.Addr().(*net.TCPAddr).Port
+	joinerCfg.LifecyclerConfig.JoinAfter = time.Hour
+	joiner := ingesterModuleWithConfig(t, t.TempDir(), joinerCfg)
+
+	srv := grpc.NewServer()
+	tempopb.RegisterHandoffServer(srv, joiner)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	leaverCfg := defaultIngesterTestConfig()
+	leaverCfg.LifecyclerConfig.RingConfig.KVStore.Mock = kv
+	leaverCfg.LifecyclerConfig.ID = "leaver"
+	leaverCfg.MaxTransferRetries = 3
+	flagext.DefaultValues(&leaverCfg.IngesterClient)
+	leaver := ingesterModuleWithConfig(t, t.TempDir(), leaverCfg)
+	require.Eventually(t, func() bool { return leaver.lifecycler.GetState() == ring.ACTIVE }, 5*time.Second, 10*time.Millisecond)
+	leaverTokens := ringTokens(t, kv, leaver.lifecycler.RingKey, "leaver")
+	require.Len(t, leaverTokens, 1)
+
+	// half of the traces are cut into the head block, the other half stay live
+	traces := make([]*tempopb.Trace, 0, 10)
+	ids := make([][]byte, 0, 10)
+	for j := 0; j < 10; j++ {
+		id := test.ValidTraceID(nil)
+		tr := test.MakeTrace(5, id)
+		for _, b := range tr.Batches {
+			pushBatchV2(t, leaver, b, id)
+		}
+		traces = append(traces, tr)
+		ids = append(ids, id)
+
+		if j == 4 {
+			inst, err := leaver.getOrCreateInstance("test")
+			require.NoError(t, err)
+			require.NoError(t, inst.CutCompleteTraces(0, true))
+		}
+	}
+
+	// stopping the lifecycler hands off instead of flushing
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), leaver.lifecycler))
+
+	inst, err := leaver.getOrCreateInstance("test")
+	require.NoError(t, err)
+	require.Empty(t, inst.traces)
+	require.Empty(t, inst.completingBlocks)
+
+	require.Equal(t, ring.ACTIVE, joiner.lifecycler.GetState())
+	require.Equal(t, leaverTokens, ringTokens(t, kv, joiner.lifecycler.RingKey, "joiner"))
+
+	for j, id := range ids {
+		resp, err := joiner.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: id})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Trace)
+		require.Equal(t, len(traces[j].Batches), len(resp.Trace.Batches))
+	}
+}
+
+func TestTransferOutDisabled(t *testing.T) {
+	i := defaultIngesterModule(t, t.TempDir())
+	require.Equal(t, ring.ErrTransferDisabled, i.TransferOut(context.Background()))
+}
+
+func TestTransferRequiresPending(t *testing.T) {
+	// the default test ingester joins the ring immediately and must refuse handoffs
+	i := defaultIngesterModule(t, t.TempDir())
+	require.Eventually(t, func() bool { return i.lifecycler.GetState() == ring.ACTIVE }, 5*time.Second, 10*time.Millisecond)
+
+	ch := make(chan *tempopb.TransferRequest, 1)
+	ch <- &tempopb.TransferRequest{FromIngesterID: "leaver", Request: &tempopb.PushBytesRequest{}}
+	close(ch)
+
+	require.Error(t, i.Transfer(&mockTransferServer{ch: ch}))
+}
+
+func TestTransferInIgnoresLimits(t *testing.T) {
+	ingester := defaultIngesterModule(t, t.TempDir())
+
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxLiveTracesBytes: 100,
+		MaxHeadBlockBytes:  100,
+		MaxBytesPerTrace:   100,
+	})
+	require.NoError(t, err)
+	i, err := newInstance(testTenantID, NewLimiter(limits, &ringCountMock{count: 1}, 1), ingester.store, ingester.local, false)
+	require.NoError(t, err)
+
+	req := makeRequestWithByteLimit(1000, nil)
+	require.Error(t, i.PushBytesRequest(context.Background(), req))
+
+	// the traces were already accepted by the leaving ingester
+	require.NoError(t, i.transferIn(context.Background(), req))
+	require.Len(t, i.traces, 1)
+	require.Greater(t, i.liveBytes, 100)
+}
+
+func TestTransferFailureDiscardsTraces(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = time.Hour
+	i := ingesterModuleWithConfig(t, t.TempDir(), cfg)
+	require.Eventually(t, func() bool { return i.lifecycler.GetState() == ring.PENDING }, 5*time.Second, 10*time.Millisecond)
+
+	addRingInstance(t, i, "leaver", ring.LEAVING)
+
+	// the second request holds a corrupt segment and fails after the first one was pushed
+	corrupt := makeRequestWithByteLimit(100, nil)
+	corrupt.Traces[0].Slice = []byte{0x01}
+
+	ch := make(chan *tempopb.TransferRequest, 2)
+	ch <- &tempopb.TransferRequest{FromIngesterID: "leaver", TenantID: testTenantID, Request: makeRequestWithByteLimit(100, nil)}
+	ch <- &tempopb.TransferRequest{FromIngesterID: "leaver", TenantID: testTenantID, Request: corrupt}
+	close(ch)
+
+	require.Error(t, i.Transfer(&mockTransferServer{ch: ch}))
+
+	inst, ok := i.getInstanceByID(testTenantID)
+	require.True(t, ok)
+	require.Empty(t, inst.traces)
+	require.Equal(t, 0, inst.liveBytes)
+	require.Equal(t, ring.PENDING, i.lifecycler.GetState())
+}
+
+func TestTransferRequiresLeavingSender(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = time.Hour
+	i := ingesterModuleWithConfig(t, t.TempDir(), cfg)
+	require.Eventually(t, func() bool { return i.lifecycler.GetState() == ring.PENDING }, 5*time.Second, 10*time.Millisecond)
+
+	transfer := func(from string) error {
+		ch := make(chan *tempopb.TransferRequest, 1)
+		ch <- &tempopb.TransferRequest{FromIngesterID: from, TenantID: testTenantID, Request: makeRequestWithByteLimit(100, nil)}
+		close(ch)
+		return i.Transfer(&mockTransferServer{ch: ch})
+	}
+
+	// unknown and active ingesters can't push data
+	require.Error(t, transfer("unknown"))
+	addRingInstance(t, i, "active", ring.ACTIVE)
+	require.Error(t, transfer("active"))
+
+	_, ok := i.getInstanceByID(testTenantID)
+	require.False(t, ok)
+	require.Equal(t, ring.PENDING, i.lifecycler.GetState())
+}
+
+// addRingInstance adds an ingester with the given state to the ring of i
+func addRingInstance(t *testing.T, i *Ingester, id string, state ring.InstanceState) {
+	err := i.lifecycler.KVStore.CAS(context.Background(), i.lifecycler.RingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.GetOrCreateRingDesc(in)
+		desc.AddIngester(id, "127.0.0.1", "", []uint32{1}, state, time.Now())
+		return desc, true, nil
+	})
+	require.NoError(t, err)
+}
+
+func ringTokens(t *testing.T, kv *consul.Client, key, id string) []uint32 {
+	desc, err := kv.Get(context.Background(), key)
+	require.NoError(t, err)
+
+	tokens, _ := ring.GetOrCreateRingDesc(desc).TokensFor(id)
+	return tokens
+}
+
+type mockTransferServer struct {
+	grpc.ServerStream
+	ch chan *tempopb.TransferRequest
+}
+
+func (m *mockTransferServer) Context() context.Context {
+	return context.Background()
+}
+
+func (m *mockTransferServer) Recv() (*tempopb.TransferRequest, error) {
+	req, ok := <-m.ch
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func (m *mockTransferServer) SendAndClose(*tempopb.PushResponse) error {
+	return nil
+}
@@ -6,8 +6,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/grafana/tempo/tempodb/search"
-
 	"github.com/go-kit/log/level"
 	"github.com/gogo/status"
 	"github.com/grafana/dskit/ring"
@@ -30,7 +28,6 @@ import (
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/log"
 	"github.com/grafana/tempo/pkg/validation"
-	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 )
 
@@ -68,6 +65,18 @@ type Ingester struct {
 
 	limiter *Limiter
 
+	replay       *replayProgress
+	replayCancel context.CancelFunc
+	replayDone   chan struct{}
+
+	// replicas is set if replica aware flushing is enabled
+	replicas *replicaFlusher
+
+	// traceIdle is set if trace completeness detection is enabled
+	traceIdle *traceIdlePeriods
+
+	handoffClientFactory func(addr string) (handoffClient, error)
+
 	subservicesWatcher *services.FailureWatcher
 }
 
@@ -79,6 +88,9 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides, reg prome
 		store:        store,
 		flushQueues:  flushqueues.New(cfg.ConcurrentFlushes, metricFlushQueueLength),
 		replayJitter: true,
+		replay:       &replayProgress{},
+
+		handoffClientFactory: newHandoffClientFactory(cfg.IngesterClient),
 	}
 
 	i.local = store.WAL().LocalBackend()
@@ -98,6 +110,17 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides, reg prome
 	// which depends on it.
 	i.limiter = NewLimiter(limits, i.lifecycler, cfg.LifecyclerConfig.RingConfig.ReplicationFactor)
 
+	if cfg.ReplicaAwareFlushing {
+		i.replicas = newReplicaFlusher(i.lifecycler, cfg.LifecyclerConfig.RingConfig.HeartbeatTimeout)
+	}
+
+	if cfg.TraceCompletenessDetection {
+		i.traceIdle = &traceIdlePeriods{
+			complete:       cfg.CompleteTraceIdle,
+			missingParents: cfg.MissingParentsTraceIdle,
+		}
+	}
+
 	i.subservicesWatcher = services.NewFailureWatcher()
 	i.subservicesWatcher.WatchService(i.lifecycler)
 
@@ -106,17 +129,16 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides, reg prome
 }
 
 func (i *Ingester) starting(ctx context.Context) error {
-	err := i.replayWal()
+	// the ids of the wal blocks are listed up front so local blocks can be rediscovered while the wal is replayed
+	walBlockIDs, err := i.store.WAL().BlockIDs()
 	if err != nil {
-		return fmt.Errorf("failed to replay wal: %w", err)
+		return fmt.Errorf("failed to list wal blocks: %w", err)
 	}
 
-	err = i.rediscoverLocalBlocks()
-	if err != nil {
-		return fmt.Errorf("failed to rediscover local blocks: %w", err)
-	}
+	// replay in the background, new traces are accepted while old blocks are replayed
+	i.startReplay(walBlockIDs)
 
-	// Now that user states have been created, we can start the lifecycler.
+	// Start the lifecycler.
 	// Important: we want to keep lifecycler running until we ask it to stop, so we need to give it independent context
 	if err := i.lifecycler.StartAsync(context.Background()); err != nil {
 		return fmt.Errorf("failed to start lifecycler: %w", err)
@@ -132,11 +154,20 @@ func (i *Ingester) loop(ctx context.Context) error {
 	flushTicker := time.NewTicker(i.cfg.FlushCheckPeriod)
 	defer flushTicker.Stop()
 
+	replayDone := i.replayDone
+
 	for {
 		select {
 		case <-flushTicker.C:
 			i.sweepAllInstances(false)
 
+		case <-replayDone:
+			// an ingester that failed to replay its data must not keep running in the ring
+			if err := i.replay.err.Load(); err != nil {
+				return fmt.Errorf("ingester replay failed: %w", err)
+			}
+			replayDone = nil
+
 		case <-ctx.Done():
 			return nil
 
@@ -150,6 +181,9 @@ func (i *Ingester) loop(ctx context.Context) error {
 func (i *Ingester) stopping(_ error) error {
 	i.markUnavailable()
 
+	// unreplayed wal blocks stay on disk and are replayed on the next start
+	i.stopReplay()
+
 	if i.flushQueues != nil {
 		i.flushQueues.Stop()
 		i.flushQueuesDone.Wait()
@@ -268,6 +302,10 @@ func (i *Ingester) CheckReady(ctx context.Context) error {
 		return fmt.Errorf("ingester check ready failed %w", err)
 	}
 
+	if err := i.replay.checkReady(); err != nil {
+		return fmt.Errorf("ingester check ready failed %w", err)
+	}
+
 	return nil
 }
 
@@ -286,6 +324,8 @@ func (i *Ingester) getOrCreateInstance(instanceID string) (*instance, error) {
 		if err != nil {
 			return nil, err
 		}
+		inst.replicas = i.replicas
+		inst.traceIdle = i.traceIdle
 		i.instances[instanceID] = inst
 	}
 	return inst, nil
@@ -317,121 +357,3 @@ func (i *Ingester) stopIncomingRequests() {
 
 	i.readonly = true
 }
-
-// TransferOut implements ring.Lifecycler.
-func (i *Ingester) TransferOut(ctx context.Context) error {
-	return ring.ErrTransferDisabled
-}
-
-func (i *Ingester) replayWal() error {
-	level.Info(log.Logger).Log("msg", "beginning wal replay")
-
-	// pass i.cfg.MaxBlockDuration into RescanBlocks to make an attempt to set the start time
-	// of the blocks correctly. as we are scanning traces in the blocks we read their start/end times
-	// and attempt to set start/end times appropriately. we use now - max_block_duration - ingestion_slack
-	// as the minimum acceptable start time for a replayed block.
-	blocks, err := i.store.WAL().RescanBlocks(i.cfg.MaxBlockDuration, log.Logger)
-	if err != nil {
-		return fmt.Errorf("fatal error replaying wal: %w", err)
-	}
-
-	searchBlocks, err := search.RescanBlocks(i.store.WAL().GetFilepath())
-	if err != nil {
-		return fmt.Errorf("fatal error replaying search wal: %w", err)
-	}
-
-	// clear any searchBlock that does not have a matching wal block
-	for j := len(searchBlocks) - 1; j >= 0; j-- {
-		clear := true
-		for _, tracesBlock := range blocks {
-			if searchBlocks[j].BlockID() == tracesBlock.BlockMeta().BlockID {
-				clear = false
-				break
-			}
-		}
-
-		if clear {
-			err := searchBlocks[j].Clear()
-			if err != nil { // just log the error
-				level.Warn(log.Logger).Log("msg", "error clearing search WAL file", "blockID", searchBlocks[j].BlockID, "err", err)
-			}
-			searchBlocks = append(searchBlocks[:j], searchBlocks[j+1:]...)
-		}
-	}
-
-	for _, b := range blocks {
-		tenantID := b.BlockMeta().TenantID
-
-		instance, err := i.getOrCreateInstance(tenantID)
-		if err != nil {
-			return err
-		}
-
-		// Delete anything remaining for the completed version of this
-		// wal block. This handles the case where a wal file is partially
-		// or fully completed to the local store, but the wal file wasn't
-		// deleted (because it was rescanned above). This can happen for reasons
-		// such as a crash or restart. In this situation we err on the side of
-		// caution and replay the wal block.
-		err = instance.local.ClearBlock(b.BlockMeta().BlockID, tenantID)
-		if err != nil {
-			return err
-		}
-
-		var searchWALBlock *search.StreamingSearchBlock
-		for _, s := range searchBlocks {
-			if b.BlockMeta().BlockID == s.BlockID() {
-				searchWALBlock = s
-				break
-			}
-		}
-		instance.AddCompletingBlock(b, searchWALBlock)
-
-		i.enqueue(&flushOp{
-			kind:    opKindComplete,
-			userID:  tenantID,
-			blockID: b.BlockMeta().BlockID,
-		}, i.replayJitter)
-	}
-
-	level.Info(log.Logger).Log("msg", "wal replay complete")
-
-	return nil
-}
-
-func (i *Ingester) rediscoverLocalBlocks() error {
-	ctx := context.TODO()
-
-	reader := backend.NewReader(i.local)
-	tenants, err := reader.Tenants(ctx)
-	if err != nil {
-		return errors.Wrap(err, "getting local tenants")
-	}
-
-	level.Info(log.Logger).Log("msg", "reloading local blocks", "tenants", len(tenants))
-
-	for _, t := range tenants {
-		inst, err := i.getOrCreateInstance(t)
-		if err != nil {
-			return err
-		}
-
-		newBlocks, err := inst.rediscoverLocalBlocks(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "getting local blocks for tenant %v", t)
-		}
-
-		// Requeue needed flushes
-		for _, b := range newBlocks {
-			if b.FlushedTime().IsZero() {
-				i.enqueue(&flushOp{
-					kind:    opKindFlush,
-					userID:  t,
-					blockID: b.BlockMeta().BlockID,
-				}, i.replayJitter)
-			}
-		}
-	}
-
-	return nil
-}
@@ -2,6 +2,7 @@ package ingester
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"os"
 	"testing"
@@ -146,24 +147,26 @@ func TestWal(t *testing.T) {
 	// create new ingester.  this should replay wal!
 	ingester, _, _ = defaultIngester(t, tmpDir)
 
-	// should be able to find old traces that were replayed
+	// should be able to find old traces that were replayed. the replayed block may be completed concurrently
 	for i, traceID := range traceIDs {
 		foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
 			TraceID: traceID,
 		})
 		require.NoError(t, err, "unexpected error querying")
+
+		trace.SortTrace(foundTrace.Trace)
 		equal := proto.Equal(traces[i], foundTrace.Trace)
 		require.True(t, equal)
 	}
 
 	// a block that has been replayed should have a flush queue entry to complete it
-	// wait for the flush queues to be empty and then confirm there is a complete block
-	for !ingester.flushQueues.IsEmpty() {
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	require.Len(t, ingester.instances["test"].completingBlocks, 0)
-	require.Len(t, ingester.instances["test"].completeBlocks, 1)
+	// wait for the block to be completed. the flush op is enqueued as the replay finishes
+	inst := ingester.instances["test"]
+	require.Eventually(t, func() bool {
+		inst.blocksMtx.RLock()
+		defer inst.blocksMtx.RUnlock()
+		return len(inst.completingBlocks) == 0 && len(inst.completeBlocks) == 1
+	}, 10*time.Second, 100*time.Millisecond)
 
 	// should be able to find old traces that were replayed
 	for i, traceID := range traceIDs {
@@ -178,6 +181,131 @@ func TestWal(t *testing.T) {
 	}
 }
 
+func TestReplayProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, traces, traceIDs := defaultIngester(t, tmpDir)
+
+	// cut the pushed traces and a few more into separate wal blocks
+	inst := ingester.instances["test"]
+	blocks := 5
+	for b := 0; b < blocks; b++ {
+		if b > 0 {
+			id := make([]byte, 16)
+			_, err := rand.Read(id)
+			require.NoError(t, err)
+			err = inst.PushBytesRequest(ctx, makeRequest(id))
+			require.NoError(t, err)
+		}
+		err := inst.CutCompleteTraces(0, true)
+		require.NoError(t, err)
+		_, err = inst.CutBlockIfReady(0, 0, true)
+		require.NoError(t, err)
+	}
+
+	cfg := defaultIngesterTestConfig()
+	cfg.ReplayConcurrency = 4
+	ingester = ingesterModuleWithConfig(t, tmpDir, cfg)
+
+	require.NoError(t, ingester.replay.checkReady())
+	require.Equal(t, int32(blocks), ingester.replay.walBlocks.Load())
+	require.Equal(t, ingester.replay.walBlocks.Load(), ingester.replay.walReplayed.Load())
+
+	// every replayed block is completed
+	inst = ingester.instances["test"]
+	require.Eventually(t, func() bool {
+		inst.blocksMtx.RLock()
+		defer inst.blocksMtx.RUnlock()
+		return len(inst.completingBlocks) == 0 && len(inst.completeBlocks) == blocks
+	}, 10*time.Second, 100*time.Millisecond)
+
+	for i, traceID := range traceIDs {
+		foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+			TraceID: traceID,
+		})
+		require.NoError(t, err, "unexpected error querying")
+
+		trace.SortTrace(foundTrace.Trace)
+		require.True(t, proto.Equal(traces[i], foundTrace.Trace))
+	}
+}
+
+func TestReplayWithPushes(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	pushWithSearchData := func(inst *instance) {
+		id := make([]byte, 16)
+		_, err := rand.Read(id)
+		require.NoError(t, err)
+		traceBytes, err := test.MakeTrace(10, id).Marshal()
+		require.NoError(t, err)
+		entry := &tempofb.SearchEntryMutable{TraceID: id}
+		entry.AddTag("foo", "bar")
+
+		require.NoError(t, inst.PushBytes(context.Background(), id, traceBytes, entry.ToBytes()))
+		require.NoError(t, inst.CutCompleteTraces(0, true))
+	}
+
+	// write a wal block with search data
+	ingester := defaultIngesterModule(t, tmpDir)
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+	pushWithSearchData(inst)
+
+	// the wal block ids are listed on start, the replay runs in the background while pushes are accepted. a push
+	// creates a new head block with its own search wal file before the search wal is rescanned
+	ingester = newIngesterWithConfig(t, tmpDir, defaultIngesterTestConfig())
+	walBlockIDs, err := ingester.store.WAL().BlockIDs()
+	require.NoError(t, err)
+	require.Len(t, walBlockIDs, 1)
+
+	inst, err = ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+	pushWithSearchData(inst)
+
+	ingester.startReplay(walBlockIDs)
+	require.NoError(t, ingester.waitReplay(context.Background()))
+
+	// the search wal file of the new head block is kept and replayed on the next start
+	pushWithSearchData(inst)
+	ingester = defaultIngesterModule(t, tmpDir)
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+
+	results, err := inst.Search(ctx, &tempopb.SearchRequest{Tags: map[string]string{"foo": "bar"}})
+	require.NoError(t, err)
+	require.Len(t, results.Traces, 3)
+}
+
+func TestReplayProgressCheckReady(t *testing.T) {
+	p := &replayProgress{}
+	p.setBlocks(replayKindWAL, 3)
+	p.setBlocks(replayKindLocal, 2)
+	p.blockReplayed(replayKindWAL)
+
+	require.EqualError(t, p.checkReady(), "replay in progress: 1/3 wal blocks and 0/2 local blocks replayed")
+
+	p.done.Store(true)
+	require.NoError(t, p.checkReady())
+
+	p.err.Store(errors.New("oops"))
+	require.EqualError(t, p.checkReady(), "replay failed: oops")
+}
+
+func TestReplayFailureFailsIngester(t *testing.T) {
+	ingester := newIngesterWithConfig(t, t.TempDir(), defaultIngesterTestConfig())
+
+	ingester.replayDone = make(chan struct{})
+	ingester.replay.err.Store(errors.New("oops"))
+	close(ingester.replayDone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.EqualError(t, ingester.loop(ctx), "ingester replay failed: oops")
+}
+
 func TestSearchWAL(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("/tmp", "")
 	require.NoError(t, err, "unexpected error getting tempdir")
@@ -313,7 +441,23 @@ func TestFlush(t *testing.T) {
 }
 
 func defaultIngesterModule(t testing.TB, tmpDir string) *Ingester {
-	ingesterConfig := defaultIngesterTestConfig()
+	return ingesterModuleWithConfig(t, tmpDir, defaultIngesterTestConfig())
+}
+
+func ingesterModuleWithConfig(t testing.TB, tmpDir string, ingesterConfig Config) *Ingester {
+	ingester := newIngesterWithConfig(t, tmpDir, ingesterConfig)
+
+	err := ingester.starting(context.Background())
+	require.NoError(t, err, "unexpected error starting ingester")
+
+	err = ingester.waitReplay(context.Background())
+	require.NoError(t, err, "unexpected error replaying")
+
+	return ingester
+}
+
+// newIngesterWithConfig creates an ingester without starting it
+func newIngesterWithConfig(t testing.TB, tmpDir string, ingesterConfig Config) *Ingester {
 	limits, err := overrides.NewOverrides(defaultLimitsTestConfig())
 	require.NoError(t, err, "unexpected error creating overrides")
 
@@ -335,16 +479,13 @@ func defaultIngesterModule(t testing.TB, tmpDir string) *Ingester {
 				Filepath: tmpDir,
 			},
 		},
-	}, log.NewNopLogger())
+	}, nil, log.NewNopLogger())
 	require.NoError(t, err, "unexpected error store")
 
 	ingester, err := New(ingesterConfig, s, limits, prometheus.NewPedanticRegistry())
 	require.NoError(t, err, "unexpected error creating ingester")
 	ingester.replayJitter = false
 
-	err = ingester.starting(context.Background())
-	require.NoError(t, err, "unexpected error starting ingester")
-
 	return ingester
 }
 
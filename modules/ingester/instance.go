@@ -76,6 +76,16 @@ var (
 		Name:      "ingester_live_traces",
 		Help:      "The current number of lives traces per tenant.",
 	}, []string{"tenant"})
+	metricLiveTracesBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_live_traces_bytes",
+		Help:      "The current total size of live traces in bytes per tenant.",
+	}, []string{"tenant"})
+	metricHeadBlockBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_head_block_bytes",
+		Help:      "The current size of the head block in bytes per tenant.",
+	}, []string{"tenant"})
 	metricBlocksClearedTotal = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "ingester_blocks_cleared_total",
@@ -91,6 +101,11 @@ var (
 		Name:      "ingester_replay_errors_total",
 		Help:      "The total number of replay errors received per tenant.",
 	}, []string{"tenant"})
+	metricCompleteTracesCutTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_complete_traces_cut_total",
+		Help:      "The total number of traces cut after receiving their root span and all referenced parents per tenant.",
+	}, []string{"tenant"})
 )
 
 type instance struct {
@@ -98,12 +113,21 @@ type instance struct {
 	traces     map[uint32]*liveTrace
 	traceSizes map[uint32]uint32
 	traceCount atomic.Int32
+	liveBytes  int // sum of the current bytes of all live traces, guarded by tracesMtx
+
+	// size of the head block, kept outside of blocksMtx so pushes can check it while holding tracesMtx
+	headBlockBytes atomic.Uint64
 
 	blocksMtx        sync.RWMutex
 	headBlock        common.WALBlock
 	completingBlocks []common.WALBlock
 	completeBlocks   []*localBlock
 
+	// replicas is set with replica aware flushing. retainedBlocks are completing blocks kept until the replicas
+	// owning some of their traces confirm the flush of those traces
+	replicas       *replicaFlusher
+	retainedBlocks map[uuid.UUID]*retainedBlock
+
 	useFlatbufferSearch  bool
 	searchHeadBlock      *searchStreamingBlockEntry
 	searchAppendBlocks   map[string]*searchStreamingBlockEntry // maps append block uuid string -> *searchStreamingBlockEntry
@@ -111,6 +135,9 @@ type instance struct {
 
 	lastBlockCut time.Time
 
+	// traceIdle is set if trace completeness detection is enabled
+	traceIdle *traceIdlePeriods
+
 	instanceID         string
 	tracesCreatedTotal prometheus.Counter
 	bytesReceivedTotal *prometheus.CounterVec
@@ -141,6 +168,7 @@ func newInstance(instanceID string, limiter *Limiter, writer tempodb.Writer, l *
 		searchAppendBlocks:   map[string]*searchStreamingBlockEntry{},
 		searchCompleteBlocks: map[*localBlock]*searchLocalBlockEntry{},
 		useFlatbufferSearch:  useFlatbufferSearch,
+		retainedBlocks:       map[uuid.UUID]*retainedBlock{},
 
 		instanceID:         instanceID,
 		tracesCreatedTotal: metricTracesCreatedTotal.WithLabelValues(instanceID),
@@ -198,17 +226,34 @@ func (i *instance) push(ctx context.Context, id, traceBytes, searchData []byte)
 	i.tracesMtx.Lock()
 	defer i.tracesMtx.Unlock()
 
+	reqSize := len(traceBytes)
+
+	err := i.limiter.AssertMaxLiveTracesBytes(i.instanceID, i.liveBytes, reqSize)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s max live bytes exceeded for tenant %s: %v", overrides.ErrorPrefixLiveBytesExceeded, i.instanceID, err)
+	}
+
+	err = i.limiter.AssertMaxHeadBlockBytes(i.instanceID, int(i.headBlockBytes.Load()))
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s max head block bytes exceeded for tenant %s: %v", overrides.ErrorPrefixHeadBlockBytesExceeded, i.instanceID, err)
+	}
+
 	tkn := i.tokenForTraceID(id)
 	maxBytes := i.limiter.limits.MaxBytesPerTrace(i.instanceID)
 
 	if maxBytes > 0 {
 		prevSize := int(i.traceSizes[tkn])
-		reqSize := len(traceBytes)
 		if prevSize+reqSize > maxBytes {
 			return status.Errorf(codes.FailedPrecondition, (newTraceTooLargeError(id, i.instanceID, maxBytes, reqSize).Error()))
 		}
 	}
 
+	return i.pushTrace(ctx, id, tkn, traceBytes, searchData, maxBytes)
+}
+
+// pushTrace appends the segment to the live trace. A maxBytes of 0 disables the size limit of a new trace. The caller
+// must hold tracesMtx.
+func (i *instance) pushTrace(ctx context.Context, id []byte, tkn uint32, traceBytes, searchData []byte, maxBytes int) error {
 	trace := i.getOrCreateTrace(id, tkn, maxBytes)
 
 	err := trace.Push(ctx, i.instanceID, traceBytes, searchData)
@@ -218,6 +263,7 @@ func (i *instance) push(ctx context.Context, id, traceBytes, searchData []byte)
 		}
 		return err
 	}
+	i.liveBytes += len(traceBytes)
 
 	if maxBytes > 0 {
 		i.traceSizes[tkn] += uint32(len(traceBytes))
@@ -236,6 +282,11 @@ func (i *instance) measureReceivedBytes(traceBytes []byte, searchData []byte) {
 
 // Moves any complete traces out of the map to complete traces.
 func (i *instance) CutCompleteTraces(cutoff time.Duration, immediate bool) error {
+	// the trace idle period of the tenant overrides replaces the one of the ingester config
+	if idle := i.limiter.limits.TraceIdlePeriod(i.instanceID); idle > 0 {
+		cutoff = -idle
+	}
+
 	tracesToCut := i.tracesToCut(cutoff, immediate)
 	segmentDecoder := model.MustNewSegmentDecoder(model.CurrentEncoding)
 
@@ -261,7 +312,8 @@ func (i *instance) CutCompleteTraces(cutoff time.Duration, immediate bool) error
 	return nil
 }
 
-// CutBlockIfReady cuts a completingBlock from the HeadBlock if ready.
+// CutBlockIfReady cuts a completingBlock from the HeadBlock if ready. The max block duration and bytes of the tenant
+// overrides replace the given ones if set.
 // Returns the ID of a block if one was cut or a nil ID if one was not cut, along with the error (if any).
 func (i *instance) CutBlockIfReady(maxBlockLifetime time.Duration, maxBlockBytes uint64, immediate bool) (uuid.UUID, error) {
 	i.blocksMtx.Lock()
@@ -271,6 +323,17 @@ func (i *instance) CutBlockIfReady(maxBlockLifetime time.Duration, maxBlockBytes
 		return uuid.Nil, nil
 	}
 
+	if d := i.limiter.limits.MaxBlockDuration(i.instanceID); d > 0 {
+		maxBlockLifetime = d
+	}
+	if b := i.limiter.limits.MaxBlockBytes(i.instanceID); b > 0 {
+		maxBlockBytes = b
+	}
+	// pushes are refused once the head block reaches the head block bytes limit, so it's cut at that size as well
+	if b := i.limiter.limits.MaxHeadBlockBytes(i.instanceID); b > 0 && uint64(b) < maxBlockBytes {
+		maxBlockBytes = uint64(b)
+	}
+
 	now := time.Now()
 	if i.lastBlockCut.Add(maxBlockLifetime).Before(now) || i.headBlock.DataLength() >= maxBlockBytes || immediate {
 		completingBlock := i.headBlock
@@ -290,6 +353,13 @@ func (i *instance) CutBlockIfReady(maxBlockLifetime time.Duration, maxBlockBytes
 
 // CompleteBlock moves a completingBlock to a completeBlock. The new completeBlock has the same ID.
 func (i *instance) CompleteBlock(blockID uuid.UUID) error {
+	_, err := i.completeBlock(blockID)
+	return err
+}
+
+// completeBlock completes the wal block and returns the id of the resulting complete block. With replica aware
+// flushing the id differs from the wal block for fallback completions and is nil if no complete block was created.
+func (i *instance) completeBlock(blockID uuid.UUID) (uuid.UUID, error) {
 	i.blocksMtx.Lock()
 	var completingBlock common.WALBlock
 	for _, iterBlock := range i.completingBlocks {
@@ -301,14 +371,37 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 	i.blocksMtx.Unlock()
 
 	if completingBlock == nil {
-		return fmt.Errorf("error finding completingBlock")
+		return uuid.Nil, fmt.Errorf("error finding completingBlock")
 	}
 
 	ctx := context.Background()
 
-	backendBlock, err := i.writer.CompleteBlockWithBackend(ctx, completingBlock, i.localReader, i.localWriter)
+	toComplete := completingBlock
+	var completion *replicaCompletion
+	if i.replicas != nil {
+		var err error
+		completion, err = i.prepareReplicaCompletion(ctx, completingBlock)
+		if err != nil {
+			return uuid.Nil, errors.Wrap(err, "error preparing replica aware completion")
+		}
+		if completion != nil {
+			toComplete = completion.block
+		}
+	}
+
+	backendBlock, err := i.writer.CompleteBlockWithBackend(ctx, toComplete, i.localReader, i.localWriter)
 	if err != nil {
-		return errors.Wrap(err, "error completing wal block with local backend")
+		return uuid.Nil, errors.Wrap(err, "error completing wal block with local backend")
+	}
+
+	if completion != nil {
+		keep, err := i.finishReplicaCompletion(ctx, completingBlock, backendBlock, completion)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if !keep {
+			return uuid.Nil, nil
+		}
 	}
 
 	ingesterBlock := newLocalBlock(ctx, backendBlock, i.local)
@@ -317,9 +410,11 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 	i.completeBlocks = append(i.completeBlocks, ingesterBlock)
 	i.blocksMtx.Unlock()
 
+	completeID := backendBlock.BlockMeta().BlockID
+
 	// only build flatbuffer search structures if we are configured to
 	if !i.useFlatbufferSearch {
-		return nil
+		return completeID, nil
 	}
 
 	// Search data (optional)
@@ -331,7 +426,7 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 	if oldSearch != nil {
 		newSearch, err = i.writer.CompleteSearchBlockWithBackend(oldSearch.b, backendBlock.BlockMeta().BlockID, backendBlock.BlockMeta().TenantID, i.localReader, i.localWriter)
 		if err != nil {
-			return err
+			return uuid.Nil, err
 		}
 	}
 
@@ -344,13 +439,15 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 		}
 	}
 
-	return nil
+	return completeID, nil
 }
 
 func (i *instance) ClearCompletingBlock(blockID uuid.UUID) error {
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
 
+	delete(i.retainedBlocks, blockID)
+
 	var completingBlock common.WALBlock
 	for j, iterBlock := range i.completingBlocks {
 		if iterBlock.BlockMeta().BlockID == blockID {
@@ -502,6 +599,9 @@ func (i *instance) getOrCreateTrace(traceID []byte, fp uint32, maxBytes int) *li
 
 	maxSearchBytes := i.limiter.limits.MaxSearchBytesPerTrace(i.instanceID)
 	trace = newTrace(traceID, maxBytes, maxSearchBytes)
+	if i.traceIdle != nil {
+		trace.completeness = newTraceCompleteness()
+	}
 	i.traces[fp] = trace
 	i.tracesCreatedTotal.Inc()
 	i.traceCount.Inc()
@@ -533,6 +633,8 @@ func (i *instance) resetHeadBlock() error {
 
 	i.headBlock = newHeadBlock
 	i.lastBlockCut = time.Now()
+	i.headBlockBytes.Store(0)
+	metricHeadBlockBytes.WithLabelValues(i.instanceID).Set(0)
 
 	// Create search data wal file
 	f, enc, err := i.writer.WAL().NewFile(i.headBlock.BlockMeta().BlockID, i.instanceID, searchDir)
@@ -559,16 +661,27 @@ func (i *instance) tracesToCut(cutoff time.Duration, immediate bool) []*liveTrac
 
 	// Set this before cutting to give a more accurate number.
 	metricLiveTraces.WithLabelValues(i.instanceID).Set(float64(len(i.traces)))
+	metricLiveTracesBytes.WithLabelValues(i.instanceID).Set(float64(i.liveBytes))
 
-	cutoffTime := time.Now().Add(cutoff)
+	now := time.Now()
 	tracesToCut := make([]*liveTrace, 0, len(i.traces))
+	completeCut := 0
 
 	for key, trace := range i.traces {
+		cutoffTime := now.Add(i.traceIdle.cutoff(now, trace, cutoff))
 		if cutoffTime.After(trace.lastAppend) || immediate {
 			tracesToCut = append(tracesToCut, trace)
 			delete(i.traces, key)
+			i.liveBytes -= trace.currentBytes
+
+			if trace.completeness != nil && trace.completeness.complete() {
+				completeCut++
+			}
 		}
 	}
+	if completeCut > 0 {
+		metricCompleteTracesCutTotal.WithLabelValues(i.instanceID).Add(float64(completeCut))
+	}
 	i.traceCount.Store(int32(len(i.traces)))
 
 	return tracesToCut
@@ -583,6 +696,10 @@ func (i *instance) writeTraceToHeadBlock(id common.ID, b []byte, searchData [][]
 		return err
 	}
 
+	headBlockBytes := i.headBlock.DataLength()
+	i.headBlockBytes.Store(headBlockBytes)
+	metricHeadBlockBytes.WithLabelValues(i.instanceID).Set(float64(headBlockBytes))
+
 	entry := i.searchHeadBlock
 	if entry != nil {
 		// Don't take a write lock on the block here. It is safe
@@ -597,72 +714,46 @@ func (i *instance) writeTraceToHeadBlock(id common.ID, b []byte, searchData [][]
 	return nil
 }
 
-func (i *instance) rediscoverLocalBlocks(ctx context.Context) ([]*localBlock, error) {
-	ids, err := i.localReader.Blocks(ctx, i.instanceID)
+// rediscoverLocalBlock reloads a complete block of the local backend. Returns nil if the block was incomplete and
+// removed or could not be read.
+func (i *instance) rediscoverLocalBlock(ctx context.Context, id uuid.UUID) (*localBlock, error) {
+	// See if block is intact by checking for meta, which is written last.
+	// If meta missing then block was not successfully written.
+	meta, err := i.localReader.BlockMeta(ctx, id, i.instanceID)
 	if err != nil {
-		return nil, err
-	}
-
-	hasWal := func(id uuid.UUID) bool {
-		i.blocksMtx.RLock()
-		defer i.blocksMtx.RUnlock()
-		for _, b := range i.completingBlocks {
-			if b.BlockMeta().BlockID == id {
-				return true
+		if err == backend.ErrDoesNotExist {
+			// Partial/incomplete block found, remove, it will be recreated from data in the wal.
+			level.Warn(log.Logger).Log("msg", "Unable to reload meta for local block. This indicates an incomplete block and will be deleted", "tenant", i.instanceID, "block", id.String())
+			err = i.local.ClearBlock(id, i.instanceID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "deleting bad local block tenant %v block %v", i.instanceID, id.String())
 			}
+		} else {
+			// Block with unknown error
+			level.Error(log.Logger).Log("msg", "Unexpected error reloading meta for local block. Ignoring and continuing. This block should be investigated.", "tenant", i.instanceID, "block", id.String(), "error", err)
+			metricReplayErrorsTotal.WithLabelValues(i.instanceID).Inc()
 		}
-		return false
-	}
-
-	var rediscoveredBlocks []*localBlock
-
-	for _, id := range ids {
 
-		// Ignore blocks that have a matching wal. The wal will be replayed and the local block recreated.
-		// NOTE - Wal replay must be done beforehand.
-		if hasWal(id) {
-			continue
-		}
-
-		// See if block is intact by checking for meta, which is written last.
-		// If meta missing then block was not successfully written.
-		meta, err := i.localReader.BlockMeta(ctx, id, i.instanceID)
-		if err != nil {
-			if err == backend.ErrDoesNotExist {
-				// Partial/incomplete block found, remove, it will be recreated from data in the wal.
-				level.Warn(log.Logger).Log("msg", "Unable to reload meta for local block. This indicates an incomplete block and will be deleted", "tenant", i.instanceID, "block", id.String())
-				err = i.local.ClearBlock(id, i.instanceID)
-				if err != nil {
-					return nil, errors.Wrapf(err, "deleting bad local block tenant %v block %v", i.instanceID, id.String())
-				}
-			} else {
-				// Block with unknown error
-				level.Error(log.Logger).Log("msg", "Unexpected error reloading meta for local block. Ignoring and continuing. This block should be investigated.", "tenant", i.instanceID, "block", id.String(), "error", err)
-				metricReplayErrorsTotal.WithLabelValues(i.instanceID).Inc()
-			}
-
-			continue
-		}
+		return nil, nil
+	}
 
-		b, err := encoding.OpenBlock(meta, i.localReader)
-		if err != nil {
-			return nil, err
-		}
+	b, err := encoding.OpenBlock(meta, i.localReader)
+	if err != nil {
+		return nil, err
+	}
 
-		ib := newLocalBlock(ctx, b, i.local)
-		rediscoveredBlocks = append(rediscoveredBlocks, ib)
+	ib := newLocalBlock(ctx, b, i.local)
 
-		sb := search.OpenBackendSearchBlock(b.BlockMeta().BlockID, b.BlockMeta().TenantID, i.localReader)
+	sb := search.OpenBackendSearchBlock(b.BlockMeta().BlockID, b.BlockMeta().TenantID, i.localReader)
 
-		i.blocksMtx.Lock()
-		i.completeBlocks = append(i.completeBlocks, ib)
-		i.searchCompleteBlocks[ib] = &searchLocalBlockEntry{b: sb}
-		i.blocksMtx.Unlock()
+	i.blocksMtx.Lock()
+	i.completeBlocks = append(i.completeBlocks, ib)
+	i.searchCompleteBlocks[ib] = &searchLocalBlockEntry{b: sb}
+	i.blocksMtx.Unlock()
 
-		level.Info(log.Logger).Log("msg", "reloaded local block", "tenantID", i.instanceID, "block", id.String(), "flushed", ib.FlushedTime())
-	}
+	level.Info(log.Logger).Log("msg", "reloaded local block", "tenantID", i.instanceID, "block", id.String(), "flushed", ib.FlushedTime())
 
-	return rediscoveredBlocks, nil
+	return ib, nil
 }
 
 // sortByteSlices sorts a []byte
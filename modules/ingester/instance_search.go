@@ -6,19 +6,32 @@ import (
 	"sort"
 
 	"github.com/go-kit/log/level"
-	v2 "github.com/grafana/tempo/pkg/model/v2"
-	"github.com/grafana/tempo/pkg/util"
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/model/trace"
 	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/traceql"
+	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/log"
 	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
 	"github.com/grafana/tempo/tempodb/search"
 )
 
+var (
+	metricLiveTraceSearchBytesExceededTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_live_trace_search_bytes_exceeded_total",
+		Help:      "The total number of searches per tenant that skipped live traces after decoding the maximum number of bytes.",
+	}, []string{"tenant"})
+)
+
 func (i *instance) Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -35,7 +48,7 @@ func (i *instance) Search(ctx context.Context, req *tempopb.SearchRequest) (*tem
 	sr := search.NewResults()
 	defer sr.Close()
 
-	i.searchLiveTraces(ctx, p, sr)
+	i.searchLiveTraces(ctx, req, p, sr)
 
 	// Lock blocks mutex until all search tasks have been created. This avoids
 	// deadlocking with other activity (ingest, flushing), caused by releasing
@@ -79,11 +92,12 @@ func (i *instance) Search(ctx context.Context, req *tempopb.SearchRequest) (*tem
 			InspectedBytes:  sr.BytesInspected(),
 			InspectedBlocks: sr.BlocksInspected(),
 			SkippedBlocks:   sr.BlocksSkipped(),
+			SkippedTraces:   sr.TracesSkipped(),
 		},
 	}, nil
 }
 
-func (i *instance) searchLiveTraces(ctx context.Context, p search.Pipeline, sr *search.Results) {
+func (i *instance) searchLiveTraces(ctx context.Context, req *tempopb.SearchRequest, p search.Pipeline, sr *search.Results) {
 	sr.StartWorker()
 
 	go func() {
@@ -98,6 +112,7 @@ func (i *instance) searchLiveTraces(ctx context.Context, p search.Pipeline, sr *
 		span.LogFields(ot_log.Event("live traces mtx acquired"))
 
 		entry := &tempofb.SearchEntry{} // buffer
+		decoder := i.newLiveTraceDecoder()
 
 		for _, t := range i.traces {
 			if sr.Quit() {
@@ -108,6 +123,27 @@ func (i *instance) searchLiveTraces(ctx context.Context, p search.Pipeline, sr *
 
 			var result *tempopb.TraceSearchMetadata
 
+			if len(t.searchData) == 0 {
+				// traces pushed without search data are decoded on demand
+				tr, size, err := decoder.decode(t)
+				if err != nil {
+					level.Error(log.Logger).Log("msg", "error decoding live trace", "tenant", i.instanceID, "err", err)
+					continue
+				}
+				if tr == nil {
+					// traces with search data are still searched, the result is partial
+					sr.AddTraceSkipped()
+					continue
+				}
+				sr.AddBytesInspected(uint64(size))
+
+				result, err = trace.MatchesProto(t.traceID, tr, req)
+				if err != nil {
+					level.Error(log.Logger).Log("msg", "error searching live trace", "tenant", i.instanceID, "err", err)
+					continue
+				}
+			}
+
 			// Search and combine from all segments for the trace.
 			for _, s := range t.searchData {
 				sr.AddBytesInspected(uint64(len(s)))
@@ -124,6 +160,7 @@ func (i *instance) searchLiveTraces(ctx context.Context, p search.Pipeline, sr *
 			}
 
 			if result != nil {
+				result.Complete = t.complete()
 				if quit := sr.AddResult(ctx, result); quit {
 					return
 				}
@@ -132,6 +169,149 @@ func (i *instance) searchLiveTraces(ctx context.Context, p search.Pipeline, sr *
 	}()
 }
 
+// Fetch implements traceql.SpansetFetcher over the live traces and the complete blocks of the instance. Live traces
+// are decoded on demand up to the live trace search bytes limit of the tenant.
+func (i *instance) Fetch(ctx context.Context, req traceql.FetchSpansRequest) (traceql.FetchSpansResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "instance.Fetch")
+	defer span.Finish()
+
+	fetcher, err := traceql.NewTraceFetcher(req)
+	if err != nil {
+		return traceql.FetchSpansResponse{}, err
+	}
+
+	live, err := i.fetchLiveTraces(ctx, fetcher)
+	if err != nil {
+		return traceql.FetchSpansResponse{}, err
+	}
+
+	i.blocksMtx.RLock()
+	blocks := make([]*localBlock, len(i.completeBlocks))
+	copy(blocks, i.completeBlocks)
+	i.blocksMtx.RUnlock()
+
+	return traceql.FetchSpansResponse{
+		Results: &instanceSpansetIterator{
+			live:   live,
+			blocks: blocks,
+			req:    req,
+		},
+	}, nil
+}
+
+func (i *instance) fetchLiveTraces(ctx context.Context, fetcher *traceql.TraceFetcher) ([]*traceql.Spanset, error) {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	decoder := i.newLiveTraceDecoder()
+
+	var spansets []*traceql.Spanset
+	for _, t := range i.traces {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tr, _, err := decoder.decode(t)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding live trace: %w", err)
+		}
+		if tr == nil {
+			break
+		}
+
+		if ss := fetcher.Fetch(t.traceID, tr); ss != nil {
+			spansets = append(spansets, ss)
+		}
+	}
+
+	return spansets, nil
+}
+
+// instanceSpansetIterator returns the spansets of the live traces followed by the spansets of every complete block
+// that supports fetching
+type instanceSpansetIterator struct {
+	live   []*traceql.Spanset
+	blocks []*localBlock
+	req    traceql.FetchSpansRequest
+
+	current traceql.SpansetIterator
+}
+
+func (it *instanceSpansetIterator) Next(ctx context.Context) (*traceql.Spanset, error) {
+	if len(it.live) > 0 {
+		ss := it.live[0]
+		it.live = it.live[1:]
+		return ss, nil
+	}
+
+	for {
+		if it.current != nil {
+			ss, err := it.current.Next(ctx)
+			if err != nil || ss != nil {
+				return ss, err
+			}
+			it.current = nil
+		}
+
+		if len(it.blocks) == 0 {
+			return nil, nil
+		}
+		b := it.blocks[0]
+		it.blocks = it.blocks[1:]
+
+		// v2 blocks don't support fetching
+		if b.BlockMeta().Version == v2.VersionString {
+			continue
+		}
+
+		resp, err := b.Fetch(ctx, it.req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching from local block (%s): %w", b.BlockMeta().BlockID, err)
+		}
+		it.current = resp.Results
+	}
+}
+
+// liveTraceDecoder decodes live traces until the live trace search bytes limit of the tenant is reached
+type liveTraceDecoder struct {
+	decoder  model.SegmentDecoder
+	tenant   string
+	maxBytes int
+	bytes    int
+}
+
+func (i *instance) newLiveTraceDecoder() *liveTraceDecoder {
+	return &liveTraceDecoder{
+		decoder:  model.MustNewSegmentDecoder(model.CurrentEncoding),
+		tenant:   i.instanceID,
+		maxBytes: i.limiter.limits.MaxLiveTraceSearchBytes(i.instanceID),
+	}
+}
+
+// decode returns the trace and its size in bytes. It returns a nil trace once the limit is reached.
+func (d *liveTraceDecoder) decode(t *liveTrace) (*tempopb.Trace, int, error) {
+	size := 0
+	for _, b := range t.batches {
+		size += len(b)
+	}
+
+	if d.maxBytes > 0 && d.bytes+size > d.maxBytes {
+		if d.bytes <= d.maxBytes {
+			// only count once per search
+			d.bytes = d.maxBytes + 1
+			metricLiveTraceSearchBytesExceededTotal.WithLabelValues(d.tenant).Inc()
+		}
+		return nil, 0, nil
+	}
+	d.bytes += size
+
+	tr, err := d.decoder.PrepareForRead(t.batches)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tr, size, nil
+}
+
 // searchWAL starts a search task for every WAL block. Must be called under lock.
 func (i *instance) searchWAL(ctx context.Context, p search.Pipeline, sr *search.Results) {
 	searchFunc := func(e *searchStreamingBlockEntry) {
@@ -196,7 +376,7 @@ func (i *instance) searchLocalBlocks(ctx context.Context, req *tempopb.SearchReq
 		}
 
 		// todo: remove support for v2 search and then this check can be removed.
-		if e.BlockMeta().Version == v2.Encoding {
+		if e.BlockMeta().Version == v2.VersionString {
 			level.Warn(log.Logger).Log("msg", "local block search not supported on v2 blocks")
 			continue
 		}
@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/tempo/pkg/model/trace"
 	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/traceql"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/search"
@@ -253,6 +254,124 @@ func TestInstanceSearchNoData(t *testing.T) {
 	}
 }
 
+func TestInstanceSearchLiveTraces(t *testing.T) {
+	i, _ := defaultInstance(t)
+	ids := writeTracesWithoutSearchData(t, i, 10)
+
+	// live traces without search data are decoded and matched
+	sr, err := i.Search(context.Background(), &tempopb.SearchRequest{
+		Tags: map[string]string{"service.name": "test-service"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, sr.Traces, len(ids))
+	checkEqual(t, ids, sr)
+
+	sr, err = i.Search(context.Background(), &tempopb.SearchRequest{
+		Tags: map[string]string{"service.name": "other-service"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, sr.Traces, 0)
+
+	// and fetched with traceql conditions
+	resp, err := i.Fetch(context.Background(), traceql.FetchSpansRequest{
+		Conditions: []traceql.Condition{traceql.MustExtractCondition(`{ name = "test" }`)},
+	})
+	require.NoError(t, err)
+	assert.Len(t, collectSpansets(t, resp.Results), len(ids))
+
+	resp, err = i.Fetch(context.Background(), traceql.FetchSpansRequest{
+		Conditions: []traceql.Condition{traceql.MustExtractCondition(`{ name = "other" }`)},
+	})
+	require.NoError(t, err)
+	assert.Len(t, collectSpansets(t, resp.Results), 0)
+}
+
+func TestInstanceSearchLiveTracesComplete(t *testing.T) {
+	i, _ := defaultInstance(t)
+	i.traceIdle = &traceIdlePeriods{}
+
+	// spans of the test traces have no parents
+	ids := writeTracesWithoutSearchData(t, i, 5)
+
+	// a trace whose root references a parent span that was not received
+	incompleteID := test.ValidTraceID(nil)
+	batch := test.MakeBatch(2, incompleteID)
+	ils := batch.InstrumentationLibrarySpans[len(batch.InstrumentationLibrarySpans)-1]
+	ils.Spans[len(ils.Spans)-1].ParentSpanId = []byte{0x01}
+	err := i.PushBytesRequest(context.Background(), makePushBytesRequest(incompleteID, batch))
+	require.NoError(t, err)
+
+	sr, err := i.Search(context.Background(), &tempopb.SearchRequest{
+		Tags: map[string]string{"service.name": "test-service"},
+	})
+	require.NoError(t, err)
+	require.Len(t, sr.Traces, len(ids)+1)
+
+	for _, tr := range sr.Traces {
+		require.Equal(t, tr.TraceID != util.TraceIDToHexString(incompleteID), tr.Complete, tr.TraceID)
+	}
+}
+
+func TestInstanceSearchLiveTracesMaxBytes(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxLiveTraceSearchBytes: 1,
+	})
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+	i, err := newInstance(testTenantID, limiter, ingester.store, ingester.local, false)
+	require.NoError(t, err)
+
+	writeTracesWithoutSearchData(t, i, 10)
+	// only every tenth trace is written with search data
+	ids, _ := writeTracesWithSearchData(t, i, "service.name", "test-service", false)
+
+	// no live trace without search data fits into the limit, the live traces with search data are still found
+	sr, err := i.Search(context.Background(), &tempopb.SearchRequest{
+		Tags: map[string]string{"service.name": "test-service"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, sr.Traces, len(ids))
+	assert.Equal(t, uint32(10+90), sr.Metrics.SkippedTraces)
+
+	resp, err := i.Fetch(context.Background(), traceql.FetchSpansRequest{})
+	require.NoError(t, err)
+	assert.Len(t, collectSpansets(t, resp.Results), 0)
+}
+
+func writeTracesWithoutSearchData(t *testing.T, i *instance, numTraces int) [][]byte {
+	dec := model.MustNewSegmentDecoder(model.CurrentEncoding)
+
+	ids := [][]byte{}
+	for j := 0; j < numTraces; j++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+
+		traceBytes, err := dec.PrepareForWrite(test.MakeTrace(2, id), 0, 0)
+		require.NoError(t, err)
+
+		err = i.PushBytes(context.Background(), id, traceBytes, nil)
+		require.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func collectSpansets(t *testing.T, it traceql.SpansetIterator) []*traceql.Spanset {
+	var spansets []*traceql.Spanset
+	for {
+		ss, err := it.Next(context.Background())
+		require.NoError(t, err)
+		if ss == nil {
+			return spansets
+		}
+		spansets = append(spansets, ss)
+	}
+}
+
 func TestInstanceSearchDoesNotRace(t *testing.T) {
 	limits, err := overrides.NewOverrides(overrides.Limits{})
 	require.NoError(t, err)
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	prom_model "github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -372,6 +373,49 @@ func TestInstanceCutCompleteTraces(t *testing.T) {
 	}
 }
 
+func TestInstanceCutCompleteTracesCompleteness(t *testing.T) {
+	instance, _ := defaultInstance(t)
+	instance.traceIdle = &traceIdlePeriods{
+		complete:       time.Second,
+		missingParents: time.Minute,
+	}
+
+	now := time.Now()
+	newLiveTrace := func(lastAppend time.Time, rootReceived bool, missingParents ...string) *liveTrace {
+		id := make([]byte, 16)
+		rand.Read(id)
+
+		c := newTraceCompleteness()
+		c.rootReceived = rootReceived
+		for _, p := range missingParents {
+			c.missingParents[p] = struct{}{}
+		}
+		return &liveTrace{traceID: id, lastAppend: lastAppend, completeness: c}
+	}
+
+	completeIdle := newLiveTrace(now.Add(-5*time.Second), true)
+	completeRecent := newLiveTrace(now, true)
+	missingParents := newLiveTrace(now.Add(-20*time.Second), true, "parent")
+	missingParentsExpired := newLiveTrace(now.Add(-2*time.Minute), true, "parent")
+	noRoot := newLiveTrace(now.Add(-20*time.Second), false, "parent")
+
+	for _, tr := range []*liveTrace{completeIdle, completeRecent, missingParents, missingParentsExpired, noRoot} {
+		instance.traces[instance.tokenForTraceID(tr.traceID)] = tr
+	}
+
+	err := instance.CutCompleteTraces(-10*time.Second, false)
+	require.NoError(t, err)
+
+	for _, tr := range []*liveTrace{completeRecent, missingParents} {
+		_, ok := instance.traces[instance.tokenForTraceID(tr.traceID)]
+		require.True(t, ok)
+	}
+	for _, tr := range []*liveTrace{completeIdle, missingParentsExpired, noRoot} {
+		_, ok := instance.traces[instance.tokenForTraceID(tr.traceID)]
+		require.False(t, ok)
+	}
+}
+
 func TestInstanceCutBlockIfReady(t *testing.T) {
 	tt := []struct {
 		name               string
@@ -452,6 +496,42 @@ func TestInstanceCutBlockIfReady(t *testing.T) {
 	}
 }
 
+func TestInstanceCutWithOverrides(t *testing.T) {
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+
+	newOverridesInstance := func(limits overrides.Limits) *instance {
+		o, err := overrides.NewOverrides(limits)
+		require.NoError(t, err)
+
+		i, err := newInstance(testTenantID, NewLimiter(o, &ringCountMock{count: 1}, 1), ingester.store, ingester.local, false)
+		require.NoError(t, err)
+		return i
+	}
+
+	// a short trace idle period of the tenant cuts traces the ingester config keeps
+	i := newOverridesInstance(overrides.Limits{TraceIdlePeriod: prom_model.Duration(time.Millisecond)})
+	require.NoError(t, i.PushBytesRequest(context.Background(), makeRequest([]byte{})))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, i.CutCompleteTraces(-time.Hour, false))
+	require.Equal(t, 0, len(i.traces))
+
+	// small max block bytes of the tenant cut a block the ingester config keeps
+	i = newOverridesInstance(overrides.Limits{MaxBlockBytes: 10})
+	require.NoError(t, i.PushBytesRequest(context.Background(), makeRequest([]byte{})))
+	require.NoError(t, i.CutCompleteTraces(0, true))
+	blockID, err := i.CutBlockIfReady(time.Hour, 1024*1024*1024, false)
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, blockID)
+
+	// a long max block duration of the tenant keeps a block the ingester config cuts
+	i = newOverridesInstance(overrides.Limits{MaxBlockDuration: prom_model.Duration(time.Hour)})
+	require.NoError(t, i.PushBytesRequest(context.Background(), makeRequest([]byte{})))
+	require.NoError(t, i.CutCompleteTraces(0, true))
+	blockID, err = i.CutBlockIfReady(time.Microsecond, 1024*1024*1024, false)
+	require.NoError(t, err)
+	require.Equal(t, uuid.Nil, blockID)
+}
+
 func TestInstanceMetrics(t *testing.T) {
 	i, _ := defaultInstance(t)
 	cutAndVerify := func(v int) {
@@ -517,6 +597,60 @@ func TestInstanceFailsLargeTracesEvenAfterFlushing(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestInstanceLiveBytesLimits(t *testing.T) {
+	ctx := context.Background()
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+
+	newInstanceWithLimits := func(l overrides.Limits) *instance {
+		limits, err := overrides.NewOverrides(l)
+		require.NoError(t, err)
+		limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+		i, err := newInstance(testTenantID, limiter, ingester.store, ingester.local, false)
+		require.NoError(t, err)
+		return i
+	}
+
+	t.Run("live traces bytes", func(t *testing.T) {
+		i := newInstanceWithLimits(overrides.Limits{MaxLiveTracesBytes: 1000})
+
+		require.NoError(t, i.PushBytesRequest(ctx, makeRequestWithByteLimit(600, []byte{})))
+		assert.Greater(t, i.liveBytes, 0)
+
+		// a second trace exceeds the limit
+		req := makeRequestWithByteLimit(600, []byte{})
+		err := i.PushBytesRequest(ctx, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), overrides.ErrorPrefixLiveBytesExceeded)
+
+		// cutting live traces frees their bytes
+		require.NoError(t, i.CutCompleteTraces(0, true))
+		assert.Equal(t, 0, i.liveBytes)
+		require.NoError(t, i.PushBytesRequest(ctx, req))
+	})
+
+	t.Run("head block bytes", func(t *testing.T) {
+		i := newInstanceWithLimits(overrides.Limits{MaxHeadBlockBytes: 1000})
+
+		require.NoError(t, i.PushBytesRequest(ctx, makeRequestWithByteLimit(1200, []byte{})))
+		require.NoError(t, i.CutCompleteTraces(0, true))
+		assert.Equal(t, i.headBlock.DataLength(), i.headBlockBytes.Load())
+
+		// the head block is full
+		req := makeRequestWithByteLimit(100, []byte{})
+		err := i.PushBytesRequest(ctx, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), overrides.ErrorPrefixHeadBlockBytesExceeded)
+
+		// the full head block is cut even though it's below the max block bytes and the push is accepted again
+		blockID, err := i.CutBlockIfReady(time.Hour, 1024*1024*1024, false)
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, blockID)
+		assert.Equal(t, uint64(0), i.headBlockBytes.Load())
+		require.NoError(t, i.PushBytesRequest(ctx, req))
+	})
+}
+
 func TestSortByteSlices(t *testing.T) {
 	numTraces := 100
 
@@ -9,6 +9,8 @@ import (
 
 const (
 	errMaxTracesPerUserLimitExceeded = "per-user traces limit (local: %d global: %d actual local: %d) exceeded"
+	errMaxLiveTracesBytesExceeded    = "per-user live traces bytes limit (%d) exceeded while adding %d bytes (actual: %d)"
+	errMaxHeadBlockBytesExceeded     = "per-user head block bytes limit (%d) exceeded (actual: %d)"
 )
 
 // RingCount is the interface exposed by a ring implementation which allows
@@ -48,6 +50,28 @@ func (l *Limiter) AssertMaxTracesPerUser(userID string, traces int) error {
 	return fmt.Errorf(errMaxTracesPerUserLimitExceeded, localLimit, globalLimit, actualLimit)
 }
 
+// AssertMaxLiveTracesBytes ensures adding reqSize bytes to the current size of the live
+// traces of the user stays within the limit and returns an error if not.
+func (l *Limiter) AssertMaxLiveTracesBytes(userID string, liveBytes, reqSize int) error {
+	limit := l.limits.MaxLiveTracesBytes(userID)
+	if limit == 0 || liveBytes+reqSize <= limit {
+		return nil
+	}
+
+	return fmt.Errorf(errMaxLiveTracesBytesExceeded, limit, reqSize, liveBytes)
+}
+
+// AssertMaxHeadBlockBytes ensures the head block of the user has not reached the limit
+// and returns an error if so.
+func (l *Limiter) AssertMaxHeadBlockBytes(userID string, headBlockBytes int) error {
+	limit := l.limits.MaxHeadBlockBytes(userID)
+	if limit == 0 || headBlockBytes < limit {
+		return nil
+	}
+
+	return fmt.Errorf(errMaxHeadBlockBytesExceeded, limit, headBlockBytes)
+}
+
 func (l *Limiter) maxTracesPerUser(userID string) int {
 	localLimit := l.limits.MaxLocalTracesPerUser(userID)
 
@@ -0,0 +1,321 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/pkg/util/log"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/search"
+)
+
+const (
+	replayKindWAL   = "wal"
+	replayKindLocal = "local"
+)
+
+var (
+	metricReplayBlocks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_replay_blocks",
+		Help:      "The number of wal and local blocks found on startup.",
+	}, []string{"kind"})
+	metricReplayBlocksReplayed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_replay_blocks_replayed",
+		Help:      "The number of wal and local blocks replayed since startup.",
+	}, []string{"kind"})
+)
+
+// replayProgress tracks the replay of the wal and local blocks on startup
+type replayProgress struct {
+	walBlocks     atomic.Int32
+	walReplayed   atomic.Int32
+	localBlocks   atomic.Int32
+	localReplayed atomic.Int32
+	done          atomic.Bool
+	err           atomic.Error
+}
+
+func (p *replayProgress) setBlocks(kind string, n int) {
+	switch kind {
+	case replayKindWAL:
+		p.walBlocks.Store(int32(n))
+	case replayKindLocal:
+		p.localBlocks.Store(int32(n))
+	}
+	metricReplayBlocks.WithLabelValues(kind).Set(float64(n))
+}
+
+func (p *replayProgress) blockReplayed(kind string) {
+	var n int32
+	switch kind {
+	case replayKindWAL:
+		n = p.walReplayed.Inc()
+	case replayKindLocal:
+		n = p.localReplayed.Inc()
+	}
+	metricReplayBlocksReplayed.WithLabelValues(kind).Set(float64(n))
+}
+
+// checkReady returns an error describing the progress until the replay is complete
+func (p *replayProgress) checkReady() error {
+	if err := p.err.Load(); err != nil {
+		return fmt.Errorf("replay failed: %w", err)
+	}
+
+	if p.done.Load() {
+		return nil
+	}
+
+	return fmt.Errorf("replay in progress: %d/%d wal blocks and %d/%d local blocks replayed",
+		p.walReplayed.Load(), p.walBlocks.Load(), p.localReplayed.Load(), p.localBlocks.Load())
+}
+
+// startReplay replays the wal and reloads the complete blocks of the local backend in the background. Both run
+// concurrently with a bounded number of workers each.
+func (i *Ingester) startReplay(walBlockIDs []uuid.UUID) {
+	ctx, cancel := context.WithCancel(context.Background())
+	i.replayCancel = cancel
+	i.replayDone = make(chan struct{})
+
+	i.replay.setBlocks(replayKindWAL, len(walBlockIDs))
+
+	go func() {
+		defer close(i.replayDone)
+
+		level.Info(log.Logger).Log("msg", "beginning replay", "walBlocks", len(walBlockIDs))
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := i.replayWal(ctx, walBlockIDs); err != nil {
+				i.replay.err.Store(fmt.Errorf("failed to replay wal: %w", err))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := i.rediscoverLocalBlocks(ctx, walBlockIDs); err != nil {
+				i.replay.err.Store(fmt.Errorf("failed to rediscover local blocks: %w", err))
+			}
+		}()
+		wg.Wait()
+
+		if err := i.replay.err.Load(); err != nil {
+			level.Error(log.Logger).Log("msg", "replay failed", "err", err)
+			return
+		}
+
+		i.replay.done.Store(true)
+		level.Info(log.Logger).Log("msg", "replay complete")
+	}()
+}
+
+// stopReplay stops replaying blocks and waits for the blocks being replayed
+func (i *Ingester) stopReplay() {
+	if i.replayCancel == nil {
+		return
+	}
+
+	i.replayCancel()
+	<-i.replayDone
+}
+
+// waitReplay blocks until the replay is complete or failed
+func (i *Ingester) waitReplay(ctx context.Context) error {
+	select {
+	case <-i.replayDone:
+		return i.replay.err.Load()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayWal replays the wal blocks listed on startup. Head blocks created by new writes in the meantime are left
+// alone.
+func (i *Ingester) replayWal(ctx context.Context, walBlockIDs []uuid.UUID) error {
+	level.Info(log.Logger).Log("msg", "beginning wal replay")
+
+	// the search files of head blocks created by writes during the replay are not touched
+	searchBlocks, err := search.RescanBlocks(i.store.WAL().GetFilepath(), walBlockIDs)
+	if err != nil {
+		return fmt.Errorf("fatal error replaying search wal: %w", err)
+	}
+
+	var (
+		mtx     sync.Mutex
+		matched = map[*search.StreamingSearchBlock]struct{}{}
+	)
+
+	// pass i.cfg.MaxBlockDuration into ReplayBlocks to make an attempt to set the start time
+	// of the blocks correctly. as we are scanning traces in the blocks we read their start/end times
+	// and attempt to set start/end times appropriately. we use now - max_block_duration - ingestion_slack
+	// as the minimum acceptable start time for a replayed block.
+	err = i.store.WAL().ReplayBlocks(walBlockIDs, i.cfg.MaxBlockDuration, i.cfg.ReplayConcurrency, func(b common.WALBlock) error {
+		// stop between blocks if the ingester is stopping. the remaining wal files are replayed on the next start
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tenantID := b.BlockMeta().TenantID
+
+		instance, err := i.getOrCreateInstance(tenantID)
+		if err != nil {
+			return err
+		}
+
+		// Delete anything remaining for the completed version of this
+		// wal block. This handles the case where a wal file is partially
+		// or fully completed to the local store, but the wal file wasn't
+		// deleted (because it was rescanned above). This can happen for reasons
+		// such as a crash or restart. In this situation we err on the side of
+		// caution and replay the wal block.
+		err = instance.local.ClearBlock(b.BlockMeta().BlockID, tenantID)
+		if err != nil {
+			return err
+		}
+
+		var searchWALBlock *search.StreamingSearchBlock
+		for _, s := range searchBlocks {
+			if b.BlockMeta().BlockID == s.BlockID() {
+				searchWALBlock = s
+				break
+			}
+		}
+		if searchWALBlock != nil {
+			mtx.Lock()
+			matched[searchWALBlock] = struct{}{}
+			mtx.Unlock()
+		}
+		instance.AddCompletingBlock(b, searchWALBlock)
+
+		i.enqueue(&flushOp{
+			kind:    opKindComplete,
+			userID:  tenantID,
+			blockID: b.BlockMeta().BlockID,
+		}, i.replayJitter)
+
+		i.replay.blockReplayed(replayKindWAL)
+		return nil
+	}, log.Logger)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			level.Info(log.Logger).Log("msg", "wal replay stopped")
+			return nil
+		}
+		return fmt.Errorf("fatal error replaying wal: %w", err)
+	}
+
+	// empty and invalid wal files are removed during the replay and not counted as replayed
+	i.replay.setBlocks(replayKindWAL, int(i.replay.walReplayed.Load()))
+
+	// clear any searchBlock that does not have a matching wal block
+	for _, s := range searchBlocks {
+		if _, ok := matched[s]; ok {
+			continue
+		}
+
+		err := s.Clear()
+		if err != nil { // just log the error
+			level.Warn(log.Logger).Log("msg", "error clearing search WAL file", "blockID", s.BlockID(), "err", err)
+		}
+	}
+
+	level.Info(log.Logger).Log("msg", "wal replay complete")
+
+	return nil
+}
+
+// rediscoverLocalBlocks reloads the complete blocks of the local backend. Blocks with a wal block are skipped, the
+// wal block is replayed and the local block recreated.
+func (i *Ingester) rediscoverLocalBlocks(ctx context.Context, walBlockIDs []uuid.UUID) error {
+	reader := backend.NewReader(i.local)
+	tenants, err := reader.Tenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting local tenants")
+	}
+
+	hasWal := make(map[uuid.UUID]struct{}, len(walBlockIDs))
+	for _, id := range walBlockIDs {
+		hasWal[id] = struct{}{}
+	}
+
+	type localBlockJob struct {
+		instance *instance
+		id       uuid.UUID
+	}
+
+	var jobs []localBlockJob
+	for _, t := range tenants {
+		inst, err := i.getOrCreateInstance(t)
+		if err != nil {
+			return err
+		}
+
+		ids, err := inst.localReader.Blocks(ctx, t)
+		if err != nil {
+			return errors.Wrapf(err, "getting local blocks for tenant %v", t)
+		}
+
+		for _, id := range ids {
+			if _, ok := hasWal[id]; ok {
+				continue
+			}
+			jobs = append(jobs, localBlockJob{instance: inst, id: id})
+		}
+	}
+
+	level.Info(log.Logger).Log("msg", "reloading local blocks", "tenants", len(tenants), "blocks", len(jobs))
+	i.replay.setBlocks(replayKindLocal, len(jobs))
+
+	concurrency := i.cfg.ReplayConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	bg := boundedwaitgroup.New(uint(concurrency))
+	anyError := atomic.Error{}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil || anyError.Load() != nil {
+			break
+		}
+
+		bg.Add(1)
+		go func(job localBlockJob) {
+			defer bg.Done()
+
+			b, err := job.instance.rediscoverLocalBlock(ctx, job.id)
+			if err != nil {
+				anyError.Store(errors.Wrapf(err, "getting local block %v for tenant %v", job.id, job.instance.instanceID))
+				return
+			}
+
+			// Requeue needed flushes
+			if b != nil && b.FlushedTime().IsZero() {
+				i.enqueue(&flushOp{
+					kind:    opKindFlush,
+					userID:  job.instance.instanceID,
+					blockID: job.id,
+				}, i.replayJitter)
+			}
+
+			i.replay.blockReplayed(replayKindLocal)
+		}(job)
+	}
+
+	bg.Wait()
+
+	return anyError.Load()
+}
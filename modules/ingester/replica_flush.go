@@ -0,0 +1,387 @@
+package ingester
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/ring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/log"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+var (
+	metricReplicaTracesSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_replica_flush_skipped_traces_total",
+		Help:      "The total number of traces left out of complete blocks because another replica flushes them.",
+	}, []string{"tenant"})
+	metricReplicaFlushesConfirmed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_replica_flush_confirmed_total",
+		Help:      "The total number of retained wal blocks cleared after the other replicas flushed their traces.",
+	})
+	metricReplicaFlushFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_replica_flush_fallbacks_total",
+		Help:      "The total number of retained wal blocks flushed because the other replicas did not confirm in time.",
+	})
+)
+
+// replicaFlusher decides which replica flushes a trace when replica aware flushing is enabled. The primary replica
+// of a trace is the first healthy active ingester clockwise from the trace token, which is the first ingester of
+// the replication set the distributor writes the trace to.
+type replicaFlusher struct {
+	lifecycler       *ring.Lifecycler
+	heartbeatTimeout time.Duration
+}
+
+func newReplicaFlusher(lifecycler *ring.Lifecycler, heartbeatTimeout time.Duration) *replicaFlusher {
+	return &replicaFlusher{
+		lifecycler:       lifecycler,
+		heartbeatTimeout: heartbeatTimeout,
+	}
+}
+
+func (f *replicaFlusher) id() string {
+	return f.lifecycler.ID
+}
+
+// owners returns a snapshot of the primary replicas of the ring
+func (f *replicaFlusher) owners(ctx context.Context) (*replicaOwners, error) {
+	desc, err := f.lifecycler.KVStore.Get(ctx, f.lifecycler.RingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	type tokenOwner struct {
+		token uint32
+		id    string
+	}
+
+	now := time.Now()
+	owners := &replicaOwners{
+		healthy: map[string]struct{}{},
+	}
+	var all []tokenOwner
+	for id, inst := range ring.GetOrCreateRingDesc(desc).Ingesters {
+		if !inst.IsHealthy(ring.WriteNoExtend, f.heartbeatTimeout, now) {
+			continue
+		}
+
+		owners.healthy[id] = struct{}{}
+		for _, t := range inst.Tokens {
+			all = append(all, tokenOwner{token: t, id: id})
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].token < all[j].token })
+	owners.tokens = make([]uint32, 0, len(all))
+	owners.ids = make([]string, 0, len(all))
+	h := fnv.New64a()
+	var buf [4]byte
+	for _, t := range all {
+		owners.tokens = append(owners.tokens, t.token)
+		owners.ids = append(owners.ids, t.id)
+
+		binary.LittleEndian.PutUint32(buf[:], t.token)
+		_, _ = h.Write(buf[:])
+		_, _ = h.Write([]byte(t.id))
+	}
+	owners.fingerprint = fmt.Sprintf("%016x", h.Sum64())
+
+	return owners, nil
+}
+
+// replicaOwners maps the tokens of the healthy ingesters of the ring to their ids. Replicas that took their
+// snapshots from different rings may disagree on the primary replica of a trace, so the fingerprint of the ring is
+// recorded with flushed blocks and only blocks flushed from the same ring confirm retained traces.
+type replicaOwners struct {
+	tokens      []uint32
+	ids         []string
+	healthy     map[string]struct{}
+	fingerprint string
+}
+
+// owner returns the id of the primary replica of the trace
+func (o *replicaOwners) owner(tenantID string, traceID []byte) string {
+	if len(o.tokens) == 0 {
+		return ""
+	}
+
+	key := util.TokenFor(tenantID, traceID)
+	idx := sort.Search(len(o.tokens), func(i int) bool { return o.tokens[i] >= key })
+	if idx == len(o.tokens) {
+		idx = 0
+	}
+
+	return o.ids[idx]
+}
+
+func (o *replicaOwners) isHealthy(id string) bool {
+	_, ok := o.healthy[id]
+	return ok
+}
+
+// retainedBlock is a completing block whose traces of other replicas were not flushed yet
+type retainedBlock struct {
+	owners   *replicaOwners
+	pending  map[string]struct{} // replicas that did not flush a block covering the traces yet
+	end      time.Time
+	since    time.Time
+	fallback bool
+}
+
+// replicaCompletion tracks the traces of a wal block that are written to its complete block
+type replicaCompletion struct {
+	block    common.WALBlock
+	owners   *replicaOwners
+	fallback bool
+
+	kept    int
+	skipped int
+	pending map[string]struct{}
+}
+
+// prepareReplicaCompletion returns how to complete the wal block. The first completion only keeps the traces this
+// ingester is the primary replica of. If a retained block timed out, the traces of the other replicas are completed
+// into a new block instead. Returns nil if all traces must be kept, e.g. if this ingester is not active.
+func (i *instance) prepareReplicaCompletion(ctx context.Context, b common.WALBlock) (*replicaCompletion, error) {
+	blockID := b.BlockMeta().BlockID
+	self := i.replicas.id()
+
+	i.blocksMtx.RLock()
+	retained := i.retainedBlocks[blockID]
+	i.blocksMtx.RUnlock()
+
+	c := &replicaCompletion{
+		pending: map[string]struct{}{},
+	}
+
+	if retained != nil {
+		meta := *b.BlockMeta()
+		meta.BlockID = uuid.New()
+
+		c.owners = retained.owners
+		c.fallback = true
+		c.block = &replicaWALBlock{
+			WALBlock: b,
+			meta:     &meta,
+			keep: func(id common.ID) bool {
+				if c.owners.owner(i.instanceID, id) == self {
+					return false
+				}
+				c.kept++
+				return true
+			},
+		}
+		return c, nil
+	}
+
+	owners, err := i.replicas.owners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !owners.isHealthy(self) {
+		return nil, nil
+	}
+
+	c.owners = owners
+	c.block = &replicaWALBlock{
+		WALBlock: b,
+		meta:     b.BlockMeta(),
+		keep: func(id common.ID) bool {
+			owner := owners.owner(i.instanceID, id)
+			if owner == self {
+				c.kept++
+				return true
+			}
+			c.pending[owner] = struct{}{}
+			c.skipped++
+			return false
+		},
+	}
+	return c, nil
+}
+
+// finishReplicaCompletion records the replica state of the completed block. Returns false if the complete block
+// holds no traces and was removed.
+func (i *instance) finishReplicaCompletion(ctx context.Context, walBlock common.WALBlock, backendBlock common.BackendBlock, c *replicaCompletion) (bool, error) {
+	walMeta := walBlock.BlockMeta()
+	meta := backendBlock.BlockMeta()
+
+	if c.fallback {
+		metricReplicaFlushFallbacks.Inc()
+
+		i.blocksMtx.Lock()
+		delete(i.retainedBlocks, walMeta.BlockID)
+		i.blocksMtx.Unlock()
+	} else {
+		metricReplicaTracesSkipped.WithLabelValues(i.instanceID).Add(float64(c.skipped))
+
+		if len(c.pending) > 0 {
+			i.blocksMtx.Lock()
+			i.retainedBlocks[walMeta.BlockID] = &retainedBlock{
+				owners:  c.owners,
+				pending: c.pending,
+				end:     walMeta.EndTime,
+				since:   time.Now(),
+			}
+			i.blocksMtx.Unlock()
+		}
+	}
+
+	if c.kept == 0 {
+		return false, i.local.ClearBlock(meta.BlockID, meta.TenantID)
+	}
+
+	if !c.fallback {
+		// the other replicas look for these fields to confirm the flush of their retained traces
+		meta.FlushedBy = i.replicas.id()
+		meta.FlushedRing = c.owners.fingerprint
+		err := i.localWriter.WriteBlockMeta(ctx, meta)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (i *instance) isRetained(blockID uuid.UUID) bool {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	_, ok := i.retainedBlocks[blockID]
+	return ok
+}
+
+// replicaFlush identifies the blocks flushed by a replica from the same ring
+type replicaFlush struct {
+	by   string
+	ring string
+}
+
+// checkRetainedBlocks returns the retained blocks whose traces were flushed by all their primary replicas and the
+// ones that timed out. A replica has flushed the traces once the blocklist holds a block flushed by it from the same
+// ring that ends after the retained block. Timed out blocks are only returned once.
+func (i *instance) checkRetainedBlocks(metas []*backend.BlockMeta, timeout time.Duration, now time.Time) (confirmed, expired []uuid.UUID) {
+	// latest end of the blocks flushed by each replica
+	flushedUntil := map[replicaFlush]time.Time{}
+	for _, m := range metas {
+		if m.FlushedBy == "" {
+			continue
+		}
+		key := replicaFlush{by: m.FlushedBy, ring: m.FlushedRing}
+		if until, ok := flushedUntil[key]; !ok || m.EndTime.After(until) {
+			flushedUntil[key] = m.EndTime
+		}
+	}
+
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	for id, r := range i.retainedBlocks {
+		if r.fallback {
+			continue
+		}
+
+		for owner := range r.pending {
+			if until, ok := flushedUntil[replicaFlush{by: owner, ring: r.owners.fingerprint}]; ok && !until.Before(r.end) {
+				delete(r.pending, owner)
+			}
+		}
+
+		if len(r.pending) == 0 {
+			confirmed = append(confirmed, id)
+			continue
+		}
+
+		if now.Sub(r.since) > timeout {
+			r.fallback = true
+			expired = append(expired, id)
+		}
+	}
+
+	return confirmed, expired
+}
+
+// sweepRetainedBlocks clears the retained blocks confirmed in the blocklist and enqueues the completion of the
+// traces of other replicas for blocks that timed out. Compacted blocks confirm flushes as well since compaction
+// drops the replica fields and the blocks flushed by the other replicas may be compacted before this ingester polls.
+func (i *Ingester) sweepRetainedBlocks(instance *instance) {
+	metas := i.store.BlockMetas(instance.instanceID)
+	for _, c := range i.store.CompactedBlockMetas(instance.instanceID) {
+		metas = append(metas, &c.BlockMeta)
+	}
+
+	confirmed, expired := instance.checkRetainedBlocks(metas, i.cfg.ReplicaFlushTimeout, time.Now())
+
+	for _, blockID := range confirmed {
+		err := instance.ClearCompletingBlock(blockID)
+		if err != nil {
+			level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to clear retained block", "block", blockID, "err", err)
+			continue
+		}
+		metricReplicaFlushesConfirmed.Inc()
+	}
+
+	for _, blockID := range expired {
+		level.Warn(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "flush of retained traces not confirmed by other replicas. flushing them", "block", blockID)
+		i.enqueue(&flushOp{
+			kind:    opKindComplete,
+			userID:  instance.instanceID,
+			blockID: blockID,
+		}, true)
+	}
+}
+
+// replicaWALBlock completes a wal block with only the traces accepted by keep
+type replicaWALBlock struct {
+	common.WALBlock
+	meta *backend.BlockMeta
+	keep func(id common.ID) bool
+}
+
+func (b *replicaWALBlock) BlockMeta() *backend.BlockMeta {
+	return b.meta
+}
+
+func (b *replicaWALBlock) Iterator() (common.Iterator, error) {
+	iter, err := b.WALBlock.Iterator()
+	if err != nil {
+		return nil, err
+	}
+
+	return &replicaIterator{Iterator: iter, keep: b.keep}, nil
+}
+
+type replicaIterator struct {
+	common.Iterator
+	keep func(id common.ID) bool
+}
+
+func (it *replicaIterator) Next(ctx context.Context) (common.ID, *tempopb.Trace, error) {
+	for {
+		id, tr, err := it.Iterator.Next(ctx)
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if id == nil || it.keep(id) {
+			return id, tr, err
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ingester
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/ring"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestReplicaOwners(t *testing.T) {
+	owners := &replicaOwners{
+		tokens: []uint32{100, 200, 300},
+		ids:    []string{"a", "b", "c"},
+	}
+
+	ownerOf := func(token uint32) string {
+		idx := 0
+		for idx < len(owners.tokens) && owners.tokens[idx] < token {
+			idx++
+		}
+		if idx == len(owners.tokens) {
+			idx = 0
+		}
+		return owners.ids[idx]
+	}
+
+	for j := 0; j < 100; j++ {
+		id := test.ValidTraceID(nil)
+		require.Equal(t, ownerOf(util.TokenFor("test", id)), owners.owner("test", id))
+	}
+
+	require.Equal(t, "", (&replicaOwners{}).owner("test", test.ValidTraceID(nil)))
+}
+
+func TestReplicaAwareCompleteBlock(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.ReplicaAwareFlushing = true
+	ingester := ingesterModuleWithConfig(t, t.TempDir(), cfg)
+	require.Eventually(t, func() bool { return ingester.lifecycler.GetState() == ring.ACTIVE }, 5*time.Second, 10*time.Millisecond)
+
+	// another active replica owning the other half of the ring
+	addReplica(t, cfg.LifecyclerConfig.RingConfig.KVStore.Mock, ingester.lifecycler.RingKey, "other")
+
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+
+	owners, err := ingester.replicas.owners(context.Background())
+	require.NoError(t, err)
+
+	owned := 0
+	for j := 0; j < 20; j++ {
+		id := test.ValidTraceID(nil)
+		require.NoError(t, inst.PushBytesRequest(context.Background(), makeRequest(id)))
+		if owners.owner("test", id) == ingester.lifecycler.ID {
+			owned++
+		}
+	}
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	walID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+
+	// only owned traces are completed, the wal block is retained for the others
+	completeID, err := inst.completeBlock(walID)
+	require.NoError(t, err)
+	require.Equal(t, walID, completeID)
+	block := inst.GetBlockToBeFlushed(completeID)
+	require.NotNil(t, block)
+	require.Equal(t, owned, block.BlockMeta().TotalObjects)
+	require.Equal(t, ingester.lifecycler.ID, block.BlockMeta().FlushedBy)
+	require.Equal(t, owners.fingerprint, block.BlockMeta().FlushedRing)
+	require.True(t, inst.isRetained(walID))
+
+	end := inst.retainedBlocks[walID].end
+
+	// blocks of other replicas ending before the retained block don't confirm it
+	confirmed, expired := inst.checkRetainedBlocks([]*backend.BlockMeta{{FlushedBy: "other", FlushedRing: owners.fingerprint, EndTime: end.Add(-time.Second)}}, time.Hour, time.Now())
+	require.Empty(t, confirmed)
+	require.Empty(t, expired)
+
+	// neither do blocks the other replica flushed from a different ring since it may have picked other primaries
+	confirmed, expired = inst.checkRetainedBlocks([]*backend.BlockMeta{{FlushedBy: "other", FlushedRing: "changed", EndTime: end}}, time.Hour, time.Now())
+	require.Empty(t, confirmed)
+	require.Empty(t, expired)
+
+	// timed out blocks complete the traces of the other replicas into a new block
+	confirmed, expired = inst.checkRetainedBlocks(nil, time.Hour, time.Now().Add(2*time.Hour))
+	require.Empty(t, confirmed)
+	require.Equal(t, []uuid.UUID{walID}, expired)
+
+	fallbackID, err := inst.completeBlock(walID)
+	require.NoError(t, err)
+	require.NotEqual(t, walID, fallbackID)
+	fallback := inst.GetBlockToBeFlushed(fallbackID)
+	require.NotNil(t, fallback)
+	require.Equal(t, 20-owned, fallback.BlockMeta().TotalObjects)
+	require.Empty(t, fallback.BlockMeta().FlushedBy)
+	require.False(t, inst.isRetained(walID))
+}
+
+func TestReplicaAwareRetainedBlockConfirmed(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.ReplicaAwareFlushing = true
+	ingester := ingesterModuleWithConfig(t, t.TempDir(), cfg)
+	require.Eventually(t, func() bool { return ingester.lifecycler.GetState() == ring.ACTIVE }, 5*time.Second, 10*time.Millisecond)
+	addReplica(t, cfg.LifecyclerConfig.RingConfig.KVStore.Mock, ingester.lifecycler.RingKey, "other")
+
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+	for j := 0; j < 20; j++ {
+		require.NoError(t, inst.PushBytesRequest(context.Background(), makeRequest(test.ValidTraceID(nil))))
+	}
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	walID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	_, err = inst.completeBlock(walID)
+	require.NoError(t, err)
+	require.True(t, inst.isRetained(walID))
+
+	r := inst.retainedBlocks[walID]
+	confirmed, expired := inst.checkRetainedBlocks([]*backend.BlockMeta{{FlushedBy: "other", FlushedRing: r.owners.fingerprint, EndTime: r.end}}, time.Hour, time.Now())
+	require.Equal(t, []uuid.UUID{walID}, confirmed)
+	require.Empty(t, expired)
+
+	require.NoError(t, inst.ClearCompletingBlock(walID))
+	require.False(t, inst.isRetained(walID))
+	require.Empty(t, inst.completingBlocks)
+}
+
+func TestReplicaAwareRetainedBlockConfirmedAfterCompaction(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.ReplicaAwareFlushing = true
+	ingester := ingesterModuleWithConfig(t, t.TempDir(), cfg)
+	require.Eventually(t, func() bool { return ingester.lifecycler.GetState() == ring.ACTIVE }, 5*time.Second, 10*time.Millisecond)
+	addReplica(t, cfg.LifecyclerConfig.RingConfig.KVStore.Mock, ingester.lifecycler.RingKey, "other")
+
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+	for j := 0; j < 20; j++ {
+		require.NoError(t, inst.PushBytesRequest(context.Background(), makeRequest(test.ValidTraceID(nil))))
+	}
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	walID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	_, err = inst.completeBlock(walID)
+	require.NoError(t, err)
+	require.True(t, inst.isRetained(walID))
+
+	// the block flushed by the other replica is compacted before this ingester sees it
+	r := inst.retainedBlocks[walID]
+	flushed := &backend.BlockMeta{BlockID: uuid.New(), TenantID: "test", FlushedBy: "other", FlushedRing: r.owners.fingerprint, EndTime: r.end}
+	ingester.store.UpdateBlocklist("test", []*backend.BlockMeta{flushed}, nil)
+	ingester.store.UpdateBlocklist("test", []*backend.BlockMeta{{BlockID: uuid.New(), TenantID: "test", EndTime: r.end}}, []*backend.CompactedBlockMeta{{BlockMeta: *flushed}})
+
+	ingester.sweepRetainedBlocks(inst)
+	require.False(t, inst.isRetained(walID))
+	require.Empty(t, inst.completingBlocks)
+}
+
+// addReplica registers an active ingester with a token opposite to the tokens of the ring
+func addReplica(t *testing.T, client kv.Client, key, id string) {
+	err := client.CAS(context.Background(), key, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.GetOrCreateRingDesc(in)
+		tokens := desc.GetTokens()
+		require.NotEmpty(t, tokens)
+
+		desc.AddIngester(id, "other:9095", "", []uint32{tokens[0] + 1<<31}, ring.ACTIVE, time.Now())
+		return desc, true, nil
+	})
+	require.NoError(t, err)
+}
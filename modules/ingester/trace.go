@@ -29,7 +29,7 @@ type liveTrace struct {
 	end        uint32
 	decoder    model.SegmentDecoder
 
-	// byte limits
+	// byte limits. currentBytes is always tracked and counts towards the live bytes of the instance
 	maxBytes     int
 	currentBytes int
 
@@ -37,6 +37,9 @@ type liveTrace struct {
 	searchData         [][]byte
 	maxSearchBytes     int
 	currentSearchBytes int
+
+	// completeness is set if trace completeness detection is enabled
+	completeness *traceCompleteness
 }
 
 func newTrace(traceID []byte, maxBytes int, maxSearchBytes int) *liveTrace {
@@ -52,19 +55,16 @@ func newTrace(traceID []byte, maxBytes int, maxSearchBytes int) *liveTrace {
 
 func (t *liveTrace) Push(_ context.Context, instanceID string, trace []byte, searchData []byte) error {
 	t.lastAppend = time.Now()
-	if t.maxBytes != 0 {
-		reqSize := len(trace)
-		if t.currentBytes+reqSize > t.maxBytes {
-			return newTraceTooLargeError(t.traceID, instanceID, t.maxBytes, reqSize)
-		}
-
-		t.currentBytes += reqSize
+	reqSize := len(trace)
+	if t.maxBytes != 0 && t.currentBytes+reqSize > t.maxBytes {
+		return newTraceTooLargeError(t.traceID, instanceID, t.maxBytes, reqSize)
 	}
 
 	start, end, err := t.decoder.FastRange(trace)
 	if err != nil {
 		return fmt.Errorf("failed to get range while adding segment: %w", err)
 	}
+	t.currentBytes += reqSize
 	t.batches = append(t.batches, trace)
 	if t.start == 0 || start < t.start {
 		t.start = start
@@ -87,3 +87,120 @@ func (t *liveTrace) Push(_ context.Context, instanceID string, trace []byte, sea
 
 	return nil
 }
+
+// traceCompleteness tracks whether the root span and all parents referenced by the received spans of a trace have
+// been received. The segments are decoded when the completeness is needed instead of on push.
+type traceCompleteness struct {
+	rootReceived   bool
+	failed         bool // a segment could not be decoded, the completeness of the trace is unknown
+	decoded        int  // number of segments of the trace already decoded
+	spans          map[string]struct{}
+	missingParents map[string]struct{}
+}
+
+func newTraceCompleteness() *traceCompleteness {
+	return &traceCompleteness{
+		spans:          map[string]struct{}{},
+		missingParents: map[string]struct{}{},
+	}
+}
+
+// update decodes the segments received since the last update
+func (c *traceCompleteness) update(decoder model.SegmentDecoder, segments [][]byte) {
+	for ; c.decoded < len(segments) && !c.failed; c.decoded++ {
+		c.push(decoder, segments[c.decoded])
+	}
+}
+
+func (c *traceCompleteness) push(decoder model.SegmentDecoder, segment []byte) {
+	if c.failed {
+		return
+	}
+
+	tr, err := decoder.PrepareForRead([][]byte{segment})
+	if err != nil {
+		c.failed = true
+		return
+	}
+
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				id := string(s.SpanId)
+				c.spans[id] = struct{}{}
+				delete(c.missingParents, id)
+
+				if len(s.ParentSpanId) == 0 {
+					c.rootReceived = true
+					continue
+				}
+
+				parent := string(s.ParentSpanId)
+				if _, ok := c.spans[parent]; !ok {
+					c.missingParents[parent] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+// complete returns true if the root span and all referenced parents were received
+func (c *traceCompleteness) complete() bool {
+	return !c.failed && c.rootReceived && len(c.missingParents) == 0
+}
+
+// hasMissingParents returns true if the root span was received but some spans reference parents that were not
+func (c *traceCompleteness) hasMissingParents() bool {
+	return !c.failed && c.rootReceived && len(c.missingParents) > 0
+}
+
+// complete returns true if trace completeness detection is enabled and the root span and all referenced parents of
+// the trace were received
+func (t *liveTrace) complete() bool {
+	if t.completeness == nil {
+		return false
+	}
+
+	t.completeness.update(t.decoder, t.batches)
+	return t.completeness.complete()
+}
+
+// traceIdlePeriods are the idle periods of live traces with trace completeness detection
+type traceIdlePeriods struct {
+	complete       time.Duration
+	missingParents time.Duration
+}
+
+// cutoff returns the offset from now before which the last span of the trace must have been received for the trace
+// to be cut. Traces that look complete are cut after a short grace period, traces with missing parents are kept
+// longer to give the parents time to arrive.
+func (p *traceIdlePeriods) cutoff(now time.Time, t *liveTrace, cutoff time.Duration) time.Duration {
+	if p == nil || t.completeness == nil {
+		return cutoff
+	}
+
+	// a trace that received spans within the shortest idle period is kept in any case, so its new segments are
+	// only decoded once it goes idle
+	shortest := cutoff
+	if -p.complete > shortest {
+		shortest = -p.complete
+	}
+	if !now.Add(shortest).After(t.lastAppend) {
+		return cutoff
+	}
+
+	t.completeness.update(t.decoder, t.batches)
+
+	switch {
+	case t.completeness.complete():
+		if -p.complete > cutoff {
+			return -p.complete
+		}
+	case t.completeness.hasMissingParents():
+		if -p.missingParents < cutoff {
+			return -p.missingParents
+		}
+	}
+
+	return cutoff
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
 	prom_dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,3 +72,46 @@ func TestTraceStartEndTime(t *testing.T) {
 	assert.Equal(t, uint32(5), tr.start)
 	assert.Equal(t, uint32(25), tr.end)
 }
+
+func TestTraceCompleteness(t *testing.T) {
+	s := model.MustNewSegmentDecoder(model.CurrentEncoding)
+	push := func(tr *liveTrace, spans ...*v1_trace.Span) {
+		buff, err := s.PrepareForWrite(&tempopb.Trace{
+			Batches: []*v1_trace.ResourceSpans{{
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{Spans: spans}},
+			}},
+		}, 10, 20)
+		require.NoError(t, err)
+		require.NoError(t, tr.Push(context.Background(), "test", buff, nil))
+	}
+	span := func(id, parent byte) *v1_trace.Span {
+		s := &v1_trace.Span{SpanId: []byte{id}}
+		if parent != 0 {
+			s.ParentSpanId = []byte{parent}
+		}
+		return s
+	}
+
+	tr := newTrace(nil, 0, 0)
+	tr.completeness = newTraceCompleteness()
+
+	// children usually arrive before the root
+	push(tr, span(3, 2))
+	assert.Equal(t, 0, tr.completeness.decoded, "segments are decoded when the completeness is needed")
+	assert.False(t, tr.complete())
+	assert.False(t, tr.completeness.hasMissingParents())
+
+	push(tr, span(1, 0))
+	assert.False(t, tr.complete())
+	assert.True(t, tr.completeness.hasMissingParents())
+
+	push(tr, span(2, 1), span(4, 2))
+	assert.True(t, tr.complete())
+	assert.Equal(t, 3, tr.completeness.decoded)
+	assert.False(t, tr.completeness.hasMissingParents())
+
+	// completeness is unknown once a segment can't be decoded
+	tr.completeness.push(s, []byte{0x01})
+	assert.False(t, tr.completeness.complete())
+	assert.False(t, tr.completeness.hasMissingParents())
+}
@@ -18,6 +18,10 @@ const (
 	ErrorPrefixLiveTracesExceeded = "LIVE_TRACES_EXCEEDED:"
 	// ErrorPrefixTraceTooLarge is used to flag batches from the ingester that were rejected b/c they exceeded the single trace limit
 	ErrorPrefixTraceTooLarge = "TRACE_TOO_LARGE:"
+	// ErrorPrefixLiveBytesExceeded is used to flag batches from the ingester that were rejected b/c the live traces of the tenant were too large
+	ErrorPrefixLiveBytesExceeded = "LIVE_BYTES_EXCEEDED:"
+	// ErrorPrefixHeadBlockBytesExceeded is used to flag batches from the ingester that were rejected b/c the head block of the tenant was too large
+	ErrorPrefixHeadBlockBytesExceeded = "HEAD_BLOCK_BYTES_EXCEEDED:"
 	// ErrorPrefixRateLimited is used to flag batches that have exceeded the spans/second of the tenant
 	ErrorPrefixRateLimited = "RATE_LIMITED:"
 
@@ -26,6 +30,8 @@ const (
 	MetricMaxGlobalTracesPerUser    = "max_global_traces_per_user"
 	MetricMaxBytesPerTrace          = "max_bytes_per_trace"
 	MetricMaxSearchBytesPerTrace    = "max_search_bytes_per_trace"
+	MetricMaxLiveTracesBytes        = "max_live_traces_bytes"
+	MetricMaxHeadBlockBytes         = "max_head_block_bytes"
 	MetricMaxBytesPerTagValuesQuery = "max_bytes_per_tag_values_query"
 	MetricIngestionRateLimitBytes   = "ingestion_rate_limit_bytes"
 	MetricIngestionBurstSizeBytes   = "ingestion_burst_size_bytes"
@@ -49,11 +55,23 @@ type Limits struct {
 	IngestionRateLimitBytes int       `yaml:"ingestion_rate_limit_bytes" json:"ingestion_rate_limit_bytes"`
 	IngestionBurstSizeBytes int       `yaml:"ingestion_burst_size_bytes" json:"ingestion_burst_size_bytes"`
 	SearchTagsAllowList     ListToMap `yaml:"search_tags_allow_list" json:"search_tags_allow_list"`
+	// AttributeProcessing modifies the attributes of received spans before they are stored.
+	AttributeProcessing []AttributeAction `yaml:"attribute_processing" json:"attribute_processing"`
+	// Mirrors send a copy of the received traces to external OTLP endpoints.
+	Mirrors []Mirror `yaml:"mirrors" json:"mirrors"`
 
 	// Ingester enforced limits.
 	MaxLocalTracesPerUser  int `yaml:"max_traces_per_user" json:"max_traces_per_user"`
 	MaxGlobalTracesPerUser int `yaml:"max_global_traces_per_user" json:"max_global_traces_per_user"`
+	MaxLiveTracesBytes     int `yaml:"max_live_traces_bytes" json:"max_live_traces_bytes"`
+	MaxHeadBlockBytes      int `yaml:"max_head_block_bytes" json:"max_head_block_bytes"`
 	MaxSearchBytesPerTrace int `yaml:"max_search_bytes_per_trace" json:"max_search_bytes_per_trace"`
+	// MaxLiveTraceSearchBytes caps the bytes of live traces decoded by a single search in an ingester.
+	MaxLiveTraceSearchBytes int `yaml:"max_live_trace_search_bytes" json:"max_live_trace_search_bytes"`
+	// Head block and live trace settings of the ingester. 0 uses the ingester config.
+	MaxBlockDuration model.Duration `yaml:"max_block_duration" json:"max_block_duration"`
+	MaxBlockBytes    uint64         `yaml:"max_block_bytes" json:"max_block_bytes"`
+	TraceIdlePeriod  model.Duration `yaml:"trace_idle_period" json:"trace_idle_period"`
 
 	// Metrics-generator config
 	MetricsGeneratorRingSize                               int           `yaml:"metrics_generator_ring_size" json:"metrics_generator_ring_size"`
@@ -69,7 +87,18 @@ type Limits struct {
 	MetricsGeneratorProcessorSpanMetricsDimensions         []string      `yaml:"metrics_generator_processor_span_metrics_dimensions" json:"metrics_generator_processor_span_metrics_dimensions"`
 
 	// Compactor enforced limits.
-	BlockRetention model.Duration `yaml:"block_retention" json:"block_retention"`
+	BlockRetention      model.Duration  `yaml:"block_retention" json:"block_retention"`
+	BlockRetentionRules []RetentionRule `yaml:"block_retention_rules" json:"block_retention_rules"`
+
+	// Compaction time sampling. Applied once blocks are compacted to CompactionSamplingLevel.
+	CompactionSamplingLevel            uint8              `yaml:"compaction_sampling_level" json:"compaction_sampling_level"`
+	CompactionSamplingRate             float64            `yaml:"compaction_sampling_rate" json:"compaction_sampling_rate"`
+	CompactionSamplingServiceRates     map[string]float64 `yaml:"compaction_sampling_service_rates" json:"compaction_sampling_service_rates"`
+	CompactionSamplingKeepErrors       bool               `yaml:"compaction_sampling_keep_errors" json:"compaction_sampling_keep_errors"`
+	CompactionSamplingLatencyThreshold model.Duration     `yaml:"compaction_sampling_latency_threshold" json:"compaction_sampling_latency_threshold"`
+
+	// Compaction block selector. One of time_window, size_tiered or trace_id_range.
+	CompactionBlockSelector string `yaml:"compaction_block_selector" json:"compaction_block_selector"`
 
 	// Querier and Ingester enforced limits.
 	MaxBytesPerTagValuesQuery int `yaml:"max_bytes_per_tag_values_query" json:"max_bytes_per_tag_values_query"`
@@ -86,6 +115,54 @@ type Limits struct {
 	PerTenantOverridePeriod model.Duration `yaml:"per_tenant_override_period" json:"per_tenant_override_period"`
 }
 
+// AttributeAction modifies span, resource or event attributes in the distributor. Actions are applied in order.
+//   - delete removes Key
+//   - rename moves the value of Key to NewKey, replacing any value of NewKey
+//   - redact replaces all matches of Pattern in the string value of Key, or of all string values if Key is empty,
+//     with Replacement
+//   - hash replaces the string value of Key with its HMAC-SHA256 using HashKey, or its SHA-256 hash if HashKey is
+//     empty. An unkeyed hash of a low entropy value like a user id can be reversed by hashing all candidates.
+//   - insert adds Key with Value if it is not present
+type AttributeAction struct {
+	Action      string   `yaml:"action" json:"action"`
+	Key         string   `yaml:"key" json:"key"`
+	NewKey      string   `yaml:"new_key,omitempty" json:"new_key,omitempty"`
+	Pattern     string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Replacement string   `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+	Value       string   `yaml:"value,omitempty" json:"value,omitempty"`
+	HashKey     string   `yaml:"hash_key,omitempty" json:"hash_key,omitempty"`
+	Scopes      []string `yaml:"scopes,omitempty" json:"scopes,omitempty"` // span, resource and/or event. all if empty
+}
+
+// Mirror sends a copy of the traces of a tenant to an external OTLP endpoint. Endpoint is host:port for the grpc
+// protocol and the full URL, e.g. https://host:4318/v1/traces, for the http protocol. Insecure sends the traces
+// unencrypted with either protocol and InsecureSkipVerify skips the verification of the certificate of the endpoint.
+// Only traces with spans of Services are mirrored if set, and a SamplingRate between 0 and 1 mirrors that fraction
+// of the traces.
+type Mirror struct {
+	Name               string            `yaml:"name" json:"name"`
+	Protocol           string            `yaml:"protocol" json:"protocol"`
+	Endpoint           string            `yaml:"endpoint" json:"endpoint"`
+	Insecure           bool              `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+	Headers            map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Timeout            model.Duration    `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Services           []string          `yaml:"services,omitempty" json:"services,omitempty"`
+	SamplingRate       float64           `yaml:"sampling_rate,omitempty" json:"sampling_rate,omitempty"`
+	QueueSize          int               `yaml:"queue_size,omitempty" json:"queue_size,omitempty"`
+	Workers            int               `yaml:"workers,omitempty" json:"workers,omitempty"`
+	MaxRetries         int               `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	RetryBackoff       model.Duration    `yaml:"retry_backoff,omitempty" json:"retry_backoff,omitempty"`
+}
+
+// RetentionRule keeps traces matching a TraceQL spanset filter for a different duration than the
+// tenant's block retention. The compactor sorts matching traces into blocks of the rule's class.
+type RetentionRule struct {
+	Class     string         `yaml:"class" json:"class"`
+	Query     string         `yaml:"query" json:"query"`
+	Retention model.Duration `yaml:"retention" json:"retention"`
+}
+
 // RegisterFlags adds the flags required to config this to the given FlagSet
 func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	// Distributor Limits
@@ -96,8 +173,11 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	// Ingester limits
 	f.IntVar(&l.MaxLocalTracesPerUser, "ingester.max-traces-per-user", 10e3, "Maximum number of active traces per user, per ingester. 0 to disable.")
 	f.IntVar(&l.MaxGlobalTracesPerUser, "ingester.max-global-traces-per-user", 0, "Maximum number of active traces per user, across the cluster. 0 to disable.")
+	f.IntVar(&l.MaxLiveTracesBytes, "ingester.max-live-traces-bytes", 0, "Maximum total size of live traces per user, per ingester, in bytes. 0 to disable.")
+	f.IntVar(&l.MaxHeadBlockBytes, "ingester.max-head-block-bytes", 0, "Maximum size of the head block per user, per ingester, in bytes. 0 to disable.")
 	f.IntVar(&l.MaxBytesPerTrace, "ingester.max-bytes-per-trace", 50e5, "Maximum size of a trace in bytes.  0 to disable.")
 	f.IntVar(&l.MaxSearchBytesPerTrace, "ingester.max-search-bytes-per-trace", 5e3, "Maximum size of search data per trace in bytes.  0 to disable.")
+	f.IntVar(&l.MaxLiveTraceSearchBytes, "ingester.max-live-trace-search-bytes", 50e6, "Maximum bytes of live traces decoded by a single search in the ingester.  0 to disable.")
 
 	// Querier limits
 	f.IntVar(&l.MaxBytesPerTagValuesQuery, "querier.max-bytes-per-tag-values-query", 50e5, "Maximum size of response for a tag-values query. Used mainly to limit large the number of values associated with a particular tag")
@@ -116,6 +196,8 @@ func (l *Limits) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxGlobalTracesPerUser), MetricMaxGlobalTracesPerUser)
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxBytesPerTrace), MetricMaxBytesPerTrace)
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxSearchBytesPerTrace), MetricMaxSearchBytesPerTrace)
+	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxLiveTracesBytes), MetricMaxLiveTracesBytes)
+	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxHeadBlockBytes), MetricMaxHeadBlockBytes)
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.MaxBytesPerTagValuesQuery), MetricMaxBytesPerTagValuesQuery)
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.IngestionRateLimitBytes), MetricIngestionRateLimitBytes)
 	ch <- prometheus.MustNewConstMetric(metricLimitsDesc, prometheus.GaugeValue, float64(l.IngestionBurstSizeBytes), MetricIngestionBurstSizeBytes)
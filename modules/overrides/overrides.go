@@ -233,6 +233,18 @@ func (o *Overrides) MaxGlobalTracesPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxGlobalTracesPerUser
 }
 
+// MaxLiveTracesBytes returns the maximum total size of live traces in bytes a user is allowed
+// to store in a single ingester.
+func (o *Overrides) MaxLiveTracesBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxLiveTracesBytes
+}
+
+// MaxHeadBlockBytes returns the maximum size of the head block in bytes a user is allowed
+// to store in a single ingester.
+func (o *Overrides) MaxHeadBlockBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxHeadBlockBytes
+}
+
 // MaxBytesPerTrace returns the maximum size of a single trace in bytes allowed for a user.
 func (o *Overrides) MaxBytesPerTrace(userID string) int {
 	return o.getOverridesForUser(userID).MaxBytesPerTrace
@@ -243,6 +255,29 @@ func (o *Overrides) MaxSearchBytesPerTrace(userID string) int {
 	return o.getOverridesForUser(userID).MaxSearchBytesPerTrace
 }
 
+// MaxLiveTraceSearchBytes returns the maximum bytes of live traces decoded by a single search in the ingester.
+func (o *Overrides) MaxLiveTraceSearchBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxLiveTraceSearchBytes
+}
+
+// MaxBlockDuration returns the maximum duration the head block of a user is appended to in an ingester before
+// cutting it. 0 if the ingester config applies.
+func (o *Overrides) MaxBlockDuration(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxBlockDuration)
+}
+
+// MaxBlockBytes returns the maximum size of the head block of a user in an ingester before cutting it. 0 if the
+// ingester config applies.
+func (o *Overrides) MaxBlockBytes(userID string) uint64 {
+	return o.getOverridesForUser(userID).MaxBlockBytes
+}
+
+// TraceIdlePeriod returns the duration after which a live trace of a user is cut to the head block if no spans have
+// been received. 0 if the ingester config applies.
+func (o *Overrides) TraceIdlePeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).TraceIdlePeriod)
+}
+
 // MaxBytesPerTagValuesQuery returns the maximum size of a response to a tag-values query allowed for a user.
 func (o *Overrides) MaxBytesPerTagValuesQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxBytesPerTagValuesQuery
@@ -263,6 +298,16 @@ func (o *Overrides) SearchTagsAllowList(userID string) map[string]struct{} {
 	return o.getOverridesForUser(userID).SearchTagsAllowList.GetMap()
 }
 
+// AttributeProcessing returns the actions applied to the attributes of received spans for this tenant.
+func (o *Overrides) AttributeProcessing(userID string) []AttributeAction {
+	return o.getOverridesForUser(userID).AttributeProcessing
+}
+
+// Mirrors returns the external OTLP endpoints the traces of this tenant are mirrored to.
+func (o *Overrides) Mirrors(userID string) []Mirror {
+	return o.getOverridesForUser(userID).Mirrors
+}
+
 // MetricsGeneratorRingSize is the desired size of the metrics-generator ring for this tenant.
 // Using shuffle sharding, a tenant can use a smaller ring than the entire ring.
 func (o *Overrides) MetricsGeneratorRingSize(userID string) int {
@@ -331,6 +376,42 @@ func (o *Overrides) BlockRetention(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).BlockRetention)
 }
 
+// BlockRetentionRules are the attribute based retention rules for this tenant.
+func (o *Overrides) BlockRetentionRules(userID string) []RetentionRule {
+	return o.getOverridesForUser(userID).BlockRetentionRules
+}
+
+// CompactionSamplingLevel is the compaction level at which compaction time sampling starts for this tenant.
+func (o *Overrides) CompactionSamplingLevel(userID string) uint8 {
+	return o.getOverridesForUser(userID).CompactionSamplingLevel
+}
+
+// CompactionSamplingRate is the fraction of traces kept by compaction time sampling for this tenant.
+func (o *Overrides) CompactionSamplingRate(userID string) float64 {
+	return o.getOverridesForUser(userID).CompactionSamplingRate
+}
+
+// CompactionSamplingServiceRates are the compaction time sample rates per root service for this tenant.
+func (o *Overrides) CompactionSamplingServiceRates(userID string) map[string]float64 {
+	return o.getOverridesForUser(userID).CompactionSamplingServiceRates
+}
+
+// CompactionSamplingKeepErrors keeps traces with errors during compaction time sampling for this tenant.
+func (o *Overrides) CompactionSamplingKeepErrors(userID string) bool {
+	return o.getOverridesForUser(userID).CompactionSamplingKeepErrors
+}
+
+// CompactionSamplingLatencyThreshold is the trace duration above which traces are kept during compaction
+// time sampling for this tenant.
+func (o *Overrides) CompactionSamplingLatencyThreshold(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).CompactionSamplingLatencyThreshold)
+}
+
+// CompactionBlockSelector is the strategy used to select blocks to compact for this tenant.
+func (o *Overrides) CompactionBlockSelector(userID string) string {
+	return o.getOverridesForUser(userID).CompactionBlockSelector
+}
+
 // MaxSearchDuration is the duration of the max search duration for this tenant.
 func (o *Overrides) MaxSearchDuration(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxSearchDuration)
@@ -367,6 +448,8 @@ func (o *Overrides) Collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.MaxGlobalTracesPerUser), MetricMaxGlobalTracesPerUser, tenant)
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.MaxBytesPerTrace), MetricMaxBytesPerTrace, tenant)
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.MaxSearchBytesPerTrace), MetricMaxSearchBytesPerTrace, tenant)
+		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.MaxLiveTracesBytes), MetricMaxLiveTracesBytes, tenant)
+		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.MaxHeadBlockBytes), MetricMaxHeadBlockBytes, tenant)
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.IngestionRateLimitBytes), MetricIngestionRateLimitBytes, tenant)
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.IngestionBurstSizeBytes), MetricIngestionBurstSizeBytes, tenant)
 		ch <- prometheus.MustNewConstMetric(metricOverridesLimitsDesc, prometheus.GaugeValue, float64(limits.BlockRetention), MetricBlockRetention, tenant)
@@ -468,8 +468,10 @@ func (q *Querier) postProcessSearchResults(req *tempopb.SearchRequest, rr []resp
 		sr := r.response.(*tempopb.SearchResponse)
 		for _, t := range sr.Traces {
 			// Just simply take first result for each trace
-			if _, ok := traces[t.TraceID]; !ok {
+			if existing, ok := traces[t.TraceID]; !ok {
 				traces[t.TraceID] = t
+			} else if t.Complete {
+				existing.Complete = true
 			}
 		}
 		if sr.Metrics != nil {
@@ -477,6 +479,7 @@ func (q *Querier) postProcessSearchResults(req *tempopb.SearchRequest, rr []resp
 			response.Metrics.InspectedTraces += sr.Metrics.InspectedTraces
 			response.Metrics.InspectedBlocks += sr.Metrics.InspectedBlocks
 			response.Metrics.SkippedBlocks += sr.Metrics.SkippedBlocks
+			response.Metrics.SkippedTraces += sr.Metrics.SkippedTraces
 		}
 	}
 
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/blocklist"
+)
+
+const (
+	eventTypeAdded     = "added"
+	eventTypeCompacted = "compacted"
+
+	publishTimeout = 10 * time.Second
+)
+
+var (
+	metricBlocklistEventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "blocklist_gossip_events_published_total",
+		Help:      "Total number of blocklist events published to other components.",
+	}, []string{"type"})
+	metricBlocklistEventsApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "blocklist_gossip_events_applied_total",
+		Help:      "Total number of blocklist events received from other components and applied to the blocklist.",
+	}, []string{"type"})
+	metricBlocklistEventsPublishFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "blocklist_gossip_publish_failures_total",
+		Help:      "Total number of times blocklist events failed to be published.",
+	})
+)
+
+type applyFn func(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta)
+
+// blocklistGossip publishes blocks added or compacted by this process over the kv store and applies
+// the events published by other components.
+type blocklistGossip struct {
+	kv     kv.Client
+	ttl    time.Duration
+	logger log.Logger
+
+	// seen holds the keys of applied events until they expire
+	seen map[string]time.Time
+}
+
+var _ tempodb.BlocklistNotifier = (*blocklistGossip)(nil)
+
+func newBlocklistGossip(client kv.Client, ttl time.Duration, logger log.Logger) *blocklistGossip {
+	if ttl <= 0 {
+		ttl = tempodb.DefaultBlocklistGossipEventTTL
+	}
+
+	return &blocklistGossip{
+		kv:     client,
+		ttl:    ttl,
+		logger: logger,
+		seen:   map[string]time.Time{},
+	}
+}
+
+// BlocksAdded implements tempodb.BlocklistNotifier
+func (g *blocklistGossip) BlocksAdded(tenantID string, metas []*backend.BlockMeta) {
+	events := blocklist.NewEvents()
+	events.AddBlocks(metas, time.Now().Add(g.ttl))
+
+	if g.publish(tenantID, events) {
+		metricBlocklistEventsPublished.WithLabelValues(eventTypeAdded).Add(float64(len(metas)))
+	}
+}
+
+// BlocksCompacted implements tempodb.BlocklistNotifier
+func (g *blocklistGossip) BlocksCompacted(tenantID string, metas []*backend.CompactedBlockMeta) {
+	events := blocklist.NewEvents()
+	events.CompactBlocks(metas, time.Now().Add(g.ttl))
+
+	if g.publish(tenantID, events) {
+		metricBlocklistEventsPublished.WithLabelValues(eventTypeCompacted).Add(float64(len(metas)))
+	}
+}
+
+func (g *blocklistGossip) publish(tenantID string, events *blocklist.Events) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	// events are merged into the existing ones, so there is no need to look at the current value
+	err := g.kv.CAS(ctx, blocklist.EventsKey, func(_ interface{}) (interface{}, bool, error) {
+		return events, true, nil
+	})
+	if err != nil {
+		// not fatal, polling will pick up the change
+		level.Warn(g.logger).Log("msg", "failed to publish blocklist events", "tenantID", tenantID, "err", err)
+		metricBlocklistEventsPublishFailed.Inc()
+		return false
+	}
+
+	return true
+}
+
+// watch applies events until the context is cancelled
+func (g *blocklistGossip) watch(ctx context.Context, apply applyFn) {
+	g.kv.WatchKey(ctx, blocklist.EventsKey, func(v interface{}) bool {
+		if events, ok := v.(*blocklist.Events); ok && events != nil {
+			g.applyEvents(events, apply, time.Now())
+		}
+		return true
+	})
+}
+
+// applyEvents applies all unexpired events that haven't been applied yet. Blocks that are known to be
+// compacted are never added back.
+func (g *blocklistGossip) applyEvents(events *blocklist.Events, apply applyFn, now time.Time) {
+	added := map[string][]*backend.BlockMeta{}
+	compacted := map[string][]*backend.CompactedBlockMeta{}
+
+	for k, ev := range events.Compacted {
+		if ev.Meta == nil || ev.ExpiresAt.Before(now) || !g.markSeen(eventTypeCompacted+"/"+k, ev.ExpiresAt) {
+			continue
+		}
+		compacted[ev.Meta.TenantID] = append(compacted[ev.Meta.TenantID], ev.Meta)
+		metricBlocklistEventsApplied.WithLabelValues(eventTypeCompacted).Inc()
+	}
+
+	for k, ev := range events.Added {
+		if ev.Meta == nil || ev.ExpiresAt.Before(now) {
+			continue
+		}
+		if _, ok := g.seen[eventTypeCompacted+"/"+k]; ok {
+			continue
+		}
+		if !g.markSeen(eventTypeAdded+"/"+k, ev.ExpiresAt) {
+			continue
+		}
+		added[ev.Meta.TenantID] = append(added[ev.Meta.TenantID], ev.Meta)
+		metricBlocklistEventsApplied.WithLabelValues(eventTypeAdded).Inc()
+	}
+
+	for tenantID, metas := range added {
+		apply(tenantID, metas, compacted[tenantID])
+		delete(compacted, tenantID)
+	}
+	for tenantID, metas := range compacted {
+		apply(tenantID, nil, metas)
+	}
+
+	for k, expiresAt := range g.seen {
+		if expiresAt.Before(now) {
+			delete(g.seen, k)
+		}
+	}
+}
+
+// markSeen records the event and returns false if it was already seen
+func (g *blocklistGossip) markSeen(key string, expiresAt time.Time) bool {
+	if _, ok := g.seen[key]; ok {
+		return false
+	}
+	g.seen[key] = expiresAt
+	return true
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/codec"
+	"github.com/grafana/dskit/kv/memberlist"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/blocklist"
+)
+
+type dnsProviderMock struct{}
+
+func (p *dnsProviderMock) Resolve(ctx context.Context, addrs []string) error { return nil }
+func (p *dnsProviderMock) Addresses() []string                               { return nil }
+
+type applied struct {
+	added     map[string][]uuid.UUID
+	compacted map[string][]uuid.UUID
+}
+
+func newApplied() *applied {
+	return &applied{
+		added:     map[string][]uuid.UUID{},
+		compacted: map[string][]uuid.UUID{},
+	}
+}
+
+func (a *applied) apply(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta) {
+	for _, m := range added {
+		a.added[tenantID] = append(a.added[tenantID], m.BlockID)
+	}
+	for _, m := range compacted {
+		a.compacted[tenantID] = append(a.compacted[tenantID], m.BlockID)
+	}
+}
+
+func TestBlocklistGossipApplyEvents(t *testing.T) {
+	now := time.Now()
+	g := newBlocklistGossip(nil, time.Minute, log.NewNopLogger())
+
+	a := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}
+	b := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}
+	c := &backend.BlockMeta{TenantID: "other", BlockID: uuid.New()}
+
+	events := blocklist.NewEvents()
+	events.AddBlocks([]*backend.BlockMeta{a, b}, now.Add(time.Minute))
+	events.AddBlocks([]*backend.BlockMeta{c}, now.Add(-time.Second))
+	events.CompactBlocks([]*backend.CompactedBlockMeta{{BlockMeta: *b}}, now.Add(time.Minute))
+
+	// expired events and additions of compacted blocks are skipped
+	res := newApplied()
+	g.applyEvents(events, res.apply, now)
+	assert.Equal(t, map[string][]uuid.UUID{"test": {a.BlockID}}, res.added)
+	assert.Equal(t, map[string][]uuid.UUID{"test": {b.BlockID}}, res.compacted)
+
+	// events are applied only once
+	res = newApplied()
+	g.applyEvents(events, res.apply, now)
+	assert.Empty(t, res.added)
+	assert.Empty(t, res.compacted)
+
+	// seen events are forgotten once they expire
+	g.applyEvents(blocklist.NewEvents(), res.apply, now.Add(2*time.Minute))
+	assert.Empty(t, g.seen)
+}
+
+func TestBlocklistGossip(t *testing.T) {
+	var cfg memberlist.KVConfig
+	flagext.DefaultValues(&cfg)
+	cfg.TCPTransport = memberlist.TCPTransportConfig{
+		BindAddrs: []string{"localhost"},
+		BindPort:  0,
+	}
+	cfg.Codecs = []codec.Codec{blocklist.EventsCodec}
+
+	mkv := memberlist.NewKV(cfg, log.NewNopLogger(), &dnsProviderMock{}, nil)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), mkv)
+	})
+
+	client, err := memberlist.NewClient(mkv, blocklist.EventsCodec)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	appliedCh := make(chan uuid.UUID, 10)
+	g := newBlocklistGossip(client, time.Minute, log.NewNopLogger())
+	go g.watch(ctx, func(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta) {
+		for _, m := range added {
+			appliedCh <- m.BlockID
+		}
+		for _, m := range compacted {
+			appliedCh <- m.BlockID
+		}
+	})
+
+	// give the watcher time to register
+	time.Sleep(100 * time.Millisecond)
+
+	added := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}
+	g.BlocksAdded("test", []*backend.BlockMeta{added})
+	compacted := &backend.CompactedBlockMeta{BlockMeta: backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}}
+	g.BlocksCompacted("test", []*backend.CompactedBlockMeta{compacted})
+
+	received := map[uuid.UUID]struct{}{}
+	timeout := time.After(5 * time.Second)
+	for len(received) < 2 {
+		select {
+		case id := <-appliedCh:
+			received[id] = struct{}{}
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, received %d", len(received))
+		}
+	}
+	assert.Contains(t, received, added.BlockID)
+	assert.Contains(t, received, compacted.BlockID)
+
+	// both events are kept in the kv
+	v, err := client.Get(ctx, blocklist.EventsKey)
+	require.NoError(t, err)
+	assert.Len(t, v.(*blocklist.Events).MergeContent(), 2)
+}
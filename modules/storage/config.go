@@ -10,6 +10,7 @@ import (
 	"github.com/grafana/tempo/tempodb"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/encryption"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
@@ -33,6 +34,7 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 
 	f.StringVar(&cfg.Trace.Backend, util.PrefixConfig(prefix, "trace.backend"), "", "Trace backend (s3, azure, gcs, local)")
 	f.DurationVar(&cfg.Trace.BlocklistPoll, util.PrefixConfig(prefix, "trace.blocklist_poll"), tempodb.DefaultBlocklistPoll, "Period at which to run the maintenance cycle.")
+	cfg.Trace.BlocklistGossip.EventTTL = tempodb.DefaultBlocklistGossipEventTTL
 
 	cfg.Trace.WAL = &wal.Config{}
 	f.StringVar(&cfg.Trace.WAL.Filepath, util.PrefixConfig(prefix, "trace.wal.path"), "/var/tempo/wal", "Path at which store WAL blocks.")
@@ -81,6 +83,26 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.Trace.Local = &local.Config{}
 	f.StringVar(&cfg.Trace.Local.Path, util.PrefixConfig(prefix, "trace.local.path"), "", "path to store traces at.")
 
+	cfg.Trace.Encryption = &encryption.Config{}
+	f.StringVar(&cfg.Trace.Encryption.KeyProvider, util.PrefixConfig(prefix, "trace.encryption.key-provider"), "", "Key provider for client side encryption of the backend (file). Empty disables encryption.")
+	cfg.Trace.Encryption.ChunkSizeBytes = encryption.DefaultChunkSizeBytes
+	cfg.Trace.Encryption.File = &encryption.FileConfig{}
+	f.StringVar(&cfg.Trace.Encryption.File.Path, util.PrefixConfig(prefix, "trace.encryption.file.path"), "", "Path of the file holding the key encryption keys of the tenants.")
+
+	cfg.Trace.Tiering.MaxBlocksPerCycle = tempodb.DefaultTieringMaxBlocksPerCycle
+	cfg.Trace.Tiering.RecoveryInterval = tempodb.DefaultTieringRecoveryInterval
+	cfg.Trace.Tiering.Local = &local.Config{}
+	cfg.Trace.Tiering.GCS = &gcs.Config{}
+	cfg.Trace.Tiering.GCS.ChunkBufferSize = 10 * 1024 * 1024
+	cfg.Trace.Tiering.GCS.HedgeRequestsUpTo = 2
+	cfg.Trace.Tiering.S3 = &s3.Config{}
+	cfg.Trace.Tiering.S3.HedgeRequestsUpTo = 2
+	cfg.Trace.Tiering.Azure = &azure.Config{}
+	cfg.Trace.Tiering.Azure.Endpoint = "blob.core.windows.net"
+	cfg.Trace.Tiering.Azure.MaxBuffers = 4
+	cfg.Trace.Tiering.Azure.BufferSize = 3 * 1024 * 1024
+	cfg.Trace.Tiering.Azure.HedgeRequestsUpTo = 2
+
 	cfg.Trace.BackgroundCache = &cache.BackgroundConfig{}
 	cfg.Trace.BackgroundCache.WriteBackBuffer = 10000
 	cfg.Trace.BackgroundCache.WriteBackGoroutines = 10
@@ -2,12 +2,15 @@ package storage
 
 import (
 	"context"
+	"sync"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/services"
 
 	"github.com/grafana/tempo/pkg/usagestats"
 	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/blocklist"
 )
 
 var (
@@ -36,10 +39,17 @@ type store struct {
 	tempodb.Reader
 	tempodb.Writer
 	tempodb.Compactor
+
+	gossip      *blocklistGossip
+	watchOnce   sync.Once
+	watchCtx    context.Context
+	watchCancel context.CancelFunc
 }
 
-// NewStore creates a new Tempo Store using configuration supplied.
-func NewStore(cfg Config, logger log.Logger) (Store, error) {
+// NewStore creates a new Tempo Store using configuration supplied. If gossipKV is not nil, blocks added or
+// compacted by this process are published to it and blocklist events of other components are applied
+// once polling is enabled.
+func NewStore(cfg Config, gossipKV kv.Client, logger log.Logger) (Store, error) {
 
 	statCache.Set(cfg.Trace.Cache)
 	statBackend.Set(cfg.Trace.Backend)
@@ -59,6 +69,12 @@ func NewStore(cfg Config, logger log.Logger) (Store, error) {
 		Writer:    w,
 		Compactor: c,
 	}
+	s.watchCtx, s.watchCancel = context.WithCancel(context.Background())
+
+	if gossipKV != nil {
+		s.gossip = newBlocklistGossip(gossipKV, cfg.Trace.BlocklistGossip.EventTTL, logger)
+		w.SetBlocklistNotifier(s.gossip)
+	}
 
 	s.Service = services.NewIdleService(s.starting, s.stopping)
 	return s, nil
@@ -68,7 +84,20 @@ func (s *store) starting(_ context.Context) error {
 	return nil
 }
 
+// EnablePolling activates polling of the blocklist. With blocklist gossip, events of other components are
+// applied in between polls.
+func (s *store) EnablePolling(sharder blocklist.JobSharder) {
+	s.Reader.EnablePolling(sharder)
+
+	if s.gossip != nil {
+		s.watchOnce.Do(func() {
+			go s.gossip.watch(s.watchCtx, s.Reader.UpdateBlocklist)
+		})
+	}
+}
+
 func (s *store) stopping(_ error) error {
+	s.watchCancel()
 	s.Reader.Shutdown()
 
 	return nil
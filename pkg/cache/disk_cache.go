@@ -0,0 +1,331 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	instr "github.com/weaveworks/common/instrument"
+)
+
+const (
+	diskCacheTempSuffix = ".tmp"
+
+	// diskCacheTouchInterval is how often the modification time of a file is updated when it is hit
+	diskCacheTouchInterval = time.Minute
+)
+
+// DiskCacheConfig is config to make a DiskCache
+type DiskCacheConfig struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes uint64 `yaml:"max_size_bytes"`
+}
+
+// DiskCache caches objects as files in a local directory. The total size is bounded and least recently used
+// objects are evicted first. Cached objects survive restarts: the index is rebuilt from the directory on start
+// using the modification time of the files as recency. Hits update it at most every diskCacheTouchInterval,
+// the recency in memory is exact.
+type DiskCache struct {
+	cfg    DiskCacheConfig
+	name   string
+	logger log.Logger
+
+	mtx   sync.Mutex
+	lru   *list.List // of *diskCacheEntry, most recently used first
+	items map[string]*list.Element
+	size  uint64
+
+	requestDuration *instr.HistogramCollector
+	sizeBytes       prometheus.Gauge
+	entries         prometheus.Gauge
+	evictions       prometheus.Counter
+}
+
+type diskCacheEntry struct {
+	file    string
+	size    uint64
+	touched time.Time // modification time of the file
+}
+
+// NewDiskCache makes a new DiskCache and loads the objects already present in the directory.
+func NewDiskCache(cfg DiskCacheConfig, name string, reg prometheus.Registerer, logger log.Logger) (*DiskCache, error) {
+	c := &DiskCache{
+		cfg:    cfg,
+		name:   name,
+		logger: logger,
+		lru:    list.New(),
+		items:  map[string]*list.Element{},
+		requestDuration: instr.NewHistogramCollector(
+			promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+				Namespace:   "tempo",
+				Name:        "disk_cache_request_duration_seconds",
+				Help:        "Total time spent in seconds doing disk cache requests.",
+				Buckets:     prometheus.ExponentialBuckets(0.000016, 4, 8),
+				ConstLabels: prometheus.Labels{"name": name},
+			}, []string{"method", "status_code"}),
+		),
+		sizeBytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace:   "tempo",
+			Name:        "disk_cache_size_bytes",
+			Help:        "Total size of the objects in the disk cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		entries: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace:   "tempo",
+			Name:        "disk_cache_entries",
+			Help:        "Number of objects in the disk cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		evictions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "tempo",
+			Name:        "disk_cache_evictions_total",
+			Help:        "Total number of objects evicted from the disk cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+
+	if err := os.MkdirAll(cfg.Path, 0o700); err != nil {
+		return nil, err
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load rebuilds the index from the files in the cache directory. Leftovers of interrupted writes are removed.
+func (c *DiskCache) load() error {
+	var entries []*diskCacheEntry
+
+	err := filepath.WalkDir(c.cfg.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, diskCacheTempSuffix) {
+			return os.Remove(path)
+		}
+
+		name := filepath.Base(path)
+		if len(name) != sha256.Size*2 {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, &diskCacheEntry{file: name, size: uint64(info.Size()), touched: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].touched.After(entries[j].touched)
+	})
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, e := range entries {
+		c.items[e.file] = c.lru.PushBack(e)
+		c.size += e.size
+	}
+	c.evict()
+	c.updateMetrics()
+
+	level.Info(c.logger).Log("msg", "loaded disk cache", "name", c.name, "entries", c.lru.Len(), "size", c.size)
+	return nil
+}
+
+// Fetch gets keys from the cache. The keys that are found must be in the order of the keys requested.
+func (c *DiskCache) Fetch(ctx context.Context, keys []string) (found []string, bufs [][]byte, missed []string) {
+	_ = instr.CollectedRequest(ctx, "DiskCache.Fetch", c.requestDuration, instr.ErrorCode, func(_ context.Context) error {
+		for _, key := range keys {
+			buf, ok := c.fetch(key)
+			if ok {
+				found = append(found, key)
+				bufs = append(bufs, buf)
+			} else {
+				missed = append(missed, key)
+			}
+		}
+		return nil
+	})
+
+	return
+}
+
+func (c *DiskCache) fetch(key string) ([]byte, bool) {
+	file := diskCacheFileName(key)
+
+	c.mtx.Lock()
+	_, ok := c.items[file]
+	c.mtx.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(file)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		// evicted concurrently or removed from disk
+		c.mtx.Lock()
+		c.remove(file)
+		c.updateMetrics()
+		c.mtx.Unlock()
+		return nil, false
+	}
+
+	// persist the recency for restarts, but not on every hit of frequently used objects
+	now := time.Now()
+	touch := false
+
+	c.mtx.Lock()
+	if e, ok := c.items[file]; ok {
+		c.lru.MoveToFront(e)
+
+		entry := e.Value.(*diskCacheEntry)
+		if now.Sub(entry.touched) >= diskCacheTouchInterval {
+			entry.touched = now
+			touch = true
+		}
+	}
+	c.mtx.Unlock()
+
+	if touch {
+		_ = os.Chtimes(path, now, now)
+	}
+
+	return buf, true
+}
+
+// Store stores the keys in the cache and evicts the least recently used objects if the cache is full.
+func (c *DiskCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	_ = instr.CollectedRequest(ctx, "DiskCache.Store", c.requestDuration, instr.ErrorCode, func(_ context.Context) error {
+		for i := range keys {
+			if err := c.store(keys[i], bufs[i]); err != nil {
+				level.Error(c.logger).Log("msg", "failed to store to disk cache", "name", c.name, "err", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *DiskCache) store(key string, buf []byte) error {
+	size := uint64(len(buf))
+	if size > c.cfg.MaxSizeBytes {
+		return nil
+	}
+
+	file := diskCacheFileName(key)
+	path := c.path(file)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// write to a temp file first so that readers and restarts never see partial objects
+	tmp, err := os.CreateTemp(filepath.Dir(path), file+"-*"+diskCacheTempSuffix)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	now := time.Now()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.items[file]; ok {
+		entry := e.Value.(*diskCacheEntry)
+		c.size = c.size - entry.size + size
+		entry.size = size
+		entry.touched = now
+		c.lru.MoveToFront(e)
+	} else {
+		c.items[file] = c.lru.PushFront(&diskCacheEntry{file: file, size: size, touched: now})
+		c.size += size
+	}
+	c.evict()
+	c.updateMetrics()
+
+	return nil
+}
+
+// evict removes least recently used objects until the cache fits into its max size. Must be called with the lock held.
+func (c *DiskCache) evict() {
+	for c.size > c.cfg.MaxSizeBytes {
+		e := c.lru.Back()
+		if e == nil {
+			return
+		}
+
+		file := e.Value.(*diskCacheEntry).file
+		if err := os.Remove(c.path(file)); err != nil && !os.IsNotExist(err) {
+			level.Warn(c.logger).Log("msg", "failed to evict from disk cache", "name", c.name, "err", err)
+		}
+		c.remove(file)
+		c.evictions.Inc()
+	}
+}
+
+// remove drops a file from the index. Must be called with the lock held.
+func (c *DiskCache) remove(file string) {
+	e, ok := c.items[file]
+	if !ok {
+		return
+	}
+
+	c.lru.Remove(e)
+	delete(c.items, file)
+	c.size -= e.Value.(*diskCacheEntry).size
+}
+
+func (c *DiskCache) updateMetrics() {
+	c.sizeBytes.Set(float64(c.size))
+	c.entries.Set(float64(c.lru.Len()))
+}
+
+// path spreads the files over subdirectories to keep directories small
+func (c *DiskCache) path(file string) string {
+	return filepath.Join(c.cfg.Path, file[:2], file)
+}
+
+// Stop implements Cache. Cached objects are kept on disk.
+func (c *DiskCache) Stop() {
+}
+
+func diskCacheFileName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDiskCache(t *testing.T, path string, maxSize uint64) *DiskCache {
+	c, err := NewDiskCache(DiskCacheConfig{Path: path, MaxSizeBytes: maxSize}, "test", prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	return c
+}
+
+func TestDiskCache(t *testing.T) {
+	c := newTestDiskCache(t, t.TempDir(), 1000)
+	ctx := context.Background()
+
+	keys := []string{"key1", "key2", "key3"}
+	bufs := [][]byte{[]byte("data1"), []byte("data2"), []byte("data3")}
+	c.Store(ctx, keys, bufs)
+
+	found, data, missed := c.Fetch(ctx, []string{"key1", "miss", "key3"})
+	require.Equal(t, []string{"key1", "key3"}, found)
+	require.Equal(t, [][]byte{bufs[0], bufs[2]}, data)
+	require.Equal(t, []string{"miss"}, missed)
+
+	// overwrite
+	c.Store(ctx, []string{"key1"}, [][]byte{[]byte("new data1")})
+	_, data, _ = c.Fetch(ctx, []string{"key1"})
+	require.Equal(t, []byte("new data1"), data[0])
+	require.Equal(t, uint64(len("new data1data2data3")), c.size)
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	c := newTestDiskCache(t, t.TempDir(), 30)
+	ctx := context.Background()
+
+	c.Store(ctx, []string{"a", "b", "c"}, [][]byte{make([]byte, 10), make([]byte, 10), make([]byte, 10)})
+
+	// use a so that b is the least recently used
+	found, _, _ := c.Fetch(ctx, []string{"a"})
+	require.Len(t, found, 1)
+
+	c.Store(ctx, []string{"d"}, [][]byte{make([]byte, 10)})
+
+	found, _, missed := c.Fetch(ctx, []string{"a", "b", "c", "d"})
+	require.Equal(t, []string{"a", "c", "d"}, found)
+	require.Equal(t, []string{"b"}, missed)
+	require.Equal(t, uint64(30), c.size)
+
+	// objects larger than the cache are not stored
+	c.Store(ctx, []string{"e"}, [][]byte{make([]byte, 31)})
+	found, _, _ = c.Fetch(ctx, []string{"a", "c", "d", "e"})
+	require.Equal(t, []string{"a", "c", "d"}, found)
+}
+
+func TestDiskCachePersistence(t *testing.T) {
+	path := t.TempDir()
+	ctx := context.Background()
+
+	c := newTestDiskCache(t, path, 30)
+	c.Store(ctx, []string{"a", "b", "c"}, [][]byte{[]byte("aaaaaaaaaa"), []byte("bbbbbbbbbb"), []byte("cccccccccc")})
+
+	// make recency visible to the modification times of the files
+	past := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b", "c"} {
+		ts := past.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(c.path(diskCacheFileName(key)), ts, ts))
+	}
+	c.Stop()
+
+	// hits update the modification time once per touch interval
+	c = newTestDiskCache(t, path, 30)
+	c.Fetch(ctx, []string{"a"})
+	info, err := os.Stat(c.path(diskCacheFileName("a")))
+	require.NoError(t, err)
+	touched := info.ModTime()
+	require.True(t, touched.After(past.Add(time.Minute)))
+
+	c.Fetch(ctx, []string{"a"})
+	info, err = os.Stat(c.path(diskCacheFileName("a")))
+	require.NoError(t, err)
+	require.Equal(t, touched, info.ModTime())
+	c.Stop()
+
+	// leftover of an interrupted write
+	tmp := filepath.Join(path, "ab", "interrupted"+diskCacheTempSuffix)
+	require.NoError(t, os.MkdirAll(filepath.Dir(tmp), 0o700))
+	require.NoError(t, os.WriteFile(tmp, []byte("partial"), 0o600))
+
+	c = newTestDiskCache(t, path, 30)
+	require.Equal(t, uint64(30), c.size)
+	require.NoFileExists(t, tmp)
+
+	// b was used least recently before the restart
+	c.Store(ctx, []string{"d"}, [][]byte{[]byte("dddddddddd")})
+	found, data, _ := c.Fetch(ctx, []string{"a", "b", "c", "d"})
+	require.Equal(t, []string{"a", "c", "d"}, found)
+	require.Equal(t, []byte("aaaaaaaaaa"), data[0])
+
+	// a smaller max size evicts on start
+	c = newTestDiskCache(t, path, 20)
+	require.Equal(t, uint64(20), c.size)
+}
@@ -122,6 +122,21 @@ func MatchesProto(id []byte, trace *tempopb.Trace, req *tempopb.SearchRequest) (
 	}, nil
 }
 
+// ServiceName returns the service name of the resource of the batch or an empty string if it has none
+func ServiceName(b *v1.ResourceSpans) string {
+	if b.Resource == nil {
+		return ""
+	}
+
+	for _, a := range b.Resource.Attributes {
+		if a.Key == ServiceNameTag {
+			return a.Value.GetStringValue()
+		}
+	}
+
+	return ""
+}
+
 func allTagsFound(tagsToFind map[string]string) bool {
 	return len(tagsToFind) == 0
 }
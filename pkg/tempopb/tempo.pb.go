@@ -472,6 +472,9 @@ type TraceSearchMetadata struct {
 	RootTraceName     string `protobuf:"bytes,3,opt,name=rootTraceName,proto3" json:"rootTraceName,omitempty"`
 	StartTimeUnixNano uint64 `protobuf:"varint,4,opt,name=startTimeUnixNano,proto3" json:"startTimeUnixNano,omitempty"`
 	DurationMs        uint32 `protobuf:"varint,5,opt,name=durationMs,proto3" json:"durationMs,omitempty"`
+	// true if the root span and all referenced parent spans of the trace were received. only set for live traces
+	// of ingesters with trace completeness detection enabled.
+	Complete bool `protobuf:"varint,6,opt,name=complete,proto3" json:"complete,omitempty"`
 }
 
 func (m *TraceSearchMetadata) Reset()         { *m = TraceSearchMetadata{} }
@@ -542,6 +545,13 @@ func (m *TraceSearchMetadata) GetDurationMs() uint32 {
 	return 0
 }
 
+func (m *TraceSearchMetadata) GetComplete() bool {
+	if m != nil {
+		return m.Complete
+	}
+	return false
+}
+
 type SearchMetrics struct {
 	InspectedTraces uint32 `protobuf:"varint,1,opt,name=inspectedTraces,proto3" json:"inspectedTraces,omitempty"`
 	InspectedBytes  uint64 `protobuf:"varint,2,opt,name=inspectedBytes,proto3" json:"inspectedBytes,omitempty"`
@@ -1009,6 +1019,68 @@ func (m *TraceBytes) GetTraces() [][]byte {
 	return nil
 }
 
+type TransferRequest struct {
+	// id of the ingester handing off its data
+	FromIngesterID string `protobuf:"bytes,1,opt,name=fromIngesterID,proto3" json:"fromIngesterID,omitempty"`
+	TenantID       string `protobuf:"bytes,2,opt,name=tenantID,proto3" json:"tenantID,omitempty"`
+	// traces of the tenant encoded like pushes to PushBytesV2
+	Request *PushBytesRequest `protobuf:"bytes,3,opt,name=request,proto3" json:"request,omitempty"`
+}
+
+func (m *TransferRequest) Reset()         { *m = TransferRequest{} }
+func (m *TransferRequest) String() string { return proto.CompactTextString(m) }
+func (*TransferRequest) ProtoMessage()    {}
+func (*TransferRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{17}
+}
+func (m *TransferRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TransferRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TransferRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TransferRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TransferRequest.Merge(m, src)
+}
+func (m *TransferRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *TransferRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TransferRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TransferRequest proto.InternalMessageInfo
+
+func (m *TransferRequest) GetFromIngesterID() string {
+	if m != nil {
+		return m.FromIngesterID
+	}
+	return ""
+}
+
+func (m *TransferRequest) GetTenantID() string {
+	if m != nil {
+		return m.TenantID
+	}
+	return ""
+}
+
+func (m *TransferRequest) GetRequest() *PushBytesRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*TraceByIDRequest)(nil), "tempopb.TraceByIDRequest")
 	proto.RegisterType((*TraceByIDResponse)(nil), "tempopb.TraceByIDResponse")
@@ -1028,83 +1100,90 @@ func init() {
 	proto.RegisterType((*PushBytesRequest)(nil), "tempopb.PushBytesRequest")
 	proto.RegisterType((*PushSpansRequest)(nil), "tempopb.PushSpansRequest")
 	proto.RegisterType((*TraceBytes)(nil), "tempopb.TraceBytes")
+	proto.RegisterType((*TransferRequest)(nil), "tempopb.TransferRequest")
 }
 
 func init() { proto.RegisterFile("pkg/tempopb/tempo.proto", fileDescriptor_f22805646f4f62b6) }
 
 var fileDescriptor_f22805646f4f62b6 = []byte{
-	// 1131 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0x4d, 0x6f, 0xdb, 0x46,
-	0x13, 0x36, 0x2d, 0xc9, 0xb2, 0x46, 0x92, 0x3f, 0x36, 0x89, 0xcd, 0x97, 0x31, 0x64, 0x81, 0x30,
-	0xde, 0xea, 0xd0, 0xc8, 0x89, 0x92, 0x36, 0x6d, 0x2e, 0x45, 0x05, 0xbb, 0xa9, 0x81, 0x2a, 0x70,
-	0x29, 0xd7, 0xf7, 0x15, 0xb9, 0x96, 0x09, 0x4b, 0x5c, 0x86, 0x5c, 0x19, 0x76, 0x4f, 0xed, 0xa5,
-	0xa7, 0x1e, 0xfa, 0x17, 0x0a, 0xf4, 0xc7, 0xe4, 0x52, 0x20, 0xc7, 0xa2, 0x87, 0xa0, 0xb0, 0xff,
-	0x47, 0x51, 0xec, 0xa7, 0x48, 0xfa, 0xe3, 0xd0, 0x9e, 0xc4, 0x79, 0xf6, 0xd9, 0xd9, 0x99, 0x67,
-	0x67, 0x66, 0x05, 0x9b, 0xf1, 0xd9, 0x78, 0x97, 0x91, 0x69, 0x4c, 0xe3, 0x91, 0xfc, 0xed, 0xc6,
-	0x09, 0x65, 0x14, 0x55, 0x15, 0xe8, 0x3c, 0x64, 0x09, 0xf6, 0xc9, 0xee, 0xf9, 0xb3, 0x5d, 0xf1,
-	0x21, 0x97, 0x9d, 0x27, 0xe3, 0x90, 0x9d, 0xce, 0x46, 0x5d, 0x9f, 0x4e, 0x77, 0xc7, 0x74, 0x4c,
-	0x77, 0x05, 0x3c, 0x9a, 0x9d, 0x08, 0x4b, 0x18, 0xe2, 0x4b, 0xd2, 0xdd, 0x9f, 0x2c, 0x58, 0x3b,
-	0xe2, 0xdb, 0xfb, 0x97, 0x07, 0x7b, 0x1e, 0x79, 0x3b, 0x23, 0x29, 0x43, 0x36, 0x54, 0x85, 0xcb,
-	0x83, 0x3d, 0xdb, 0x6a, 0x5b, 0x9d, 0x86, 0xa7, 0x4d, 0xd4, 0x02, 0x18, 0x4d, 0xa8, 0x7f, 0x36,
-	0x64, 0x38, 0x61, 0xf6, 0x62, 0xdb, 0xea, 0xd4, 0xbc, 0x0c, 0x82, 0x1c, 0x58, 0x16, 0xd6, 0x7e,
-	0x14, 0xd8, 0x25, 0xb1, 0x6a, 0x6c, 0xb4, 0x05, 0xb5, 0xb7, 0x33, 0x92, 0x5c, 0x0e, 0x68, 0x40,
-	0xec, 0x8a, 0x58, 0x9c, 0x03, 0x6e, 0x04, 0xeb, 0x99, 0x38, 0xd2, 0x98, 0x46, 0x29, 0x41, 0x3b,
-	0x50, 0x11, 0x27, 0x8b, 0x30, 0xea, 0xbd, 0x95, 0xae, 0xca, 0xbd, 0x2b, 0xa8, 0x9e, 0x5c, 0x44,
-	0xcf, 0xa1, 0x3a, 0x25, 0x2c, 0x09, 0xfd, 0x54, 0x44, 0x54, 0xef, 0xfd, 0x2f, 0xcf, 0xe3, 0x2e,
-	0x07, 0x92, 0xe0, 0x69, 0xa6, 0xfb, 0x69, 0x26, 0x6f, 0xb5, 0x88, 0x5c, 0x68, 0x9c, 0xe0, 0x70,
-	0x42, 0x82, 0x3e, 0x8f, 0x39, 0x15, 0xa7, 0x36, 0xbd, 0x1c, 0xe6, 0xfe, 0xb6, 0x08, 0xcd, 0x21,
-	0xc1, 0x89, 0x7f, 0xaa, 0xd5, 0x7a, 0x05, 0xe5, 0x23, 0x3c, 0xe6, 0xec, 0x52, 0xa7, 0xde, 0x6b,
-	0x9b, 0xb3, 0x73, 0xac, 0x2e, 0xa7, 0xec, 0x47, 0x2c, 0xb9, 0xec, 0x97, 0xdf, 0x7d, 0xd8, 0x5e,
-	0xf0, 0xc4, 0x1e, 0xb4, 0x03, 0xcd, 0x41, 0x18, 0xed, 0xcd, 0x12, 0xcc, 0x42, 0x1a, 0x0d, 0x64,
-	0x02, 0x4d, 0x2f, 0x0f, 0x0a, 0x16, 0xbe, 0xc8, 0xb0, 0x4a, 0x8a, 0x95, 0x05, 0xd1, 0x43, 0xa8,
-	0x7c, 0x13, 0x4e, 0x43, 0x66, 0x97, 0xc5, 0xaa, 0x34, 0x38, 0x9a, 0x8a, 0xcb, 0xaa, 0x48, 0x54,
-	0x18, 0x68, 0x0d, 0x4a, 0x24, 0x0a, 0xec, 0x25, 0x81, 0xf1, 0x4f, 0xce, 0xfb, 0x96, 0x5f, 0x86,
-	0xbd, 0x2c, 0x6e, 0x46, 0x1a, 0xce, 0x4b, 0xa8, 0x99, 0xc0, 0xf9, 0xa6, 0x33, 0x72, 0x29, 0x54,
-	0xa9, 0x79, 0xfc, 0x93, 0x6f, 0x3a, 0xc7, 0x93, 0x19, 0x51, 0x95, 0x20, 0x8d, 0x57, 0x8b, 0x9f,
-	0x59, 0xee, 0x0f, 0x25, 0x40, 0x52, 0x00, 0xa1, 0x9b, 0xd6, 0xea, 0x05, 0xd4, 0x52, 0x2d, 0x8b,
-	0xba, 0xd4, 0x8d, 0xdb, 0x05, 0xf3, 0xe6, 0x44, 0x5e, 0x8f, 0xa2, 0x8a, 0x0e, 0xf6, 0xd4, 0x41,
-	0xda, 0xe4, 0x35, 0x25, 0x12, 0x3a, 0xc4, 0x63, 0xa2, 0x54, 0x99, 0x03, 0x5c, 0xb7, 0x18, 0x8f,
-	0x49, 0x7a, 0x44, 0xa5, 0x6b, 0xa5, 0x4c, 0x1e, 0xe4, 0x35, 0x4b, 0x22, 0x9f, 0x06, 0x61, 0x34,
-	0x56, 0x65, 0x69, 0x6c, 0xee, 0x21, 0x8c, 0x02, 0x72, 0xc1, 0xdd, 0x0d, 0xc3, 0xef, 0x89, 0x52,
-	0x2c, 0x0f, 0xf2, 0xba, 0x61, 0x94, 0xe1, 0x89, 0x47, 0x7c, 0x9a, 0x04, 0xa9, 0x5d, 0x95, 0x75,
-	0x93, 0xc5, 0x38, 0x27, 0xc0, 0x0c, 0xef, 0xeb, 0x93, 0xa4, 0xcc, 0x39, 0x8c, 0xe7, 0x79, 0x4e,
-	0x92, 0x34, 0xa4, 0x91, 0x5d, 0x93, 0x79, 0x2a, 0x13, 0x21, 0x28, 0xa7, 0xfc, 0x78, 0x68, 0x5b,
-	0x9d, 0xb2, 0x27, 0xbe, 0x79, 0x2f, 0x9e, 0x50, 0xca, 0x48, 0x22, 0x02, 0xab, 0x8b, 0x33, 0x33,
-	0x88, 0x7b, 0x01, 0x2b, 0x5a, 0x51, 0xd5, 0x4e, 0x2f, 0x60, 0x49, 0x74, 0x8c, 0xae, 0xd5, 0xad,
-	0x7c, 0x9f, 0x48, 0xf6, 0x80, 0x30, 0xcc, 0xa3, 0xf2, 0x14, 0x17, 0x3d, 0x2d, 0xb6, 0x57, 0xf1,
-	0xc6, 0x6e, 0xf4, 0xd6, 0xef, 0x16, 0x3c, 0xb8, 0xc5, 0x63, 0x71, 0xae, 0xd4, 0xe6, 0x73, 0xa5,
-	0x03, 0xab, 0x09, 0xa5, 0x6c, 0x48, 0x92, 0xf3, 0xd0, 0x27, 0x6f, 0xf0, 0x54, 0x97, 0x54, 0x11,
-	0xe6, 0x37, 0xc2, 0x21, 0xe1, 0x5e, 0xf0, 0xe4, 0x98, 0xc9, 0x83, 0xe8, 0x63, 0x58, 0x17, 0x65,
-	0x70, 0x14, 0x4e, 0xc9, 0x77, 0x51, 0x78, 0xf1, 0x06, 0x47, 0x54, 0xdc, 0x7e, 0xd9, 0xbb, 0xb9,
-	0xc0, 0x95, 0x0c, 0xe6, 0xcd, 0x25, 0x1b, 0x25, 0x83, 0xb8, 0x3f, 0x9a, 0x9e, 0xd7, 0x93, 0xa2,
-	0x03, 0xab, 0x61, 0x94, 0xc6, 0xc4, 0x67, 0x24, 0x38, 0xd2, 0x92, 0xf2, 0x6d, 0x45, 0x18, 0xfd,
-	0x1f, 0x56, 0x0c, 0xd4, 0xbf, 0x64, 0x44, 0x8a, 0x58, 0xf6, 0x0a, 0x68, 0xce, 0xa3, 0x1a, 0x3f,
-	0xa5, 0x82, 0x47, 0x09, 0x73, 0x05, 0xd2, 0xb3, 0x30, 0x8e, 0x0d, 0x4f, 0x55, 0x75, 0x0e, 0xcc,
-	0xb0, 0x54, 0x7c, 0x95, 0x1c, 0x4b, 0x45, 0xd7, 0x81, 0x55, 0x51, 0xa5, 0x62, 0x93, 0x0c, 0x6f,
-	0x49, 0x84, 0x57, 0x84, 0xdd, 0x07, 0xb0, 0x2e, 0x25, 0xe0, 0xf3, 0x40, 0xf5, 0xa8, 0xfb, 0x54,
-	0x37, 0xb9, 0x04, 0x55, 0x99, 0x39, 0xb0, 0xcc, 0xf0, 0x98, 0xdf, 0x83, 0x2c, 0xb4, 0x9a, 0x67,
-	0x6c, 0xb7, 0x07, 0x1b, 0x66, 0xc7, 0x31, 0x9f, 0x16, 0x69, 0xf6, 0xd1, 0x91, 0x2c, 0x53, 0x1c,
-	0xd2, 0x74, 0x5f, 0xc2, 0xe6, 0x8d, 0x3d, 0xea, 0xa8, 0x2d, 0xa8, 0x31, 0x0d, 0xaa, 0xb3, 0xe6,
-	0x80, 0xdb, 0x87, 0x8a, 0xc8, 0x13, 0x7d, 0x0e, 0xd5, 0x11, 0x66, 0xfe, 0xa9, 0xa9, 0xfc, 0x6d,
-	0x53, 0xc2, 0xf2, 0xed, 0x3c, 0x7f, 0xd6, 0xf5, 0x48, 0x4a, 0x67, 0x89, 0x4f, 0x86, 0x31, 0x8e,
-	0x52, 0x4f, 0xf3, 0xdd, 0x15, 0x68, 0x1c, 0xce, 0x52, 0xd3, 0x43, 0xee, 0xaf, 0x16, 0xac, 0x71,
-	0x40, 0xa8, 0xa2, 0x63, 0x7f, 0x62, 0x1a, 0x6b, 0xb1, 0x5d, 0xea, 0x34, 0xfa, 0x8f, 0xf8, 0x88,
-	0xff, 0xf3, 0xc3, 0x76, 0xf3, 0x30, 0x21, 0x78, 0x32, 0xa1, 0xbe, 0x64, 0xeb, 0x8e, 0xfa, 0x08,
-	0x4a, 0x61, 0xc0, 0xef, 0xf7, 0x1e, 0x2e, 0x67, 0xa0, 0x4f, 0x00, 0xe4, 0x14, 0xdc, 0xc3, 0x0c,
-	0xdb, 0xe5, 0xfb, 0xf8, 0x19, 0xa2, 0x3b, 0x90, 0x21, 0xca, 0x4c, 0x54, 0x88, 0xff, 0x41, 0x82,
-	0x1d, 0x00, 0xf5, 0x54, 0xf2, 0x42, 0xdd, 0xc8, 0x0d, 0x91, 0x86, 0x4e, 0xaa, 0xf7, 0xb3, 0x05,
-	0x4b, 0xfc, 0x54, 0x92, 0xa0, 0x2f, 0xa0, 0x66, 0x24, 0x42, 0xf3, 0xc7, 0xb8, 0x28, 0x9b, 0xf3,
-	0x28, 0xb7, 0x64, 0x24, 0x5e, 0x40, 0x5f, 0x42, 0xdd, 0x90, 0x8f, 0x7b, 0xff, 0xc6, 0x45, 0x6f,
-	0x08, 0x6b, 0xaa, 0x59, 0x5f, 0x93, 0x88, 0x24, 0x98, 0x51, 0x13, 0x97, 0x48, 0xaf, 0xe0, 0x34,
-	0xab, 0xd5, 0xdd, 0x4e, 0xff, 0x5e, 0x84, 0x2a, 0x7f, 0x18, 0x43, 0x92, 0xa0, 0xaf, 0xa1, 0xf9,
-	0x55, 0x18, 0x05, 0xe6, 0x4f, 0x04, 0xba, 0xe5, 0x5f, 0x87, 0x76, 0xe8, 0xdc, 0xb6, 0x94, 0xc9,
-	0xb6, 0xa1, 0x07, 0xb5, 0x4f, 0x22, 0x86, 0xee, 0x78, 0x11, 0x9d, 0xcd, 0x1b, 0xb8, 0x71, 0xb1,
-	0x0f, 0xf5, 0xcc, 0x6b, 0x8b, 0x1e, 0x17, 0x98, 0xd9, 0x37, 0xf8, 0x3e, 0x37, 0xaf, 0x01, 0xe6,
-	0xfd, 0x8c, 0x9c, 0x02, 0x31, 0xd3, 0xf9, 0xce, 0xe3, 0x5b, 0xd7, 0x8c, 0xa3, 0x63, 0x58, 0x2d,
-	0xb4, 0x2c, 0xda, 0xbe, 0xb9, 0x23, 0x37, 0x00, 0x9c, 0xf6, 0xdd, 0x04, 0xed, 0xb7, 0x6f, 0xbf,
-	0xbb, 0x6a, 0x59, 0xef, 0xaf, 0x5a, 0xd6, 0x5f, 0x57, 0x2d, 0xeb, 0x97, 0xeb, 0xd6, 0xc2, 0xfb,
-	0xeb, 0xd6, 0xc2, 0x1f, 0xd7, 0xad, 0x85, 0xd1, 0x92, 0xf8, 0x3f, 0xfb, 0xfc, 0x9f, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0xa6, 0x95, 0x7f, 0x34, 0x38, 0x0b, 0x00, 0x00,
+	// 1218 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0x4f, 0x6f, 0x1b, 0x45,
+	0x14, 0xcf, 0xc6, 0x76, 0x1c, 0x3f, 0xdb, 0x49, 0x3a, 0xfd, 0xb7, 0xb8, 0x95, 0x63, 0xad, 0xaa,
+	0xe2, 0x03, 0x75, 0x5a, 0xb7, 0x50, 0xe8, 0xa5, 0xc2, 0x4a, 0x68, 0x83, 0x70, 0x55, 0xd6, 0xa6,
+	0xf7, 0xf1, 0xee, 0xd8, 0x59, 0xc5, 0xde, 0x71, 0x67, 0xc7, 0x51, 0xc2, 0x09, 0x2e, 0x1c, 0x10,
+	0x07, 0xbe, 0x02, 0x12, 0x1f, 0xa6, 0xc7, 0x1e, 0x11, 0x87, 0x0a, 0x25, 0x27, 0xbe, 0x04, 0x42,
+	0xf3, 0xd7, 0xbb, 0x9b, 0x3f, 0x07, 0x38, 0xd9, 0xef, 0x37, 0xbf, 0x79, 0xf3, 0xe6, 0xbd, 0xdf,
+	0x7b, 0xb3, 0x70, 0x7b, 0x7e, 0x38, 0xd9, 0xe1, 0x64, 0x36, 0xa7, 0xf3, 0x91, 0xfa, 0xed, 0xcc,
+	0x19, 0xe5, 0x14, 0x95, 0x35, 0xd8, 0xb8, 0xc1, 0x19, 0x0e, 0xc8, 0xce, 0xd1, 0xa3, 0x1d, 0xf9,
+	0x47, 0x2d, 0x37, 0x1e, 0x4c, 0x22, 0x7e, 0xb0, 0x18, 0x75, 0x02, 0x3a, 0xdb, 0x99, 0xd0, 0x09,
+	0xdd, 0x91, 0xf0, 0x68, 0x31, 0x96, 0x96, 0x34, 0xe4, 0x3f, 0x45, 0xf7, 0x7e, 0x72, 0x60, 0x6b,
+	0x28, 0xb6, 0xf7, 0x4e, 0xf6, 0x77, 0x7d, 0xf2, 0x76, 0x41, 0x12, 0x8e, 0x5c, 0x28, 0x4b, 0x97,
+	0xfb, 0xbb, 0xae, 0xd3, 0x72, 0xda, 0x35, 0xdf, 0x98, 0xa8, 0x09, 0x30, 0x9a, 0xd2, 0xe0, 0x70,
+	0xc0, 0x31, 0xe3, 0xee, 0x6a, 0xcb, 0x69, 0x57, 0xfc, 0x14, 0x82, 0x1a, 0xb0, 0x2e, 0xad, 0xbd,
+	0x38, 0x74, 0x0b, 0x72, 0xd5, 0xda, 0xe8, 0x2e, 0x54, 0xde, 0x2e, 0x08, 0x3b, 0xe9, 0xd3, 0x90,
+	0xb8, 0x25, 0xb9, 0xb8, 0x04, 0xbc, 0x18, 0xae, 0xa5, 0xe2, 0x48, 0xe6, 0x34, 0x4e, 0x08, 0xba,
+	0x07, 0x25, 0x79, 0xb2, 0x0c, 0xa3, 0xda, 0xdd, 0xe8, 0xe8, 0xbb, 0x77, 0x24, 0xd5, 0x57, 0x8b,
+	0xe8, 0x31, 0x94, 0x67, 0x84, 0xb3, 0x28, 0x48, 0x64, 0x44, 0xd5, 0xee, 0x47, 0x59, 0x9e, 0x70,
+	0xd9, 0x57, 0x04, 0xdf, 0x30, 0xbd, 0xcf, 0x52, 0xf7, 0xd6, 0x8b, 0xc8, 0x83, 0xda, 0x18, 0x47,
+	0x53, 0x12, 0xf6, 0x44, 0xcc, 0x89, 0x3c, 0xb5, 0xee, 0x67, 0x30, 0xef, 0xf7, 0x55, 0xa8, 0x0f,
+	0x08, 0x66, 0xc1, 0x81, 0xc9, 0xd6, 0x33, 0x28, 0x0e, 0xf1, 0x44, 0xb0, 0x0b, 0xed, 0x6a, 0xb7,
+	0x65, 0xcf, 0xce, 0xb0, 0x3a, 0x82, 0xb2, 0x17, 0x73, 0x76, 0xd2, 0x2b, 0xbe, 0xfb, 0xb0, 0xbd,
+	0xe2, 0xcb, 0x3d, 0xe8, 0x1e, 0xd4, 0xfb, 0x51, 0xbc, 0xbb, 0x60, 0x98, 0x47, 0x34, 0xee, 0xab,
+	0x0b, 0xd4, 0xfd, 0x2c, 0x28, 0x59, 0xf8, 0x38, 0xc5, 0x2a, 0x68, 0x56, 0x1a, 0x44, 0x37, 0xa0,
+	0xf4, 0x4d, 0x34, 0x8b, 0xb8, 0x5b, 0x94, 0xab, 0xca, 0x10, 0x68, 0x22, 0x8b, 0x55, 0x52, 0xa8,
+	0x34, 0xd0, 0x16, 0x14, 0x48, 0x1c, 0xba, 0x6b, 0x12, 0x13, 0x7f, 0x05, 0xef, 0x5b, 0x51, 0x0c,
+	0x77, 0x5d, 0x56, 0x46, 0x19, 0x8d, 0xa7, 0x50, 0xb1, 0x81, 0x8b, 0x4d, 0x87, 0xe4, 0x44, 0x66,
+	0xa5, 0xe2, 0x8b, 0xbf, 0x62, 0xd3, 0x11, 0x9e, 0x2e, 0x88, 0x56, 0x82, 0x32, 0x9e, 0xad, 0x7e,
+	0xee, 0x78, 0x3f, 0x14, 0x00, 0xa9, 0x04, 0xc8, 0xbc, 0x99, 0x5c, 0x3d, 0x81, 0x4a, 0x62, 0xd2,
+	0xa2, 0x8b, 0x7a, 0xeb, 0xe2, 0x84, 0xf9, 0x4b, 0xa2, 0xd0, 0xa3, 0x54, 0xd1, 0xfe, 0xae, 0x3e,
+	0xc8, 0x98, 0x42, 0x53, 0xf2, 0x42, 0xaf, 0xf1, 0x84, 0xe8, 0xac, 0x2c, 0x01, 0x91, 0xb7, 0x39,
+	0x9e, 0x90, 0x64, 0x48, 0x95, 0x6b, 0x9d, 0x99, 0x2c, 0x28, 0x34, 0x4b, 0xe2, 0x80, 0x86, 0x51,
+	0x3c, 0xd1, 0xb2, 0xb4, 0xb6, 0xf0, 0x10, 0xc5, 0x21, 0x39, 0x16, 0xee, 0x06, 0xd1, 0xf7, 0x44,
+	0x67, 0x2c, 0x0b, 0x0a, 0xdd, 0x70, 0xca, 0xf1, 0xd4, 0x27, 0x01, 0x65, 0x61, 0xe2, 0x96, 0x95,
+	0x6e, 0xd2, 0x98, 0xe0, 0x84, 0x98, 0xe3, 0x3d, 0x73, 0x92, 0x4a, 0x73, 0x06, 0x13, 0xf7, 0x3c,
+	0x22, 0x2c, 0x89, 0x68, 0xec, 0x56, 0xd4, 0x3d, 0xb5, 0x89, 0x10, 0x14, 0x13, 0x71, 0x3c, 0xb4,
+	0x9c, 0x76, 0xd1, 0x97, 0xff, 0x45, 0x2f, 0x8e, 0x29, 0xe5, 0x84, 0xc9, 0xc0, 0xaa, 0xf2, 0xcc,
+	0x14, 0xe2, 0x1d, 0xc3, 0x86, 0xc9, 0xa8, 0x6e, 0xa7, 0x27, 0xb0, 0x26, 0x3b, 0xc6, 0x68, 0xf5,
+	0x6e, 0xb6, 0x4f, 0x14, 0xbb, 0x4f, 0x38, 0x16, 0x51, 0xf9, 0x9a, 0x8b, 0x1e, 0xe6, 0xdb, 0x2b,
+	0x5f, 0xb1, 0x73, 0xbd, 0xf5, 0xb7, 0x03, 0xd7, 0x2f, 0xf0, 0x98, 0x9f, 0x2b, 0x95, 0xe5, 0x5c,
+	0x69, 0xc3, 0x26, 0xa3, 0x94, 0x0f, 0x08, 0x3b, 0x8a, 0x02, 0xf2, 0x0a, 0xcf, 0x8c, 0xa4, 0xf2,
+	0xb0, 0xa8, 0x88, 0x80, 0xa4, 0x7b, 0xc9, 0x53, 0x63, 0x26, 0x0b, 0xa2, 0x4f, 0xe0, 0x9a, 0x94,
+	0xc1, 0x30, 0x9a, 0x91, 0xef, 0xe2, 0xe8, 0xf8, 0x15, 0x8e, 0xa9, 0xac, 0x7e, 0xd1, 0x3f, 0xbf,
+	0x20, 0x32, 0x19, 0x2e, 0x9b, 0x4b, 0x35, 0x4a, 0x0a, 0x11, 0x0a, 0x09, 0xe8, 0x6c, 0x3e, 0x25,
+	0x5c, 0x09, 0x60, 0xdd, 0xb7, 0xb6, 0xf7, 0xa3, 0x9d, 0x07, 0x66, 0x8a, 0xb4, 0x61, 0x33, 0x8a,
+	0x93, 0x39, 0x09, 0x38, 0x09, 0x87, 0x26, 0xdd, 0xc2, 0x65, 0x1e, 0x46, 0xf7, 0x61, 0xc3, 0x42,
+	0xbd, 0x13, 0x4e, 0x54, 0x82, 0x8b, 0x7e, 0x0e, 0xcd, 0x78, 0xd4, 0xa3, 0xa9, 0x90, 0xf3, 0xa8,
+	0x60, 0x91, 0x9d, 0xe4, 0x30, 0x9a, 0xcf, 0x2d, 0x4f, 0x2b, 0x3e, 0x03, 0xa6, 0x58, 0x3a, 0xbe,
+	0x52, 0x86, 0xa5, 0xa3, 0x6b, 0xc3, 0xa6, 0x54, 0xb0, 0xdc, 0xa4, 0xc2, 0x5b, 0x93, 0xe1, 0xe5,
+	0x61, 0xef, 0x3a, 0x5c, 0x53, 0x29, 0x10, 0xb3, 0x42, 0xf7, 0xaf, 0xf7, 0xd0, 0x0c, 0x00, 0x05,
+	0x6a, 0x09, 0x36, 0x60, 0x9d, 0xe3, 0x89, 0xa8, 0x91, 0x12, 0x61, 0xc5, 0xb7, 0xb6, 0xd7, 0x85,
+	0x5b, 0x76, 0xc7, 0x1b, 0x31, 0x49, 0x92, 0xf4, 0x83, 0xa4, 0x58, 0x56, 0x38, 0xca, 0xf4, 0x9e,
+	0xc2, 0xed, 0x73, 0x7b, 0xf4, 0x51, 0x77, 0xa1, 0xc2, 0x0d, 0xa8, 0xcf, 0x5a, 0x02, 0x5e, 0x0f,
+	0x4a, 0xf2, 0x9e, 0xe8, 0x0b, 0x28, 0x8f, 0x30, 0x0f, 0x0e, 0x6c, 0x57, 0x6c, 0x5b, 0x79, 0xab,
+	0x77, 0xf5, 0xe8, 0x51, 0xc7, 0x27, 0x09, 0x5d, 0xb0, 0x80, 0x0c, 0xe6, 0x38, 0x4e, 0x7c, 0xc3,
+	0xf7, 0x36, 0xa0, 0xf6, 0x7a, 0x91, 0xd8, 0xfe, 0xf2, 0x7e, 0x73, 0x60, 0x4b, 0x00, 0x32, 0x2b,
+	0x26, 0xf6, 0x07, 0xb6, 0xe9, 0x56, 0x5b, 0x85, 0x76, 0xad, 0x77, 0x53, 0x8c, 0xff, 0x3f, 0x3f,
+	0x6c, 0xd7, 0x5f, 0x33, 0x82, 0xa7, 0x53, 0x1a, 0x28, 0xb6, 0xe9, 0xb6, 0x8f, 0xa1, 0x10, 0x85,
+	0xa2, 0xbe, 0x57, 0x70, 0x05, 0x03, 0x7d, 0x0a, 0xa0, 0x26, 0xe4, 0x2e, 0xe6, 0xd8, 0x2d, 0x5e,
+	0xc5, 0x4f, 0x11, 0xbd, 0xbe, 0x0a, 0x51, 0xdd, 0x44, 0x87, 0xf8, 0x3f, 0x52, 0x70, 0x0f, 0x40,
+	0x3f, 0xa3, 0x42, 0xa8, 0xb7, 0x32, 0x03, 0xa6, 0x66, 0x2e, 0xe5, 0xfd, 0xec, 0xc0, 0xe6, 0x90,
+	0xe1, 0x38, 0x19, 0x13, 0x66, 0x0e, 0xbd, 0x0f, 0x1b, 0x63, 0x46, 0x67, 0xfb, 0xf1, 0x84, 0x24,
+	0x9c, 0x30, 0x3b, 0x13, 0x72, 0xa8, 0x54, 0x0c, 0x89, 0x71, 0xcc, 0xed, 0xf4, 0xb7, 0xb6, 0x78,
+	0xf9, 0x99, 0x72, 0x27, 0x1b, 0x22, 0xfd, 0xf2, 0xe7, 0xeb, 0xe0, 0x1b, 0x66, 0xf7, 0x17, 0x07,
+	0xd6, 0xc4, 0x2a, 0x61, 0xe8, 0x39, 0x54, 0x2c, 0x0f, 0x5d, 0xbe, 0xb7, 0x71, 0x33, 0xb3, 0x64,
+	0xeb, 0xbd, 0x82, 0xbe, 0x84, 0xaa, 0x25, 0xbf, 0xe9, 0xfe, 0x17, 0x17, 0xdd, 0x01, 0x6c, 0xe9,
+	0xc9, 0xf1, 0x82, 0xc4, 0x84, 0x61, 0x4e, 0x6d, 0x5c, 0x32, 0xd7, 0x39, 0xa7, 0xe9, 0xc2, 0x5d,
+	0xee, 0xf4, 0x9f, 0x55, 0x28, 0x8b, 0x17, 0x3c, 0x22, 0x0c, 0xbd, 0x84, 0xfa, 0x57, 0x51, 0x1c,
+	0xda, 0xaf, 0x1d, 0x74, 0xc1, 0xe7, 0x91, 0x71, 0xd8, 0xb8, 0x68, 0x29, 0x75, 0xdb, 0x9a, 0x79,
+	0x51, 0x02, 0x12, 0x73, 0x74, 0xc9, 0xd3, 0xdd, 0xb8, 0x7d, 0x0e, 0xb7, 0x2e, 0xf6, 0xa0, 0x9a,
+	0xfa, 0x2c, 0x40, 0x77, 0x72, 0xcc, 0xf4, 0xc7, 0xc2, 0x55, 0x6e, 0x5e, 0x00, 0x2c, 0x87, 0x0b,
+	0x6a, 0xe4, 0x88, 0xa9, 0x31, 0xd4, 0xb8, 0x73, 0xe1, 0x9a, 0x75, 0xf4, 0x06, 0x36, 0x73, 0xf3,
+	0x03, 0x6d, 0x9f, 0xdf, 0x91, 0x99, 0x46, 0x8d, 0xd6, 0xe5, 0x04, 0x5b, 0x80, 0xaf, 0xa1, 0xfc,
+	0x12, 0xc7, 0x21, 0x1d, 0x8f, 0xd1, 0x73, 0x58, 0x37, 0xda, 0x47, 0x6e, 0x3a, 0xbf, 0xe9, 0x76,
+	0xb8, 0xb4, 0x94, 0x6d, 0xa7, 0xe7, 0xbe, 0x3b, 0x6d, 0x3a, 0xef, 0x4f, 0x9b, 0xce, 0x5f, 0xa7,
+	0x4d, 0xe7, 0xd7, 0xb3, 0xe6, 0xca, 0xfb, 0xb3, 0xe6, 0xca, 0x1f, 0x67, 0xcd, 0x95, 0xd1, 0x9a,
+	0xfc, 0x88, 0x7f, 0xfc, 0x6f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xcd, 0x35, 0x47, 0x24, 0x2d, 0x0c,
+	0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1513,6 +1592,114 @@ var _Querier_serviceDesc = grpc.ServiceDesc{
 	Metadata: "pkg/tempopb/tempo.proto",
 }
 
+// HandoffClient is the client API for Handoff service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type HandoffClient interface {
+	// Transfer streams the live traces and wal blocks of a leaving ingester to the ingester taking over its tokens
+	Transfer(ctx context.Context, opts ...grpc.CallOption) (Handoff_TransferClient, error)
+}
+
+type handoffClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewHandoffClient(cc *grpc.ClientConn) HandoffClient {
+	return &handoffClient{cc}
+}
+
+func (c *handoffClient) Transfer(ctx context.Context, opts ...grpc.CallOption) (Handoff_TransferClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Handoff_serviceDesc.Streams[0], "/tempopb.Handoff/Transfer", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &handoffTransferClient{stream}
+	return x, nil
+}
+
+type Handoff_TransferClient interface {
+	Send(*TransferRequest) error
+	CloseAndRecv() (*PushResponse, error)
+	grpc.ClientStream
+}
+
+type handoffTransferClient struct {
+	grpc.ClientStream
+}
+
+func (x *handoffTransferClient) Send(m *TransferRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *handoffTransferClient) CloseAndRecv() (*PushResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PushResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HandoffServer is the server API for Handoff service.
+type HandoffServer interface {
+	// Transfer streams the live traces and wal blocks of a leaving ingester to the ingester taking over its tokens
+	Transfer(Handoff_TransferServer) error
+}
+
+// UnimplementedHandoffServer can be embedded to have forward compatible implementations.
+type UnimplementedHandoffServer struct {
+}
+
+func (*UnimplementedHandoffServer) Transfer(srv Handoff_TransferServer) error {
+	return status.Errorf(codes.Unimplemented, "method Transfer not implemented")
+}
+
+func RegisterHandoffServer(s *grpc.Server, srv HandoffServer) {
+	s.RegisterService(&_Handoff_serviceDesc, srv)
+}
+
+func _Handoff_Transfer_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HandoffServer).Transfer(&handoffTransferServer{stream})
+}
+
+type Handoff_TransferServer interface {
+	SendAndClose(*PushResponse) error
+	Recv() (*TransferRequest, error)
+	grpc.ServerStream
+}
+
+type handoffTransferServer struct {
+	grpc.ServerStream
+}
+
+func (x *handoffTransferServer) SendAndClose(m *PushResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *handoffTransferServer) Recv() (*TransferRequest, error) {
+	m := new(TransferRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Handoff_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.Handoff",
+	HandlerType: (*HandoffServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Transfer",
+			Handler:       _Handoff_Transfer_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/tempopb/tempo.proto",
+}
+
 func (m *TraceByIDRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1875,6 +2062,16 @@ func (m *TraceSearchMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Complete {
+		i--
+		if m.Complete {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.DurationMs != 0 {
 		i = encodeVarintTempo(dAtA, i, uint64(m.DurationMs))
 		i--
@@ -2273,6 +2470,55 @@ func (m *TraceBytes) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *TransferRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TransferRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TransferRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Request != nil {
+		{
+			size, err := m.Request.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.TenantID) > 0 {
+		i -= len(m.TenantID)
+		copy(dAtA[i:], m.TenantID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TenantID)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.FromIngesterID) > 0 {
+		i -= len(m.FromIngesterID)
+		copy(dAtA[i:], m.FromIngesterID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.FromIngesterID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintTempo(dAtA []byte, offset int, v uint64) int {
 	offset -= sovTempo(v)
 	base := offset
@@ -2464,6 +2710,9 @@ func (m *TraceSearchMetadata) Size() (n int) {
 	if m.DurationMs != 0 {
 		n += 1 + sovTempo(uint64(m.DurationMs))
 	}
+	if m.Complete {
+		n += 2
+	}
 	return n
 }
 
@@ -2627,6 +2876,27 @@ func (m *TraceBytes) Size() (n int) {
 	return n
 }
 
+func (m *TransferRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.FromIngesterID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.TenantID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.Request != nil {
+		l = m.Request.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
 func sovTempo(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -3919,6 +4189,26 @@ func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Complete", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Complete = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -4855,6 +5145,156 @@ func (m *TraceBytes) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *TransferRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TransferRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TransferRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FromIngesterID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FromIngesterID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TenantID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TenantID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Request", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Request == nil {
+				m.Request = &PushBytesRequest{}
+			}
+			if err := m.Request.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipTempo(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
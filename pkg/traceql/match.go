@@ -0,0 +1,427 @@
+package traceql
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+// TraceMatcher evaluates a single spanset filter like { status = error || .priority = "high" } against
+// fully materialized traces. A trace matches if any of its spans satisfies the filter. Only field expressions
+// on span and resource attributes and the intrinsics name, status and duration are supported.
+type TraceMatcher struct {
+	query string
+	expr  FieldExpression
+	regex map[string]*regexp.Regexp
+}
+
+// NewTraceMatcher parses the given query. It must consist of exactly one spanset filter.
+func NewTraceMatcher(query string) (*TraceMatcher, error) {
+	ast, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := ast.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(ast.Pipeline.Elements) != 1 {
+		return nil, fmt.Errorf("query must consist of a single spanset filter: %s", query)
+	}
+	f, ok := ast.Pipeline.Elements[0].(SpansetFilter)
+	if !ok {
+		return nil, fmt.Errorf("query must consist of a single spanset filter: %s", query)
+	}
+
+	m := &TraceMatcher{
+		query: query,
+		expr:  f.Expression,
+		regex: map[string]*regexp.Regexp{},
+	}
+
+	// precompile regular expressions
+	err = m.compileRegex(f.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *TraceMatcher) String() string {
+	return m.query
+}
+
+// Matches returns true if any span of the trace satisfies the filter.
+func (m *TraceMatcher) Matches(tr *tempopb.Trace) bool {
+	if tr == nil {
+		return false
+	}
+
+	for _, b := range tr.Batches {
+		var resourceAttrs []*v1_common.KeyValue
+		if b.Resource != nil {
+			resourceAttrs = b.Resource.Attributes
+		}
+
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				v := m.eval(m.expr, s, resourceAttrs)
+				if v.Type == TypeBoolean && v.B {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *TraceMatcher) compileRegex(e FieldExpression) error {
+	switch o := e.(type) {
+	case BinaryOperation:
+		if o.Op == OpRegex || o.Op == OpNotRegex {
+			if s, ok := o.RHS.(Static); ok && s.Type == TypeString {
+				r, err := regexp.Compile(s.S)
+				if err != nil {
+					return err
+				}
+				m.regex[s.S] = r
+			}
+		}
+		if err := m.compileRegex(o.LHS); err != nil {
+			return err
+		}
+		return m.compileRegex(o.RHS)
+	case UnaryOperation:
+		return m.compileRegex(o.Expression)
+	}
+
+	return nil
+}
+
+func (m *TraceMatcher) eval(e FieldExpression, s *v1_trace.Span, resourceAttrs []*v1_common.KeyValue) Static {
+	switch o := e.(type) {
+	case Static:
+		return o
+	case Attribute:
+		return attributeForSpan(o, s, resourceAttrs)
+	case UnaryOperation:
+		v := m.eval(o.Expression, s, resourceAttrs)
+		switch {
+		case o.Op == OpNot && v.Type == TypeBoolean:
+			return NewStaticBool(!v.B)
+		case o.Op == OpSub && v.Type == TypeInt:
+			return NewStaticInt(-v.N)
+		case o.Op == OpSub && v.Type == TypeFloat:
+			return NewStaticFloat(-v.F)
+		case o.Op == OpSub && v.Type == TypeDuration:
+			return NewStaticDuration(-v.D)
+		}
+		return NewStaticNil()
+	case BinaryOperation:
+		lhs := m.eval(o.LHS, s, resourceAttrs)
+
+		// short circuit boolean operators
+		switch o.Op {
+		case OpAnd:
+			if lhs.Type != TypeBoolean || !lhs.B {
+				return NewStaticBool(false)
+			}
+			rhs := m.eval(o.RHS, s, resourceAttrs)
+			return NewStaticBool(rhs.Type == TypeBoolean && rhs.B)
+		case OpOr:
+			if lhs.Type == TypeBoolean && lhs.B {
+				return NewStaticBool(true)
+			}
+			rhs := m.eval(o.RHS, s, resourceAttrs)
+			return NewStaticBool(rhs.Type == TypeBoolean && rhs.B)
+		}
+
+		rhs := m.eval(o.RHS, s, resourceAttrs)
+		return m.compare(o.Op, lhs, rhs)
+	}
+
+	return NewStaticNil()
+}
+
+func (m *TraceMatcher) compare(op Operator, lhs, rhs Static) Static {
+	// a missing attribute never matches
+	if lhs.Type == TypeNil || rhs.Type == TypeNil {
+		return NewStaticBool(false)
+	}
+
+	switch op {
+	case OpRegex, OpNotRegex:
+		if lhs.Type != TypeString || rhs.Type != TypeString {
+			return NewStaticBool(false)
+		}
+		r := m.regex[rhs.S]
+		if r == nil {
+			return NewStaticBool(false)
+		}
+		return NewStaticBool(r.MatchString(lhs.S) == (op == OpRegex))
+	}
+
+	if lhs.Type.isNumeric() && rhs.Type.isNumeric() {
+		l, r := lhs.asFloat(), rhs.asFloat()
+		switch op {
+		case OpEqual:
+			return NewStaticBool(l == r)
+		case OpNotEqual:
+			return NewStaticBool(l != r)
+		case OpGreater:
+			return NewStaticBool(l > r)
+		case OpGreaterEqual:
+			return NewStaticBool(l >= r)
+		case OpLess:
+			return NewStaticBool(l < r)
+		case OpLessEqual:
+			return NewStaticBool(l <= r)
+		}
+		return NewStaticNil()
+	}
+
+	if lhs.Type != rhs.Type {
+		return NewStaticBool(op == OpNotEqual)
+	}
+
+	var cmp int
+	switch lhs.Type {
+	case TypeString:
+		switch {
+		case lhs.S < rhs.S:
+			cmp = -1
+		case lhs.S > rhs.S:
+			cmp = 1
+		}
+	case TypeBoolean:
+		if lhs.B != rhs.B {
+			cmp = 1
+		}
+	case TypeStatus:
+		if lhs.Status != rhs.Status {
+			cmp = 1
+		}
+	}
+
+	switch op {
+	case OpEqual:
+		return NewStaticBool(cmp == 0)
+	case OpNotEqual:
+		return NewStaticBool(cmp != 0)
+	}
+
+	// ordering is only defined for strings
+	if lhs.Type != TypeString {
+		return NewStaticNil()
+	}
+
+	switch op {
+	case OpGreater:
+		return NewStaticBool(cmp > 0)
+	case OpGreaterEqual:
+		return NewStaticBool(cmp >= 0)
+	case OpLess:
+		return NewStaticBool(cmp < 0)
+	case OpLessEqual:
+		return NewStaticBool(cmp <= 0)
+	}
+
+	return NewStaticNil()
+}
+
+func (s Static) asFloat() float64 {
+	switch s.Type {
+	case TypeInt:
+		return float64(s.N)
+	case TypeFloat:
+		return s.F
+	case TypeDuration:
+		return float64(s.D.Nanoseconds())
+	}
+	return 0
+}
+
+func attributeForSpan(a Attribute, s *v1_trace.Span, resourceAttrs []*v1_common.KeyValue) Static {
+	switch a.Intrinsic {
+	case IntrinsicName:
+		return NewStaticString(s.Name)
+	case IntrinsicDuration:
+		return NewStaticDuration(time.Duration(s.EndTimeUnixNano - s.StartTimeUnixNano))
+	case IntrinsicStatus:
+		status := StatusUnset
+		if s.Status != nil {
+			switch s.Status.Code {
+			case v1_trace.Status_STATUS_CODE_ERROR:
+				status = StatusError
+			case v1_trace.Status_STATUS_CODE_OK:
+				status = StatusOk
+			}
+		}
+		return NewStaticStatus(status)
+	case IntrinsicNone:
+	default:
+		// childCount and parent require the full span tree
+		return NewStaticNil()
+	}
+
+	// unscoped attributes check the span first and fall back to the resource
+	if a.Scope != AttributeScopeResource {
+		if v, ok := staticFromAttrs(a.Name, s.Attributes); ok {
+			return v
+		}
+	}
+	if a.Scope != AttributeScopeSpan {
+		if v, ok := staticFromAttrs(a.Name, resourceAttrs); ok {
+			return v
+		}
+	}
+
+	return NewStaticNil()
+}
+
+func staticFromAttrs(name string, attrs []*v1_common.KeyValue) (Static, bool) {
+	for _, kv := range attrs {
+		if kv.Key != name || kv.Value == nil {
+			continue
+		}
+
+		switch v := kv.Value.Value.(type) {
+		case *v1_common.AnyValue_StringValue:
+			return NewStaticString(v.StringValue), true
+		case *v1_common.AnyValue_IntValue:
+			return NewStaticInt(int(v.IntValue)), true
+		case *v1_common.AnyValue_DoubleValue:
+			return NewStaticFloat(v.DoubleValue), true
+		case *v1_common.AnyValue_BoolValue:
+			return NewStaticBool(v.BoolValue), true
+		}
+	}
+
+	return Static{}, false
+}
+
+// TraceFetcher answers a FetchSpansRequest from fully materialized traces the way a storage layer answers it from
+// a block. Spans are selected if they satisfy any condition, or all conditions if the request says so. Every
+// selected span carries the values of the requested attributes.
+type TraceFetcher struct {
+	req FetchSpansRequest
+	m   *TraceMatcher
+}
+
+// NewTraceFetcher checks the conditions of the request and precompiles its regular expressions.
+func NewTraceFetcher(req FetchSpansRequest) (*TraceFetcher, error) {
+	m := &TraceMatcher{
+		regex: map[string]*regexp.Regexp{},
+	}
+
+	for _, c := range req.Conditions {
+		if c.Op != OpNone && len(c.Operands) == 0 {
+			return nil, fmt.Errorf("operation %v requires an operand. condition: %+v", c.Op, c)
+		}
+
+		for _, o := range c.Operands {
+			if (c.Op == OpRegex || c.Op == OpNotRegex) && o.Type == TypeString {
+				r, err := regexp.Compile(o.S)
+				if err != nil {
+					return nil, err
+				}
+				m.regex[o.S] = r
+			}
+		}
+	}
+
+	return &TraceFetcher{
+		req: req,
+		m:   m,
+	}, nil
+}
+
+// Fetch returns the selected spans of the trace or nil if there are none.
+func (f *TraceFetcher) Fetch(id []byte, tr *tempopb.Trace) *Spanset {
+	if tr == nil {
+		return nil
+	}
+
+	var spanset *Spanset
+	for _, b := range tr.Batches {
+		var resourceAttrs []*v1_common.KeyValue
+		if b.Resource != nil {
+			resourceAttrs = b.Resource.Attributes
+		}
+
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				span, ok := f.fetchSpan(s, resourceAttrs)
+				if !ok {
+					continue
+				}
+
+				if spanset == nil {
+					spanset = &Spanset{TraceID: id}
+				}
+				spanset.Spans = append(spanset.Spans, span)
+			}
+		}
+	}
+
+	return spanset
+}
+
+func (f *TraceFetcher) fetchSpan(s *v1_trace.Span, resourceAttrs []*v1_common.KeyValue) (Span, bool) {
+	if f.req.StartTimeUnixNanos > 0 && s.EndTimeUnixNano < f.req.StartTimeUnixNanos {
+		return Span{}, false
+	}
+	if f.req.EndTimeUnixNanos > 0 && s.StartTimeUnixNano > f.req.EndTimeUnixNanos {
+		return Span{}, false
+	}
+
+	span := Span{
+		ID:                 s.SpanId,
+		StartTimeUnixNanos: s.StartTimeUnixNano,
+		EndtimeUnixNanos:   s.EndTimeUnixNano,
+		Attributes:         map[Attribute]Static{},
+	}
+
+	// without conditions all spans are selected
+	matched := 0
+	for _, c := range f.req.Conditions {
+		v := attributeForSpan(c.Attribute, s, resourceAttrs)
+		if v.Type == TypeNil {
+			continue
+		}
+		span.Attributes[c.Attribute] = v
+
+		if f.matches(c, v) {
+			matched++
+		}
+	}
+
+	if len(f.req.Conditions) == 0 {
+		return span, true
+	}
+	if f.req.AllConditions {
+		return span, matched == len(f.req.Conditions)
+	}
+	return span, matched > 0
+}
+
+// matches returns true if the value satisfies the condition with any of its operands
+func (f *TraceFetcher) matches(c Condition, v Static) bool {
+	if c.Op == OpNone {
+		return true
+	}
+
+	for _, o := range c.Operands {
+		res := f.m.compare(c.Op, v, o)
+		if res.Type == TypeBoolean && res.B {
+			return true
+		}
+	}
+	return false
+}
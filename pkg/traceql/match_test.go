@@ -0,0 +1,219 @@
+package traceql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+func TestTraceMatcher(t *testing.T) {
+	tr := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "checkout"}}},
+						{Key: "priority", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "low"}}},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								Name:              "GET /cart",
+								StartTimeUnixNano: 1_000_000_000,
+								EndTimeUnixNano:   3_000_000_000,
+								Status:            &v1_trace.Status{Code: v1_trace.Status_STATUS_CODE_ERROR},
+								Attributes: []*v1_common.KeyValue{
+									{Key: "priority", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "high"}}},
+									{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 500}}},
+									{Key: "sampled", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+									{Key: "ratio", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+								},
+							},
+							{
+								Name:              "db",
+								StartTimeUnixNano: 1_000_000_000,
+								EndTimeUnixNano:   1_100_000_000,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{query: `{ status = error }`, expected: true},
+		{query: `{ status = ok }`, expected: false},
+		{query: `{ status = unset }`, expected: true},
+		{query: `{ name = "db" }`, expected: true},
+		{query: `{ name =~ "GET.*" }`, expected: true},
+		{query: `{ name !~ "GET.*" && name != "db" }`, expected: false},
+		{query: `{ duration > 1s }`, expected: true},
+		{query: `{ duration > 5s }`, expected: false},
+		{query: `{ .priority = "high" }`, expected: true},
+		{query: `{ span.priority = "low" }`, expected: false},
+		{query: `{ resource.priority = "low" }`, expected: true},
+		{query: `{ .service.name = "checkout" }`, expected: true},
+		{query: `{ .http.status_code >= 500 }`, expected: true},
+		{query: `{ .http.status_code = 500.0 }`, expected: true},
+		{query: `{ .http.status_code < 500 }`, expected: false},
+		{query: `{ .sampled = true }`, expected: true},
+		{query: `{ .ratio > 0.25 }`, expected: true},
+		{query: `{ .missing = "foo" }`, expected: false},
+		{query: `{ .missing != "foo" }`, expected: false},
+		{query: `{ status = error || .priority = "high" }`, expected: true},
+		{query: `{ status = error && name = "db" }`, expected: false},
+		{query: `{ !(name = "db") && duration < 1s }`, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			m, err := NewTraceMatcher(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, m.Matches(tr))
+		})
+	}
+}
+
+func TestTraceMatcherErrors(t *testing.T) {
+	queries := []string{
+		`{ .a = `,
+		`{ .a } | { .b }`,
+		`{ .a } && { .b }`,
+		`{ .a =~ "(" }`,
+	}
+
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			_, err := NewTraceMatcher(q)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestTraceFetcher(t *testing.T) {
+	tr := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{
+					Attributes: []*v1_common.KeyValue{
+						{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "checkout"}}},
+					},
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1_trace.Span{
+							{
+								SpanId:            []byte{1},
+								Name:              "GET /cart",
+								StartTimeUnixNano: 1_000_000_000,
+								EndTimeUnixNano:   3_000_000_000,
+								Attributes: []*v1_common.KeyValue{
+									{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 500}}},
+								},
+							},
+							{
+								SpanId:            []byte{2},
+								Name:              "db",
+								StartTimeUnixNano: 5_000_000_000,
+								EndTimeUnixNano:   6_000_000_000,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		req      FetchSpansRequest
+		expected [][]byte
+	}{
+		{
+			name:     "no conditions",
+			req:      FetchSpansRequest{},
+			expected: [][]byte{{1}, {2}},
+		},
+		{
+			name:     "equal",
+			req:      FetchSpansRequest{Conditions: []Condition{MustExtractCondition(`{ name = "db" }`)}},
+			expected: [][]byte{{2}},
+		},
+		{
+			name:     "regex",
+			req:      FetchSpansRequest{Conditions: []Condition{MustExtractCondition(`{ name =~ "GET.*" }`)}},
+			expected: [][]byte{{1}},
+		},
+		{
+			name:     "attribute exists",
+			req:      FetchSpansRequest{Conditions: []Condition{{Attribute: NewAttribute("http.status_code"), Op: OpNone}}},
+			expected: [][]byte{{1}},
+		},
+		{
+			name: "any condition",
+			req: FetchSpansRequest{Conditions: []Condition{
+				MustExtractCondition(`{ name = "db" }`),
+				MustExtractCondition(`{ .http.status_code > 400 }`),
+			}},
+			expected: [][]byte{{1}, {2}},
+		},
+		{
+			name: "all conditions",
+			req: FetchSpansRequest{AllConditions: true, Conditions: []Condition{
+				MustExtractCondition(`{ .service.name = "checkout" }`),
+				MustExtractCondition(`{ .http.status_code > 400 }`),
+			}},
+			expected: [][]byte{{1}},
+		},
+		{
+			name:     "time range",
+			req:      FetchSpansRequest{StartTimeUnixNanos: 4_000_000_000, EndTimeUnixNanos: 10_000_000_000},
+			expected: [][]byte{{2}},
+		},
+		{
+			name:     "no match",
+			req:      FetchSpansRequest{Conditions: []Condition{MustExtractCondition(`{ name = "foo" }`)}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewTraceFetcher(tc.req)
+			require.NoError(t, err)
+
+			ss := f.Fetch([]byte{0xa}, tr)
+			if tc.expected == nil {
+				require.Nil(t, ss)
+				return
+			}
+
+			require.NotNil(t, ss)
+			require.Equal(t, []byte{0xa}, ss.TraceID)
+			ids := make([][]byte, 0, len(ss.Spans))
+			for _, s := range ss.Spans {
+				ids = append(ids, s.ID)
+			}
+			require.Equal(t, tc.expected, ids)
+		})
+	}
+
+	// requested attributes are returned
+	cond := MustExtractCondition(`{ .http.status_code > 400 }`)
+	f, err := NewTraceFetcher(FetchSpansRequest{Conditions: []Condition{cond}})
+	require.NoError(t, err)
+	ss := f.Fetch(nil, tr)
+	require.Len(t, ss.Spans, 1)
+	require.Equal(t, NewStaticInt(500), ss.Spans[0].Attributes[cond.Attribute])
+}
@@ -32,6 +32,12 @@ type Writer interface {
 	CloseAppend(ctx context.Context, tracker AppendTracker) error
 	// WriteTenantIndex writes the two meta slices as a tenant index
 	WriteTenantIndex(ctx context.Context, tenantID string, meta []*BlockMeta, compactedMeta []*CompactedBlockMeta) error
+	// WriteDeletionRequest creates or updates a deletion request
+	WriteDeletionRequest(ctx context.Context, req *DeletionRequest) error
+	// WriteCompactionPlan writes the compaction jobs of a tenant
+	WriteCompactionPlan(ctx context.Context, plan *CompactionPlan) error
+	// WriteCompactionLeases writes the leases of a compactor on the compaction jobs of a tenant
+	WriteCompactionLeases(ctx context.Context, leases *CompactionLeases) error
 }
 
 // Reader is a collection of methods to read data from tempodb backends
@@ -51,6 +57,12 @@ type Reader interface {
 	BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID string) (*BlockMeta, error)
 	// TenantIndex returns lists of all metas given a tenant
 	TenantIndex(ctx context.Context, tenantID string) (*TenantIndex, error)
+	// DeletionRequests returns all deletion requests given a tenant
+	DeletionRequests(ctx context.Context, tenantID string) ([]*DeletionRequest, error)
+	// CompactionPlan returns the compaction jobs of a tenant. Returns nil if nothing has been planned yet
+	CompactionPlan(ctx context.Context, tenantID string) (*CompactionPlan, error)
+	// CompactionLeases returns the leases of all compactors on the compaction jobs of a tenant
+	CompactionLeases(ctx context.Context, tenantID string) ([]*CompactionLeases, error)
 	// Shutdown shuts...down?
 	Shutdown()
 }
@@ -7,6 +7,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// RetentionClassDefault is assigned to traces that don't match any of the tenant's retention rules.
+const RetentionClassDefault = "default"
+
+// TierCold is the tier of blocks that were moved to the cold backend. Their metas stay in the primary backend.
+const TierCold = "cold"
+
 type CompactedBlockMeta struct {
 	BlockMeta
 
@@ -14,22 +20,37 @@ type CompactedBlockMeta struct {
 }
 
 type BlockMeta struct {
-	Version         string    `json:"format"`          // Version indicates the block format version. This includes specifics of how the indexes and data is stored
-	BlockID         uuid.UUID `json:"blockID"`         // Unique block id
-	MinID           []byte    `json:"minID"`           // Minimum object id stored in this block
-	MaxID           []byte    `json:"maxID"`           // Maximum object id stored in this block
-	TenantID        string    `json:"tenantID"`        // ID of tehant to which this block belongs
-	StartTime       time.Time `json:"startTime"`       // Roughly matches when the first obj was written to this block. Used to determine block age for different purposes (cacheing, etc)
-	EndTime         time.Time `json:"endTime"`         // Currently mostly meaningless but roughly matches to the time the last obj was written to this block
-	TotalObjects    int       `json:"totalObjects"`    // Total objects in this block
-	Size            uint64    `json:"size"`            // Total size in bytes of the data object
-	CompactionLevel uint8     `json:"compactionLevel"` // Kind of the number of times this block has been compacted
-	Encoding        Encoding  `json:"encoding"`        // Encoding/compression format
-	IndexPageSize   uint32    `json:"indexPageSize"`   // Size of each index page in bytes
-	TotalRecords    uint32    `json:"totalRecords"`    // Total Records stored in the index file
-	DataEncoding    string    `json:"dataEncoding"`    // DataEncoding is a string provided externally, but tracked by tempodb that indicates the way the bytes are encoded
-	BloomShardCount uint16    `json:"bloomShards"`     // Number of bloom filter shards
-	FooterSize      uint32    `json:"footerSize"`      // Size of data file footer (parquet)
+	Version         string      `json:"format"`                   // Version indicates the block format version. This includes specifics of how the indexes and data is stored
+	BlockID         uuid.UUID   `json:"blockID"`                  // Unique block id
+	MinID           []byte      `json:"minID"`                    // Minimum object id stored in this block
+	MaxID           []byte      `json:"maxID"`                    // Maximum object id stored in this block
+	TenantID        string      `json:"tenantID"`                 // ID of tehant to which this block belongs
+	StartTime       time.Time   `json:"startTime"`                // Roughly matches when the first obj was written to this block. Used to determine block age for different purposes (cacheing, etc)
+	EndTime         time.Time   `json:"endTime"`                  // Currently mostly meaningless but roughly matches to the time the last obj was written to this block
+	TotalObjects    int         `json:"totalObjects"`             // Total objects in this block
+	Size            uint64      `json:"size"`                     // Total size in bytes of the data object
+	CompactionLevel uint8       `json:"compactionLevel"`          // Kind of the number of times this block has been compacted
+	Encoding        Encoding    `json:"encoding"`                 // Encoding/compression format
+	IndexPageSize   uint32      `json:"indexPageSize"`            // Size of each index page in bytes
+	TotalRecords    uint32      `json:"totalRecords"`             // Total Records stored in the index file
+	DataEncoding    string      `json:"dataEncoding"`             // DataEncoding is a string provided externally, but tracked by tempodb that indicates the way the bytes are encoded
+	BloomShardCount uint16      `json:"bloomShards"`              // Number of bloom filter shards
+	FooterSize      uint32      `json:"footerSize"`               // Size of data file footer (parquet)
+	RetentionClass  string      `json:"retentionClass,omitempty"` // Retention class of the traces in this block. Empty if the block has not been classified yet
+	SampleRate      float64     `json:"sampleRate,omitempty"`     // Fraction of the originally ingested traces kept by compaction time sampling. 0 if the block was never sampled
+	Stats           *BlockStats `json:"stats,omitempty"`          // Statistics of the traces in this block used to skip it at query time. Nil if unknown
+
+	Checksums   map[string]uint32 `json:"checksums,omitempty"`   // CRC-32C checksums of the objects of this block by name. Nil for blocks written before checksums were recorded
+	Quarantine  *Quarantine       `json:"quarantine,omitempty"`  // Set if the block was found corrupt. Quarantined blocks are left out of the blocklist
+	Tier        string            `json:"tier,omitempty"`        // Storage tier of the block objects. Empty for blocks in the primary backend
+	FlushedBy   string            `json:"flushedBy,omitempty"`   // ID of the ingester that flushed the block with replica aware flushing. Empty otherwise
+	FlushedRing string            `json:"flushedRing,omitempty"` // Fingerprint of the ring the primary replicas of the traces in the block were picked from. Empty if FlushedBy is empty
+}
+
+// Quarantine describes why a block was quarantined
+type Quarantine struct {
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
 }
 
 func NewBlockMeta(tenantID string, blockID uuid.UUID, version string, encoding Encoding, dataEncoding string) *BlockMeta {
@@ -64,11 +85,12 @@ func (b *BlockMeta) ObjectAdded(id []byte, start uint32, end uint32) {
 		}
 	}
 
+	// ids are copied because callers may reuse the underlying buffer, i.e. compaction of pooled parquet rows
 	if len(b.MinID) == 0 || bytes.Compare(id, b.MinID) == -1 {
-		b.MinID = id
+		b.MinID = append(b.MinID[:0:0], id...)
 	}
 	if len(b.MaxID) == 0 || bytes.Compare(id, b.MaxID) == 1 {
-		b.MaxID = id
+		b.MaxID = append(b.MaxID[:0:0], id...)
 	}
 
 	b.TotalObjects++
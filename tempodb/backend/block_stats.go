@@ -0,0 +1,224 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxBlockStatsServiceNames is the number of distinct service names recorded before they are truncated
+	MaxBlockStatsServiceNames = 50
+	// MaxBlockStatsSpanNames is the number of most frequent span names recorded
+	MaxBlockStatsSpanNames = 10
+	// MaxBlockStatsAttributes is the number of distinct attribute keys recorded before they are truncated
+	MaxBlockStatsAttributes = 100
+
+	// maxTrackedSpanNames bounds the memory used to count span names while building stats
+	maxTrackedSpanNames = 10_000
+)
+
+// BlockStats are compact statistics of the traces in a block. They are stored in the block meta and
+// used to skip blocks that can't match a query. Sets that exceed their limit are truncated and can't
+// be used to skip blocks anymore.
+type BlockStats struct {
+	SpanCount             uint64          `json:"spanCount"`
+	MinTraceDurationNanos uint64          `json:"minTraceDurationNanos"`
+	MaxTraceDurationNanos uint64          `json:"maxTraceDurationNanos"`
+	ServiceNames          []string        `json:"serviceNames,omitempty"`          // Distinct service names of all resources
+	ServiceNamesTruncated bool            `json:"serviceNamesTruncated,omitempty"` // ServiceNames exceeded MaxBlockStatsServiceNames and is empty
+	SpanNames             []SpanNameCount `json:"spanNames,omitempty"`             // Most frequent span names
+	Attributes            []string        `json:"attributes,omitempty"`            // Distinct keys of all span and resource attributes
+	AttributesTruncated   bool            `json:"attributesTruncated,omitempty"`   // Attributes exceeded MaxBlockStatsAttributes and is empty
+}
+
+type SpanNameCount struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+// MayContainService returns false if no service name of the block contains the given value. Service names
+// are matched by substring like search does.
+func (s *BlockStats) MayContainService(value string) bool {
+	if s.ServiceNamesTruncated {
+		return true
+	}
+	for _, name := range s.ServiceNames {
+		if strings.Contains(name, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// MayContainAttribute returns false if no span or resource of the block has an attribute with the given key
+func (s *BlockStats) MayContainAttribute(key string) bool {
+	if s.AttributesTruncated {
+		return true
+	}
+	i := sort.SearchStrings(s.Attributes, key)
+	return i < len(s.Attributes) && s.Attributes[i] == key
+}
+
+// MayContainDuration returns false if no trace of the block has a duration within [min, max]. A max of 0 is unbounded.
+func (s *BlockStats) MayContainDuration(minNanos, maxNanos uint64) bool {
+	if s.MaxTraceDurationNanos < minNanos {
+		return false
+	}
+	if maxNanos > 0 && s.MinTraceDurationNanos > maxNanos {
+		return false
+	}
+	return true
+}
+
+// MergeBlockStats returns the stats of a block holding the traces of all given blocks. The result is a superset:
+// it is still correct if traces are combined or dropped, except for the max trace duration which must be raised
+// by the caller for combined traces. Returns nil if any of the stats is nil.
+func MergeBlockStats(stats ...*BlockStats) *BlockStats {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	b := NewBlockStatsBuilder()
+	for _, s := range stats {
+		if s == nil {
+			return nil
+		}
+
+		b.spanCount += s.SpanCount
+		b.addDuration(s.MinTraceDurationNanos)
+		b.addDuration(s.MaxTraceDurationNanos)
+
+		b.servicesTruncated = b.servicesTruncated || s.ServiceNamesTruncated
+		for _, name := range s.ServiceNames {
+			b.AddService(name)
+		}
+		b.attributesTruncated = b.attributesTruncated || s.AttributesTruncated
+		for _, key := range s.Attributes {
+			b.AddAttribute(key)
+		}
+		for _, n := range s.SpanNames {
+			b.addSpanName(n.Name, n.Count)
+		}
+	}
+
+	return b.Stats()
+}
+
+// BlockStatsBuilder collects the stats of a block while traces are added
+type BlockStatsBuilder struct {
+	spanCount           uint64
+	traces              uint64
+	minDuration         uint64
+	maxDuration         uint64
+	services            map[string]struct{}
+	servicesTruncated   bool
+	spanNames           map[string]uint64
+	attributes          map[string]struct{}
+	attributesTruncated bool
+}
+
+func NewBlockStatsBuilder() *BlockStatsBuilder {
+	return &BlockStatsBuilder{
+		services:   map[string]struct{}{},
+		spanNames:  map[string]uint64{},
+		attributes: map[string]struct{}{},
+	}
+}
+
+// AddTrace records the duration of a trace
+func (b *BlockStatsBuilder) AddTrace(durationNanos uint64) {
+	b.addDuration(durationNanos)
+}
+
+func (b *BlockStatsBuilder) addDuration(durationNanos uint64) {
+	if b.traces == 0 || durationNanos < b.minDuration {
+		b.minDuration = durationNanos
+	}
+	if durationNanos > b.maxDuration {
+		b.maxDuration = durationNanos
+	}
+	b.traces++
+}
+
+// AddSpan records a span with the given name
+func (b *BlockStatsBuilder) AddSpan(name string) {
+	b.spanCount++
+	b.addSpanName(name, 1)
+}
+
+func (b *BlockStatsBuilder) addSpanName(name string, count uint64) {
+	if _, ok := b.spanNames[name]; !ok && len(b.spanNames) >= maxTrackedSpanNames {
+		return
+	}
+	b.spanNames[name] += count
+}
+
+// AddService records the service name of a resource
+func (b *BlockStatsBuilder) AddService(name string) {
+	if b.servicesTruncated {
+		return
+	}
+	b.services[name] = struct{}{}
+	if len(b.services) > MaxBlockStatsServiceNames {
+		b.servicesTruncated = true
+		b.services = nil
+	}
+}
+
+// AddAttribute records the key of a span or resource attribute
+func (b *BlockStatsBuilder) AddAttribute(key string) {
+	if b.attributesTruncated {
+		return
+	}
+	b.attributes[key] = struct{}{}
+	if len(b.attributes) > MaxBlockStatsAttributes {
+		b.attributesTruncated = true
+		b.attributes = nil
+	}
+}
+
+// Stats returns the collected stats
+func (b *BlockStatsBuilder) Stats() *BlockStats {
+	s := &BlockStats{
+		SpanCount:             b.spanCount,
+		MinTraceDurationNanos: b.minDuration,
+		MaxTraceDurationNanos: b.maxDuration,
+		ServiceNamesTruncated: b.servicesTruncated,
+		AttributesTruncated:   b.attributesTruncated,
+	}
+
+	if !b.servicesTruncated {
+		s.ServiceNames = sortedKeys(b.services)
+	}
+	if !b.attributesTruncated {
+		s.Attributes = sortedKeys(b.attributes)
+	}
+
+	for name, count := range b.spanNames {
+		s.SpanNames = append(s.SpanNames, SpanNameCount{Name: name, Count: count})
+	}
+	sort.Slice(s.SpanNames, func(i, j int) bool {
+		if s.SpanNames[i].Count != s.SpanNames[j].Count {
+			return s.SpanNames[i].Count > s.SpanNames[j].Count
+		}
+		return s.SpanNames[i].Name < s.SpanNames[j].Name
+	})
+	if len(s.SpanNames) > MaxBlockStatsSpanNames {
+		s.SpanNames = s.SpanNames[:MaxBlockStatsSpanNames]
+	}
+
+	return s
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockStatsBuilder(t *testing.T) {
+	b := NewBlockStatsBuilder()
+	b.AddTrace(20)
+	b.AddTrace(10)
+	b.AddTrace(30)
+	b.AddService("svc-b")
+	b.AddService("svc-a")
+	b.AddService("svc-a")
+	b.AddAttribute("http.method")
+	b.AddAttribute("foo")
+	for i := 0; i < 3; i++ {
+		b.AddSpan("frequent")
+	}
+	b.AddSpan("rare")
+
+	s := b.Stats()
+	assert.Equal(t, uint64(4), s.SpanCount)
+	assert.Equal(t, uint64(10), s.MinTraceDurationNanos)
+	assert.Equal(t, uint64(30), s.MaxTraceDurationNanos)
+	assert.Equal(t, []string{"svc-a", "svc-b"}, s.ServiceNames)
+	assert.Equal(t, []string{"foo", "http.method"}, s.Attributes)
+	assert.Equal(t, []SpanNameCount{{Name: "frequent", Count: 3}, {Name: "rare", Count: 1}}, s.SpanNames)
+
+	assert.True(t, s.MayContainService("svc"))
+	assert.True(t, s.MayContainService("svc-a"))
+	assert.False(t, s.MayContainService("other"))
+	assert.True(t, s.MayContainAttribute("foo"))
+	assert.False(t, s.MayContainAttribute("bar"))
+	assert.True(t, s.MayContainDuration(0, 0))
+	assert.True(t, s.MayContainDuration(25, 0))
+	assert.True(t, s.MayContainDuration(0, 15))
+	assert.False(t, s.MayContainDuration(31, 0))
+	assert.False(t, s.MayContainDuration(0, 9))
+
+	// stats survive the round trip through the block meta
+	meta := &BlockMeta{Stats: s}
+	buf, err := json.Marshal(meta)
+	require.NoError(t, err)
+	actual := &BlockMeta{}
+	require.NoError(t, json.Unmarshal(buf, actual))
+	assert.Equal(t, s, actual.Stats)
+}
+
+func TestBlockStatsTruncation(t *testing.T) {
+	b := NewBlockStatsBuilder()
+	for i := 0; i <= MaxBlockStatsServiceNames; i++ {
+		b.AddService(fmt.Sprintf("svc-%d", i))
+	}
+	for i := 0; i <= MaxBlockStatsAttributes; i++ {
+		b.AddAttribute(fmt.Sprintf("attr-%d", i))
+	}
+	for i := 0; i < 2*MaxBlockStatsSpanNames; i++ {
+		b.AddSpan(fmt.Sprintf("span-%d", i))
+	}
+
+	s := b.Stats()
+	assert.True(t, s.ServiceNamesTruncated)
+	assert.Nil(t, s.ServiceNames)
+	assert.True(t, s.AttributesTruncated)
+	assert.Nil(t, s.Attributes)
+	assert.Len(t, s.SpanNames, MaxBlockStatsSpanNames)
+
+	// truncated sets can't rule anything out
+	assert.True(t, s.MayContainService("anything"))
+	assert.True(t, s.MayContainAttribute("anything"))
+}
+
+func TestMergeBlockStats(t *testing.T) {
+	a := &BlockStats{
+		SpanCount:             10,
+		MinTraceDurationNanos: 5,
+		MaxTraceDurationNanos: 50,
+		ServiceNames:          []string{"a", "b"},
+		SpanNames:             []SpanNameCount{{Name: "x", Count: 5}, {Name: "y", Count: 5}},
+		Attributes:            []string{"foo"},
+	}
+	b := &BlockStats{
+		SpanCount:             20,
+		MinTraceDurationNanos: 10,
+		MaxTraceDurationNanos: 100,
+		ServiceNames:          []string{"b", "c"},
+		SpanNames:             []SpanNameCount{{Name: "y", Count: 10}},
+		Attributes:            []string{"bar"},
+	}
+
+	s := MergeBlockStats(a, b)
+	assert.Equal(t, uint64(30), s.SpanCount)
+	assert.Equal(t, uint64(5), s.MinTraceDurationNanos)
+	assert.Equal(t, uint64(100), s.MaxTraceDurationNanos)
+	assert.Equal(t, []string{"a", "b", "c"}, s.ServiceNames)
+	assert.Equal(t, []string{"bar", "foo"}, s.Attributes)
+	assert.Equal(t, []SpanNameCount{{Name: "y", Count: 15}, {Name: "x", Count: 5}}, s.SpanNames)
+
+	// truncation is carried over
+	b.ServiceNamesTruncated = true
+	b.ServiceNames = nil
+	s = MergeBlockStats(a, b)
+	assert.True(t, s.ServiceNamesTruncated)
+	assert.Nil(t, s.ServiceNames)
+
+	// unknown stats make the merged stats unknown
+	assert.Nil(t, MergeBlockStats(a, nil))
+	assert.Nil(t, MergeBlockStats())
+}
@@ -67,8 +67,9 @@ func (r *readerWriter) ReadRange(ctx context.Context, name string, keypath backe
 		keyGen = append(keyGen, strconv.Itoa(int(offset)), strconv.Itoa(len(buffer)))
 		k = strings.Join(keyGen, ":")
 		found, vals, _ := r.cache.Fetch(ctx, []string{k})
-		if len(found) > 0 {
+		if len(found) > 0 && len(vals[0]) == len(buffer) {
 			copy(buffer, vals[0])
+			return nil
 		}
 	}
 
@@ -141,3 +141,28 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+func TestReadRange(t *testing.T) {
+	keypath := backend.KeyPathForBlock(uuid.New(), "test")
+	ctx := context.Background()
+
+	mockR := &backend.MockRawReader{
+		Range: []byte{0x01, 0x02},
+	}
+	r, _, _ := NewCache(mockR, &backend.MockRawWriter{}, NewMockClient())
+
+	buffer := make([]byte, 2)
+	assert.NoError(t, r.ReadRange(ctx, "foo", keypath, 10, buffer, true))
+	assert.Equal(t, []byte{0x01, 0x02}, buffer)
+
+	// cached ranges are served without reading the backend
+	mockR.Range = []byte{0x03, 0x04}
+	buffer = make([]byte, 2)
+	assert.NoError(t, r.ReadRange(ctx, "foo", keypath, 10, buffer, true))
+	assert.Equal(t, []byte{0x01, 0x02}, buffer)
+
+	// other ranges are not
+	buffer = make([]byte, 2)
+	assert.NoError(t, r.ReadRange(ctx, "foo", keypath, 12, buffer, true))
+	assert.Equal(t, []byte{0x03, 0x04}, buffer)
+}
@@ -0,0 +1,45 @@
+package disk
+
+import (
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/tempo/pkg/cache"
+)
+
+const (
+	DefaultPath         = "/var/tempo/cache"
+	DefaultMaxSizeBytes = 1024 * 1024 * 1024
+)
+
+type Config struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes uint64 `yaml:"max_size_bytes"`
+}
+
+// NewClient creates a disk cache. Writes are done in the background if a background config is given.
+func NewClient(cfg *Config, cfgBackground *cache.BackgroundConfig, logger log.Logger) (cache.Cache, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	if cfg.MaxSizeBytes == 0 {
+		cfg.MaxSizeBytes = DefaultMaxSizeBytes
+	}
+
+	diskCfg := cache.DiskCacheConfig{
+		Path:         cfg.Path,
+		MaxSizeBytes: cfg.MaxSizeBytes,
+	}
+	c, err := cache.NewDiskCache(diskCfg, "tempo", prometheus.DefaultRegisterer, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfgBackground == nil {
+		return c, nil
+	}
+	return cache.NewBackground("tempo", *cfgBackground, c, prometheus.DefaultRegisterer), nil
+}
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"context"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// checksumPendingTimeout is how long checksums of a block are kept while waiting for its meta. Objects written
+// after the meta, like search data, are never collected.
+const checksumPendingTimeout = 24 * time.Hour
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// NewChecksum returns the hash used for the checksums of block objects
+func NewChecksum() hash.Hash32 {
+	return crc32.New(castagnoli)
+}
+
+// ObjectChecksum streams an object of a block from the backend and returns its checksum
+func ObjectChecksum(ctx context.Context, r Reader, name string, blockID uuid.UUID, tenantID string) (uint32, error) {
+	rc, _, err := r.StreamReader(ctx, name, blockID, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	h := NewChecksum()
+	if _, err := io.Copy(h, rc); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+// checksums collects the checksums of the objects written for a block until its meta is written
+type checksums struct {
+	mtx    sync.Mutex
+	blocks map[uuid.UUID]*pendingChecksums
+}
+
+type pendingChecksums struct {
+	created time.Time
+	objects map[string]hash.Hash32
+}
+
+func newChecksums() *checksums {
+	return &checksums{
+		blocks: map[uuid.UUID]*pendingChecksums{},
+	}
+}
+
+// start returns a new hash for the object, replacing any previous one
+func (c *checksums) start(blockID uuid.UUID, name string) hash.Hash32 {
+	h := NewChecksum()
+	c.set(blockID, name, h)
+	return h
+}
+
+// set records the hash of the object, replacing any previous one
+func (c *checksums) set(blockID uuid.UUID, name string, h hash.Hash32) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	p, ok := c.blocks[blockID]
+	if !ok {
+		c.removeStale(time.Now())
+		p = &pendingChecksums{created: time.Now(), objects: map[string]hash.Hash32{}}
+		c.blocks[blockID] = p
+	}
+	p.objects[name] = h
+}
+
+// get returns the hash of an object that is appended to or starts a new one
+func (c *checksums) get(blockID uuid.UUID, name string) hash.Hash32 {
+	c.mtx.Lock()
+	p, ok := c.blocks[blockID]
+	if ok {
+		if h, ok := p.objects[name]; ok {
+			c.mtx.Unlock()
+			return h
+		}
+	}
+	c.mtx.Unlock()
+
+	return c.start(blockID, name)
+}
+
+// take returns the checksums of all objects written for the block and forgets them
+func (c *checksums) take(blockID uuid.UUID) map[string]uint32 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	p, ok := c.blocks[blockID]
+	if !ok {
+		return nil
+	}
+	delete(c.blocks, blockID)
+
+	sums := make(map[string]uint32, len(p.objects))
+	for name, h := range p.objects {
+		sums[name] = h.Sum32()
+	}
+	return sums
+}
+
+// removeStale must be called under lock
+func (c *checksums) removeStale(now time.Time) {
+	for id, p := range c.blocks {
+		if now.Sub(p.created) > checksumPendingTimeout {
+			delete(c.blocks, id)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRecordsChecksums(t *testing.T) {
+	m := &MockRawWriter{}
+	w := NewWriter(m)
+	ctx := context.Background()
+
+	blockID := uuid.New()
+	a := []byte{0x01, 0x02, 0x03}
+	b := []byte{0x04, 0x05}
+	c := []byte{0x06}
+
+	require.NoError(t, w.Write(ctx, "a", blockID, "test", a, false))
+	require.NoError(t, w.StreamWriter(ctx, "b", blockID, "test", bytes.NewReader(b), int64(len(b))))
+	_, err := w.Append(ctx, "c", blockID, "test", nil, c)
+	require.NoError(t, err)
+
+	// objects of other blocks are not included
+	require.NoError(t, w.Write(ctx, "a", uuid.New(), "test", b, false))
+
+	meta := NewBlockMeta("test", blockID, "v2", EncNone, "")
+	require.NoError(t, w.WriteBlockMeta(ctx, meta))
+
+	table := crc32.MakeTable(crc32.Castagnoli)
+	assert.Equal(t, map[string]uint32{
+		"a": crc32.Checksum(a, table),
+		"b": crc32.Checksum(b, table),
+		"c": crc32.Checksum(c, table),
+	}, meta.Checksums)
+
+	// checksums are only recorded once
+	meta = NewBlockMeta("test", blockID, "v2", EncNone, "")
+	require.NoError(t, w.WriteBlockMeta(ctx, meta))
+	assert.Nil(t, meta.Checksums)
+}
+
+func TestObjectChecksum(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03}
+	r := NewReader(&MockRawReader{R: data})
+
+	sum, err := ObjectChecksum(context.Background(), r, "test", uuid.New(), "test")
+	require.NoError(t, err)
+	assert.Equal(t, crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)), sum)
+}
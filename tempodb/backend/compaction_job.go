@@ -0,0 +1,93 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CompactionJobsDir is the folder beneath a tenant in which the compaction job queue is stored
+	CompactionJobsDir = "compaction_jobs"
+	// CompactionPlanName is the name of the object holding the compaction jobs of a tenant
+	CompactionPlanName = "plan.json"
+	// CompactionLeasesName is the name of the object holding the leases of a single compactor
+	CompactionLeasesName = "leases.json"
+)
+
+type CompactionJobStatus string
+
+const (
+	CompactionJobPending  CompactionJobStatus = "pending"
+	CompactionJobRunning  CompactionJobStatus = "running"
+	CompactionJobComplete CompactionJobStatus = "complete"
+	CompactionJobFailed   CompactionJobStatus = "failed"
+)
+
+// CompactionJob is a set of blocks of a tenant to be compacted together
+type CompactionJob struct {
+	ID        string      `json:"id"` // Derived from the block ids. Planning the same blocks twice results in the same job
+	BlockIDs  []uuid.UUID `json:"blockIDs"`
+	Hash      string      `json:"hash"` // Hash string returned by the block selector
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+func NewCompactionJob(blockIDs []uuid.UUID, hash string) *CompactionJob {
+	ids := append([]uuid.UUID(nil), blockIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	h := sha256.New()
+	for _, id := range ids {
+		_, _ = h.Write(id[:])
+	}
+
+	return &CompactionJob{
+		ID:        hex.EncodeToString(h.Sum(nil)[:8]),
+		BlockIDs:  ids,
+		Hash:      hash,
+		CreatedAt: time.Now(),
+	}
+}
+
+// CompactionPlan holds the compaction jobs of a tenant. It is only written by the compactor that
+// plans compaction jobs.
+type CompactionPlan struct {
+	TenantID  string           `json:"tenantID"`
+	Planner   string           `json:"planner"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Jobs      []*CompactionJob `json:"jobs"`
+}
+
+// CompactionLease is the claim of a compactor on a compaction job. Running leases must be renewed
+// before they expire, otherwise the job can be claimed by another compactor.
+type CompactionLease struct {
+	JobID     string              `json:"jobID"`
+	Status    CompactionJobStatus `json:"status"` // running, complete or failed
+	ClaimedAt time.Time           `json:"claimedAt"`
+	ExpiresAt time.Time           `json:"expiresAt"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+	Attempt   int                 `json:"attempt"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// Live returns true if the lease is held by a running compactor at the given time
+func (l *CompactionLease) Live(now time.Time) bool {
+	return l.Status == CompactionJobRunning && now.Before(l.ExpiresAt)
+}
+
+// CompactionLeases holds all leases of a single compactor for a tenant. Every compactor only writes
+// its own leases, which is what makes claiming jobs safe without conditional writes.
+type CompactionLeases struct {
+	TenantID  string             `json:"tenantID"`
+	Owner     string             `json:"owner"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Leases    []*CompactionLease `json:"leases"`
+}
+
+// KeyPathForCompactionLeases returns the keypath for the leases of the given compactor
+func KeyPathForCompactionLeases(owner string, tenantID string) KeyPath {
+	return []string{tenantID, CompactionJobsDir, owner}
+}
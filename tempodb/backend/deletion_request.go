@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DeletionRequestsDir is the folder beneath a tenant in which deletion requests are stored
+	DeletionRequestsDir = "deletion_requests"
+	// DeletionRequestName is the name of the object holding a single deletion request
+	DeletionRequestName = "request.json"
+)
+
+type DeletionRequestStatus string
+
+const (
+	DeletionRequestPending  DeletionRequestStatus = "pending"
+	DeletionRequestComplete DeletionRequestStatus = "complete"
+	DeletionRequestFailed   DeletionRequestStatus = "failed"
+)
+
+// DeletionRequest describes a set of traces that must be erased from all blocks of a tenant. Traces
+// are matched either by id or by carrying a span or resource attribute with the given key and value.
+type DeletionRequest struct {
+	ID        uuid.UUID             `json:"id"`
+	TenantID  string                `json:"tenantID"`
+	TraceIDs  []string              `json:"traceIDs,omitempty"`  // Hex encoded trace ids to delete
+	AttrKey   string                `json:"attrKey,omitempty"`   // Attribute key to match. Traces with any span carrying AttrKey=AttrValue are deleted
+	AttrValue string                `json:"attrValue,omitempty"` // Attribute value to match
+	StartTime time.Time             `json:"startTime,omitempty"` // Optional. Only blocks overlapping [StartTime, EndTime] are considered
+	EndTime   time.Time             `json:"endTime,omitempty"`   // Optional
+	CreatedAt time.Time             `json:"createdAt"`
+	UpdatedAt time.Time             `json:"updatedAt"`
+	Status    DeletionRequestStatus `json:"status"`
+	Error     string                `json:"error,omitempty"`
+
+	BlocksRewritten int `json:"blocksRewritten"` // Number of blocks rewritten to satisfy this request
+	TracesDeleted   int `json:"tracesDeleted"`   // Number of traces dropped while rewriting
+}
+
+func NewDeletionRequest(tenantID string) *DeletionRequest {
+	now := time.Now()
+	return &DeletionRequest{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    DeletionRequestPending,
+	}
+}
+
+// DecodedTraceIDs returns the trace ids of the request in binary form.
+func (r *DeletionRequest) DecodedTraceIDs() ([][]byte, error) {
+	ids := make([][]byte, 0, len(r.TraceIDs))
+	for _, id := range r.TraceIDs {
+		b, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, b)
+	}
+	return ids, nil
+}
+
+// OverlapsBlock returns true if the given block may hold traces matching this request
+// based on its time and id range. It is a coarse check and does not consult bloom filters.
+func (r *DeletionRequest) OverlapsBlock(meta *BlockMeta, ids [][]byte) bool {
+	if !r.StartTime.IsZero() && meta.EndTime.Before(r.StartTime) {
+		return false
+	}
+	if !r.EndTime.IsZero() && meta.StartTime.After(r.EndTime) {
+		return false
+	}
+
+	// attribute based requests can match any block
+	if len(ids) == 0 {
+		return true
+	}
+
+	for _, id := range ids {
+		if bytes.Compare(id, meta.MinID) >= 0 && bytes.Compare(id, meta.MaxID) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPathForDeletionRequest returns the keypath for the given deletion request
+func KeyPathForDeletionRequest(requestID uuid.UUID, tenantID string) KeyPath {
+	return []string{tenantID, DeletionRequestsDir, requestID.String()}
+}
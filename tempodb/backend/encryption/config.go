@@ -0,0 +1,21 @@
+package encryption
+
+const DefaultChunkSizeBytes = 16 * 1024
+
+// Config configures client side encryption of all objects written to the backend
+type Config struct {
+	// KeyProvider selects where key encryption keys come from. Empty disables encryption.
+	KeyProvider    string      `yaml:"key_provider"`
+	ChunkSizeBytes int         `yaml:"chunk_size_bytes"`
+	File           *FileConfig `yaml:"file"`
+}
+
+// FileConfig configures the file key provider
+type FileConfig struct {
+	Path string `yaml:"path"`
+}
+
+// Enabled returns true if encryption is configured
+func (c *Config) Enabled() bool {
+	return c != nil && c.KeyProvider != ""
+}
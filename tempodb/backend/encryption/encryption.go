@@ -0,0 +1,356 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+
+	tempo_io "github.com/grafana/tempo/pkg/io"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// openedObjectsCacheSize is the number of objects whose decrypted data keys are kept for range reads
+const openedObjectsCacheSize = 1000
+
+// openedObject holds what is needed to decrypt ranges of an object without reading its header again
+type openedObject struct {
+	aead         cipher.AEAD
+	chunkSize    int
+	headerLength int
+}
+
+type readerWriter struct {
+	nextReader backend.RawReader
+	nextWriter backend.RawWriter
+	keys       KeyProvider
+	chunkSize  int
+
+	openedMtx sync.Mutex
+	opened    *simplelru.LRU
+}
+
+// NewEncryption wraps the given reader and writer and encrypts all objects with a new AES-256-GCM data key
+// before they are written. Data keys are encrypted by the key provider with a key of the tenant the object
+// belongs to and stored in the object header.
+//
+// Block metas are not encrypted because backend compactors read and copy them directly. Objects outside
+// of a tenant, like the cluster seed, are not encrypted either. Objects without an encryption header are
+// read as is, so that encryption can be enabled on existing backends.
+func NewEncryption(nextReader backend.RawReader, nextWriter backend.RawWriter, keys KeyProvider, chunkSize int) (backend.RawReader, backend.RawWriter, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSizeBytes
+	}
+	if chunkSize < minChunkSizeBytes {
+		return nil, nil, fmt.Errorf("chunk size must be at least %d bytes", minChunkSizeBytes)
+	}
+
+	opened, err := simplelru.NewLRU(openedObjectsCacheSize, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw := &readerWriter{
+		nextReader: nextReader,
+		nextWriter: nextWriter,
+		keys:       keys,
+		chunkSize:  chunkSize,
+		opened:     opened,
+	}
+
+	return rw, rw, nil
+}
+
+// List implements backend.RawReader
+func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	return rw.nextReader.List(ctx, keypath)
+}
+
+// Read implements backend.RawReader
+func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	object, size, err := rw.nextReader.Read(ctx, name, keypath, shouldCache)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !shouldEncrypt(name, keypath) {
+		return object, size, nil
+	}
+	defer object.Close()
+
+	b, err := tempo_io.ReadAllWithEstimate(object, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h, err := unmarshalHeader(b)
+	if err == errNotEncrypted {
+		return io.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	aead, err := rw.openDataKey(ctx, tenantID(keypath), h.encryptedKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	plaintext, err := decryptAll(aead, h.chunkSize, b[h.length():])
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decrypt %s: %w", backend.ObjectFileName(keypath, name), err)
+	}
+
+	return io.NopCloser(bytes.NewReader(plaintext)), int64(len(plaintext)), nil
+}
+
+// ReadRange implements backend.RawReader
+func (rw *readerWriter) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte, shouldCache bool) error {
+	if !shouldEncrypt(name, keypath) || len(buffer) == 0 {
+		return rw.nextReader.ReadRange(ctx, name, keypath, offset, buffer, shouldCache)
+	}
+
+	obj, cached, err := rw.open(ctx, name, keypath)
+	if err == errNotEncrypted {
+		return rw.nextReader.ReadRange(ctx, name, keypath, offset, buffer, shouldCache)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = rw.readRange(ctx, obj, name, keypath, offset, buffer, shouldCache)
+	if err != nil && cached {
+		// the object might have been overwritten with a new data key
+		rw.forget(name, keypath)
+		obj, _, err = rw.open(ctx, name, keypath)
+		if err != nil {
+			return err
+		}
+		err = rw.readRange(ctx, obj, name, keypath, offset, buffer, shouldCache)
+	}
+
+	return err
+}
+
+func (rw *readerWriter) readRange(ctx context.Context, obj *openedObject, name string, keypath backend.KeyPath, offset uint64, buffer []byte, shouldCache bool) error {
+	var (
+		chunkSize    = uint64(obj.chunkSize)
+		encChunkSize = chunkSize + uint64(obj.aead.Overhead())
+		first        = offset / chunkSize
+		last         = (offset + uint64(len(buffer)) - 1) / chunkSize
+	)
+
+	ciphertext := make([]byte, (last-first+1)*encChunkSize)
+	err := rw.nextReader.ReadRange(ctx, name, keypath, uint64(obj.headerLength)+first*encChunkSize, ciphertext, shouldCache)
+	if err != nil {
+		return err
+	}
+
+	var (
+		plaintext = make([]byte, 0, (last-first+1)*chunkSize)
+		nonce     = make([]byte, obj.aead.NonceSize())
+	)
+	for i := uint64(0); i <= last-first; i++ {
+		plaintext, _, err = openChunk(obj.aead, plaintext, ciphertext[i*encChunkSize:(i+1)*encChunkSize], first+i, nonce)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", backend.ObjectFileName(keypath, name), err)
+		}
+	}
+
+	copy(buffer, plaintext[offset-first*chunkSize:])
+	return nil
+}
+
+// open returns the data key and layout of the object. It is read from the header of the object if
+// it's not cached yet.
+func (rw *readerWriter) open(ctx context.Context, name string, keypath backend.KeyPath) (*openedObject, bool, error) {
+	k := key(keypath, name)
+
+	rw.openedMtx.Lock()
+	v, ok := rw.opened.Get(k)
+	rw.openedMtx.Unlock()
+	if ok {
+		return v.(*openedObject), true, nil
+	}
+
+	prefix := make([]byte, headerPrefixLength)
+	err := rw.nextReader.ReadRange(ctx, name, keypath, 0, prefix, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	chunkSize, keyLength, err := unmarshalHeaderPrefix(prefix)
+	if err != nil {
+		return nil, false, err
+	}
+
+	encryptedKey := make([]byte, keyLength)
+	err = rw.nextReader.ReadRange(ctx, name, keypath, headerPrefixLength, encryptedKey, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	aead, err := rw.openDataKey(ctx, tenantID(keypath), encryptedKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	obj := &openedObject{
+		aead:         aead,
+		chunkSize:    chunkSize,
+		headerLength: headerPrefixLength + keyLength,
+	}
+
+	rw.openedMtx.Lock()
+	rw.opened.Add(k, obj)
+	rw.openedMtx.Unlock()
+
+	return obj, false, nil
+}
+
+func (rw *readerWriter) forget(name string, keypath backend.KeyPath) {
+	rw.openedMtx.Lock()
+	rw.opened.Remove(key(keypath, name))
+	rw.openedMtx.Unlock()
+}
+
+// Shutdown implements backend.RawReader
+func (rw *readerWriter) Shutdown() {
+	rw.nextReader.Shutdown()
+}
+
+// Write implements backend.RawWriter
+func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, size int64, shouldCache bool) error {
+	if !shouldEncrypt(name, keypath) {
+		return rw.nextWriter.Write(ctx, name, keypath, data, size, shouldCache)
+	}
+
+	if size < 0 {
+		b, err := tempo_io.ReadAllWithEstimate(data, 0)
+		if err != nil {
+			return err
+		}
+		data = bytes.NewReader(b)
+		size = int64(len(b))
+	}
+
+	h, aead, err := rw.newDataKey(ctx, tenantID(keypath))
+	if err != nil {
+		return err
+	}
+
+	// overwriting an object creates a new data key
+	rw.forget(name, keypath)
+
+	return rw.nextWriter.Write(ctx, name, keypath, newEncryptingReader(data, h, aead), encryptedLength(h, aead, size), shouldCache)
+}
+
+type appendTracker struct {
+	next    backend.AppendTracker
+	name    string
+	keypath backend.KeyPath
+	sealer  *chunkSealer
+}
+
+// Append implements backend.RawWriter. Data not filling a complete chunk is held back until the next
+// call to Append or CloseAppend.
+func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	if !shouldEncrypt(name, keypath) {
+		return rw.nextWriter.Append(ctx, name, keypath, tracker, buffer)
+	}
+
+	var (
+		a   *appendTracker
+		out []byte
+	)
+	if tracker == nil {
+		h, aead, err := rw.newDataKey(ctx, tenantID(keypath))
+		if err != nil {
+			return nil, err
+		}
+		rw.forget(name, keypath)
+
+		a = &appendTracker{
+			name:    name,
+			keypath: keypath,
+			sealer:  newChunkSealer(aead, h.chunkSize),
+		}
+		out = h.marshal()
+	} else {
+		a = tracker.(*appendTracker)
+	}
+
+	out = a.sealer.write(out, buffer)
+	if len(out) == 0 {
+		return a, nil
+	}
+
+	next, err := rw.nextWriter.Append(ctx, name, keypath, a.next, out)
+	if err != nil {
+		return nil, err
+	}
+	a.next = next
+
+	return a, nil
+}
+
+// CloseAppend implements backend.RawWriter
+func (rw *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendTracker) error {
+	a, ok := tracker.(*appendTracker)
+	if !ok {
+		return rw.nextWriter.CloseAppend(ctx, tracker)
+	}
+
+	next, err := rw.nextWriter.Append(ctx, a.name, a.keypath, a.next, a.sealer.close(nil))
+	if err != nil {
+		return err
+	}
+
+	return rw.nextWriter.CloseAppend(ctx, next)
+}
+
+func (rw *readerWriter) newDataKey(ctx context.Context, tenantID string) (header, cipher.AEAD, error) {
+	key, encryptedKey, err := rw.keys.GenerateDataKey(ctx, tenantID)
+	if err != nil {
+		return header{}, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return header{}, nil, err
+	}
+
+	return header{
+		chunkSize:    rw.chunkSize,
+		encryptedKey: encryptedKey,
+	}, aead, nil
+}
+
+func (rw *readerWriter) openDataKey(ctx context.Context, tenantID string, encryptedKey []byte) (cipher.AEAD, error) {
+	key, err := rw.keys.DecryptDataKey(ctx, tenantID, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAEAD(key)
+}
+
+func shouldEncrypt(name string, keypath backend.KeyPath) bool {
+	if len(keypath) == 0 {
+		return false
+	}
+
+	return name != backend.MetaName && name != backend.CompactedMetaName
+}
+
+func tenantID(keypath backend.KeyPath) string {
+	return keypath[0]
+}
+
+func key(keypath backend.KeyPath, name string) string {
+	return strings.Join(keypath, ":") + ":" + name
+}
@@ -0,0 +1,229 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"io"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+)
+
+const testChunkSize = minChunkSizeBytes
+
+func newTestKeyProvider(t *testing.T, tenants ...string) KeyProvider {
+	f := keyFile{Keys: map[string]string{}}
+	for _, tenant := range tenants {
+		key := make([]byte, dataKeyLength)
+		_, err := crand.Read(key)
+		require.NoError(t, err)
+		f.Keys[tenant] = base64.StdEncoding.EncodeToString(key)
+	}
+
+	b, err := yaml.Marshal(f)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, b, 0600))
+
+	p, err := NewKeyProvider(&Config{KeyProvider: KeyProviderFile, File: &FileConfig{Path: path}})
+	require.NoError(t, err)
+	return p
+}
+
+func newTestBackend(t *testing.T, keys KeyProvider) (backend.RawReader, backend.RawWriter, backend.RawReader) {
+	rawR, rawW, _, err := local.New(&local.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	r, w, err := NewEncryption(rawR, rawW, keys, testChunkSize)
+	require.NoError(t, err)
+
+	return r, w, rawR
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = mrand.Read(b)
+	return b
+}
+
+func TestRoundtrip(t *testing.T) {
+	r, w, rawR := newTestBackend(t, newTestKeyProvider(t, "tenant"))
+	ctx := context.Background()
+	keypath := backend.KeyPathForBlock(uuid.New(), "tenant")
+
+	sizes := []int{0, 1, testChunkSize - lengthSuffixLength, testChunkSize - lengthSuffixLength + 1, testChunkSize, testChunkSize + 1, 3*testChunkSize + 17}
+	for _, size := range sizes {
+		data := randomBytes(size)
+
+		// write
+		require.NoError(t, w.Write(ctx, "write", keypath, bytes.NewReader(data), int64(len(data)), false))
+
+		// append in random pieces
+		var tracker backend.AppendTracker
+		var err error
+		for remaining := data; len(remaining) > 0; {
+			n := mrand.Intn(testChunkSize*2) + 1
+			if n > len(remaining) {
+				n = len(remaining)
+			}
+			tracker, err = w.Append(ctx, "append", keypath, tracker, remaining[:n])
+			require.NoError(t, err)
+			remaining = remaining[n:]
+		}
+		if tracker == nil {
+			tracker, err = w.Append(ctx, "append", keypath, nil, nil)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.CloseAppend(ctx, tracker))
+
+		for _, name := range []string{"write", "append"} {
+			// stored encrypted
+			raw, _, err := rawR.Read(ctx, name, keypath, false)
+			require.NoError(t, err)
+			rawBytes, err := io.ReadAll(raw)
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(rawBytes, []byte(magic)))
+			if size > 16 {
+				require.False(t, bytes.Contains(rawBytes, data))
+			}
+
+			// read
+			rc, readSize, err := r.Read(ctx, name, keypath, false)
+			require.NoError(t, err)
+			actual, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.Equal(t, int64(size), readSize)
+			require.Equal(t, data, actual)
+
+			// read ranges
+			for i := 0; i < 20 && size > 0; i++ {
+				offset := mrand.Intn(size)
+				length := mrand.Intn(size-offset) + 1
+
+				buffer := make([]byte, length)
+				require.NoError(t, r.ReadRange(ctx, name, keypath, uint64(offset), buffer, false))
+				require.Equal(t, data[offset:offset+length], buffer)
+			}
+		}
+	}
+}
+
+func TestEncryptedLength(t *testing.T) {
+	keys := newTestKeyProvider(t, "tenant")
+	ctx := context.Background()
+
+	rw, _, err := NewEncryption(nil, nil, keys, testChunkSize)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, testChunkSize - lengthSuffixLength, testChunkSize - 1, testChunkSize, 5*testChunkSize + 3} {
+		h, aead, err := rw.(*readerWriter).newDataKey(ctx, "tenant")
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(newEncryptingReader(bytes.NewReader(randomBytes(size)), h, aead))
+		require.NoError(t, err)
+		assert.Equal(t, encryptedLength(h, aead, int64(size)), int64(len(b)), "size %d", size)
+	}
+}
+
+func TestUnencryptedObjects(t *testing.T) {
+	r, w, rawR := newTestBackend(t, newTestKeyProvider(t, "tenant"))
+	ctx := context.Background()
+	blockID := uuid.New()
+	keypath := backend.KeyPathForBlock(blockID, "tenant")
+
+	// metas are stored in plain text
+	meta := backend.NewBlockMeta("tenant", blockID, "v2", backend.EncNone, "")
+	require.NoError(t, backend.NewWriter(w).WriteBlockMeta(ctx, meta))
+	raw, _, err := rawR.Read(ctx, backend.MetaName, keypath, false)
+	require.NoError(t, err)
+	rawBytes, err := io.ReadAll(raw)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(rawBytes, []byte("{")))
+
+	actual, err := backend.NewReader(r).BlockMeta(ctx, blockID, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, meta.BlockID, actual.BlockID)
+
+	// objects written before encryption was enabled can still be read
+	rawW := rawR.(backend.RawWriter)
+	data := randomBytes(2 * testChunkSize)
+	require.NoError(t, rawW.Write(ctx, "plain", keypath, bytes.NewReader(data), int64(len(data)), false))
+
+	rc, _, err := r.Read(ctx, "plain", keypath, false)
+	require.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	buffer := make([]byte, 100)
+	require.NoError(t, r.ReadRange(ctx, "plain", keypath, 50, buffer, false))
+	require.Equal(t, data[50:150], buffer)
+}
+
+func TestTenantKeys(t *testing.T) {
+	keys := newTestKeyProvider(t, "a", "b")
+	ctx := context.Background()
+
+	key, encryptedKey, err := keys.GenerateDataKey(ctx, "a")
+	require.NoError(t, err)
+
+	decrypted, err := keys.DecryptDataKey(ctx, "a", encryptedKey)
+	require.NoError(t, err)
+	require.Equal(t, key, decrypted)
+
+	// data keys are bound to their tenant
+	_, err = keys.DecryptDataKey(ctx, "b", encryptedKey)
+	require.Error(t, err)
+
+	// tenants without a key can't write
+	_, _, err = keys.GenerateDataKey(ctx, "c")
+	require.Error(t, err)
+
+	// unless there is a wildcard key
+	keys = newTestKeyProvider(t, "a", wildcardTenant)
+	_, _, err = keys.GenerateDataKey(ctx, "c")
+	require.NoError(t, err)
+}
+
+func TestTampering(t *testing.T) {
+	r, w, rawR := newTestBackend(t, newTestKeyProvider(t, "tenant"))
+	ctx := context.Background()
+	keypath := backend.KeyPathForBlock(uuid.New(), "tenant")
+
+	data := randomBytes(3 * testChunkSize)
+	require.NoError(t, w.Write(ctx, "data", keypath, bytes.NewReader(data), int64(len(data)), false))
+
+	raw, _, err := rawR.Read(ctx, "data", keypath, false)
+	require.NoError(t, err)
+	rawBytes, err := io.ReadAll(raw)
+	require.NoError(t, err)
+
+	rawW := rawR.(backend.RawWriter)
+	write := func(b []byte) {
+		require.NoError(t, rawW.Write(ctx, "data", keypath, bytes.NewReader(b), int64(len(b)), false))
+	}
+
+	// flipped bit
+	tampered := append([]byte{}, rawBytes...)
+	tampered[len(tampered)-100] ^= 1
+	write(tampered)
+	_, _, err = r.Read(ctx, "data", keypath, false)
+	require.Error(t, err)
+
+	// truncated by a chunk
+	write(rawBytes[:len(rawBytes)-(testChunkSize+16)])
+	_, _, err = r.Read(ctx, "data", keypath, false)
+	require.Error(t, err)
+}
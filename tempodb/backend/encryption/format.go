@@ -0,0 +1,269 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted objects start with a header followed by a sequence of AES-GCM sealed chunks:
+//
+//	magic (4) | chunk size (4) | encrypted data key length (2) | encrypted data key | chunk 0 | chunk 1 | ...
+//
+// All chunks hold exactly chunk size bytes of plaintext so that plaintext offsets map directly onto
+// ciphertext offsets, which makes range reads possible. The last chunk is the final chunk. It is padded
+// with zeros and ends with the total plaintext length. If the remaining data does not fit into the final
+// chunk it is written padded into its own chunk. The chunk index and a final flag make up the nonce which
+// prevents chunks from being reordered or the object from being truncated unnoticed.
+const (
+	magic = "TEC1"
+
+	headerPrefixLength = 4 + 4 + 2
+	lengthSuffixLength = 8
+
+	minChunkSizeBytes = 1024
+)
+
+var (
+	errNotEncrypted  = errors.New("object is not encrypted")
+	errInvalidObject = errors.New("invalid encrypted object")
+)
+
+type header struct {
+	chunkSize    int
+	encryptedKey []byte
+}
+
+func (h header) length() int {
+	return headerPrefixLength + len(h.encryptedKey)
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, h.length())
+	copy(b, magic)
+	binary.BigEndian.PutUint32(b[4:], uint32(h.chunkSize))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(h.encryptedKey)))
+	copy(b[headerPrefixLength:], h.encryptedKey)
+	return b
+}
+
+// unmarshalHeaderPrefix parses the fixed size part of the header and returns the length of the
+// encrypted data key that follows it.
+func unmarshalHeaderPrefix(b []byte) (chunkSize int, keyLength int, err error) {
+	if len(b) < headerPrefixLength || !bytes.Equal(b[:4], []byte(magic)) {
+		return 0, 0, errNotEncrypted
+	}
+
+	chunkSize = int(binary.BigEndian.Uint32(b[4:]))
+	if chunkSize < minChunkSizeBytes {
+		return 0, 0, errInvalidObject
+	}
+
+	return chunkSize, int(binary.BigEndian.Uint16(b[8:])), nil
+}
+
+func unmarshalHeader(b []byte) (header, error) {
+	chunkSize, keyLength, err := unmarshalHeaderPrefix(b)
+	if err != nil {
+		return header{}, err
+	}
+	if len(b) < headerPrefixLength+keyLength {
+		return header{}, errInvalidObject
+	}
+
+	return header{
+		chunkSize:    chunkSize,
+		encryptedKey: b[headerPrefixLength : headerPrefixLength+keyLength],
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(nonce []byte, idx uint64, final bool) []byte {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce, idx)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptedLength returns the size of the encrypted object for the given plaintext length
+func encryptedLength(h header, aead cipher.AEAD, plaintextLength int64) int64 {
+	chunks := chunkCount(h.chunkSize, plaintextLength)
+	return int64(h.length()) + chunks*int64(h.chunkSize+aead.Overhead())
+}
+
+func chunkCount(chunkSize int, plaintextLength int64) int64 {
+	full := plaintextLength / int64(chunkSize)
+	rem := plaintextLength % int64(chunkSize)
+
+	if rem+lengthSuffixLength <= int64(chunkSize) {
+		return full + 1
+	}
+	return full + 2
+}
+
+// chunkSealer encrypts a stream of plaintext into chunks
+type chunkSealer struct {
+	aead      cipher.AEAD
+	chunkSize int
+	nonce     []byte
+
+	idx     uint64
+	length  uint64
+	pending []byte
+}
+
+func newChunkSealer(aead cipher.AEAD, chunkSize int) *chunkSealer {
+	return &chunkSealer{
+		aead:      aead,
+		chunkSize: chunkSize,
+		nonce:     make([]byte, aead.NonceSize()),
+		pending:   make([]byte, 0, chunkSize),
+	}
+}
+
+// write consumes p and appends all complete chunks to dst. Data that does not fill a chunk is held back
+// until the next call to write or close.
+func (s *chunkSealer) write(dst []byte, p []byte) []byte {
+	s.length += uint64(len(p))
+
+	for len(p) > 0 {
+		n := s.chunkSize - len(s.pending)
+		if n > len(p) {
+			n = len(p)
+		}
+		s.pending = append(s.pending, p[:n]...)
+		p = p[n:]
+
+		// only seal when more data is following. the final chunk is sealed in close
+		if len(s.pending) == s.chunkSize && len(p) > 0 {
+			dst = s.seal(dst, false)
+		}
+	}
+
+	return dst
+}
+
+// close appends the remaining chunks including the final chunk to dst
+func (s *chunkSealer) close(dst []byte) []byte {
+	if len(s.pending)+lengthSuffixLength > s.chunkSize {
+		s.pad(s.chunkSize)
+		dst = s.seal(dst, false)
+	}
+
+	s.pad(s.chunkSize - lengthSuffixLength)
+	s.pending = binary.BigEndian.AppendUint64(s.pending, s.length)
+	return s.seal(dst, true)
+}
+
+func (s *chunkSealer) pad(n int) {
+	for len(s.pending) < n {
+		s.pending = append(s.pending, 0)
+	}
+}
+
+func (s *chunkSealer) seal(dst []byte, final bool) []byte {
+	dst = s.aead.Seal(dst, chunkNonce(s.nonce, s.idx, final), s.pending, nil)
+	s.idx++
+	s.pending = s.pending[:0]
+	return dst
+}
+
+// openChunk decrypts a single chunk. If final is unknown both variants are attempted.
+func openChunk(aead cipher.AEAD, dst []byte, chunk []byte, idx uint64, nonce []byte) ([]byte, bool, error) {
+	out, err := aead.Open(dst, chunkNonce(nonce, idx, false), chunk, nil)
+	if err == nil {
+		return out, false, nil
+	}
+
+	out, err = aead.Open(dst, chunkNonce(nonce, idx, true), chunk, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt chunk %d: %w", idx, err)
+	}
+	return out, true, nil
+}
+
+// decryptAll decrypts a complete object that starts after the header
+func decryptAll(aead cipher.AEAD, chunkSize int, ciphertext []byte) ([]byte, error) {
+	encChunkSize := chunkSize + aead.Overhead()
+	if len(ciphertext) == 0 || len(ciphertext)%encChunkSize != 0 {
+		return nil, errInvalidObject
+	}
+
+	chunks := len(ciphertext) / encChunkSize
+	plaintext := make([]byte, 0, chunks*chunkSize)
+	nonce := make([]byte, aead.NonceSize())
+
+	for i := 0; i < chunks; i++ {
+		var (
+			final = i == chunks-1
+			err   error
+		)
+		plaintext, err = aead.Open(plaintext, chunkNonce(nonce, uint64(i), final), ciphertext[i*encChunkSize:(i+1)*encChunkSize], nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chunk %d: %w", i, err)
+		}
+	}
+
+	length := binary.BigEndian.Uint64(plaintext[len(plaintext)-lengthSuffixLength:])
+	if length > uint64(len(plaintext)-lengthSuffixLength) {
+		return nil, errInvalidObject
+	}
+
+	return plaintext[:length], nil
+}
+
+// encryptingReader encrypts the plaintext of the wrapped reader on the fly
+type encryptingReader struct {
+	r      io.Reader
+	sealer *chunkSealer
+
+	buf  []byte
+	read []byte
+	done bool
+}
+
+func newEncryptingReader(r io.Reader, h header, aead cipher.AEAD) *encryptingReader {
+	return &encryptingReader{
+		r:      r,
+		sealer: newChunkSealer(aead, h.chunkSize),
+		buf:    h.marshal(),
+		read:   make([]byte, h.chunkSize),
+	}
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+
+		n, err := e.r.Read(e.read)
+		e.buf = e.sealer.write(e.buf[:0], e.read[:n])
+		if err == io.EOF {
+			e.buf = e.sealer.close(e.buf)
+			e.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,140 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	KeyProviderFile = "file"
+
+	// dataKeyLength selects AES-256 for data keys
+	dataKeyLength = 32
+
+	// wildcardTenant is the key of the file key provider used for tenants without their own key
+	wildcardTenant = "*"
+)
+
+// KeyProvider generates and decrypts the per object data keys (envelope encryption). Data keys are
+// encrypted with a tenant specific key encryption key that never leaves the provider.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key and its encrypted form which is stored alongside the data.
+	GenerateDataKey(ctx context.Context, tenantID string) (key []byte, encryptedKey []byte, err error)
+	// DecryptDataKey returns the data key of an encrypted key created by GenerateDataKey.
+	DecryptDataKey(ctx context.Context, tenantID string, encryptedKey []byte) ([]byte, error)
+}
+
+// NewKeyProvider returns the key provider selected in the config
+func NewKeyProvider(cfg *Config) (KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case KeyProviderFile:
+		return NewFileKeyProvider(cfg.File)
+	default:
+		return nil, fmt.Errorf("unknown key provider %s", cfg.KeyProvider)
+	}
+}
+
+// keyFile is the format of the file read by the file key provider. It maps tenants to base64 encoded
+// AES-256 keys. The key of tenant "*" is used for all tenants without their own key.
+type keyFile struct {
+	Keys map[string]string `yaml:"keys"`
+}
+
+// FileKeyProvider reads key encryption keys from a file. It is meant for testing and small setups.
+type FileKeyProvider struct {
+	keys map[string][]byte
+}
+
+var _ KeyProvider = (*FileKeyProvider)(nil)
+
+// NewFileKeyProvider loads the keys from the file in the config
+func NewFileKeyProvider(cfg *FileConfig) (*FileKeyProvider, error) {
+	if cfg == nil || cfg.Path == "" {
+		return nil, fmt.Errorf("file key provider requires a path")
+	}
+
+	b, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	f := keyFile{}
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	p := &FileKeyProvider{
+		keys: make(map[string][]byte, len(f.Keys)),
+	}
+	for tenantID, encoded := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key of tenant %s: %w", tenantID, err)
+		}
+		if len(key) != dataKeyLength {
+			return nil, fmt.Errorf("key of tenant %s must be %d bytes long", tenantID, dataKeyLength)
+		}
+		p.keys[tenantID] = key
+	}
+
+	return p, nil
+}
+
+// GenerateDataKey implements KeyProvider
+func (p *FileKeyProvider) GenerateDataKey(_ context.Context, tenantID string) ([]byte, []byte, error) {
+	aead, err := p.aead(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := make([]byte, dataKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	// the tenant id is authenticated so that a data key can't be used for another tenant
+	return key, aead.Seal(nonce, nonce, key, []byte(tenantID)), nil
+}
+
+// DecryptDataKey implements KeyProvider
+func (p *FileKeyProvider) DecryptDataKey(_ context.Context, tenantID string, encryptedKey []byte) ([]byte, error) {
+	aead, err := p.aead(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encryptedKey) < aead.NonceSize() {
+		return nil, errInvalidObject
+	}
+
+	nonce := encryptedKey[:aead.NonceSize()]
+	key, err := aead.Open(nil, nonce, encryptedKey[aead.NonceSize():], []byte(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key of tenant %s: %w", tenantID, err)
+	}
+
+	return key, nil
+}
+
+func (p *FileKeyProvider) aead(tenantID string) (cipher.AEAD, error) {
+	key, ok := p.keys[tenantID]
+	if !ok {
+		key, ok = p.keys[wildcardTenant]
+	}
+	if !ok {
+		return nil, fmt.Errorf("no key found for tenant %s", tenantID)
+	}
+
+	return newAEAD(key)
+}
@@ -95,6 +95,10 @@ type MockReader struct {
 	R             []byte // read
 	Range         []byte // ReadRange
 	ReadFn        func(name string, blockID uuid.UUID, tenantID string) ([]byte, error)
+
+	DeletionRequestsFn func(ctx context.Context, tenantID string) ([]*DeletionRequest, error)
+	CompactionPlanFn   func(ctx context.Context, tenantID string) (*CompactionPlan, error)
+	CompactionLeasesFn func(ctx context.Context, tenantID string) ([]*CompactionLeases, error)
 }
 
 func (m *MockReader) Tenants(ctx context.Context) ([]string, error) {
@@ -140,12 +144,39 @@ func (m *MockReader) TenantIndex(ctx context.Context, tenantID string) (*TenantI
 	return &TenantIndex{}, nil
 }
 
+func (m *MockReader) DeletionRequests(ctx context.Context, tenantID string) ([]*DeletionRequest, error) {
+	if m.DeletionRequestsFn != nil {
+		return m.DeletionRequestsFn(ctx, tenantID)
+	}
+
+	return nil, nil
+}
+
+func (m *MockReader) CompactionPlan(ctx context.Context, tenantID string) (*CompactionPlan, error) {
+	if m.CompactionPlanFn != nil {
+		return m.CompactionPlanFn(ctx, tenantID)
+	}
+
+	return nil, nil
+}
+
+func (m *MockReader) CompactionLeases(ctx context.Context, tenantID string) ([]*CompactionLeases, error) {
+	if m.CompactionLeasesFn != nil {
+		return m.CompactionLeasesFn(ctx, tenantID)
+	}
+
+	return nil, nil
+}
+
 func (m *MockReader) Shutdown() {}
 
 // MockWriter
 type MockWriter struct {
 	IndexMeta          map[string][]*BlockMeta
 	IndexCompactedMeta map[string][]*CompactedBlockMeta
+	DeletionRequests   []*DeletionRequest
+	CompactionPlans    []*CompactionPlan
+	CompactionLeases   []*CompactionLeases
 }
 
 func (m *MockWriter) Write(ctx context.Context, name string, blockID uuid.UUID, tenantID string, buffer []byte, shouldCache bool) error {
@@ -174,3 +205,15 @@ func (m *MockWriter) WriteTenantIndex(ctx context.Context, tenantID string, meta
 	m.IndexCompactedMeta[tenantID] = compactedMeta
 	return nil
 }
+func (m *MockWriter) WriteDeletionRequest(ctx context.Context, req *DeletionRequest) error {
+	m.DeletionRequests = append(m.DeletionRequests, req)
+	return nil
+}
+func (m *MockWriter) WriteCompactionPlan(ctx context.Context, plan *CompactionPlan) error {
+	m.CompactionPlans = append(m.CompactionPlans, plan)
+	return nil
+}
+func (m *MockWriter) WriteCompactionLeases(ctx context.Context, leases *CompactionLeases) error {
+	m.CompactionLeases = append(m.CompactionLeases, leases)
+	return nil
+}
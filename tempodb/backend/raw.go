@@ -48,28 +48,50 @@ type RawReader interface {
 }
 
 type writer struct {
-	w RawWriter
+	w         RawWriter
+	checksums *checksums
 }
 
-// NewWriter returns an object that implements Writer and bridges to a RawWriter
+// NewWriter returns an object that implements Writer and bridges to a RawWriter. The checksums of the objects
+// written for a block are recorded in its meta.
 func NewWriter(w RawWriter) Writer {
 	return &writer{
-		w: w,
+		w:         w,
+		checksums: newChecksums(),
 	}
 }
 
 func (w *writer) Write(ctx context.Context, name string, blockID uuid.UUID, tenantID string, buffer []byte, shouldCache bool) error {
-	return w.w.Write(ctx, name, KeyPathForBlock(blockID, tenantID), bytes.NewReader(buffer), int64(len(buffer)), shouldCache)
+	err := w.w.Write(ctx, name, KeyPathForBlock(blockID, tenantID), bytes.NewReader(buffer), int64(len(buffer)), shouldCache)
+	if err != nil {
+		return err
+	}
+
+	_, _ = w.checksums.start(blockID, name).Write(buffer)
+	return nil
 }
 
 func (w *writer) StreamWriter(ctx context.Context, name string, blockID uuid.UUID, tenantID string, data io.Reader, size int64) error {
-	return w.w.Write(ctx, name, KeyPathForBlock(blockID, tenantID), data, size, false)
+	h := NewChecksum()
+	err := w.w.Write(ctx, name, KeyPathForBlock(blockID, tenantID), io.TeeReader(data, h), size, false)
+	if err != nil {
+		return err
+	}
+
+	w.checksums.set(blockID, name, h)
+	return nil
 }
 
+// WriteBlockMeta writes the meta of a block. The checksums of the objects written for the block since the
+// last meta are added to the meta.
 func (w *writer) WriteBlockMeta(ctx context.Context, meta *BlockMeta) error {
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
 
+	if sums := w.checksums.take(blockID); len(sums) > 0 {
+		meta.Checksums = sums
+	}
+
 	bMeta, err := json.Marshal(meta)
 	if err != nil {
 		return err
@@ -79,7 +101,19 @@ func (w *writer) WriteBlockMeta(ctx context.Context, meta *BlockMeta) error {
 }
 
 func (w *writer) Append(ctx context.Context, name string, blockID uuid.UUID, tenantID string, tracker AppendTracker, buffer []byte) (AppendTracker, error) {
-	return w.w.Append(ctx, name, KeyPathForBlock(blockID, tenantID), tracker, buffer)
+	start := tracker == nil
+
+	tracker, err := w.w.Append(ctx, name, KeyPathForBlock(blockID, tenantID), tracker, buffer)
+	if err != nil {
+		return tracker, err
+	}
+
+	if start {
+		_, _ = w.checksums.start(blockID, name).Write(buffer)
+	} else {
+		_, _ = w.checksums.get(blockID, name).Write(buffer)
+	}
+	return tracker, nil
 }
 
 func (w *writer) CloseAppend(ctx context.Context, tracker AppendTracker) error {
@@ -102,6 +136,33 @@ func (w *writer) WriteTenantIndex(ctx context.Context, tenantID string, meta []*
 	return nil
 }
 
+func (w *writer) WriteDeletionRequest(ctx context.Context, req *DeletionRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return w.w.Write(ctx, DeletionRequestName, KeyPathForDeletionRequest(req.ID, req.TenantID), bytes.NewReader(b), int64(len(b)), false)
+}
+
+func (w *writer) WriteCompactionPlan(ctx context.Context, plan *CompactionPlan) error {
+	b, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+
+	return w.w.Write(ctx, CompactionPlanName, KeyPath{plan.TenantID, CompactionJobsDir}, bytes.NewReader(b), int64(len(b)), false)
+}
+
+func (w *writer) WriteCompactionLeases(ctx context.Context, leases *CompactionLeases) error {
+	b, err := json.Marshal(leases)
+	if err != nil {
+		return err
+	}
+
+	return w.w.Write(ctx, CompactionLeasesName, KeyPathForCompactionLeases(leases.Owner, leases.TenantID), bytes.NewReader(b), int64(len(b)), false)
+}
+
 type reader struct {
 	r RawReader
 }
@@ -155,7 +216,7 @@ func (r *reader) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, erro
 	for _, id := range objects {
 		// TODO: this line exists due to behavior differences in backends: https://github.com/grafana/tempo/issues/880
 		// revisit once #880 is resolved.
-		if id == TenantIndexName || id == "" {
+		if id == TenantIndexName || id == DeletionRequestsDir || id == CompactionJobsDir || id == "" {
 			continue
 		}
 		uuid, err := uuid.Parse(id)
@@ -209,6 +270,47 @@ func (r *reader) TenantIndex(ctx context.Context, tenantID string) (*TenantIndex
 	return i, nil
 }
 
+func (r *reader) DeletionRequests(ctx context.Context, tenantID string) ([]*DeletionRequest, error) {
+	ids, err := r.r.List(ctx, KeyPath{tenantID, DeletionRequestsDir})
+	if err == ErrDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*DeletionRequest, 0, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		requestID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse deletion request id %s: %w", id, err)
+		}
+
+		reader, size, err := r.r.Read(ctx, DeletionRequestName, KeyPathForDeletionRequest(requestID, tenantID), false)
+		if err != nil {
+			return nil, err
+		}
+
+		bytes, err := tempo_io.ReadAllWithEstimate(reader, size)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		req := &DeletionRequest{}
+		err = json.Unmarshal(bytes, req)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
 func (r *reader) Shutdown() {
 	r.r.Shutdown()
 }
@@ -237,3 +339,60 @@ func CompactedMetaFileName(blockID uuid.UUID, tenantID string) string {
 func RootPath(blockID uuid.UUID, tenantID string) string {
 	return path.Join(tenantID, blockID.String())
 }
+
+func (r *reader) CompactionPlan(ctx context.Context, tenantID string) (*CompactionPlan, error) {
+	plan := &CompactionPlan{}
+	err := r.readJSON(ctx, CompactionPlanName, KeyPath{tenantID, CompactionJobsDir}, plan)
+	if err == ErrDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (r *reader) CompactionLeases(ctx context.Context, tenantID string) ([]*CompactionLeases, error) {
+	owners, err := r.r.List(ctx, KeyPath{tenantID, CompactionJobsDir})
+	if err == ErrDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]*CompactionLeases, 0, len(owners))
+	for _, owner := range owners {
+		if owner == "" {
+			continue
+		}
+
+		leases := &CompactionLeases{}
+		err := r.readJSON(ctx, CompactionLeasesName, KeyPathForCompactionLeases(owner, tenantID), leases)
+		if err == ErrDoesNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, leases)
+	}
+
+	return all, nil
+}
+
+func (r *reader) readJSON(ctx context.Context, name string, keypath KeyPath, v interface{}) error {
+	reader, size, err := r.r.Read(ctx, name, keypath, false)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	bytes, err := tempo_io.ReadAllWithEstimate(reader, size)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, v)
+}
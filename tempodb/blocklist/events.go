@@ -0,0 +1,166 @@
+package blocklist
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/dskit/kv/memberlist"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// EventsKey is the key under which blocklist events are gossiped
+const EventsKey = "blocklist-events"
+
+// Events are recent changes to the blocklists of all tenants. They are shared between components so that
+// blocks are known before the next poll. Events expire after a while and are not needed anymore once the
+// change has been picked up by polling.
+type Events struct {
+	Added     map[string]*AddedEvent     `json:"added,omitempty"`
+	Compacted map[string]*CompactedEvent `json:"compacted,omitempty"`
+}
+
+// AddedEvent announces a new block in the backend
+type AddedEvent struct {
+	Meta      *backend.BlockMeta `json:"meta"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+}
+
+// CompactedEvent announces a block that was marked compacted in the backend
+type CompactedEvent struct {
+	Meta      *backend.CompactedBlockMeta `json:"meta"`
+	ExpiresAt time.Time                   `json:"expiresAt"`
+}
+
+func NewEvents() *Events {
+	return &Events{
+		Added:     map[string]*AddedEvent{},
+		Compacted: map[string]*CompactedEvent{},
+	}
+}
+
+// EventKey returns the key of the event for the given block
+func EventKey(tenantID string, meta *backend.BlockMeta) string {
+	return tenantID + "/" + meta.BlockID.String()
+}
+
+// AddBlocks records added events for the given metas
+func (e *Events) AddBlocks(metas []*backend.BlockMeta, expiresAt time.Time) {
+	for _, m := range metas {
+		e.Added[EventKey(m.TenantID, m)] = &AddedEvent{Meta: m, ExpiresAt: expiresAt}
+	}
+}
+
+// CompactBlocks records compacted events for the given metas
+func (e *Events) CompactBlocks(metas []*backend.CompactedBlockMeta, expiresAt time.Time) {
+	for _, m := range metas {
+		e.Compacted[EventKey(m.TenantID, &m.BlockMeta)] = &CompactedEvent{Meta: m, ExpiresAt: expiresAt}
+	}
+}
+
+// Merge implements the memberlist.Mergeable interface. Events are immutable, so merging is a union
+// of both sets of events. Expired events are dropped.
+func (e *Events) Merge(mergeable memberlist.Mergeable, localCAS bool) (memberlist.Mergeable, error) {
+	if mergeable == nil {
+		return nil, nil
+	}
+	other, ok := mergeable.(*Events)
+	if !ok {
+		return nil, fmt.Errorf("expected *blocklist.Events, got %T", mergeable)
+	}
+	if other == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	e.removeExpired(now)
+	if e.Added == nil {
+		e.Added = map[string]*AddedEvent{}
+	}
+	if e.Compacted == nil {
+		e.Compacted = map[string]*CompactedEvent{}
+	}
+
+	change := NewEvents()
+	for k, ev := range other.Added {
+		if _, ok := e.Added[k]; ok || ev.ExpiresAt.Before(now) {
+			continue
+		}
+		e.Added[k] = ev
+		change.Added[k] = ev
+	}
+	for k, ev := range other.Compacted {
+		if _, ok := e.Compacted[k]; ok || ev.ExpiresAt.Before(now) {
+			continue
+		}
+		e.Compacted[k] = ev
+		change.Compacted[k] = ev
+	}
+
+	if len(change.Added) == 0 && len(change.Compacted) == 0 {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// MergeContent returns the keys of all events
+func (e *Events) MergeContent() []string {
+	content := make([]string, 0, len(e.Added)+len(e.Compacted))
+	for k := range e.Added {
+		content = append(content, "added/"+k)
+	}
+	for k := range e.Compacted {
+		content = append(content, "compacted/"+k)
+	}
+	sort.Strings(content)
+	return content
+}
+
+// RemoveTombstones is not required for events. They expire instead.
+func (e *Events) RemoveTombstones(limit time.Time) (total, removed int) {
+	return 0, 0
+}
+
+func (e *Events) Clone() memberlist.Mergeable {
+	clone := NewEvents()
+	for k, ev := range e.Added {
+		clone.Added[k] = ev
+	}
+	for k, ev := range e.Compacted {
+		clone.Compacted[k] = ev
+	}
+	return clone
+}
+
+func (e *Events) removeExpired(now time.Time) {
+	for k, ev := range e.Added {
+		if ev.ExpiresAt.Before(now) {
+			delete(e.Added, k)
+		}
+	}
+	for k, ev := range e.Compacted {
+		if ev.ExpiresAt.Before(now) {
+			delete(e.Compacted, k)
+		}
+	}
+}
+
+var EventsCodec = eventsCodec{}
+
+type eventsCodec struct{}
+
+func (eventsCodec) Decode(data []byte) (interface{}, error) {
+	events := NewEvents()
+	if err := jsoniter.ConfigFastest.Unmarshal(data, events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (eventsCodec) Encode(obj interface{}) ([]byte, error) {
+	return jsoniter.ConfigFastest.Marshal(obj)
+}
+
+func (eventsCodec) CodecID() string { return "blocklist.eventsCodec" }
@@ -0,0 +1,76 @@
+package blocklist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestEventsMerge(t *testing.T) {
+	now := time.Now()
+	a := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}
+	b := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New()}
+	c := &backend.BlockMeta{TenantID: "other", BlockID: uuid.New()}
+
+	local := NewEvents()
+	local.AddBlocks([]*backend.BlockMeta{a}, now.Add(time.Minute))
+
+	incoming := NewEvents()
+	incoming.AddBlocks([]*backend.BlockMeta{a, b}, now.Add(time.Minute))
+	incoming.CompactBlocks([]*backend.CompactedBlockMeta{{BlockMeta: *c}}, now.Add(time.Minute))
+
+	// only unknown events are returned as change
+	change, err := local.Merge(incoming, false)
+	require.NoError(t, err)
+	require.NotNil(t, change)
+	assert.Equal(t, []string{"added/" + EventKey("test", b), "compacted/" + EventKey("other", c)}, change.MergeContent())
+	assert.Len(t, local.MergeContent(), 3)
+
+	// merging again is a noop
+	change, err = local.Merge(incoming, false)
+	require.NoError(t, err)
+	assert.Nil(t, change)
+
+	// expired events are dropped
+	expired := NewEvents()
+	expired.AddBlocks([]*backend.BlockMeta{{TenantID: "test", BlockID: uuid.New()}}, now.Add(-time.Minute))
+	change, err = local.Merge(expired, false)
+	require.NoError(t, err)
+	assert.Nil(t, change)
+
+	local.Added[EventKey("test", a)].ExpiresAt = now.Add(-time.Minute)
+	_, err = local.Merge(NewEvents(), false)
+	require.NoError(t, err)
+	assert.Len(t, local.MergeContent(), 2)
+
+	_, err = local.Merge(&fakeMergeable{}, false)
+	require.Error(t, err)
+}
+
+func TestEventsCodec(t *testing.T) {
+	events := NewEvents()
+	meta := &backend.BlockMeta{TenantID: "test", BlockID: uuid.New(), TotalObjects: 10}
+	events.AddBlocks([]*backend.BlockMeta{meta}, time.Unix(100, 0))
+	events.CompactBlocks([]*backend.CompactedBlockMeta{{BlockMeta: *meta, CompactedTime: time.Unix(50, 0)}}, time.Unix(200, 0))
+
+	buf, err := EventsCodec.Encode(events)
+	require.NoError(t, err)
+	decoded, err := EventsCodec.Decode(buf)
+	require.NoError(t, err)
+
+	actual := decoded.(*Events)
+	key := EventKey("test", meta)
+	assert.Equal(t, meta.BlockID, actual.Added[key].Meta.BlockID)
+	assert.Equal(t, 10, actual.Added[key].Meta.TotalObjects)
+	assert.True(t, time.Unix(100, 0).Equal(actual.Added[key].ExpiresAt))
+	assert.True(t, time.Unix(50, 0).Equal(actual.Compacted[key].Meta.CompactedTime))
+}
+
+type fakeMergeable struct {
+	Events
+}
@@ -278,6 +278,11 @@ func (p *Poller) pollBlock(ctx context.Context, tenantID string, blockID uuid.UU
 		return nil, nil, err
 	}
 
+	// quarantined blocks are corrupt and left out of the blocklist
+	if blockMeta != nil && blockMeta.Quarantine != nil {
+		return nil, nil, nil
+	}
+
 	return blockMeta, compactedBlockMeta, nil
 }
 
@@ -1,7 +1,9 @@
 package tempodb
 
 import (
+	"bytes"
 	"fmt"
+	"math/bits"
 	"sort"
 	"time"
 
@@ -19,6 +21,26 @@ const (
 	defaultMaxInputBlocks = 4
 )
 
+const (
+	BlockSelectorTimeWindow   = "time_window"
+	BlockSelectorSizeTiered   = "size_tiered"
+	BlockSelectorTraceIDRange = "trace_id_range"
+)
+
+// newBlockSelector creates the selector with the given name. The time window selector is used by default.
+func newBlockSelector(name string, blocklist []*backend.BlockMeta, maxCompactionRange time.Duration, maxCompactionObjects int, maxBlockBytes uint64, minInputBlocks int, maxInputBlocks int) (CompactionBlockSelector, error) {
+	switch name {
+	case "", BlockSelectorTimeWindow:
+		return newTimeWindowBlockSelector(blocklist, maxCompactionRange, maxCompactionObjects, maxBlockBytes, minInputBlocks, maxInputBlocks), nil
+	case BlockSelectorSizeTiered:
+		return newSizeTieredBlockSelector(blocklist, maxCompactionRange, maxCompactionObjects, maxBlockBytes, minInputBlocks, maxInputBlocks), nil
+	case BlockSelectorTraceIDRange:
+		return newTraceIDRangeBlockSelector(blocklist, maxCompactionRange, maxCompactionObjects, maxBlockBytes, minInputBlocks, maxInputBlocks), nil
+	default:
+		return nil, fmt.Errorf("unknown block selector %s", name)
+	}
+}
+
 /*************************** Time Window Block Selector **************************/
 
 // Sharding will be based on time slot - not level. Since each compactor works on two levels.
@@ -82,7 +104,8 @@ func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRan
 
 			// Within group choose smallest blocks first.
 			// update after parquet: we want to make sure blocks of the same version end up together
-			entry.order = fmt.Sprintf("%016X-%v", entry.meta.TotalObjects, entry.meta.Version)
+			// blocks of the same retention class are kept together as well
+			entry.order = fmt.Sprintf("%016X-%v-%v", entry.meta.TotalObjects, entry.meta.Version, entry.meta.RetentionClass)
 
 			entry.hash = fmt.Sprintf("%v-%v-%v", b.TenantID, b.CompactionLevel, w)
 		} else {
@@ -92,7 +115,8 @@ func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRan
 
 			// Within group chose lowest compaction lvl and smallest blocks first.
 			// update after parquet: we want to make sure blocks of the same version end up together
-			entry.order = fmt.Sprintf("%v-%016X-%v", b.CompactionLevel, entry.meta.TotalObjects, entry.meta.Version)
+			// blocks of the same retention class are kept together as well
+			entry.order = fmt.Sprintf("%v-%016X-%v-%v", b.CompactionLevel, entry.meta.TotalObjects, entry.meta.Version, entry.meta.RetentionClass)
 
 			entry.hash = fmt.Sprintf("%v-%v", b.TenantID, w)
 		}
@@ -100,16 +124,7 @@ func newTimeWindowBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRan
 		twbs.entries = append(twbs.entries, entry)
 	}
 
-	// sort by group then order
-	sort.SliceStable(twbs.entries, func(i, j int) bool {
-		ei := twbs.entries[i]
-		ej := twbs.entries[j]
-
-		if ei.group == ej.group {
-			return ei.order < ej.order
-		}
-		return ei.group < ej.group
-	})
+	sortEntries(twbs.entries)
 
 	return twbs
 }
@@ -127,6 +142,7 @@ func (twbs *timeWindowBlockSelector) BlocksToCompact() ([]*backend.BlockMeta, st
 				if twbs.entries[i].group == twbs.entries[j].group &&
 					twbs.entries[i].meta.DataEncoding == twbs.entries[j].meta.DataEncoding &&
 					twbs.entries[i].meta.Version == twbs.entries[j].meta.Version && // update after parquet: only compact blocks of the same version
+					twbs.entries[i].meta.RetentionClass == twbs.entries[j].meta.RetentionClass && // only compact blocks of the same retention class
 					len(stripe) <= twbs.MaxInputBlocks &&
 					totalObjects(stripe) <= twbs.MaxCompactionObjects &&
 					totalSize(stripe) <= twbs.MaxBlockBytes {
@@ -181,3 +197,132 @@ func (twbs *timeWindowBlockSelector) windowForBlock(meta *backend.BlockMeta) int
 func (twbs *timeWindowBlockSelector) windowForTime(t time.Time) int64 {
 	return t.Unix() / int64(twbs.MaxCompactionRange/time.Second)
 }
+
+/*************************** Size Tiered Block Selector **************************/
+
+// sizeTieredBlockSelector compacts blocks of similar size together. Blocks are bucketed into tiers of
+// powers of four bytes within each time window, and the smallest tiers of the most recent windows are
+// compacted first. Compacting blocks of similar size keeps the amount of data rewritten per block low.
+// Jobs are sharded by time window so that every window is owned by a single compactor.
+type sizeTieredBlockSelector struct {
+	timeWindowBlockSelector
+}
+
+var _ (CompactionBlockSelector) = (*sizeTieredBlockSelector)(nil)
+
+func newSizeTieredBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRange time.Duration, maxCompactionObjects int, maxBlockBytes uint64, minInputBlocks int, maxInputBlocks int) CompactionBlockSelector {
+	stbs := &sizeTieredBlockSelector{
+		timeWindowBlockSelector: timeWindowBlockSelector{
+			MinInputBlocks:       minInputBlocks,
+			MaxInputBlocks:       maxInputBlocks,
+			MaxCompactionRange:   maxCompactionRange,
+			MaxCompactionObjects: maxCompactionObjects,
+			MaxBlockBytes:        maxBlockBytes,
+		},
+	}
+
+	currWindow := stbs.windowForTime(time.Now())
+
+	for _, b := range blocklist {
+		w := stbs.windowForBlock(b)
+		tier := bits.Len64(b.Size) / 2
+
+		stbs.entries = append(stbs.entries, timeWindowBlockEntry{
+			meta:  b,
+			group: fmt.Sprintf("%016X-%02d-%v-%v-%v", currWindow-w, tier, b.Version, b.DataEncoding, b.RetentionClass),
+			order: fmt.Sprintf("%016X-%016X", b.Size, b.TotalObjects),
+			hash:  fmt.Sprintf("%v-%v", b.TenantID, w),
+		})
+	}
+
+	sortEntries(stbs.entries)
+
+	return stbs
+}
+
+/*************************** Trace ID Range Block Selector **************************/
+
+// traceIDRangeBlockSelector compacts blocks with overlapping trace ID ranges together to maximize the
+// number of traces that are deduplicated and combined. Within each time window blocks are sorted by their
+// min ID and split into runs of overlapping [MinID, MaxID] ranges. Blocks are only compacted with blocks
+// of the same run and neighbours in ID order are picked together.
+// Jobs are sharded by time window so that every window is owned by a single compactor.
+type traceIDRangeBlockSelector struct {
+	timeWindowBlockSelector
+}
+
+var _ (CompactionBlockSelector) = (*traceIDRangeBlockSelector)(nil)
+
+func newTraceIDRangeBlockSelector(blocklist []*backend.BlockMeta, maxCompactionRange time.Duration, maxCompactionObjects int, maxBlockBytes uint64, minInputBlocks int, maxInputBlocks int) CompactionBlockSelector {
+	tbs := &traceIDRangeBlockSelector{
+		timeWindowBlockSelector: timeWindowBlockSelector{
+			MinInputBlocks:       minInputBlocks,
+			MaxInputBlocks:       maxInputBlocks,
+			MaxCompactionRange:   maxCompactionRange,
+			MaxCompactionObjects: maxCompactionObjects,
+			MaxBlockBytes:        maxBlockBytes,
+		},
+	}
+
+	currWindow := tbs.windowForTime(time.Now())
+
+	// partition blocks that can be compacted together
+	partitions := map[string][]*backend.BlockMeta{}
+	for _, b := range blocklist {
+		p := fmt.Sprintf("%016X-%v-%v-%v", currWindow-tbs.windowForBlock(b), b.Version, b.DataEncoding, b.RetentionClass)
+		partitions[p] = append(partitions[p], b)
+	}
+
+	for p, metas := range partitions {
+		sort.SliceStable(metas, func(i, j int) bool {
+			if c := bytes.Compare(metas[i].MinID, metas[j].MinID); c != 0 {
+				return c < 0
+			}
+			return bytes.Compare(metas[i].BlockID[:], metas[j].BlockID[:]) < 0
+		})
+
+		run := 0
+		var runMax []byte
+		for i, b := range metas {
+			if i > 0 && bytes.Compare(b.MinID, runMax) > 0 {
+				// no overlap with any block of the current run
+				run++
+				runMax = nil
+			}
+			maxID := b.MaxID
+			if len(maxID) == 0 {
+				// ids are unknown, assume the block overlaps everything
+				maxID = maxTraceID
+			}
+			if runMax == nil || bytes.Compare(maxID, runMax) > 0 {
+				runMax = maxID
+			}
+
+			tbs.entries = append(tbs.entries, timeWindowBlockEntry{
+				meta:  b,
+				group: fmt.Sprintf("%v-%08X", p, run),
+				order: fmt.Sprintf("%08X", i),
+				hash:  fmt.Sprintf("%v-%v", b.TenantID, tbs.windowForBlock(b)),
+			})
+		}
+	}
+
+	sortEntries(tbs.entries)
+
+	return tbs
+}
+
+var maxTraceID = bytes.Repeat([]byte{0xFF}, 16)
+
+// sortEntries sorts entries by group then order
+func sortEntries(entries []timeWindowBlockEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		ei := entries[i]
+		ej := entries[j]
+
+		if ei.group == ej.group {
+			return ei.order < ej.order
+		}
+		return ei.group < ej.group
+	})
+}
@@ -2,6 +2,7 @@ package tempodb
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -728,3 +729,476 @@ func TestTimeWindowBlockSelectorBlocksToCompact(t *testing.T) {
 		})
 	}
 }
+
+func TestSizeTieredBlockSelectorBlocksToCompact(t *testing.T) {
+	now := time.Now()
+	tenantID := ""
+
+	tests := []struct {
+		name           string
+		blocklist      []*backend.BlockMeta
+		maxInputBlocks int // optional, defaults to global const
+		expected       []*backend.BlockMeta
+		expectedHash   string
+		expectedSecond []*backend.BlockMeta
+		expectedHash2  string
+	}{
+		{
+			name:      "nil - nil",
+			blocklist: nil,
+			expected:  nil,
+		},
+		{
+			name: "only one",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "similar sizes are compacted together",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					Size:    1000,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    100_000,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					Size:    900,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+					EndTime: now,
+					Size:    110_000,
+				},
+			},
+			expected: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					Size:    900,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					Size:    1000,
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+			expectedSecond: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    100_000,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+					EndTime: now,
+					Size:    110_000,
+				},
+			},
+			expectedHash2: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+		},
+		{
+			name: "blocks of different tiers are not compacted",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					Size:    1000,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    100_000,
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "different windows are not compacted",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					Size:    1000,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now.Add(-time.Hour),
+					Size:    1000,
+				},
+			},
+			expected: nil,
+		},
+		{
+			name:           "max input blocks",
+			maxInputBlocks: 2,
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					Size:    1003,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    1002,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					Size:    1001,
+				},
+			},
+			expected: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					Size:    1001,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    1002,
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+		},
+		{
+			name: "different retention classes are not compacted",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID:        uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime:        now,
+					Size:           1000,
+					RetentionClass: "long",
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					Size:    1000,
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			max := defaultMaxInputBlocks
+			if tt.maxInputBlocks > 0 {
+				max = tt.maxInputBlocks
+			}
+
+			selector := newSizeTieredBlockSelector(tt.blocklist, time.Second, 100, 1024*1024, defaultMinInputBlocks, max)
+
+			actual, hash := selector.BlocksToCompact()
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.expectedHash, hash)
+
+			actual, hash = selector.BlocksToCompact()
+			assert.Equal(t, tt.expectedSecond, actual)
+			assert.Equal(t, tt.expectedHash2, hash)
+		})
+	}
+}
+
+func TestTraceIDRangeBlockSelectorBlocksToCompact(t *testing.T) {
+	now := time.Now()
+	tenantID := ""
+
+	id := func(b byte) []byte {
+		return []byte{b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	}
+
+	tests := []struct {
+		name           string
+		blocklist      []*backend.BlockMeta
+		maxInputBlocks int // optional, defaults to global const
+		expected       []*backend.BlockMeta
+		expectedHash   string
+		expectedSecond []*backend.BlockMeta
+		expectedHash2  string
+	}{
+		{
+			name:      "nil - nil",
+			blocklist: nil,
+			expected:  nil,
+		},
+		{
+			name: "disjoint ranges are not compacted",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x10),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x20),
+					MaxID:   id(0x30),
+				},
+			},
+			expected: nil,
+		},
+		{
+			name: "overlapping ranges are compacted together",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x80),
+					MaxID:   id(0x90),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x10),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					MinID:   id(0x85),
+					MaxID:   id(0xA0),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+					EndTime: now,
+					MinID:   id(0x05),
+					MaxID:   id(0x20),
+				},
+			},
+			expected: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x10),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+					EndTime: now,
+					MinID:   id(0x05),
+					MaxID:   id(0x20),
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+			expectedSecond: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x80),
+					MaxID:   id(0x90),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					MinID:   id(0x85),
+					MaxID:   id(0xA0),
+				},
+			},
+			expectedHash2: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+		},
+		{
+			name: "overlap is transitive",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x50),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x10),
+					MaxID:   id(0x20),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					MinID:   id(0x40),
+					MaxID:   id(0x60),
+				},
+			},
+			expected: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x50),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x10),
+					MaxID:   id(0x20),
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+					EndTime: now,
+					MinID:   id(0x40),
+					MaxID:   id(0x60),
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+		},
+		{
+			name: "unknown ranges overlap everything",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x80),
+					MaxID:   id(0x90),
+				},
+			},
+			expected: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x80),
+					MaxID:   id(0x90),
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v", tenantID, now.Unix()),
+		},
+		{
+			name: "different versions are not compacted",
+			blocklist: []*backend.BlockMeta{
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime: now,
+					MinID:   id(0x00),
+					MaxID:   id(0x50),
+					Version: "v2",
+				},
+				{
+					BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime: now,
+					MinID:   id(0x10),
+					MaxID:   id(0x20),
+					Version: "vParquet",
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			max := defaultMaxInputBlocks
+			if tt.maxInputBlocks > 0 {
+				max = tt.maxInputBlocks
+			}
+
+			selector := newTraceIDRangeBlockSelector(tt.blocklist, time.Second, 100, 1024*1024, defaultMinInputBlocks, max)
+
+			actual, hash := selector.BlocksToCompact()
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.expectedHash, hash)
+
+			actual, hash = selector.BlocksToCompact()
+			assert.Equal(t, tt.expectedSecond, actual)
+			assert.Equal(t, tt.expectedHash2, hash)
+		})
+	}
+}
+
+// TestBlockSelectorsInvariants checks rules every selector must follow on random blocklists
+func TestBlockSelectorsInvariants(t *testing.T) {
+	now := time.Now()
+	r := rand.New(rand.NewSource(0))
+
+	var blocklist []*backend.BlockMeta
+	for i := 0; i < 500; i++ {
+		minID := make([]byte, 16)
+		maxID := make([]byte, 16)
+		r.Read(minID)
+		copy(maxID, minID)
+		maxID[0] += byte(r.Intn(16))
+
+		blocklist = append(blocklist, &backend.BlockMeta{
+			BlockID:         uuid.New(),
+			TenantID:        "tenant",
+			EndTime:         now.Add(-time.Duration(r.Intn(72)) * time.Hour),
+			CompactionLevel: uint8(r.Intn(3)),
+			Size:            uint64(r.Intn(10 * 1024 * 1024)),
+			TotalObjects:    r.Intn(100),
+			Version:         []string{"v2", "vParquet"}[r.Intn(2)],
+			RetentionClass:  []string{"", "long"}[r.Intn(2)],
+			MinID:           minID,
+			MaxID:           maxID,
+		})
+	}
+
+	const (
+		maxObjects = 300
+		maxBytes   = 20 * 1024 * 1024
+	)
+
+	for _, name := range []string{BlockSelectorTimeWindow, BlockSelectorSizeTiered, BlockSelectorTraceIDRange} {
+		t.Run(name, func(t *testing.T) {
+			selector, err := newBlockSelector(name, blocklist, time.Hour, maxObjects, maxBytes, defaultMinInputBlocks, defaultMaxInputBlocks)
+			assert.NoError(t, err)
+
+			seen := map[uuid.UUID]struct{}{}
+			jobs := 0
+			for {
+				blocks, hash := selector.BlocksToCompact()
+				if len(blocks) == 0 {
+					break
+				}
+				jobs++
+
+				assert.NotEmpty(t, hash)
+				assert.GreaterOrEqual(t, len(blocks), defaultMinInputBlocks)
+				assert.LessOrEqual(t, len(blocks), defaultMaxInputBlocks)
+
+				var objects int
+				var size uint64
+				for _, b := range blocks {
+					assert.Equal(t, blocks[0].Version, b.Version)
+					assert.Equal(t, blocks[0].DataEncoding, b.DataEncoding)
+					assert.Equal(t, blocks[0].RetentionClass, b.RetentionClass)
+					assert.Equal(t, blocks[0].EndTime.Unix()/3600, b.EndTime.Unix()/3600)
+
+					_, ok := seen[b.BlockID]
+					assert.False(t, ok, "block returned twice")
+					seen[b.BlockID] = struct{}{}
+
+					objects += b.TotalObjects
+					size += b.Size
+				}
+				assert.LessOrEqual(t, objects, maxObjects)
+				assert.LessOrEqual(t, size, uint64(maxBytes))
+			}
+			assert.Greater(t, jobs, 0)
+		})
+	}
+
+	_, err := newBlockSelector("unknown", blocklist, time.Hour, maxObjects, maxBytes, defaultMinInputBlocks, defaultMaxInputBlocks)
+	assert.Error(t, err)
+}
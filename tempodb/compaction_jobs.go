@@ -0,0 +1,621 @@
+package tempodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	DefaultCompactionJobLeaseDuration = 5 * time.Minute
+	DefaultCompactionJobClaimSettle   = 5 * time.Second
+
+	// compactionPlannerKey is hashed on the compactor ring to elect the compactor that plans jobs
+	compactionPlannerKey = "compaction-job-planner"
+	// finishedLeaseRetention is how long complete and failed leases are kept. It must be long enough
+	// for the planner to observe that the blocks of a complete job are gone.
+	finishedLeaseRetention = time.Hour
+)
+
+var (
+	metricCompactionJobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_jobs",
+		Help:      "Number of planned compaction jobs by status.",
+	}, []string{"tenant", "status"})
+	metricCompactionJobClaims = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_job_claims_total",
+		Help:      "Total number of attempts to claim a compaction job by result.",
+	}, []string{"result"})
+)
+
+var compactionJobStatuses = []backend.CompactionJobStatus{
+	backend.CompactionJobPending,
+	backend.CompactionJobRunning,
+	backend.CompactionJobComplete,
+	backend.CompactionJobFailed,
+}
+
+// CompactionJobQueueConfig enables coordinating compactors through a queue of compaction jobs in the
+// backend instead of hashing jobs onto the compactor ring. A single compactor plans the jobs and all
+// compactors claim them with leases.
+type CompactionJobQueueConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+	// ClaimSettleTime is how long a compactor waits after claiming a job before checking for competing
+	// claims. It must exceed the time it takes for a write to become visible in the backend.
+	ClaimSettleTime time.Duration `yaml:"claim_settle_time"`
+
+	// InstanceID identifies this compactor as the owner of leases. Set by the compactor module.
+	InstanceID string `yaml:"-"`
+}
+
+// CompactionJobState is a compaction job with its status derived from the leases of all compactors
+type CompactionJobState struct {
+	*backend.CompactionJob
+	Status    backend.CompactionJobStatus `json:"status"`
+	Owner     string                      `json:"owner,omitempty"`
+	ExpiresAt time.Time                   `json:"expiresAt"`
+	Attempts  int                         `json:"attempts"`
+	Error     string                      `json:"error,omitempty"`
+}
+
+// compactionJobStates derives the status of all planned jobs. A job is complete if any compactor
+// completed it and running if any compactor holds a live lease on it. Competing live leases are
+// resolved in favor of the earliest claim. Otherwise the job is failed if its last attempt failed
+// or expired and pending if it was never attempted.
+func compactionJobStates(plan *backend.CompactionPlan, leases []*backend.CompactionLeases, now time.Time) []*CompactionJobState {
+	if plan == nil {
+		return nil
+	}
+
+	type ownedLease struct {
+		owner string
+		*backend.CompactionLease
+	}
+	byJob := map[string][]ownedLease{}
+	for _, l := range leases {
+		for _, lease := range l.Leases {
+			byJob[lease.JobID] = append(byJob[lease.JobID], ownedLease{owner: l.Owner, CompactionLease: lease})
+		}
+	}
+
+	states := make([]*CompactionJobState, 0, len(plan.Jobs))
+	for _, job := range plan.Jobs {
+		s := &CompactionJobState{
+			CompactionJob: job,
+			Status:        backend.CompactionJobPending,
+		}
+		states = append(states, s)
+
+		var complete, live, last *ownedLease
+		for i := range byJob[job.ID] {
+			l := &byJob[job.ID][i]
+			if l.Attempt > s.Attempts {
+				s.Attempts = l.Attempt
+			}
+			switch {
+			case l.Status == backend.CompactionJobComplete:
+				complete = l
+			case l.Live(now):
+				if live == nil || claimedBefore(l.owner, l.CompactionLease, live.owner, live.CompactionLease) {
+					live = l
+				}
+			}
+			if last == nil || l.UpdatedAt.After(last.UpdatedAt) {
+				last = l
+			}
+		}
+
+		switch {
+		case complete != nil:
+			s.Status = backend.CompactionJobComplete
+			s.Owner = complete.owner
+		case live != nil:
+			s.Status = backend.CompactionJobRunning
+			s.Owner = live.owner
+			s.ExpiresAt = live.ExpiresAt
+		case last != nil:
+			s.Status = backend.CompactionJobFailed
+			s.Owner = last.owner
+			s.Error = last.Error
+			if last.Status == backend.CompactionJobRunning {
+				s.Error = "lease expired"
+			}
+		}
+	}
+
+	return states
+}
+
+// claimedBefore returns true if lease a of owner ownerA takes precedence over lease b of owner ownerB
+func claimedBefore(ownerA string, a *backend.CompactionLease, ownerB string, b *backend.CompactionLease) bool {
+	if !a.ClaimedAt.Equal(b.ClaimedAt) {
+		return a.ClaimedAt.Before(b.ClaimedAt)
+	}
+	return ownerA < ownerB
+}
+
+// CompactionJobs returns the planned compaction jobs of a tenant with their status
+func (rw *readerWriter) CompactionJobs(ctx context.Context, tenantID string) ([]*CompactionJobState, error) {
+	plan, leases, err := rw.readCompactionJobs(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return compactionJobStates(plan, leases, time.Now()), nil
+}
+
+func (rw *readerWriter) readCompactionJobs(ctx context.Context, tenantID string) (*backend.CompactionPlan, []*backend.CompactionLeases, error) {
+	plan, err := rw.r.CompactionPlan(ctx, tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read compaction plan: %w", err)
+	}
+	leases, err := rw.r.CompactionLeases(ctx, tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read compaction leases: %w", err)
+	}
+
+	return plan, leases, nil
+}
+
+// doJobQueueCompaction is the compaction cycle when compactors are coordinated by the job queue. The planner
+// refreshes the jobs of all tenants. Then every compactor picks a tenant and works through its jobs.
+func (rw *readerWriter) doJobQueueCompaction() {
+	ctx := context.Background()
+
+	tenants := rw.blocklist.Tenants()
+	if len(tenants) == 0 {
+		return
+	}
+
+	if rw.compactorSharder.Owns(compactionPlannerKey) {
+		for _, tenantID := range tenants {
+			err := rw.planCompactionJobs(ctx, tenantID, time.Now())
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to plan compaction jobs", "tenantID", tenantID, "err", err)
+				metricCompactionErrors.Inc()
+			}
+		}
+	}
+
+	now := time.Now()
+	rw.compactorScheduler.forget(tenants)
+	backlogs := make([]tenantBacklog, 0, len(tenants))
+	for _, tenantID := range tenants {
+		plan, leases, err := rw.readCompactionJobs(ctx, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to read compaction jobs", "tenantID", tenantID, "err", err)
+			continue
+		}
+		backlogs = append(backlogs, measureJobBacklog(tenantID, rw.blocklist.Metas(tenantID), compactionJobStates(plan, leases, now), now))
+	}
+
+	tenantID, ok := rw.compactorScheduler.next(backlogs)
+	if !ok {
+		level.Info(rw.logger).Log("msg", "compaction cycle skipped. No tenant has compaction jobs")
+		return
+	}
+
+	start := time.Now()
+
+	level.Info(rw.logger).Log("msg", "starting compaction cycle", "tenantID", tenantID)
+	for {
+		lease, metas, err := rw.claimCompactionJob(ctx, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to claim compaction job", "tenantID", tenantID, "err", err)
+			metricCompactionErrors.Inc()
+			break
+		}
+		if lease == nil {
+			level.Info(rw.logger).Log("msg", "compaction cycle complete. No more compaction jobs", "tenantID", tenantID)
+			break
+		}
+
+		rw.runCompactionJob(ctx, tenantID, lease, metas)
+
+		// after a maintenance cycle bail out
+		if start.Add(rw.compactorCfg.MaxTimePerTenant).Before(time.Now()) {
+			level.Info(rw.logger).Log("msg", "compacted blocks for a maintenance cycle, bailing out", "tenantID", tenantID)
+			break
+		}
+	}
+}
+
+// planCompactionJobs refreshes the compaction jobs of a tenant. Jobs that are complete or whose blocks
+// are gone are dropped and the blocks that are not part of any job are planned with the block selector
+// of the tenant. Jobs are identified by their blocks, so planning is idempotent.
+func (rw *readerWriter) planCompactionJobs(ctx context.Context, tenantID string, now time.Time) error {
+	plan, leases, err := rw.readCompactionJobs(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	blocklist := rw.blocklist.Metas(tenantID)
+	exists := make(map[uuid.UUID]struct{}, len(blocklist))
+	for _, m := range blocklist {
+		exists[m.BlockID] = struct{}{}
+	}
+
+	var (
+		jobs    []*backend.CompactionJob
+		planned = map[uuid.UUID]struct{}{}
+		counts  = map[backend.CompactionJobStatus]int{}
+	)
+	for _, s := range compactionJobStates(plan, leases, now) {
+		if s.Status == backend.CompactionJobComplete {
+			continue
+		}
+		// blocks of jobs that are not running can be gone due to retention or an earlier plan
+		if s.Status != backend.CompactionJobRunning && !allBlocksExist(s.BlockIDs, exists) {
+			continue
+		}
+
+		jobs = append(jobs, s.CompactionJob)
+		counts[s.Status]++
+		for _, id := range s.BlockIDs {
+			planned[id] = struct{}{}
+		}
+	}
+
+	unplanned := make([]*backend.BlockMeta, 0, len(blocklist))
+	for _, m := range blocklist {
+		if _, ok := planned[m.BlockID]; !ok {
+			unplanned = append(unplanned, m)
+		}
+	}
+
+	blockSelector := rw.newBlockSelector(tenantID, unplanned)
+	for {
+		toBeCompacted, hashString := blockSelector.BlocksToCompact()
+		if len(toBeCompacted) == 0 {
+			break
+		}
+
+		ids := make([]uuid.UUID, 0, len(toBeCompacted))
+		for _, m := range toBeCompacted {
+			ids = append(ids, m.BlockID)
+		}
+		jobs = append(jobs, backend.NewCompactionJob(ids, hashString))
+		counts[backend.CompactionJobPending]++
+	}
+
+	for _, status := range compactionJobStatuses {
+		metricCompactionJobs.WithLabelValues(tenantID, string(status)).Set(float64(counts[status]))
+	}
+
+	if plan != nil && sameJobs(plan.Jobs, jobs) {
+		return nil
+	}
+
+	level.Info(rw.logger).Log("msg", "planned compaction jobs", "tenantID", tenantID, "jobs", len(jobs))
+	return rw.w.WriteCompactionPlan(ctx, &backend.CompactionPlan{
+		TenantID:  tenantID,
+		Planner:   rw.compactorCfg.JobQueue.InstanceID,
+		UpdatedAt: now,
+		Jobs:      jobs,
+	})
+}
+
+// claimCompactionJob claims the first pending or failed job of the tenant whose blocks are all known
+// to this compactor. Claims are written to this compactor's leases and verified after the claim settle
+// time. If another compactor claimed the same job first the claim is withdrawn and the next job is tried.
+// Returns a nil lease if there is no job to claim.
+func (rw *readerWriter) claimCompactionJob(ctx context.Context, tenantID string) (*backend.CompactionLease, []*backend.BlockMeta, error) {
+	plan, leases, err := rw.readCompactionJobs(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metasByID := map[uuid.UUID]*backend.BlockMeta{}
+	for _, m := range rw.blocklist.Metas(tenantID) {
+		metasByID[m.BlockID] = m
+	}
+
+	cfg := rw.compactorCfg.JobQueue
+	for _, s := range compactionJobStates(plan, leases, time.Now()) {
+		if s.Status != backend.CompactionJobPending && s.Status != backend.CompactionJobFailed {
+			continue
+		}
+
+		metas := make([]*backend.BlockMeta, 0, len(s.BlockIDs))
+		for _, id := range s.BlockIDs {
+			if m, ok := metasByID[id]; ok {
+				metas = append(metas, m)
+			}
+		}
+		if len(metas) != len(s.BlockIDs) {
+			// the blocklist of this compactor is behind the planner
+			continue
+		}
+
+		now := time.Now()
+		lease := &backend.CompactionLease{
+			JobID:     s.ID,
+			Status:    backend.CompactionJobRunning,
+			ClaimedAt: now,
+			ExpiresAt: now.Add(cfg.LeaseDuration),
+			UpdatedAt: now,
+			Attempt:   s.Attempts + 1,
+		}
+		err = rw.compactorLeases.put(ctx, tenantID, lease)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// wait for competing claims to become visible
+		time.Sleep(cfg.ClaimSettleTime)
+
+		leases, err = rw.r.CompactionLeases(ctx, tenantID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if wonClaim(cfg.InstanceID, lease, leases, time.Now()) {
+			metricCompactionJobClaims.WithLabelValues("claimed").Inc()
+			level.Info(rw.logger).Log("msg", "claimed compaction job", "tenantID", tenantID, "jobID", lease.JobID, "attempt", lease.Attempt)
+			return lease, metas, nil
+		}
+
+		metricCompactionJobClaims.WithLabelValues("conflict").Inc()
+		level.Info(rw.logger).Log("msg", "compaction job claimed by another compactor", "tenantID", tenantID, "jobID", lease.JobID)
+		err = rw.compactorLeases.remove(ctx, tenantID, lease.JobID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// wonClaim returns true if no other compactor completed the job or holds a live lease claimed earlier
+func wonClaim(owner string, lease *backend.CompactionLease, leases []*backend.CompactionLeases, now time.Time) bool {
+	for _, l := range leases {
+		if l.Owner == owner {
+			continue
+		}
+		for _, other := range l.Leases {
+			if other.JobID != lease.JobID {
+				continue
+			}
+			if other.Status == backend.CompactionJobComplete {
+				return false
+			}
+			if other.Live(now) && claimedBefore(l.Owner, other, owner, lease) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// runCompactionJob compacts the blocks of a claimed job and renews its lease until compaction is done
+func (rw *readerWriter) runCompactionJob(ctx context.Context, tenantID string, lease *backend.CompactionLease, metas []*backend.BlockMeta) {
+	cfg := rw.compactorCfg.JobQueue
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := rw.compactorLeases.update(ctx, tenantID, func(_ *backend.CompactionLeases) {
+					now := time.Now()
+					lease.ExpiresAt = now.Add(cfg.LeaseDuration)
+					lease.UpdatedAt = now
+				})
+				if err != nil {
+					level.Warn(rw.logger).Log("msg", "failed to renew compaction job lease", "tenantID", tenantID, "jobID", lease.JobID, "err", err)
+				}
+			}
+		}
+	}()
+
+	level.Info(rw.logger).Log("msg", "Compacting job", "jobID", lease.JobID)
+	compactErr := rw.compact(metas, tenantID)
+
+	close(done)
+	wg.Wait()
+
+	if compactErr != nil {
+		level.Error(rw.logger).Log("msg", "error during compaction job", "tenantID", tenantID, "jobID", lease.JobID, "err", compactErr)
+		metricCompactionErrors.Inc()
+	}
+
+	err := rw.compactorLeases.update(ctx, tenantID, func(_ *backend.CompactionLeases) {
+		lease.UpdatedAt = time.Now()
+		lease.Status = backend.CompactionJobComplete
+		if compactErr != nil {
+			lease.Status = backend.CompactionJobFailed
+			lease.Error = compactErr.Error()
+		}
+	})
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to finish compaction job lease", "tenantID", tenantID, "jobID", lease.JobID, "err", err)
+	}
+}
+
+// measureJobBacklog records the backlog of a tenant from the jobs that can be claimed
+func measureJobBacklog(tenantID string, blocklist []*backend.BlockMeta, states []*CompactionJobState, now time.Time) tenantBacklog {
+	b := tenantBacklog{
+		tenantID:        tenantID,
+		blocklistLength: len(blocklist),
+	}
+
+	metasByID := make(map[uuid.UUID]*backend.BlockMeta, len(blocklist))
+	for _, m := range blocklist {
+		metasByID[m.BlockID] = m
+	}
+
+	for _, s := range states {
+		if s.Status != backend.CompactionJobPending && s.Status != backend.CompactionJobFailed {
+			continue
+		}
+
+		b.outstandingBlocks += len(s.BlockIDs)
+		for _, id := range s.BlockIDs {
+			m, ok := metasByID[id]
+			if !ok || m.CompactionLevel > 0 {
+				continue
+			}
+			if age := now.Sub(m.EndTime); age > b.oldestBlockAge {
+				b.oldestBlockAge = age
+			}
+		}
+	}
+
+	metricCompactionOutstandingBlocks.WithLabelValues(tenantID).Set(float64(b.outstandingBlocks))
+	metricCompactionOldestOutstandingBlockAge.WithLabelValues(tenantID).Set(b.oldestBlockAge.Seconds())
+
+	return b
+}
+
+func allBlocksExist(ids []uuid.UUID, exists map[uuid.UUID]struct{}) bool {
+	for _, id := range ids {
+		if _, ok := exists[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sameJobs(a, b []*backend.CompactionJob) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	ids := make(map[string]struct{}, len(a))
+	for _, j := range a {
+		ids[j.ID] = struct{}{}
+	}
+	for _, j := range b {
+		if _, ok := ids[j.ID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compactionLeases holds the leases of this compactor. They are loaded from the backend the first time a
+// tenant is used, which fails the jobs that were running when this compactor restarted.
+type compactionLeases struct {
+	owner string
+	r     backend.Reader
+	w     backend.Writer
+
+	mtx      sync.Mutex
+	byTenant map[string]*backend.CompactionLeases
+}
+
+func newCompactionLeases(owner string, r backend.Reader, w backend.Writer) *compactionLeases {
+	return &compactionLeases{
+		owner:    owner,
+		r:        r,
+		w:        w,
+		byTenant: map[string]*backend.CompactionLeases{},
+	}
+}
+
+// put adds or replaces the lease on a job and writes the leases of the tenant
+func (c *compactionLeases) put(ctx context.Context, tenantID string, lease *backend.CompactionLease) error {
+	return c.update(ctx, tenantID, func(leases *backend.CompactionLeases) {
+		for i, l := range leases.Leases {
+			if l.JobID == lease.JobID {
+				leases.Leases[i] = lease
+				return
+			}
+		}
+		leases.Leases = append(leases.Leases, lease)
+	})
+}
+
+// remove drops the lease on a job and writes the leases of the tenant
+func (c *compactionLeases) remove(ctx context.Context, tenantID string, jobID string) error {
+	return c.update(ctx, tenantID, func(leases *backend.CompactionLeases) {
+		kept := leases.Leases[:0]
+		for _, l := range leases.Leases {
+			if l.JobID != jobID {
+				kept = append(kept, l)
+			}
+		}
+		leases.Leases = kept
+	})
+}
+
+func (c *compactionLeases) update(ctx context.Context, tenantID string, fn func(leases *backend.CompactionLeases)) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	leases, err := c.load(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fn(leases)
+	leases.UpdatedAt = now
+
+	kept := leases.Leases[:0]
+	for _, l := range leases.Leases {
+		if l.Status != backend.CompactionJobRunning && now.Sub(l.UpdatedAt) > finishedLeaseRetention {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	leases.Leases = kept
+	sort.Slice(leases.Leases, func(i, j int) bool { return leases.Leases[i].ClaimedAt.Before(leases.Leases[j].ClaimedAt) })
+
+	return c.w.WriteCompactionLeases(ctx, leases)
+}
+
+// load returns the leases of a tenant. Must be called with the lock held.
+func (c *compactionLeases) load(ctx context.Context, tenantID string) (*backend.CompactionLeases, error) {
+	if leases, ok := c.byTenant[tenantID]; ok {
+		return leases, nil
+	}
+
+	all, err := c.r.CompactionLeases(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	leases := &backend.CompactionLeases{
+		TenantID: tenantID,
+		Owner:    c.owner,
+	}
+	for _, l := range all {
+		if l.Owner == c.owner {
+			leases = l
+			break
+		}
+	}
+
+	// anything running belongs to a previous run of this compactor
+	now := time.Now()
+	for _, l := range leases.Leases {
+		if l.Status == backend.CompactionJobRunning {
+			l.Status = backend.CompactionJobFailed
+			l.Error = "compactor restarted"
+			l.UpdatedAt = now
+		}
+	}
+
+	c.byTenant[tenantID] = leases
+	return leases, nil
+}
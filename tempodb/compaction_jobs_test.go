@@ -0,0 +1,244 @@
+package tempodb
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/pool"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestCompactionJobStates(t *testing.T) {
+	now := time.Now()
+
+	job := func(id string) *backend.CompactionJob {
+		return &backend.CompactionJob{ID: id}
+	}
+	lease := func(jobID string, status backend.CompactionJobStatus, claimed time.Duration, expires time.Duration) *backend.CompactionLease {
+		return &backend.CompactionLease{
+			JobID:     jobID,
+			Status:    status,
+			ClaimedAt: now.Add(claimed),
+			ExpiresAt: now.Add(expires),
+			UpdatedAt: now.Add(claimed),
+			Attempt:   1,
+			Error:     "err-" + jobID,
+		}
+	}
+
+	plan := &backend.CompactionPlan{
+		Jobs: []*backend.CompactionJob{job("pending"), job("running"), job("contended"), job("complete"), job("failed"), job("expired")},
+	}
+	leases := []*backend.CompactionLeases{
+		{
+			Owner: "a",
+			Leases: []*backend.CompactionLease{
+				lease("running", backend.CompactionJobRunning, -time.Minute, time.Minute),
+				lease("contended", backend.CompactionJobRunning, -time.Second, time.Minute),
+				lease("complete", backend.CompactionJobFailed, -2*time.Minute, 0),
+				lease("expired", backend.CompactionJobRunning, -time.Hour, -time.Minute),
+			},
+		},
+		{
+			Owner: "b",
+			Leases: []*backend.CompactionLease{
+				lease("contended", backend.CompactionJobRunning, -time.Minute, time.Minute),
+				lease("complete", backend.CompactionJobComplete, -time.Minute, 0),
+				lease("failed", backend.CompactionJobFailed, -time.Minute, 0),
+			},
+		},
+	}
+
+	expected := map[string]struct {
+		status backend.CompactionJobStatus
+		owner  string
+		err    string
+	}{
+		"pending":   {status: backend.CompactionJobPending},
+		"running":   {status: backend.CompactionJobRunning, owner: "a"},
+		"contended": {status: backend.CompactionJobRunning, owner: "b"},
+		"complete":  {status: backend.CompactionJobComplete, owner: "b"},
+		"failed":    {status: backend.CompactionJobFailed, owner: "b", err: "err-failed"},
+		"expired":   {status: backend.CompactionJobFailed, owner: "a", err: "lease expired"},
+	}
+
+	states := compactionJobStates(plan, leases, now)
+	require.Len(t, states, len(expected))
+	for _, s := range states {
+		e := expected[s.ID]
+		assert.Equal(t, e.status, s.Status, s.ID)
+		assert.Equal(t, e.owner, s.Owner, s.ID)
+		assert.Equal(t, e.err, s.Error, s.ID)
+	}
+
+	assert.Nil(t, compactionJobStates(nil, leases, now))
+}
+
+func TestWonClaim(t *testing.T) {
+	now := time.Now()
+	mine := &backend.CompactionLease{JobID: "job", Status: backend.CompactionJobRunning, ClaimedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	other := func(owner string, status backend.CompactionJobStatus, claimed time.Time, expires time.Time) *backend.CompactionLeases {
+		return &backend.CompactionLeases{
+			Owner:  owner,
+			Leases: []*backend.CompactionLease{{JobID: "job", Status: status, ClaimedAt: claimed, ExpiresAt: expires}},
+		}
+	}
+
+	tcs := []struct {
+		name   string
+		leases []*backend.CompactionLeases
+		won    bool
+	}{
+		{name: "uncontended", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobFailed, now.Add(-time.Hour), now)}, won: true},
+		{name: "claimed earlier", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobRunning, now.Add(-time.Second), now.Add(time.Minute))}, won: false},
+		{name: "claimed later", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobRunning, now.Add(time.Second), now.Add(time.Minute))}, won: true},
+		{name: "expired", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobRunning, now.Add(-time.Hour), now.Add(-time.Minute))}, won: true},
+		{name: "same time lower owner", leases: []*backend.CompactionLeases{other("0", backend.CompactionJobRunning, now, now.Add(time.Minute))}, won: false},
+		{name: "same time higher owner", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobRunning, now, now.Add(time.Minute))}, won: true},
+		{name: "complete", leases: []*backend.CompactionLeases{other("b", backend.CompactionJobComplete, now.Add(-time.Hour), now)}, won: false},
+		{name: "own leases are ignored", leases: []*backend.CompactionLeases{other("a", backend.CompactionJobComplete, now.Add(-time.Hour), now)}, won: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.won, wonClaim("a", mine, tc.leases, now))
+		})
+	}
+}
+
+func newJobQueueCompactor(t *testing.T, dir string, instanceID string) (*readerWriter, Writer) {
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(dir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              encoding.DefaultEncoding().Version(),
+			Encoding:             backend.EncLZ4_64k,
+			IndexPageSizeBytes:   1000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(dir, "wal-"+instanceID),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      24 * time.Hour,
+		MaxCompactionObjects:    1000,
+		MaxBlockBytes:           1024 * 1024 * 1024,
+		MaxTimePerTenant:        time.Minute,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+		JobQueue: CompactionJobQueueConfig{
+			Enabled:         true,
+			LeaseDuration:   time.Minute,
+			ClaimSettleTime: time.Millisecond,
+			InstanceID:      instanceID,
+		},
+	}, &mockSharder{}, &mockOverrides{})
+
+	r.EnablePolling(&mockJobSharder{})
+
+	return r.(*readerWriter), w
+}
+
+func TestJobQueueCompaction(t *testing.T) {
+	ctx := context.Background()
+	rw, w := newJobQueueCompactor(t, t.TempDir(), "compactor-1")
+
+	cutTestBlocks(t, w, testTenantID, 2, 2)
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+
+	// plans, claims and compacts the job
+	rw.doJobQueueCompaction()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 1)
+
+	jobs, err := rw.CompactionJobs(ctx, testTenantID)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, backend.CompactionJobComplete, jobs[0].Status)
+	assert.Equal(t, "compactor-1", jobs[0].Owner)
+	assert.Equal(t, 1, jobs[0].Attempts)
+	assert.Len(t, jobs[0].BlockIDs, 2)
+
+	// complete jobs are dropped from the plan
+	rw.pollBlocklist()
+	require.NoError(t, rw.planCompactionJobs(ctx, testTenantID, time.Now()))
+	jobs, err = rw.CompactionJobs(ctx, testTenantID)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 0)
+}
+
+func TestJobQueueLeases(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	rw1, w := newJobQueueCompactor(t, dir, "compactor-1")
+	rw2, _ := newJobQueueCompactor(t, dir, "compactor-2")
+
+	blocks := cutTestBlocks(t, w, testTenantID, 2, 2)
+	rw1.pollBlocklist()
+	rw2.pollBlocklist()
+
+	require.NoError(t, rw1.planCompactionJobs(ctx, testTenantID, time.Now()))
+
+	// planning is idempotent
+	require.NoError(t, rw2.planCompactionJobs(ctx, testTenantID, time.Now()))
+	jobs, err := rw1.CompactionJobs(ctx, testTenantID)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, backend.CompactionJobPending, jobs[0].Status)
+	assert.ElementsMatch(t, []uuid.UUID{blocks[0].BlockMeta().BlockID, blocks[1].BlockMeta().BlockID}, jobs[0].BlockIDs)
+
+	// the first compactor claims the job
+	lease, metas, err := rw1.claimCompactionJob(ctx, testTenantID)
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	require.Len(t, metas, 2)
+
+	// and the second one can't
+	lease2, _, err := rw2.claimCompactionJob(ctx, testTenantID)
+	require.NoError(t, err)
+	require.Nil(t, lease2)
+
+	jobs, err = rw2.CompactionJobs(ctx, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, backend.CompactionJobRunning, jobs[0].Status)
+	assert.Equal(t, "compactor-1", jobs[0].Owner)
+
+	// a restart of the first compactor fails its running jobs so they can be claimed again
+	rw1.compactorLeases = newCompactionLeases("compactor-1", rw1.r, rw1.w)
+	require.NoError(t, rw1.compactorLeases.remove(ctx, testTenantID, "unknown"))
+
+	jobs, err = rw2.CompactionJobs(ctx, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, backend.CompactionJobFailed, jobs[0].Status)
+	assert.Equal(t, "compactor restarted", jobs[0].Error)
+
+	lease2, _, err = rw2.claimCompactionJob(ctx, testTenantID)
+	require.NoError(t, err)
+	require.NotNil(t, lease2)
+	assert.Equal(t, 2, lease2.Attempt)
+}
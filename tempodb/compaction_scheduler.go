@@ -0,0 +1,221 @@
+package tempodb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	TenantSchedulingWeighted   = "weighted"
+	TenantSchedulingRoundRobin = "round_robin"
+
+	DefaultOutstandingBlocksWeight = 1.0
+	DefaultBlocklistLengthWeight   = 0.25
+	DefaultOldestBlockAgeWeight    = 0.5
+	DefaultWaitWeight              = 0.25
+)
+
+var (
+	metricCompactionOldestOutstandingBlockAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_oldest_outstanding_block_age_seconds",
+		Help:      "Age of the oldest uncompacted block waiting for compaction.",
+	}, []string{"tenant"})
+	metricCompactionTenantScore = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_tenant_score",
+		Help:      "Score used to pick the next tenant to compact. The tenant with the highest score is compacted next.",
+	}, []string{"tenant"})
+)
+
+// TenantSchedulingConfig controls which tenant is compacted in a compaction cycle. The weighted strategy
+// compacts the tenant with the most outstanding work. Every weight applies to its factor normalized
+// to the maximum across all tenants.
+type TenantSchedulingConfig struct {
+	Strategy                string  `yaml:"strategy"`
+	OutstandingBlocksWeight float64 `yaml:"outstanding_blocks_weight"`
+	BlocklistLengthWeight   float64 `yaml:"blocklist_length_weight"`
+	OldestBlockAgeWeight    float64 `yaml:"oldest_block_age_weight"`
+	// WaitWeight applies to the number of cycles since the tenant was last compacted. It keeps tenants with
+	// a small backlog from starving.
+	WaitWeight float64 `yaml:"wait_weight"`
+}
+
+// tenantBacklog is the outstanding compaction work of a tenant owned by this compactor
+type tenantBacklog struct {
+	tenantID          string
+	outstandingBlocks int
+	blocklistLength   int
+	oldestBlockAge    time.Duration // age of the oldest uncompacted block waiting for compaction
+}
+
+type compactionScheduler struct {
+	cfg TenantSchedulingConfig
+
+	offset        int
+	cycle         int
+	lastScheduled map[string]int
+
+	// owned are the tenants in which this compactor owned compaction jobs since all tenants were last measured
+	owned           map[string]struct{}
+	lastMeasuredAll time.Time
+}
+
+func newCompactionScheduler(cfg TenantSchedulingConfig) *compactionScheduler {
+	if cfg.OutstandingBlocksWeight == 0 && cfg.BlocklistLengthWeight == 0 && cfg.OldestBlockAgeWeight == 0 && cfg.WaitWeight == 0 {
+		cfg.OutstandingBlocksWeight = DefaultOutstandingBlocksWeight
+		cfg.BlocklistLengthWeight = DefaultBlocklistLengthWeight
+		cfg.OldestBlockAgeWeight = DefaultOldestBlockAgeWeight
+		cfg.WaitWeight = DefaultWaitWeight
+	}
+
+	return &compactionScheduler{
+		cfg:           cfg,
+		lastScheduled: map[string]int{},
+	}
+}
+
+// next returns the tenant to compact in this cycle. Tenants are sorted and ties are broken in round robin
+// order so that tenants with the same backlog take turns. Returns false if no tenant has outstanding work.
+func (s *compactionScheduler) next(backlogs []tenantBacklog) (string, bool) {
+	if len(backlogs) == 0 {
+		return "", false
+	}
+
+	sort.Slice(backlogs, func(i, j int) bool { return backlogs[i].tenantID < backlogs[j].tenantID })
+	s.cycle++
+	s.offset = (s.offset + 1) % len(backlogs)
+
+	if s.cfg.Strategy == TenantSchedulingRoundRobin {
+		tenantID := backlogs[s.offset].tenantID
+		s.lastScheduled[tenantID] = s.cycle
+		return tenantID, true
+	}
+
+	scores := s.scores(backlogs)
+
+	best := -1
+	for i := 0; i < len(backlogs); i++ {
+		idx := (s.offset + i) % len(backlogs)
+		if backlogs[idx].outstandingBlocks == 0 {
+			continue
+		}
+		if best == -1 || scores[idx] > scores[best] {
+			best = idx
+		}
+	}
+	if best == -1 {
+		return "", false
+	}
+
+	tenantID := backlogs[best].tenantID
+	s.lastScheduled[tenantID] = s.cycle
+	return tenantID, true
+}
+
+// scores calculates the weighted score of all tenants and records it
+func (s *compactionScheduler) scores(backlogs []tenantBacklog) []float64 {
+	var (
+		maxOutstanding int
+		maxLength      int
+		maxAge         time.Duration
+	)
+	for _, b := range backlogs {
+		if b.outstandingBlocks > maxOutstanding {
+			maxOutstanding = b.outstandingBlocks
+		}
+		if b.blocklistLength > maxLength {
+			maxLength = b.blocklistLength
+		}
+		if b.oldestBlockAge > maxAge {
+			maxAge = b.oldestBlockAge
+		}
+	}
+
+	scores := make([]float64, len(backlogs))
+	for i, b := range backlogs {
+		// tenants that were never compacted have waited since startup
+		waited := s.cycle - s.lastScheduled[b.tenantID]
+
+		scores[i] = s.cfg.OutstandingBlocksWeight*ratio(float64(b.outstandingBlocks), float64(maxOutstanding)) +
+			s.cfg.BlocklistLengthWeight*ratio(float64(b.blocklistLength), float64(maxLength)) +
+			s.cfg.OldestBlockAgeWeight*ratio(float64(b.oldestBlockAge), float64(maxAge)) +
+			s.cfg.WaitWeight*float64(waited)/float64(len(backlogs))
+
+		metricCompactionTenantScore.WithLabelValues(b.tenantID).Set(scores[i])
+	}
+
+	return scores
+}
+
+// forget drops the state and metrics of tenants that are gone
+func (s *compactionScheduler) forget(tenants []string) {
+	current := make(map[string]struct{}, len(tenants))
+	for _, t := range tenants {
+		current[t] = struct{}{}
+	}
+
+	for tenantID := range s.lastScheduled {
+		if _, ok := current[tenantID]; ok {
+			continue
+		}
+
+		delete(s.lastScheduled, tenantID)
+		metricCompactionTenantScore.DeleteLabelValues(tenantID)
+		metricCompactionOutstandingBlocks.DeleteLabelValues(tenantID)
+		metricCompactionOldestOutstandingBlockAge.DeleteLabelValues(tenantID)
+		for _, status := range compactionJobStatuses {
+			metricCompactionJobs.DeleteLabelValues(tenantID, string(status))
+		}
+	}
+	for _, tenantID := range tenants {
+		if _, ok := s.lastScheduled[tenantID]; !ok {
+			s.lastScheduled[tenantID] = 0
+		}
+	}
+}
+
+// measureBacklog walks all compaction jobs of the tenant owned by this compactor and records its backlog
+func measureBacklog(tenantID string, blocklist []*backend.BlockMeta, blockSelector CompactionBlockSelector, owned func(hash string) bool, now time.Time) tenantBacklog {
+	b := tenantBacklog{
+		tenantID:        tenantID,
+		blocklistLength: len(blocklist),
+	}
+
+	for {
+		toBeCompacted, hashString := blockSelector.BlocksToCompact()
+		if len(toBeCompacted) == 0 {
+			break
+		}
+		if !owned(hashString) {
+			continue
+		}
+
+		b.outstandingBlocks += len(toBeCompacted)
+		for _, m := range toBeCompacted {
+			if m.CompactionLevel > 0 {
+				continue
+			}
+			if age := now.Sub(m.EndTime); age > b.oldestBlockAge {
+				b.oldestBlockAge = age
+			}
+		}
+	}
+
+	metricCompactionOutstandingBlocks.WithLabelValues(tenantID).Set(float64(b.outstandingBlocks))
+	metricCompactionOldestOutstandingBlockAge.WithLabelValues(tenantID).Set(b.oldestBlockAge.Seconds())
+
+	return b
+}
+
+func ratio(v, max float64) float64 {
+	if max == 0 {
+		return 0
+	}
+	return v / max
+}
@@ -0,0 +1,212 @@
+package tempodb
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/blocklist"
+)
+
+func TestCompactionSchedulerPicksNeediestTenant(t *testing.T) {
+	s := newCompactionScheduler(TenantSchedulingConfig{})
+	s.forget([]string{"a", "b", "c"})
+
+	tenantID, ok := s.next([]tenantBacklog{
+		{tenantID: "a", outstandingBlocks: 10, blocklistLength: 20},
+		{tenantID: "b", outstandingBlocks: 10000, blocklistLength: 10500, oldestBlockAge: time.Hour},
+		{tenantID: "c", outstandingBlocks: 10, blocklistLength: 20},
+	})
+	require.True(t, ok)
+	assert.Equal(t, "b", tenantID)
+
+	// the age of the oldest block matters too
+	tenantID, ok = s.next([]tenantBacklog{
+		{tenantID: "a", outstandingBlocks: 10, blocklistLength: 20, oldestBlockAge: 48 * time.Hour},
+		{tenantID: "b", outstandingBlocks: 10, blocklistLength: 20, oldestBlockAge: time.Hour},
+		{tenantID: "c", outstandingBlocks: 10, blocklistLength: 20},
+	})
+	require.True(t, ok)
+	assert.Equal(t, "a", tenantID)
+}
+
+func TestCompactionSchedulerSkipsTenantsWithoutWork(t *testing.T) {
+	s := newCompactionScheduler(TenantSchedulingConfig{})
+	s.forget([]string{"a", "b"})
+
+	_, ok := s.next([]tenantBacklog{
+		{tenantID: "a", blocklistLength: 20, oldestBlockAge: time.Hour},
+		{tenantID: "b", blocklistLength: 1},
+	})
+	assert.False(t, ok)
+
+	tenantID, ok := s.next([]tenantBacklog{
+		{tenantID: "a", blocklistLength: 20, oldestBlockAge: time.Hour},
+		{tenantID: "b", outstandingBlocks: 2, blocklistLength: 2},
+	})
+	require.True(t, ok)
+	assert.Equal(t, "b", tenantID)
+}
+
+func TestCompactionSchedulerDoesNotStarveTenants(t *testing.T) {
+	s := newCompactionScheduler(TenantSchedulingConfig{})
+	s.forget([]string{"big", "small"})
+
+	backlogs := []tenantBacklog{
+		{tenantID: "big", outstandingBlocks: 10000, blocklistLength: 10000, oldestBlockAge: time.Hour},
+		{tenantID: "small", outstandingBlocks: 2, blocklistLength: 2},
+	}
+
+	picked := map[string]int{}
+	for i := 0; i < 100; i++ {
+		tenantID, ok := s.next(backlogs)
+		require.True(t, ok)
+		picked[tenantID]++
+	}
+
+	assert.Greater(t, picked["big"], picked["small"])
+	assert.Greater(t, picked["small"], 0)
+}
+
+func TestCompactionSchedulerRoundRobin(t *testing.T) {
+	s := newCompactionScheduler(TenantSchedulingConfig{Strategy: TenantSchedulingRoundRobin})
+	s.forget([]string{"a", "b", "c"})
+
+	backlogs := []tenantBacklog{
+		{tenantID: "c"},
+		{tenantID: "a", outstandingBlocks: 1000},
+		{tenantID: "b"},
+	}
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		tenantID, ok := s.next(backlogs)
+		require.True(t, ok)
+		picked = append(picked, tenantID)
+	}
+	assert.Equal(t, []string{"b", "c", "a", "b"}, picked)
+}
+
+func TestCompactionSchedulerTiesTakeTurns(t *testing.T) {
+	s := newCompactionScheduler(TenantSchedulingConfig{OutstandingBlocksWeight: 1})
+	s.forget([]string{"a", "b"})
+
+	backlogs := []tenantBacklog{
+		{tenantID: "a", outstandingBlocks: 4},
+		{tenantID: "b", outstandingBlocks: 4},
+	}
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		tenantID, ok := s.next(backlogs)
+		require.True(t, ok)
+		picked = append(picked, tenantID)
+	}
+	assert.Equal(t, []string{"b", "a", "b", "a"}, picked)
+}
+
+func TestMeasureBacklog(t *testing.T) {
+	// align to the compaction window so the level 0 blocks always share a window
+	now := time.Now().Truncate(time.Hour)
+	newMeta := func(compactionLevel uint8, end time.Time) *backend.BlockMeta {
+		return &backend.BlockMeta{
+			BlockID:         uuid.New(),
+			TenantID:        "tenant",
+			CompactionLevel: compactionLevel,
+			StartTime:       end.Add(-time.Minute),
+			EndTime:         end,
+			TotalObjects:    1,
+		}
+	}
+
+	blocklist := []*backend.BlockMeta{
+		newMeta(0, now.Add(-10*time.Minute)),
+		newMeta(0, now.Add(-20*time.Minute)),
+		newMeta(0, now.Add(-30*time.Minute)),
+		newMeta(1, now.Add(-2*time.Hour)),
+		newMeta(1, now.Add(-2*time.Hour)),
+	}
+	selector := newTimeWindowBlockSelector(blocklist, time.Hour, 100, 1024*1024, defaultMinInputBlocks, defaultMaxInputBlocks)
+
+	b := measureBacklog("tenant", blocklist, selector, func(string) bool { return true }, now)
+	assert.Equal(t, tenantBacklog{
+		tenantID:          "tenant",
+		outstandingBlocks: 5,
+		blocklistLength:   5,
+		oldestBlockAge:    30 * time.Minute,
+	}, b)
+
+	// blocks of jobs owned by other compactors are not counted
+	selector = newTimeWindowBlockSelector(blocklist, time.Hour, 100, 1024*1024, defaultMinInputBlocks, defaultMaxInputBlocks)
+	b = measureBacklog("tenant", blocklist, selector, func(string) bool { return false }, now)
+	assert.Equal(t, 0, b.outstandingBlocks)
+	assert.Equal(t, time.Duration(0), b.oldestBlockAge)
+}
+
+func TestMeasureOwnedBacklogs(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	newMetas := func(tenantID string) []*backend.BlockMeta {
+		var metas []*backend.BlockMeta
+		for i := 0; i < 3; i++ {
+			metas = append(metas, &backend.BlockMeta{
+				BlockID:      uuid.New(),
+				TenantID:     tenantID,
+				StartTime:    now.Add(-time.Duration(i+2) * time.Minute),
+				EndTime:      now.Add(-time.Duration(i+1) * time.Minute),
+				TotalObjects: 1,
+			})
+		}
+		return metas
+	}
+
+	list := blocklist.New()
+	list.ApplyPollResults(blocklist.PerTenant{"a": newMetas("a"), "b": newMetas("b")}, blocklist.PerTenantCompacted{})
+
+	// this compactor only owns the jobs of tenant a
+	owned := "a"
+	rw := &readerWriter{
+		cfg:                &Config{BlocklistPoll: time.Minute},
+		compactorCfg:       &CompactorConfig{MaxCompactionRange: time.Hour, MaxCompactionObjects: 100, MaxBlockBytes: 1024 * 1024},
+		compactorOverrides: &mockOverrides{},
+		compactorSharder:   &ownsSharder{owns: func(hash string) bool { return hash[:1] == owned }},
+		compactorScheduler: newCompactionScheduler(TenantSchedulingConfig{}),
+		blocklist:          list,
+	}
+
+	tenantIDs := func(backlogs []tenantBacklog) []string {
+		var ids []string
+		for _, b := range backlogs {
+			ids = append(ids, b.tenantID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// all tenants are measured on the first cycle
+	assert.Equal(t, []string{"a", "b"}, tenantIDs(rw.measureOwnedBacklogs(list.Tenants(), now)))
+
+	// only the owned tenants are measured until the next blocklist poll
+	owned = "b"
+	assert.Equal(t, []string{"a"}, tenantIDs(rw.measureOwnedBacklogs(list.Tenants(), now.Add(30*time.Second))))
+
+	backlogs := rw.measureOwnedBacklogs(list.Tenants(), now.Add(time.Minute))
+	sort.Slice(backlogs, func(i, j int) bool { return backlogs[i].tenantID < backlogs[j].tenantID })
+	assert.Equal(t, []string{"a", "b"}, tenantIDs(backlogs))
+	assert.Equal(t, 0, backlogs[0].outstandingBlocks)
+	assert.Equal(t, 3, backlogs[1].outstandingBlocks)
+	assert.Equal(t, []string{"b"}, tenantIDs(rw.measureOwnedBacklogs(list.Tenants(), now.Add(90*time.Second))))
+}
+
+type ownsSharder struct {
+	mockSharder
+	owns func(hash string) bool
+}
+
+func (s *ownsSharder) Owns(hash string) bool {
+	return s.owns(hash)
+}
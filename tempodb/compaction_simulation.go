@@ -0,0 +1,145 @@
+package tempodb
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// DefaultCompactionSimulationRounds is the number of compaction cycles simulated if not configured
+const DefaultCompactionSimulationRounds = 100
+
+// CompactionSimulationConfig are the compaction settings to simulate
+type CompactionSimulationConfig struct {
+	BlockSelector        string
+	MaxCompactionRange   time.Duration
+	MaxCompactionObjects int
+	MaxBlockBytes        uint64
+	// MaxRounds limits the number of compaction cycles. Every cycle runs the block selector on the blocklist
+	// resulting from the previous cycle until it returns no more blocks.
+	MaxRounds int
+}
+
+// CompactionSimulationResult summarizes a simulated compaction of a blocklist
+type CompactionSimulationResult struct {
+	Rounds           int
+	Compactions      int
+	BlocksCompacted  int
+	BytesRewritten   uint64
+	ObjectsRewritten int
+	FinalBlocks      int
+	FinalBytes       uint64
+	// BlocksByLevel is the number of blocks by compaction level after the simulation
+	BlocksByLevel map[uint8]int
+}
+
+// SimulateCompaction runs the configured block selector repeatedly on the blocklist and replaces the selected blocks
+// with their compacted output without reading or writing any data. Outputs are estimated as the sum of their inputs,
+// so the number of objects and bytes of the final blocks are upper bounds when traces are combined.
+// All blocks are assumed to be owned, as if a single compactor compacted the whole blocklist.
+func SimulateCompaction(blocklist []*backend.BlockMeta, cfg CompactionSimulationConfig) (*CompactionSimulationResult, error) {
+	if cfg.MaxRounds <= 0 {
+		cfg.MaxRounds = DefaultCompactionSimulationRounds
+	}
+
+	// the passed metas are not modified, compacted blocks are replaced by new metas
+	current := make(map[uuid.UUID]*backend.BlockMeta, len(blocklist))
+	for _, m := range blocklist {
+		current[m.BlockID] = m
+	}
+
+	res := &CompactionSimulationResult{
+		BlocksByLevel: map[uint8]int{},
+	}
+
+	for res.Rounds < cfg.MaxRounds {
+		metas := make([]*backend.BlockMeta, 0, len(current))
+		for _, m := range current {
+			metas = append(metas, m)
+		}
+		// sort for reproducible results
+		sort.Slice(metas, func(i, j int) bool {
+			return bytes.Compare(metas[i].BlockID[:], metas[j].BlockID[:]) < 0
+		})
+
+		selector, err := newBlockSelector(cfg.BlockSelector, metas, cfg.MaxCompactionRange, cfg.MaxCompactionObjects, cfg.MaxBlockBytes, defaultMinInputBlocks, defaultMaxInputBlocks)
+		if err != nil {
+			return nil, err
+		}
+
+		compactions := 0
+		for {
+			toBeCompacted, _ := selector.BlocksToCompact()
+			if len(toBeCompacted) == 0 {
+				break
+			}
+
+			out := simulatedCompactionOutput(toBeCompacted)
+			for _, m := range toBeCompacted {
+				delete(current, m.BlockID)
+				res.BytesRewritten += m.Size
+				res.ObjectsRewritten += m.TotalObjects
+			}
+			current[out.BlockID] = out
+
+			res.BlocksCompacted += len(toBeCompacted)
+			compactions++
+		}
+
+		if compactions == 0 {
+			break
+		}
+		res.Compactions += compactions
+		res.Rounds++
+	}
+
+	for _, m := range current {
+		res.FinalBlocks++
+		res.FinalBytes += m.Size
+		res.BlocksByLevel[m.CompactionLevel]++
+	}
+
+	return res, nil
+}
+
+// simulatedCompactionOutput returns the meta of the block that compacting the inputs would create
+func simulatedCompactionOutput(inputs []*backend.BlockMeta) *backend.BlockMeta {
+	first := inputs[0]
+	out := backend.NewBlockMeta(first.TenantID, uuid.New(), first.Version, first.Encoding, first.DataEncoding)
+	out.CompactionLevel = compactionLevelForBlocks(inputs) + 1
+	out.RetentionClass = first.RetentionClass
+	out.StartTime = first.StartTime
+	out.EndTime = first.EndTime
+	out.MinID = first.MinID
+	out.MaxID = first.MaxID
+
+	unknownMaxID := false
+	for _, m := range inputs {
+		out.TotalObjects += m.TotalObjects
+		out.Size += m.Size
+
+		if m.StartTime.Before(out.StartTime) {
+			out.StartTime = m.StartTime
+		}
+		if m.EndTime.After(out.EndTime) {
+			out.EndTime = m.EndTime
+		}
+		if bytes.Compare(m.MinID, out.MinID) < 0 {
+			out.MinID = m.MinID
+		}
+		if len(m.MaxID) == 0 {
+			unknownMaxID = true
+		} else if bytes.Compare(m.MaxID, out.MaxID) > 0 {
+			out.MaxID = m.MaxID
+		}
+	}
+	if unknownMaxID {
+		out.MaxID = nil
+	}
+
+	return out
+}
@@ -0,0 +1,109 @@
+package tempodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestSimulateCompaction(t *testing.T) {
+	// blocks outside of the active window
+	ts := time.Now().Add(-48 * time.Hour)
+
+	blocklist := make([]*backend.BlockMeta, 0, 8)
+	for i := 0; i < 8; i++ {
+		blocklist = append(blocklist, &backend.BlockMeta{
+			BlockID:      uuid.New(),
+			TenantID:     testTenantID,
+			Version:      "v2",
+			StartTime:    ts,
+			EndTime:      ts,
+			TotalObjects: 10,
+			Size:         100,
+		})
+	}
+
+	tests := []struct {
+		name     string
+		cfg      CompactionSimulationConfig
+		expected CompactionSimulationResult
+	}{
+		{
+			name: "compacts everything",
+			cfg: CompactionSimulationConfig{
+				MaxCompactionRange:   time.Hour,
+				MaxCompactionObjects: 1000,
+				MaxBlockBytes:        10_000,
+			},
+			// 8 blocks are compacted in groups of 4 and the outputs once more
+			expected: CompactionSimulationResult{
+				Rounds:           2,
+				Compactions:      3,
+				BlocksCompacted:  10,
+				BytesRewritten:   1600,
+				ObjectsRewritten: 160,
+				FinalBlocks:      1,
+				FinalBytes:       800,
+				BlocksByLevel:    map[uint8]int{2: 1},
+			},
+		},
+		{
+			name: "limited by block size",
+			cfg: CompactionSimulationConfig{
+				MaxCompactionRange:   time.Hour,
+				MaxCompactionObjects: 1000,
+				MaxBlockBytes:        250,
+			},
+			expected: CompactionSimulationResult{
+				Rounds:           1,
+				Compactions:      4,
+				BlocksCompacted:  8,
+				BytesRewritten:   800,
+				ObjectsRewritten: 80,
+				FinalBlocks:      4,
+				FinalBytes:       800,
+				BlocksByLevel:    map[uint8]int{1: 4},
+			},
+		},
+		{
+			name: "limited by rounds",
+			cfg: CompactionSimulationConfig{
+				MaxCompactionRange:   time.Hour,
+				MaxCompactionObjects: 1000,
+				MaxBlockBytes:        10_000,
+				MaxRounds:            1,
+			},
+			expected: CompactionSimulationResult{
+				Rounds:           1,
+				Compactions:      2,
+				BlocksCompacted:  8,
+				BytesRewritten:   800,
+				ObjectsRewritten: 80,
+				FinalBlocks:      2,
+				FinalBytes:       800,
+				BlocksByLevel:    map[uint8]int{1: 2},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := SimulateCompaction(blocklist, tc.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, *res)
+		})
+	}
+
+	// the blocklist is not modified
+	for _, m := range blocklist {
+		assert.Equal(t, uint8(0), m.CompactionLevel)
+	}
+
+	_, err := SimulateCompaction(blocklist, CompactionSimulationConfig{BlockSelector: "unknown"})
+	assert.Error(t, err)
+}
@@ -3,7 +3,6 @@ package tempodb
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strconv"
 	"time"
 
@@ -12,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
@@ -71,6 +71,10 @@ func (rw *readerWriter) compactionLoop() {
 
 	ticker := time.NewTicker(compactionCycle)
 	for range ticker.C {
+		if rw.compactorCfg.JobQueue.Enabled {
+			rw.doJobQueueCompaction()
+			continue
+		}
 		rw.doCompaction()
 	}
 }
@@ -81,28 +85,26 @@ func (rw *readerWriter) doCompaction() {
 		return
 	}
 
-	// Iterate through tenants each cycle
-	// Sort tenants for stability (since original map does not guarantee order)
-	sort.Slice(tenants, func(i, j int) bool { return tenants[i] < tenants[j] })
-	rw.compactorTenantOffset = (rw.compactorTenantOffset + 1) % uint(len(tenants))
+	// measure the backlog of all tenants and pick the one most in need of compaction
+	rw.compactorScheduler.forget(tenants)
+	backlogs := rw.measureOwnedBacklogs(tenants, time.Now())
 
-	tenantID := tenants[rw.compactorTenantOffset]
-	blocklist := rw.blocklist.Metas(tenantID)
+	tenantID, ok := rw.compactorScheduler.next(backlogs)
+	if !ok {
+		level.Info(rw.logger).Log("msg", "compaction cycle skipped. No tenant has blocks to compact")
+		return
+	}
 
-	blockSelector := newTimeWindowBlockSelector(blocklist,
-		rw.compactorCfg.MaxCompactionRange,
-		rw.compactorCfg.MaxCompactionObjects,
-		rw.compactorCfg.MaxBlockBytes,
-		defaultMinInputBlocks,
-		defaultMaxInputBlocks)
+	blocklist := rw.blocklist.Metas(tenantID)
+	blockSelector := rw.newBlockSelector(tenantID, blocklist)
 
 	start := time.Now()
 
-	level.Info(rw.logger).Log("msg", "starting compaction cycle", "tenantID", tenantID, "offset", rw.compactorTenantOffset)
+	level.Info(rw.logger).Log("msg", "starting compaction cycle", "tenantID", tenantID)
 	for {
 		toBeCompacted, hashString := blockSelector.BlocksToCompact()
 		if len(toBeCompacted) == 0 {
-			measureOutstandingBlocks(tenantID, blockSelector, rw.compactorSharder.Owns)
+			measureBacklog(tenantID, blocklist, blockSelector, rw.compactorSharder.Owns, time.Now())
 
 			level.Info(rw.logger).Log("msg", "compaction cycle complete. No more blocks to compact", "tenantID", tenantID)
 			break
@@ -123,7 +125,7 @@ func (rw *readerWriter) doCompaction() {
 
 		// after a maintenance cycle bail out
 		if start.Add(rw.compactorCfg.MaxTimePerTenant).Before(time.Now()) {
-			measureOutstandingBlocks(tenantID, blockSelector, rw.compactorSharder.Owns)
+			measureBacklog(tenantID, blocklist, blockSelector, rw.compactorSharder.Owns, time.Now())
 
 			level.Info(rw.logger).Log("msg", "compacted blocks for a maintenance cycle, bailing out", "tenantID", tenantID)
 			break
@@ -131,6 +133,34 @@ func (rw *readerWriter) doCompaction() {
 	}
 }
 
+// measureOwnedBacklogs measures the backlog of the tenants in which this compactor owns compaction jobs. All tenants
+// are measured at most once per blocklist poll to find them instead of on every compaction cycle.
+func (rw *readerWriter) measureOwnedBacklogs(tenants []string, now time.Time) []tenantBacklog {
+	s := rw.compactorScheduler
+
+	measureAll := s.owned == nil || now.Sub(s.lastMeasuredAll) >= rw.cfg.BlocklistPoll
+	if measureAll {
+		s.owned = map[string]struct{}{}
+		s.lastMeasuredAll = now
+	}
+
+	backlogs := make([]tenantBacklog, 0, len(s.owned))
+	for _, tenantID := range tenants {
+		if _, ok := s.owned[tenantID]; !ok && !measureAll {
+			continue
+		}
+
+		blocklist := rw.blocklist.Metas(tenantID)
+		b := measureBacklog(tenantID, blocklist, rw.newBlockSelector(tenantID, blocklist), rw.compactorSharder.Owns, now)
+		if b.outstandingBlocks > 0 {
+			s.owned[tenantID] = struct{}{}
+		}
+		backlogs = append(backlogs, b)
+	}
+
+	return backlogs
+}
+
 func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string) error {
 	level.Debug(rw.logger).Log("msg", "beginning compaction", "num blocks compacting", len(blockMetas))
 
@@ -199,6 +229,30 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 		},
 	}
 
+	// Blocks are classified once by retention rules. The traces of unclassified blocks are written to output
+	// blocks of their class. Otherwise the class of the inputs is carried over.
+	opts.RetentionClass = blockMetas[0].RetentionClass
+	if blockMetas[0].RetentionClass == "" {
+		if rules := rw.compactorOverrides.BlockRetentionRulesForTenant(tenantID); len(rules) > 0 {
+			classifier := newRetentionClassifier(tenantID, rules, rw.logger)
+			opts.ClassifyObject = func(_ common.ID, tr *tempopb.Trace) string {
+				return classifier.classify(tr)
+			}
+		}
+	}
+
+	// Traces are sampled once the output reaches the compaction level of the tenant's sampling policy.
+	// Sampled inputs pass on their sample rate to the output.
+	sampler := newCompactionSampler(tenantID, rw.compactorOverrides.CompactionSamplingPolicyForTenant(tenantID), blockMetas, compactionLevel+1)
+	if sampler != nil {
+		opts.SampleRate = sampler.sampleRate
+		if sampler.sampling {
+			opts.DropObject = func(id common.ID, tr *tempopb.Trace) bool {
+				return !sampler.keep(id, tr)
+			}
+		}
+	}
+
 	compactor := enc.NewCompactor(opts)
 
 	newCompactedBlocks, err := compactor.Compact(ctx, rw.logger, rw.r, rw.getWriterForBlock, blockMetas)
@@ -245,23 +299,43 @@ func markCompacted(rw *readerWriter, tenantID string, oldBlocks []*backend.Block
 
 	// Update blocklist in memory
 	rw.blocklist.Update(tenantID, newBlocks, oldBlocks, newCompactions, nil)
+
+	rw.notifyBlocksAdded(tenantID, newBlocks)
+	rw.notifyBlocksCompacted(tenantID, newCompactions)
 }
 
-func measureOutstandingBlocks(tenantID string, blockSelector CompactionBlockSelector, owned func(hash string) bool) {
-	// count number of per-tenant outstanding blocks before next maintenance cycle
-	var totalOutstandingBlocks int
-	for {
-		leftToBeCompacted, hashString := blockSelector.BlocksToCompact()
-		if len(leftToBeCompacted) == 0 {
-			break
+// newBlockSelector creates the block selector configured for the tenant. It falls back to the time window
+// selector if the configured one is unknown. Blocks in the cold tier are never compacted.
+func (rw *readerWriter) newBlockSelector(tenantID string, blocklist []*backend.BlockMeta) CompactionBlockSelector {
+	name := rw.compactorOverrides.BlockSelectorForTenant(tenantID)
+
+	if rw.cfg.Tiering.Enabled {
+		hot := make([]*backend.BlockMeta, 0, len(blocklist))
+		for _, m := range blocklist {
+			if m.Tier == "" {
+				hot = append(hot, m)
+			}
 		}
-		if !owned(hashString) {
-			// continue on this tenant until we find something we own
-			continue
-		}
-		totalOutstandingBlocks += len(leftToBeCompacted)
+		blocklist = hot
+	}
+
+	selector, err := newBlockSelector(name, blocklist,
+		rw.compactorCfg.MaxCompactionRange,
+		rw.compactorCfg.MaxCompactionObjects,
+		rw.compactorCfg.MaxBlockBytes,
+		defaultMinInputBlocks,
+		defaultMaxInputBlocks)
+	if err != nil {
+		level.Warn(rw.logger).Log("msg", "falling back to time window block selector", "tenantID", tenantID, "err", err)
+		selector, _ = newBlockSelector(BlockSelectorTimeWindow, blocklist,
+			rw.compactorCfg.MaxCompactionRange,
+			rw.compactorCfg.MaxCompactionObjects,
+			rw.compactorCfg.MaxBlockBytes,
+			defaultMinInputBlocks,
+			defaultMaxInputBlocks)
 	}
-	metricCompactionOutstandingBlocks.WithLabelValues(tenantID).Set(float64(totalOutstandingBlocks))
+
+	return selector
 }
 
 func compactionLevelForBlocks(blockMetas []*backend.BlockMeta) uint8 {
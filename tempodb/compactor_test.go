@@ -51,6 +51,9 @@ func (m *mockJobSharder) Owns(_ string) bool { return true }
 type mockOverrides struct {
 	blockRetention   time.Duration
 	maxBytesPerTrace int
+	retentionRules   []RetentionRule
+	samplingPolicy   SamplingPolicy
+	blockSelector    string
 }
 
 func (m *mockOverrides) BlockRetentionForTenant(_ string) time.Duration {
@@ -61,6 +64,18 @@ func (m *mockOverrides) MaxBytesPerTraceForTenant(_ string) int {
 	return m.maxBytesPerTrace
 }
 
+func (m *mockOverrides) BlockRetentionRulesForTenant(_ string) []RetentionRule {
+	return m.retentionRules
+}
+
+func (m *mockOverrides) CompactionSamplingPolicyForTenant(_ string) SamplingPolicy {
+	return m.samplingPolicy
+}
+
+func (m *mockOverrides) BlockSelectorForTenant(_ string) string {
+	return m.blockSelector
+}
+
 func TestCompactionRoundtrip(t *testing.T) {
 	testEncodings := []string{v2.VersionString, vparquet.VersionString}
 	for _, enc := range testEncodings {
@@ -7,8 +7,10 @@ import (
 
 	"github.com/grafana/tempo/pkg/cache"
 	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/cache/disk"
 	"github.com/grafana/tempo/tempodb/backend/cache/memcached"
 	"github.com/grafana/tempo/tempodb/backend/cache/redis"
+	"github.com/grafana/tempo/tempodb/backend/encryption"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
@@ -24,6 +26,7 @@ const (
 	DefaultBlocklistPollConcurrency = uint(50)
 	DefaultRetentionConcurrency     = uint(10)
 	DefaultTenantIndexBuilders      = 2
+	DefaultBlocklistGossipEventTTL  = 5 * time.Minute
 
 	DefaultPrefetchTraceCount   = 1000
 	DefaultSearchChunkSizeBytes = 1_000_000
@@ -46,6 +49,8 @@ type Config struct {
 	BlocklistPollStaleTenantIndex    time.Duration `yaml:"blocklist_poll_stale_tenant_index"`
 	BlocklistPollJitterMs            int           `yaml:"blocklist_poll_jitter_ms"`
 
+	BlocklistGossip BlocklistGossipConfig `yaml:"blocklist_gossip"`
+
 	// backends
 	Backend string        `yaml:"backend"`
 	Local   *local.Config `yaml:"local"`
@@ -53,6 +58,12 @@ type Config struct {
 	S3      *s3.Config    `yaml:"s3"`
 	Azure   *azure.Config `yaml:"azure"`
 
+	// client side encryption
+	Encryption *encryption.Config `yaml:"encryption"`
+
+	// moving old blocks to a cold backend
+	Tiering TieringConfig `yaml:"tiering"`
+
 	// caches
 	Cache                   string                  `yaml:"cache"`
 	CacheMinCompactionLevel uint8                   `yaml:"cache_min_compaction_level"`
@@ -60,6 +71,13 @@ type Config struct {
 	BackgroundCache         *cache.BackgroundConfig `yaml:"background_cache"`
 	Memcached               *memcached.Config       `yaml:"memcached"`
 	Redis                   *redis.Config           `yaml:"redis"`
+	Disk                    *disk.Config            `yaml:"disk"`
+}
+
+// BlocklistGossipConfig configures sharing blocklist changes over memberlist. Polling remains the source of truth.
+type BlocklistGossipConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	EventTTL time.Duration `yaml:"event_ttl"`
 }
 
 type SearchConfig struct {
@@ -74,6 +92,7 @@ type SearchConfig struct {
 		Footer      bool `yaml:"footer"`
 		ColumnIndex bool `yaml:"column_index"`
 		OffsetIndex bool `yaml:"offset_index"`
+		Pages       bool `yaml:"pages"`
 	} `yaml:"cache_control"`
 }
 
@@ -99,21 +118,25 @@ func (c SearchConfig) ApplyToOptions(o *common.SearchOptions) {
 	o.CacheControl.Footer = c.CacheControl.Footer
 	o.CacheControl.ColumnIndex = c.CacheControl.ColumnIndex
 	o.CacheControl.OffsetIndex = c.CacheControl.OffsetIndex
+	o.CacheControl.Pages = c.CacheControl.Pages
 }
 
 // CompactorConfig contains compaction configuration options
 type CompactorConfig struct {
-	ChunkSizeBytes          uint32        `yaml:"chunk_size_bytes"`
-	FlushSizeBytes          uint32        `yaml:"flush_size_bytes"`
-	MaxCompactionRange      time.Duration `yaml:"compaction_window"`
-	MaxCompactionObjects    int           `yaml:"max_compaction_objects"`
-	MaxBlockBytes           uint64        `yaml:"max_block_bytes"`
-	BlockRetention          time.Duration `yaml:"block_retention"`
-	CompactedBlockRetention time.Duration `yaml:"compacted_block_retention"`
-	RetentionConcurrency    uint          `yaml:"retention_concurrency"`
-	IteratorBufferSize      int           `yaml:"iterator_buffer_size"`
-	MaxTimePerTenant        time.Duration `yaml:"max_time_per_tenant"`
-	CompactionCycle         time.Duration `yaml:"compaction_cycle"`
+	ChunkSizeBytes          uint32                   `yaml:"chunk_size_bytes"`
+	FlushSizeBytes          uint32                   `yaml:"flush_size_bytes"`
+	MaxCompactionRange      time.Duration            `yaml:"compaction_window"`
+	MaxCompactionObjects    int                      `yaml:"max_compaction_objects"`
+	MaxBlockBytes           uint64                   `yaml:"max_block_bytes"`
+	BlockRetention          time.Duration            `yaml:"block_retention"`
+	CompactedBlockRetention time.Duration            `yaml:"compacted_block_retention"`
+	RetentionConcurrency    uint                     `yaml:"retention_concurrency"`
+	IteratorBufferSize      int                      `yaml:"iterator_buffer_size"`
+	MaxTimePerTenant        time.Duration            `yaml:"max_time_per_tenant"`
+	CompactionCycle         time.Duration            `yaml:"compaction_cycle"`
+	TenantScheduling        TenantSchedulingConfig   `yaml:"tenant_scheduling"`
+	JobQueue                CompactionJobQueueConfig `yaml:"job_queue"`
+	Scrubber                ScrubberConfig           `yaml:"scrubber"`
 }
 
 func validateConfig(cfg *Config) error {
@@ -135,5 +158,10 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("block version validation failed: %w", err)
 	}
 
+	err = validateTieringConfig(&cfg.Tiering)
+	if err != nil {
+		return fmt.Errorf("tiering config validation failed: %w", err)
+	}
+
 	return nil
 }
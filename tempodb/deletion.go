@@ -0,0 +1,367 @@
+package tempodb
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+var (
+	metricDeletionBlocksRewritten = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "deletion_blocks_rewritten_total",
+		Help:      "Total number of blocks rewritten to satisfy deletion requests.",
+	})
+	metricDeletionTracesDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "deletion_traces_deleted_total",
+		Help:      "Total number of traces removed to satisfy deletion requests.",
+	})
+	metricDeletionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "deletion_errors_total",
+		Help:      "Total number of errors occurring while processing deletion requests.",
+	})
+)
+
+// ErrInvalidDeletionRequest is wrapped by the errors AddDeletionRequest returns for requests that can't be processed
+var ErrInvalidDeletionRequest = errors.New("invalid deletion request")
+
+var errEmptyDeletionRequest = fmt.Errorf("%w: must contain trace ids or an attribute to match", ErrInvalidDeletionRequest)
+
+// AddDeletionRequest validates and persists a new deletion request. It will be picked up by the
+// compactor that owns it on its next deletion cycle.
+func (rw *readerWriter) AddDeletionRequest(ctx context.Context, req *backend.DeletionRequest) error {
+	if len(req.TraceIDs) == 0 && req.AttrKey == "" {
+		return errEmptyDeletionRequest
+	}
+	if _, err := req.DecodedTraceIDs(); err != nil {
+		return fmt.Errorf("%w: invalid trace id: %v", ErrInvalidDeletionRequest, err)
+	}
+
+	return rw.w.WriteDeletionRequest(ctx, req)
+}
+
+// DeletionRequests returns all deletion requests for the given tenant
+func (rw *readerWriter) DeletionRequests(ctx context.Context, tenantID string) ([]*backend.DeletionRequest, error) {
+	return rw.r.DeletionRequests(ctx, tenantID)
+}
+
+// todo: pass a context/chan in to cancel this cleanly
+func (rw *readerWriter) deletionLoop() {
+	ticker := time.NewTicker(rw.cfg.BlocklistPoll)
+	for range ticker.C {
+		rw.doDeletions()
+	}
+}
+
+func (rw *readerWriter) doDeletions() {
+	ctx := context.Background()
+
+	for _, tenantID := range rw.blocklist.Tenants() {
+		reqs, err := rw.r.DeletionRequests(ctx, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to read deletion requests", "tenantID", tenantID, "err", err)
+			metricDeletionErrors.Inc()
+			continue
+		}
+
+		for _, req := range reqs {
+			if req.Status != backend.DeletionRequestPending || !rw.compactorSharder.Owns(req.ID.String()) {
+				continue
+			}
+
+			rw.processDeletionRequest(ctx, req)
+		}
+	}
+}
+
+// processDeletionRequest does a single pass over the tenant's blocklist and rewrites every block
+// holding matching traces. A request is only marked complete after a pass in which no block had
+// to be rewritten. This guards against blocks that were being compacted concurrently and carried
+// matching traces into their output.
+func (rw *readerWriter) processDeletionRequest(ctx context.Context, req *backend.DeletionRequest) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "rw.processDeletionRequest")
+	defer span.Finish()
+
+	level.Info(rw.logger).Log("msg", "processing deletion request", "tenantID", req.TenantID, "requestID", req.ID)
+
+	rewritten, missing, deleted, err := rw.deleteMatchingTraces(ctx, req)
+
+	req.BlocksRewritten += rewritten
+	req.TracesDeleted += deleted
+	req.UpdatedAt = time.Now()
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to process deletion request", "tenantID", req.TenantID, "requestID", req.ID, "err", err)
+		metricDeletionErrors.Inc()
+		req.Error = err.Error()
+		if err == errEmptyDeletionRequest {
+			req.Status = backend.DeletionRequestFailed
+		}
+	} else if rewritten == 0 && missing == 0 {
+		level.Info(rw.logger).Log("msg", "deletion request complete", "tenantID", req.TenantID, "requestID", req.ID, "tracesDeleted", req.TracesDeleted)
+		req.Status = backend.DeletionRequestComplete
+		req.Error = ""
+	}
+
+	err = rw.w.WriteDeletionRequest(ctx, req)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to update deletion request", "tenantID", req.TenantID, "requestID", req.ID, "err", err)
+		metricDeletionErrors.Inc()
+	}
+}
+
+// deleteMatchingTraces rewrites the blocks of the tenant holding traces matching the request. Blocks that no
+// longer exist are skipped and counted as missing.
+func (rw *readerWriter) deleteMatchingTraces(ctx context.Context, req *backend.DeletionRequest) (blocksRewritten int, blocksMissing int, tracesDeleted int, err error) {
+	ids, err := req.DecodedTraceIDs()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(ids) == 0 && req.AttrKey == "" {
+		return 0, 0, 0, errEmptyDeletionRequest
+	}
+
+	match := newDeletionMatcher(req, ids)
+
+	opts := common.SearchOptions{}
+	if rw.cfg.Search != nil {
+		rw.cfg.Search.ApplyToOptions(&opts)
+	}
+
+	for _, meta := range rw.blocklist.Metas(req.TenantID) {
+		if !req.OverlapsBlock(meta, ids) {
+			continue
+		}
+
+		// Make sure block still exists
+		_, err := rw.r.BlockMeta(ctx, meta.BlockID, meta.TenantID)
+		if err == backend.ErrDoesNotExist {
+			// block was compacted in the meantime. the next pass will pick up the new block
+			blocksMissing++
+			continue
+		}
+		if err != nil {
+			return blocksRewritten, blocksMissing, tracesDeleted, err
+		}
+
+		// only blocks holding a matching trace are rewritten. trace ids are looked up using the bloom
+		// filters of the block, attributes by scanning the block if its stats don't rule it out.
+		found := false
+		if len(ids) > 0 {
+			found, err = blockContainsAny(ctx, meta, rw.readerForTier(meta, rw.r), ids, opts)
+			if err != nil {
+				return blocksRewritten, blocksMissing, tracesDeleted, err
+			}
+		}
+		if !found && req.AttrKey != "" {
+			found, err = blockContainsMatch(ctx, meta, rw.readerForTier(meta, rw.r), req.AttrKey, match)
+			if err != nil {
+				return blocksRewritten, blocksMissing, tracesDeleted, err
+			}
+		}
+		if !found {
+			continue
+		}
+
+		deleted, err := rw.rewriteBlock(ctx, meta, match)
+		if err != nil {
+			return blocksRewritten, blocksMissing, tracesDeleted, err
+		}
+		if deleted > 0 {
+			blocksRewritten++
+			tracesDeleted += deleted
+		}
+	}
+
+	return blocksRewritten, blocksMissing, tracesDeleted, nil
+}
+
+// rewriteBlock compacts the given block into a new one leaving out all traces that match. If nothing
+// matched the new blocks are discarded and the original block is kept.
+func (rw *readerWriter) rewriteBlock(ctx context.Context, meta *backend.BlockMeta, match func(common.ID, *tempopb.Trace) bool) (int, error) {
+	enc, err := encoding.FromVersion(meta.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	opts := common.CompactionOptions{
+		BlockConfig:        *rw.cfg.Block,
+		ChunkSizeBytes:     rw.compactorCfg.ChunkSizeBytes,
+		FlushSizeBytes:     rw.compactorCfg.FlushSizeBytes,
+		IteratorBufferSize: rw.compactorCfg.IteratorBufferSize,
+		OutputBlocks:       outputBlocks,
+		Combiner: instrumentedObjectCombiner{
+			tenant:               meta.TenantID,
+			inner:                rw.compactorSharder,
+			compactionLevelLabel: strconv.Itoa(int(meta.CompactionLevel)),
+		},
+		MaxBytesPerTrace: rw.compactorOverrides.MaxBytesPerTraceForTenant(meta.TenantID),
+		DropObject:       match,
+		RetentionClass:   meta.RetentionClass,
+		SampleRate: func(_, _ int) float64 {
+			return meta.SampleRate
+		},
+		ObjectsCombined: func(compactionLevel, objs int) {},
+		ObjectsDropped: func(compactionLevel, objs int) {
+			deleted += objs
+		},
+		SpansDiscarded: func(spans int) {
+			rw.compactorSharder.RecordDiscardedSpans(spans, meta.TenantID)
+		},
+	}
+
+	newBlocks, err := enc.NewCompactor(opts).Compact(ctx, rw.logger, rw.readerForTier(meta, rw.r), rw.getWriterForBlock, []*backend.BlockMeta{meta})
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted == 0 {
+		for _, b := range newBlocks {
+			if err := rw.c.ClearBlock(b.BlockID, b.TenantID); err != nil {
+				level.Error(rw.logger).Log("msg", "failed to clear unneeded rewritten block", "blockID", b.BlockID, "tenantID", b.TenantID, "err", err)
+				metricDeletionErrors.Inc()
+			}
+		}
+		return 0, nil
+	}
+
+	level.Info(rw.logger).Log("msg", "rewrote block for deletion request", "blockID", meta.BlockID, "tenantID", meta.TenantID, "tracesDeleted", deleted, "newBlocks", len(newBlocks))
+	metricDeletionBlocksRewritten.Inc()
+	metricDeletionTracesDeleted.Add(float64(deleted))
+
+	markCompacted(rw, meta.TenantID, []*backend.BlockMeta{meta}, newBlocks)
+
+	return deleted, nil
+}
+
+// blockContainsAny returns true if the block may contain any of the given trace ids
+func blockContainsAny(ctx context.Context, meta *backend.BlockMeta, r backend.Reader, ids [][]byte, opts common.SearchOptions) (bool, error) {
+	block, err := encoding.OpenBlock(meta, r)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		tr, err := block.FindTraceByID(ctx, id, opts)
+		if err != nil {
+			return false, err
+		}
+		if tr != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// blockContainsMatch returns true if any trace of the block matches. Blocks whose stats show that none of their
+// spans or resources has the attribute key are skipped without reading them.
+func blockContainsMatch(ctx context.Context, meta *backend.BlockMeta, r backend.Reader, attrKey string, match func(common.ID, *tempopb.Trace) bool) (bool, error) {
+	if meta.Stats != nil && !meta.Stats.MayContainAttribute(attrKey) {
+		return false, nil
+	}
+
+	enc, err := encoding.FromVersion(meta.Version)
+	if err != nil {
+		return false, err
+	}
+
+	iter, err := enc.TraceIterator(ctx, meta, r)
+	if err != nil {
+		return false, err
+	}
+	defer iter.Close()
+
+	for {
+		id, tr, err := iter.Next(ctx)
+		if err == io.EOF || (err == nil && id == nil) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if match(id, tr) {
+			return true, nil
+		}
+	}
+}
+
+// newDeletionMatcher returns a func that matches traces by id or by the presence of a span
+// or resource attribute.
+func newDeletionMatcher(req *backend.DeletionRequest, ids [][]byte) func(common.ID, *tempopb.Trace) bool {
+	idSet := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		idSet[hex.EncodeToString(id)] = struct{}{}
+	}
+
+	return func(id common.ID, tr *tempopb.Trace) bool {
+		if _, ok := idSet[hex.EncodeToString(id)]; ok {
+			return true
+		}
+
+		if req.AttrKey == "" || tr == nil {
+			return false
+		}
+
+		for _, b := range tr.Batches {
+			if b.Resource != nil && attributesMatch(b.Resource.Attributes, req.AttrKey, req.AttrValue) {
+				return true
+			}
+			for _, ils := range b.InstrumentationLibrarySpans {
+				for _, s := range ils.Spans {
+					if attributesMatch(s.Attributes, req.AttrKey, req.AttrValue) {
+						return true
+					}
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+func attributesMatch(attrs []*v1.KeyValue, key, value string) bool {
+	for _, a := range attrs {
+		if a.Key != key || a.Value == nil {
+			continue
+		}
+
+		switch v := a.Value.Value.(type) {
+		case *v1.AnyValue_StringValue:
+			if v.StringValue == value {
+				return true
+			}
+		case *v1.AnyValue_IntValue:
+			if strconv.FormatInt(v.IntValue, 10) == value {
+				return true
+			}
+		case *v1.AnyValue_BoolValue:
+			if strconv.FormatBool(v.BoolValue) == value {
+				return true
+			}
+		case *v1.AnyValue_DoubleValue:
+			if strconv.FormatFloat(v.DoubleValue, 'g', -1, 64) == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,210 @@
+package tempodb
+
+import (
+	"context"
+	"encoding/hex"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
+	"github.com/grafana/tempo/tempodb/encoding/vparquet"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestDeletionRequests(t *testing.T) {
+	testEncodings := []string{v2.VersionString, vparquet.VersionString}
+	for _, enc := range testEncodings {
+		t.Run(enc, func(t *testing.T) {
+			testDeletionRequests(t, enc)
+		})
+	}
+}
+
+func testDeletionRequests(t *testing.T, targetBlockVersion string) {
+	tempDir := t.TempDir()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              targetBlockVersion,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+			RowGroupSizeBytes:    30_000_000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:     10_000_000,
+		FlushSizeBytes:     10_000_000,
+		MaxCompactionRange: 24 * time.Hour,
+	}, &mockSharder{}, &mockOverrides{})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	// two blocks with 10 traces each. the first trace of the first block carries a user attribute
+	// and the second trace of the second block is deleted by id.
+	var allIDs []common.ID
+	for i := 0; i < 2; i++ {
+		data := make([]testData, 0, 10)
+		for j := 0; j < 10; j++ {
+			id := test.ValidTraceID(nil)
+			tr := test.MakeTrace(2, id)
+			if i == 0 && j == 0 {
+				span := tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0]
+				span.Attributes = append(span.Attributes, &v1_common.KeyValue{
+					Key:   "user.id",
+					Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "bob"}},
+				})
+			}
+			data = append(data, testData{id: id, t: tr})
+			allIDs = append(allIDs, id)
+		}
+		cutTestBlockWithTraces(t, w, testTenantID, data)
+	}
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+
+	// invalid requests are rejected
+	require.ErrorIs(t, c.AddDeletionRequest(context.Background(), backend.NewDeletionRequest(testTenantID)), ErrInvalidDeletionRequest)
+
+	byID := backend.NewDeletionRequest(testTenantID)
+	byID.TraceIDs = []string{hex.EncodeToString(allIDs[11])}
+	require.NoError(t, c.AddDeletionRequest(context.Background(), byID))
+
+	byAttr := backend.NewDeletionRequest(testTenantID)
+	byAttr.AttrKey = "user.id"
+	byAttr.AttrValue = "bob"
+	require.NoError(t, c.AddDeletionRequest(context.Background(), byAttr))
+
+	// only the first block is scanned as holding a match. blocks whose stats don't have the key aren't read at all
+	matchAttr := newDeletionMatcher(byAttr, nil)
+	matching := 0
+	for _, meta := range rw.blocklist.Metas(testTenantID) {
+		found, err := blockContainsMatch(context.Background(), meta, rw.r, byAttr.AttrKey, matchAttr)
+		require.NoError(t, err)
+		if found {
+			matching++
+		}
+	}
+	require.Equal(t, 1, matching)
+
+	unread := &backend.BlockMeta{BlockID: uuid.New(), TenantID: testTenantID, Version: targetBlockVersion, Stats: &backend.BlockStats{}}
+	skipped, err := blockContainsMatch(context.Background(), unread, rw.r, byAttr.AttrKey, matchAttr)
+	require.NoError(t, err)
+	require.False(t, skipped)
+
+	// first pass rewrites both blocks, second pass finds nothing left to rewrite and completes the requests
+	rw.doDeletions()
+	reqs, err := c.DeletionRequests(context.Background(), testTenantID)
+	require.NoError(t, err)
+	require.Len(t, reqs, 2)
+	for _, req := range reqs {
+		require.Equal(t, backend.DeletionRequestPending, req.Status)
+		require.Equal(t, 1, req.BlocksRewritten)
+		require.Equal(t, 1, req.TracesDeleted)
+	}
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+	require.Len(t, rw.blocklist.CompactedMetas(testTenantID), 2)
+
+	rewritten := rw.blocklist.Metas(testTenantID)
+
+	rw.doDeletions()
+	reqs, err = c.DeletionRequests(context.Background(), testTenantID)
+	require.NoError(t, err)
+	for _, req := range reqs {
+		require.Equal(t, backend.DeletionRequestComplete, req.Status)
+		require.Equal(t, 1, req.TracesDeleted)
+	}
+	require.ElementsMatch(t, rewritten, rw.blocklist.Metas(testTenantID))
+	require.Len(t, rw.blocklist.CompactedMetas(testTenantID), 2)
+
+	// compacted blocks are still searched by Find for a short time, so open the live blocks directly
+	found := map[int]bool{}
+	for _, meta := range rw.blocklist.Metas(testTenantID) {
+		require.Equal(t, 9, meta.TotalObjects)
+
+		block, err := encoding.OpenBlock(meta, rw.r)
+		require.NoError(t, err)
+		for i, id := range allIDs {
+			tr, err := block.FindTraceByID(context.Background(), id, common.SearchOptions{})
+			require.NoError(t, err)
+			if tr != nil {
+				found[i] = true
+			}
+		}
+	}
+	require.Len(t, found, 18)
+	require.False(t, found[0])
+	require.False(t, found[11])
+
+	// blocks compacted in the meantime are not counted as rewritten but keep the request pending
+	missing := &backend.BlockMeta{BlockID: uuid.New(), TenantID: testTenantID}
+	rw.blocklist.Update(testTenantID, []*backend.BlockMeta{missing}, nil, nil, nil)
+
+	again := backend.NewDeletionRequest(testTenantID)
+	again.AttrKey = "user.id"
+	again.AttrValue = "bob"
+	require.NoError(t, c.AddDeletionRequest(context.Background(), again))
+
+	rw.doDeletions()
+	req := deletionRequestByID(t, c, again.ID)
+	require.Equal(t, backend.DeletionRequestPending, req.Status)
+	require.Equal(t, 0, req.BlocksRewritten)
+
+	rw.blocklist.Update(testTenantID, nil, []*backend.BlockMeta{missing}, nil, nil)
+	rw.doDeletions()
+	req = deletionRequestByID(t, c, again.ID)
+	require.Equal(t, backend.DeletionRequestComplete, req.Status)
+	require.Equal(t, 0, req.BlocksRewritten)
+}
+
+func deletionRequestByID(t *testing.T, c Compactor, id uuid.UUID) *backend.DeletionRequest {
+	reqs, err := c.DeletionRequests(context.Background(), testTenantID)
+	require.NoError(t, err)
+	for _, req := range reqs {
+		if req.ID == id {
+			return req
+		}
+	}
+	require.FailNow(t, "deletion request not found", id)
+	return nil
+}
+
+func TestAttributesMatch(t *testing.T) {
+	attrs := []*v1_common.KeyValue{
+		{Key: "str", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "foo"}}},
+		{Key: "int", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 123}}},
+		{Key: "bool", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+		{Key: "nil"},
+	}
+
+	require.True(t, attributesMatch(attrs, "str", "foo"))
+	require.True(t, attributesMatch(attrs, "int", "123"))
+	require.True(t, attributesMatch(attrs, "bool", "true"))
+	require.False(t, attributesMatch(attrs, "str", "bar"))
+	require.False(t, attributesMatch(attrs, "nil", ""))
+	require.False(t, attributesMatch(attrs, "missing", "foo"))
+}
@@ -29,6 +29,7 @@ type CacheControl struct {
 	Footer      bool
 	ColumnIndex bool
 	OffsetIndex bool
+	Pages       bool
 }
 
 type SearchOptions struct {
@@ -55,7 +56,24 @@ type CompactionOptions struct {
 	BlockConfig        BlockConfig
 	Combiner           model.ObjectCombiner
 
+	// DropObject is optional. If set it is called with every trace before it is written to an
+	// output block. Traces for which it returns true are left out of the compacted blocks.
+	DropObject func(id ID, tr *tempopb.Trace) bool
+
+	// RetentionClass is recorded in the metas of all output blocks.
+	RetentionClass string
+
+	// ClassifyObject is optional. If set it is called with every trace and the trace is written to an
+	// output block of the returned retention class instead of RetentionClass.
+	ClassifyObject func(id ID, tr *tempopb.Trace) string
+
+	// SampleRate is optional. If set it is called before an output block is completed with the number of
+	// traces written to the block and the number of traces of its retention class dropped by DropObject
+	// while it was written. The result is recorded in its meta as the effective sample rate.
+	SampleRate func(kept, dropped int) float64
+
 	ObjectsCombined func(compactionLevel, objects int)
+	ObjectsDropped  func(compactionLevel, objects int)
 	ObjectsWritten  func(compactionLevel, objects int)
 	BytesWritten    func(compactionLevel, bytes int)
 	SpansDiscarded  func(spans int)
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"github.com/grafana/tempo/pkg/model/trace"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// AddTraceStats records the duration, service names, span names and attribute keys of a trace
+func AddTraceStats(b *backend.BlockStatsBuilder, tr *tempopb.Trace, durationNanos uint64) {
+	b.AddTrace(durationNanos)
+
+	for _, batch := range tr.Batches {
+		if batch.Resource != nil {
+			for _, a := range batch.Resource.Attributes {
+				b.AddAttribute(a.Key)
+				if a.Key == trace.ServiceNameTag {
+					b.AddService(a.Value.GetStringValue())
+				}
+			}
+		}
+
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				b.AddSpan(span.Name)
+				for _, a := range span.Attributes {
+					b.AddAttribute(a.Key)
+				}
+			}
+		}
+	}
+}
+
+// TraceDuration returns the time between the earliest start and the latest end of all spans of the trace
+func TraceDuration(tr *tempopb.Trace) uint64 {
+	var (
+		start, end uint64
+		found      bool
+	)
+	for _, batch := range tr.Batches {
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if !found || s.StartTimeUnixNano < start {
+					found = true
+					start = s.StartTimeUnixNano
+				}
+				if s.EndTimeUnixNano > end {
+					end = s.EndTimeUnixNano
+				}
+			}
+		}
+	}
+	if end < start {
+		return 0
+	}
+	return end - start
+}
@@ -98,7 +98,7 @@ func (a *appender) RecordsForID(id common.ID) []Record {
 }
 
 func (a *appender) Length() int {
-	a.recordsMtx.Lock()
+	a.recordsMtx.RLock()
 	defer a.recordsMtx.RUnlock()
 
 	return len(a.records)
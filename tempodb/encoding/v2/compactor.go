@@ -11,9 +11,11 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding/common"
 	"github.com/pkg/errors"
+	"go.uber.org/atomic"
 )
 
 type Compactor struct {
@@ -42,7 +44,10 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 
 	var compactionLevel uint8
 	var totalRecords int
+	// stats of the output are merged from the inputs
+	inputStats := make([]*backend.BlockStats, 0, len(inputs))
 	for _, blockMeta := range inputs {
+		inputStats = append(inputStats, blockMeta.Stats)
 		totalRecords += blockMeta.TotalObjects
 
 		if blockMeta.CompactionLevel > compactionLevel {
@@ -72,10 +77,31 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 		combiner = model.StaticCombiner
 	}
 
-	var currentBlock *StreamingBlock
-	var tracker backend.AppendTracker
+	decoder, err := model.NewObjectDecoder(dataEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// combined traces can be longer than any input trace
+	durations := &durationCombiner{ObjectCombiner: combiner, decoder: decoder}
+	outputStats := func() *backend.BlockStats {
+		stats := backend.MergeBlockStats(inputStats...)
+		if d := durations.maxDuration.Load(); stats != nil && d > stats.MaxTraceDurationNanos {
+			stats.MaxTraceDurationNanos = d
+		}
+		return stats
+	}
+
+	// blocks currently written to by retention class
+	type outputBlock struct {
+		block   *StreamingBlock
+		tracker backend.AppendTracker
+	}
+	outputs := map[string]*outputBlock{}
+	// traces dropped by retention class since the last block of the class was shipped
+	dropped := map[string]int{}
 
-	iter := NewMultiblockIterator(ctx, iters, c.opts.IteratorBufferSize, combiner, dataEncoding, l)
+	iter := NewMultiblockIterator(ctx, iters, c.opts.IteratorBufferSize, durations, dataEncoding, l)
 	defer iter.Close()
 
 	for {
@@ -89,44 +115,68 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 			return nil, errors.Wrap(err, "error iterating input blocks")
 		}
 
+		class := c.opts.RetentionClass
+		if c.opts.ClassifyObject != nil || c.opts.DropObject != nil {
+			var tr *tempopb.Trace
+			tr, err = decoder.PrepareForRead(body)
+			if err != nil {
+				return nil, errors.Wrap(err, "error decoding object")
+			}
+			if c.opts.ClassifyObject != nil {
+				class = c.opts.ClassifyObject(id, tr)
+			}
+			if c.opts.DropObject != nil && c.opts.DropObject(id, tr) {
+				if c.opts.ObjectsDropped != nil {
+					c.opts.ObjectsDropped(int(compactionLevel), 1)
+				}
+				dropped[class]++
+				continue
+			}
+		}
+
 		// make a new block if necessary
-		if currentBlock == nil {
-			currentBlock, err = NewStreamingBlock(&c.opts.BlockConfig, uuid.New(), tenantID, inputs, recordsPerBlock)
+		out := outputs[class]
+		if out == nil {
+			block, err := NewStreamingBlock(&c.opts.BlockConfig, uuid.New(), tenantID, inputs, recordsPerBlock)
 			if err != nil {
 				return nil, errors.Wrap(err, "error making new compacted block")
 			}
-			currentBlock.BlockMeta().CompactionLevel = nextCompactionLevel
-			newCompactedBlocks = append(newCompactedBlocks, currentBlock.BlockMeta())
+			block.BlockMeta().CompactionLevel = nextCompactionLevel
+			block.BlockMeta().RetentionClass = class
+			newCompactedBlocks = append(newCompactedBlocks, block.BlockMeta())
+
+			out = &outputBlock{block: block}
+			outputs[class] = out
 		}
 
-		err = currentBlock.AddObject(id, body)
+		err = out.block.AddObject(id, body)
 		if err != nil {
 			return nil, err
 		}
 
 		// write partial block
-		if currentBlock.CurrentBufferLength() >= int(c.opts.FlushSizeBytes) {
+		if out.block.CurrentBufferLength() >= int(c.opts.FlushSizeBytes) {
 			runtime.GC()
-			tracker, err = c.appendBlock(ctx, writerCallback, tracker, currentBlock)
+			out.tracker, err = c.appendBlock(ctx, writerCallback, out.tracker, out.block)
 			if err != nil {
 				return nil, errors.Wrap(err, "error writing partial block")
 			}
 		}
 
 		// ship block to backend if done
-		if currentBlock.Length() >= recordsPerBlock {
-			err = c.finishBlock(ctx, writerCallback, tracker, currentBlock, l)
+		if out.block.Length() >= recordsPerBlock {
+			err = c.finishBlock(ctx, writerCallback, out.tracker, out.block, outputStats, dropped[class], l)
 			if err != nil {
 				return nil, errors.Wrap(err, "error shipping block to backend")
 			}
-			currentBlock = nil
-			tracker = nil
+			delete(outputs, class)
+			delete(dropped, class)
 		}
 	}
 
-	// ship final block to backend
-	if currentBlock != nil {
-		err = c.finishBlock(ctx, writerCallback, tracker, currentBlock, l)
+	// ship final blocks to backend
+	for class, out := range outputs {
+		err = c.finishBlock(ctx, writerCallback, out.tracker, out.block, outputStats, dropped[class], l)
 		if err != nil {
 			return nil, errors.Wrap(err, "error shipping block to backend")
 		}
@@ -154,7 +204,12 @@ func (c *Compactor) appendBlock(ctx context.Context, writerCallback func(*backen
 	return tracker, nil
 }
 
-func (c *Compactor) finishBlock(ctx context.Context, writerCallback func(*backend.BlockMeta, time.Time) backend.Writer, tracker backend.AppendTracker, block *StreamingBlock, l log.Logger) error {
+func (c *Compactor) finishBlock(ctx context.Context, writerCallback func(*backend.BlockMeta, time.Time) backend.Writer, tracker backend.AppendTracker, block *StreamingBlock, stats func() *backend.BlockStats, dropped int, l log.Logger) error {
+	if c.opts.SampleRate != nil {
+		block.BlockMeta().SampleRate = c.opts.SampleRate(block.Length(), dropped)
+	}
+	block.BlockMeta().Stats = stats()
+
 	level.Info(l).Log("msg", "writing compacted block", "block", fmt.Sprintf("%+v", block.BlockMeta()))
 
 	bytesFlushed, err := block.Complete(ctx, tracker, writerCallback(block.BlockMeta(), time.Now()))
@@ -169,3 +224,28 @@ func (c *Compactor) finishBlock(ctx context.Context, writerCallback func(*backen
 
 	return nil
 }
+
+// durationCombiner records the longest duration of the traces it combined. Combine is called by the iterator
+// goroutine of the multiblock iterator.
+type durationCombiner struct {
+	model.ObjectCombiner
+	decoder     model.ObjectDecoder
+	maxDuration atomic.Uint64
+}
+
+func (c *durationCombiner) Combine(dataEncoding string, objs ...[]byte) ([]byte, bool, error) {
+	obj, wasCombined, err := c.ObjectCombiner.Combine(dataEncoding, objs...)
+	if err != nil || !wasCombined {
+		return obj, wasCombined, err
+	}
+
+	tr, err := c.decoder.PrepareForRead(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	if d := common.TraceDuration(tr); d > c.maxDuration.Load() {
+		c.maxDuration.Store(d)
+	}
+
+	return obj, wasCombined, nil
+}
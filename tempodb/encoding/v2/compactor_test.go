@@ -0,0 +1,135 @@
+package v2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+func TestCompactorStats(t *testing.T) {
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	r := backend.NewReader(rawR)
+	w := backend.NewWriter(rawW)
+	ctx := context.Background()
+
+	cfg := &common.BlockConfig{
+		BloomFP:              0.01,
+		BloomShardSizeBytes:  100,
+		IndexDownsampleBytes: 1000,
+		IndexPageSizeBytes:   1000,
+		Encoding:             backend.EncNone,
+	}
+	enc := model.MustNewSegmentDecoder(model.CurrentEncoding)
+
+	// blocks are created from the objects of the wal
+	createBlock := func(traces ...*tempopb.Trace) *backend.BlockMeta {
+		iter := &testIterator{}
+		for _, tr := range traces {
+			segment, err := enc.PrepareForWrite(tr, 0, 0)
+			require.NoError(t, err)
+			obj, err := enc.ToObject([][]byte{segment})
+			require.NoError(t, err)
+			iter.Add(test.ValidTraceID(tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0].TraceId), obj, nil)
+		}
+		meta := backend.NewBlockMeta(testTenantID, uuid.New(), VersionString, backend.EncNone, model.CurrentEncoding)
+		meta.TotalObjects = len(traces)
+
+		meta, err := CreateBlock(ctx, cfg, meta, &walIterator{iter}, w)
+		require.NoError(t, err)
+		return meta
+	}
+
+	// the parts of trace 1 are 1s long each but the combined trace is 10s long
+	a := createBlock(makeStatsTestTrace([]byte{0x01}, "svc-a", "a", 0, time.Second))
+	b := createBlock(
+		makeStatsTestTrace([]byte{0x01}, "svc-b", "b", 9*time.Second, 10*time.Second),
+		makeStatsTestTrace([]byte{0x02}, "svc-b", "b", 0, 2*time.Second),
+	)
+
+	require.Equal(t, &backend.BlockStats{
+		SpanCount:             2,
+		MinTraceDurationNanos: uint64(time.Second),
+		MaxTraceDurationNanos: uint64(2 * time.Second),
+		ServiceNames:          []string{"svc-b"},
+		SpanNames:             []backend.SpanNameCount{{Name: "b", Count: 2}},
+		Attributes:            []string{"foo", "service.name"},
+	}, b.Stats)
+
+	// stats are written to the meta
+	writtenMeta, err := r.BlockMeta(ctx, b.BlockID, b.TenantID)
+	require.NoError(t, err)
+	require.Equal(t, b.Stats, writtenMeta.Stats)
+
+	c := NewCompactor(common.CompactionOptions{
+		BlockConfig:      *cfg,
+		OutputBlocks:     1,
+		ChunkSizeBytes:   1_000_000,
+		FlushSizeBytes:   30_000_000,
+		MaxBytesPerTrace: 50_000_000,
+		ObjectsCombined:  func(compactionLevel, objects int) {},
+	})
+	metas, err := c.Compact(ctx, log.NewNopLogger(), r, func(*backend.BlockMeta, time.Time) backend.Writer { return w }, []*backend.BlockMeta{a, b})
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+
+	stats := metas[0].Stats
+	require.NotNil(t, stats)
+	require.Equal(t, []string{"svc-a", "svc-b"}, stats.ServiceNames)
+	require.Equal(t, uint64(3), stats.SpanCount)
+	require.Equal(t, uint64(time.Second), stats.MinTraceDurationNanos)
+	require.Equal(t, uint64(10*time.Second), stats.MaxTraceDurationNanos)
+
+	// blocks without stats make the stats of the output unknown
+	b.Stats = nil
+	metas, err = c.Compact(ctx, log.NewNopLogger(), r, func(*backend.BlockMeta, time.Time) backend.Writer { return w }, []*backend.BlockMeta{a, b})
+	require.NoError(t, err)
+	require.Nil(t, metas[0].Stats)
+}
+
+// walIterator is a common.Iterator whose objects are read as bytes like the iterators of the wal
+type walIterator struct {
+	*testIterator
+}
+
+func (i *walIterator) Next(context.Context) (common.ID, *tempopb.Trace, error) {
+	panic("objects are read with NextBytes")
+}
+
+// makeStatsTestTrace returns a trace with a single span of the given service and duration
+func makeStatsTestTrace(id []byte, service, spanName string, start, end time.Duration) *tempopb.Trace {
+	return &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1_common.KeyValue{{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}}},
+			},
+			InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+				Spans: []*v1_trace.Span{{
+					TraceId:           id,
+					SpanId:            []byte(spanName),
+					Name:              spanName,
+					StartTimeUnixNano: uint64(start),
+					EndTimeUnixNano:   uint64(end),
+					Attributes:        []*v1_common.KeyValue{{Key: "foo", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "bar"}}}},
+				}},
+			}},
+		}},
+	}
+}
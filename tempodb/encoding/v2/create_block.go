@@ -26,10 +26,30 @@ func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.Blo
 		return nil, fmt.Errorf("error creating segment decoder: %w", err)
 	}
 
+	objDec, err := model.NewObjectDecoder(meta.DataEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("error creating object decoder: %w", err)
+	}
+
+	// every trace is decoded once to record the stats of the block
+	stats := backend.NewBlockStatsBuilder()
+
 	var next func(ctx context.Context) (common.ID, []byte, error)
 	if isBytesIterator {
 		// if this is one of our iterators we are in luck. this is quite fast
-		next = bytesIterator.NextBytes
+		next = func(ctx context.Context) (common.ID, []byte, error) {
+			id, obj, err := bytesIterator.NextBytes(ctx)
+			if err != nil || id == nil {
+				return id, obj, err
+			}
+			tr, err := objDec.PrepareForRead(obj)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error decoding object: %w", err)
+			}
+			common.AddTraceStats(stats, tr, common.TraceDuration(tr))
+
+			return id, obj, nil
+		}
 	} else {
 		// otherwise we need to marshal the object to bytes
 		next = func(ctx context.Context) (common.ID, []byte, error) {
@@ -37,6 +57,7 @@ func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.Blo
 			if err != nil || tr == nil {
 				return nil, nil, err
 			}
+			common.AddTraceStats(stats, tr, common.TraceDuration(tr))
 			obj, err := dec.PrepareForWrite(tr, 0, 0) // start/end of the blockmeta are used
 
 			return id, obj, err
@@ -68,6 +89,8 @@ func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.Blo
 		}
 	}
 
+	newBlock.BlockMeta().Stats = stats.Stats()
+
 	_, err = newBlock.Complete(ctx, tracker, to)
 	if err != nil {
 		return nil, errors.Wrap(err, "error completing compactor block")
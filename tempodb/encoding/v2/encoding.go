@@ -30,6 +30,14 @@ func (v Encoding) CopyBlock(ctx context.Context, meta *backend.BlockMeta, from b
 	return CopyBlock(ctx, meta, from, to)
 }
 
+func (v Encoding) TraceIterator(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) (common.Iterator, error) {
+	return TraceIterator(ctx, meta, r)
+}
+
+func (v Encoding) VerifyBlock(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) error {
+	return VerifyBlock(ctx, meta, r)
+}
+
 func (v Encoding) CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.BlockMeta, i common.Iterator, _ backend.Reader, to backend.Writer) (*backend.BlockMeta, error) {
 	return CreateBlock(ctx, cfg, meta, i, to)
 }
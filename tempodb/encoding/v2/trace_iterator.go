@@ -0,0 +1,35 @@
+package v2
+
+import (
+	"context"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+// iteratorChunkSizeBytes is the size of the pages read when iterating a whole block
+const iteratorChunkSizeBytes = 1024 * 1024
+
+// TraceIterator returns an iterator over the decoded traces of a backend block
+func TraceIterator(_ context.Context, meta *backend.BlockMeta, r backend.Reader) (common.Iterator, error) {
+	dec, err := model.NewObjectDecoder(meta.DataEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := NewBackendBlock(meta, r)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := block.Iterator(iteratorChunkSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &commonIterator{
+		iter: iter,
+		dec:  dec,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// VerifyBlock reads all objects of the block through its index and checks that they can be decoded and that
+// their number matches the meta.
+func VerifyBlock(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) error {
+	dec, err := model.NewObjectDecoder(meta.DataEncoding)
+	if err != nil {
+		return err
+	}
+
+	block, err := NewBackendBlock(meta, r)
+	if err != nil {
+		return err
+	}
+
+	iter, err := block.Iterator(iteratorChunkSizeBytes)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	count := 0
+	for {
+		id, obj, err := iter.NextBytes(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := dec.PrepareForRead(obj); err != nil {
+			return fmt.Errorf("failed to decode object %x: %w", id, err)
+		}
+		count++
+	}
+
+	if count != meta.TotalObjects {
+		return fmt.Errorf("block has %d objects, meta says %d", count, meta.TotalObjects)
+	}
+
+	return nil
+}
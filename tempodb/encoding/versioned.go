@@ -38,6 +38,12 @@ type VersionedEncoding interface {
 	// CopyBlock from one backend to another.
 	CopyBlock(ctx context.Context, meta *backend.BlockMeta, from backend.Reader, to backend.Writer) error
 
+	// TraceIterator returns an iterator over all traces of a block in the backend in trace ID order.
+	TraceIterator(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) (common.Iterator, error)
+
+	// VerifyBlock reads the entire block and checks that it is readable and consistent with its meta.
+	VerifyBlock(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) error
+
 	// OpenWALBlock opens an existing appendable block for the WAL
 	OpenWALBlock(filename string, path string, ingestionSlack time.Duration, additionalStartSlack time.Duration) (common.WALBlock, error, error)
 
@@ -87,3 +93,12 @@ func CopyBlock(ctx context.Context, meta *backend.BlockMeta, from backend.Reader
 	}
 	return v.CopyBlock(ctx, meta, from, to)
 }
+
+// VerifyBlock reads the entire block and checks its structure. It automatically chooses the encoding for the given block.
+func VerifyBlock(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) error {
+	v, err := FromVersion(meta.Version)
+	if err != nil {
+		return err
+	}
+	return v.VerifyBlock(ctx, meta, r)
+}
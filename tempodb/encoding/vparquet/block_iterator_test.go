@@ -1,13 +1,18 @@
 package vparquet
 
 import (
+	"bytes"
 	"context"
+	"sort"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding/common"
 )
 
 func TestIteratorReadsAllRows(t *testing.T) {
@@ -44,3 +49,43 @@ func TestIteratorReadsAllRows(t *testing.T) {
 
 	require.Equal(t, meta.TotalObjects, actualCount)
 }
+
+func TestTraceIterator(t *testing.T) {
+	ctx := context.Background()
+
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	r := backend.NewReader(rawR)
+	w := backend.NewWriter(rawW)
+
+	iter := newTestIterator()
+	ids := []common.ID{test.ValidTraceID(nil), test.ValidTraceID(nil), test.ValidTraceID(nil)}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i], ids[j]) < 0 })
+	for _, id := range ids {
+		iter.AddWithID(id, test.MakeTrace(5, id))
+	}
+
+	meta := backend.NewBlockMeta("fake", uuid.New(), VersionString, backend.EncNone, "")
+	meta.TotalObjects = len(ids)
+	meta, err = CreateBlock(ctx, &common.BlockConfig{BloomFP: 0.01, BloomShardSizeBytes: 100 * 1024}, meta, iter, r, w)
+	require.NoError(t, err)
+
+	traces, err := TraceIterator(ctx, meta, r)
+	require.NoError(t, err)
+	defer traces.Close()
+
+	for _, expected := range ids {
+		id, tr, err := traces.Next(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, tr)
+		require.Equal(t, expected, id)
+		require.Len(t, tr.Batches, 5)
+	}
+
+	_, tr, err := traces.Next(ctx)
+	require.NoError(t, err)
+	require.Nil(t, tr)
+}
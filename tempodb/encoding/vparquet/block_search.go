@@ -64,7 +64,7 @@ func (b *backendBlock) openForSearch(ctx context.Context, opts common.SearchOpti
 	readerAt = newParquetOptimizedReaderAt(readerAt, int64(b.meta.Size), b.meta.FooterSize)
 
 	// cached reader
-	if opts.CacheControl.ColumnIndex || opts.CacheControl.Footer || opts.CacheControl.OffsetIndex {
+	if opts.CacheControl.ColumnIndex || opts.CacheControl.Footer || opts.CacheControl.OffsetIndex || opts.CacheControl.Pages {
 		readerAt = newCachedReaderAt(readerAt, backendReaderAt, opts.CacheControl)
 	}
 
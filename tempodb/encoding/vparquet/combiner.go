@@ -99,6 +99,17 @@ func (c *Combiner) ConsumeWithFinal(tr *Trace, final bool) (spanCount int) {
 		return
 	}
 
+	// the trace level time range covers both traces
+	if tr.StartTimeUnixNano < c.result.StartTimeUnixNano {
+		c.result.StartTimeUnixNano = tr.StartTimeUnixNano
+	}
+	if tr.EndTimeUnixNano > c.result.EndTimeUnixNano {
+		c.result.EndTimeUnixNano = tr.EndTimeUnixNano
+	}
+	if c.result.EndTimeUnixNano > c.result.StartTimeUnixNano {
+		c.result.DurationNanos = c.result.EndTimeUnixNano - c.result.StartTimeUnixNano
+	}
+
 	// loop through every span and copy spans in B that don't exist to A
 	for _, b := range tr.ResourceSpans {
 		notFoundILS := b.InstrumentationLibrarySpans[:0]
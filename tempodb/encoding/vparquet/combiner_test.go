@@ -42,8 +42,11 @@ func TestCombiner(t *testing.T) {
 		},
 		{
 			traceA: &Trace{
-				TraceID:         []byte{0x00, 0x01},
-				RootServiceName: "serviceNameA",
+				TraceID:           []byte{0x00, 0x01},
+				RootServiceName:   "serviceNameA",
+				StartTimeUnixNano: 10,
+				EndTimeUnixNano:   20,
+				DurationNanos:     10,
 				ResourceSpans: []ResourceSpans{
 					{
 						Resource: Resource{
@@ -63,8 +66,11 @@ func TestCombiner(t *testing.T) {
 				},
 			},
 			traceB: &Trace{
-				TraceID:         []byte{0x00, 0x01},
-				RootServiceName: "serviceNameB",
+				TraceID:           []byte{0x00, 0x01},
+				RootServiceName:   "serviceNameB",
+				StartTimeUnixNano: 5,
+				EndTimeUnixNano:   30,
+				DurationNanos:     25,
 				ResourceSpans: []ResourceSpans{
 					{
 						Resource: Resource{
@@ -86,8 +92,11 @@ func TestCombiner(t *testing.T) {
 			},
 			expectedTotal: 2,
 			expectedTrace: &Trace{
-				TraceID:         []byte{0x00, 0x01},
-				RootServiceName: "serviceNameA",
+				TraceID:           []byte{0x00, 0x01},
+				RootServiceName:   "serviceNameA",
+				StartTimeUnixNano: 5,
+				EndTimeUnixNano:   30,
+				DurationNanos:     25,
 				ResourceSpans: []ResourceSpans{
 					{
 						Resource: Resource{
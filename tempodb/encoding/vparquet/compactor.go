@@ -16,6 +16,7 @@ import (
 	"github.com/segmentio/parquet-go"
 
 	tempo_io "github.com/grafana/tempo/pkg/io"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding/common"
 )
@@ -39,8 +40,13 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 		// MaxBytesPerTrace is the largest trace that can be expected, and assumes 1 byte per value on average (same as flushing).
 		// Divide by 4 to presumably require 2 slice allocations if we ever see a trace this large
 		pool = newRowPool(c.opts.MaxBytesPerTrace / 4)
+		// stats of the output are merged from the inputs. Combined traces can be longer than any input trace.
+		inputStats          = make([]*backend.BlockStats, 0, len(inputs))
+		maxCombinedDuration uint64
 	)
 	for _, blockMeta := range inputs {
+		inputStats = append(inputStats, blockMeta.Stats)
+
 		totalRecords += blockMeta.TotalObjects
 
 		if blockMeta.CompactionLevel > compactionLevel {
@@ -121,15 +127,29 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 			pool.Put(row)
 		}
 		tr, _ := cmb.Result()
+		if tr.DurationNanos > maxCombinedDuration {
+			maxCombinedDuration = tr.DurationNanos
+		}
 
 		c.opts.ObjectsCombined(int(compactionLevel), 1)
 		return sch.Deconstruct(pool.Get(), tr), nil
 	}
 
+	outputStats := func() *backend.BlockStats {
+		stats := backend.MergeBlockStats(inputStats...)
+		if stats != nil && maxCombinedDuration > stats.MaxTraceDurationNanos {
+			stats.MaxTraceDurationNanos = maxCombinedDuration
+		}
+		return stats
+	}
+
 	var (
 		m               = newMultiblockIterator(bookmarks, combine)
 		recordsPerBlock = (totalRecords / int(c.opts.OutputBlocks))
-		currentBlock    *streamingBlock
+		// blocks currently written to by retention class
+		outputs = map[string]*streamingBlock{}
+		// traces dropped by retention class since the last block of the class was shipped
+		dropped = map[string]int{}
 	)
 	defer m.Close()
 
@@ -143,7 +163,27 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 			return nil, errors.Wrap(err, "error iterating input blocks")
 		}
 
+		class := c.opts.RetentionClass
+		if c.opts.ClassifyObject != nil || c.opts.DropObject != nil {
+			tr, err := reconstructTrace(sch, lowestObject)
+			if err != nil {
+				return nil, err
+			}
+			if c.opts.ClassifyObject != nil {
+				class = c.opts.ClassifyObject(lowestID, tr)
+			}
+			if c.opts.DropObject != nil && c.opts.DropObject(lowestID, tr) {
+				if c.opts.ObjectsDropped != nil {
+					c.opts.ObjectsDropped(int(compactionLevel), 1)
+				}
+				dropped[class]++
+				pool.Put(lowestObject)
+				continue
+			}
+		}
+
 		// make a new block if necessary
+		currentBlock := outputs[class]
 		if currentBlock == nil {
 			// Start with a copy and then customize
 			newMeta := &backend.BlockMeta{
@@ -156,7 +196,9 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 
 			currentBlock = newStreamingBlock(ctx, &c.opts.BlockConfig, newMeta, r, w, tempo_io.NewBufferedWriter)
 			currentBlock.meta.CompactionLevel = nextCompactionLevel
+			currentBlock.meta.RetentionClass = class
 			newCompactedBlocks = append(newCompactedBlocks, currentBlock.meta)
+			outputs[class] = currentBlock
 		}
 
 		// Flush existing block data if the next trace can't fit
@@ -192,19 +234,20 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 			currentBlockPtrCopy := currentBlock
 			currentBlockPtrCopy.meta.StartTime = minBlockStart
 			currentBlockPtrCopy.meta.EndTime = maxBlockEnd
-			err := c.finishBlock(ctx, currentBlockPtrCopy, l)
+			err := c.finishBlock(ctx, currentBlockPtrCopy, outputStats, dropped[class], l)
 			if err != nil {
 				return nil, errors.Wrap(err, fmt.Sprintf("error shipping block to backend, blockID %s", currentBlockPtrCopy.meta.BlockID.String()))
 			}
-			currentBlock = nil
+			delete(outputs, class)
+			delete(dropped, class)
 		}
 	}
 
-	// ship final block to backend
-	if currentBlock != nil {
+	// ship final blocks to backend
+	for class, currentBlock := range outputs {
 		currentBlock.meta.StartTime = minBlockStart
 		currentBlock.meta.EndTime = maxBlockEnd
-		err := c.finishBlock(ctx, currentBlock, l)
+		err := c.finishBlock(ctx, currentBlock, outputStats, dropped[class], l)
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("error shipping block to backend, blockID %s", currentBlock.meta.BlockID.String()))
 		}
@@ -213,6 +256,17 @@ func (c *Compactor) Compact(ctx context.Context, l log.Logger, r backend.Reader,
 	return newCompactedBlocks, nil
 }
 
+// reconstructTrace reconstructs the trace in the given row for the ClassifyObject and DropObject callbacks.
+func reconstructTrace(sch *parquet.Schema, row parquet.Row) (*tempopb.Trace, error) {
+	tr := new(Trace)
+	err := sch.Reconstruct(tr, row)
+	if err != nil {
+		return nil, err
+	}
+
+	return parquetTraceToTempopbTrace(tr), nil
+}
+
 func (c *Compactor) appendBlock(ctx context.Context, block *streamingBlock, l log.Logger) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "vparquet.compactor.appendBlock")
 	defer span.Finish()
@@ -241,10 +295,15 @@ func (c *Compactor) appendBlock(ctx context.Context, block *streamingBlock, l lo
 	return nil
 }
 
-func (c *Compactor) finishBlock(ctx context.Context, block *streamingBlock, l log.Logger) error {
+func (c *Compactor) finishBlock(ctx context.Context, block *streamingBlock, stats func() *backend.BlockStats, dropped int, l log.Logger) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "vparquet.compactor.finishBlock")
 	defer span.Finish()
 
+	if c.opts.SampleRate != nil {
+		block.meta.SampleRate = c.opts.SampleRate(block.meta.TotalObjects, dropped)
+	}
+	block.meta.Stats = stats()
+
 	bytesFlushed, err := block.Complete()
 	if err != nil {
 		return errors.Wrap(err, "error completing block")
@@ -13,6 +13,7 @@ import (
 	"github.com/segmentio/parquet-go"
 
 	tempo_io "github.com/grafana/tempo/pkg/io"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
@@ -20,6 +21,67 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestCompactorStats(t *testing.T) {
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	r := backend.NewReader(rawR)
+	w := backend.NewWriter(rawW)
+	ctx := context.Background()
+
+	cfg := &common.BlockConfig{
+		BloomFP:             0.01,
+		BloomShardSizeBytes: 100 * 1024,
+		RowGroupSizeBytes:   20_000_000,
+	}
+
+	createBlock := func(traces ...*tempopb.Trace) *backend.BlockMeta {
+		iter := newTestIterator()
+		for _, tr := range traces {
+			iter.AddWithID(tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0].TraceId, tr)
+		}
+		meta := backend.NewBlockMeta(tenantID, uuid.New(), VersionString, backend.EncNone, "")
+		meta.TotalObjects = len(traces)
+
+		meta, err := CreateBlock(ctx, cfg, meta, iter, r, w)
+		require.NoError(t, err)
+		return meta
+	}
+
+	// the parts of trace 1 are 1s long each but the combined trace is 10s long
+	a := createBlock(makeStatsTestTrace([]byte{0x01}, "svc-a", "a", 0, time.Second))
+	b := createBlock(
+		makeStatsTestTrace([]byte{0x01}, "svc-b", "b", 9*time.Second, 10*time.Second),
+		makeStatsTestTrace([]byte{0x02}, "svc-b", "b", 0, 2*time.Second),
+	)
+
+	c := NewCompactor(common.CompactionOptions{
+		BlockConfig:      *cfg,
+		OutputBlocks:     1,
+		FlushSizeBytes:   30_000_000,
+		MaxBytesPerTrace: 50_000_000,
+		ObjectsCombined:  func(compactionLevel, objects int) {},
+	})
+	metas, err := c.Compact(ctx, log.NewNopLogger(), r, func(*backend.BlockMeta, time.Time) backend.Writer { return w }, []*backend.BlockMeta{a, b})
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+
+	stats := metas[0].Stats
+	require.NotNil(t, stats)
+	require.Equal(t, []string{"svc-a", "svc-b"}, stats.ServiceNames)
+	require.Equal(t, uint64(3), stats.SpanCount)
+	require.Equal(t, uint64(time.Second), stats.MinTraceDurationNanos)
+	require.Equal(t, uint64(10*time.Second), stats.MaxTraceDurationNanos)
+
+	// blocks without stats make the stats of the output unknown
+	b.Stats = nil
+	metas, err = c.Compact(ctx, log.NewNopLogger(), r, func(*backend.BlockMeta, time.Time) backend.Writer { return w }, []*backend.BlockMeta{a, b})
+	require.NoError(t, err)
+	require.Nil(t, metas[0].Stats)
+}
+
 func BenchmarkCompactor(b *testing.B) {
 	b.Run("Small", func(b *testing.B) {
 		benchmarkCompactor(b, 1000, 100, 100) // 10M spans
@@ -36,10 +36,14 @@ func (b *backendWriter) Close() error {
 
 func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.BlockMeta, i common.Iterator, r backend.Reader, to backend.Writer) (*backend.BlockMeta, error) {
 	s := newStreamingBlock(ctx, cfg, meta, r, to, tempo_io.NewBufferedWriter)
+	stats := backend.NewBlockStatsBuilder()
 
 	for {
 		id, tr, err := i.Next(ctx)
-		if err == io.EOF || tr == nil {
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if tr == nil {
 			break
 		}
 
@@ -47,6 +51,7 @@ func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.Blo
 		id = append([]byte(nil), id...)
 
 		trp := traceToParquet(id, tr)
+		common.AddTraceStats(stats, tr, trp.DurationNanos)
 		s.Add(&trp, 0, 0) // start and end time of the wal meta are used.
 
 		// Here we repurpose RowGroupSizeBytes as number of raw column values.
@@ -59,6 +64,11 @@ func CreateBlock(ctx context.Context, cfg *common.BlockConfig, meta *backend.Blo
 		}
 	}
 
+	s.meta.Stats = stats.Stats()
+	s.meta.CompactionLevel = meta.CompactionLevel
+	s.meta.RetentionClass = meta.RetentionClass
+	s.meta.SampleRate = meta.SampleRate
+
 	_, err := s.Complete()
 	if err != nil {
 		return nil, err
@@ -2,12 +2,16 @@ package vparquet
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
@@ -48,6 +52,49 @@ func TestCreateBlockHonorsTraceStartEndTimesFromWalMeta(t *testing.T) {
 	require.Equal(t, 305, int(outMeta.EndTime.Unix()))
 }
 
+func TestCreateBlockCarriesOverCompactionAttributes(t *testing.T) {
+	ctx := context.Background()
+
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	iter := newTestIterator()
+	iter.Add(test.MakeTrace(10, nil), 100, 401)
+
+	meta := backend.NewBlockMeta("fake", uuid.New(), VersionString, backend.EncNone, "")
+	meta.TotalObjects = 1
+	meta.CompactionLevel = 3
+	meta.RetentionClass = "errors"
+	meta.SampleRate = 0.5
+
+	outMeta, err := CreateBlock(ctx, &common.BlockConfig{BloomFP: 0.01, BloomShardSizeBytes: 100 * 1024}, meta, iter, backend.NewReader(rawR), backend.NewWriter(rawW))
+	require.NoError(t, err)
+	require.Equal(t, uint8(3), outMeta.CompactionLevel)
+	require.Equal(t, "errors", outMeta.RetentionClass)
+	require.Equal(t, 0.5, outMeta.SampleRate)
+}
+
+func TestCreateBlockReturnsIteratorErrors(t *testing.T) {
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	meta := backend.NewBlockMeta("fake", uuid.New(), VersionString, backend.EncNone, "")
+	_, err = CreateBlock(context.Background(), &common.BlockConfig{BloomFP: 0.01, BloomShardSizeBytes: 100 * 1024}, meta, &errIterator{}, backend.NewReader(rawR), backend.NewWriter(rawW))
+	require.EqualError(t, err, "iterator failed")
+}
+
+type errIterator struct{}
+
+func (i *errIterator) Next(context.Context) (common.ID, *tempopb.Trace, error) {
+	return nil, nil, errors.New("iterator failed")
+}
+
+func (i *errIterator) Close() {}
+
 // func TestEstimateTraceSize(t *testing.T) {
 // 	f := "<put data.parquet file here>"
 // 	file, err := os.OpenFile(f, os.O_RDONLY, 0644)
@@ -90,7 +137,72 @@ func TestCreateBlockHonorsTraceStartEndTimesFromWalMeta(t *testing.T) {
 // 	fmt.Println(totalParqSz, totalProtoSz)
 // }
 
+func TestCreateBlockStats(t *testing.T) {
+	ctx := context.Background()
+
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	r := backend.NewReader(rawR)
+	w := backend.NewWriter(rawW)
+
+	iter := newTestIterator()
+	iter.AddWithID([]byte{0x01}, makeStatsTestTrace([]byte{0x01}, "svc-a", "span-a", 0, 10*time.Second))
+	iter.AddWithID([]byte{0x02}, makeStatsTestTrace([]byte{0x02}, "svc-b", "span-a", time.Second, 3*time.Second))
+
+	cfg := &common.BlockConfig{
+		BloomFP:             0.01,
+		BloomShardSizeBytes: 100 * 1024,
+	}
+
+	meta := backend.NewBlockMeta("fake", uuid.New(), VersionString, backend.EncNone, "")
+	meta.TotalObjects = 2
+
+	outMeta, err := CreateBlock(ctx, cfg, meta, iter, r, w)
+	require.NoError(t, err)
+
+	require.NotNil(t, outMeta.Stats)
+	require.Equal(t, &backend.BlockStats{
+		SpanCount:             2,
+		MinTraceDurationNanos: uint64(2 * time.Second),
+		MaxTraceDurationNanos: uint64(10 * time.Second),
+		ServiceNames:          []string{"svc-a", "svc-b"},
+		SpanNames:             []backend.SpanNameCount{{Name: "span-a", Count: 2}},
+		Attributes:            []string{"foo", "service.name"},
+	}, outMeta.Stats)
+
+	// stats are written to the meta
+	writtenMeta, err := r.BlockMeta(ctx, meta.BlockID, meta.TenantID)
+	require.NoError(t, err)
+	require.Equal(t, outMeta.Stats, writtenMeta.Stats)
+}
+
+// makeStatsTestTrace returns a trace with a single span of the given service and duration
+func makeStatsTestTrace(id []byte, service, spanName string, start, end time.Duration) *tempopb.Trace {
+	return &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{{
+			Resource: &v1_resource.Resource{
+				Attributes: []*v1.KeyValue{{Key: LabelServiceName, Value: &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: service}}}},
+			},
+			InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+				Spans: []*v1_trace.Span{{
+					TraceId:           id,
+					SpanId:            []byte(spanName),
+					Name:              spanName,
+					Status:            &v1_trace.Status{},
+					StartTimeUnixNano: uint64(start),
+					EndTimeUnixNano:   uint64(end),
+					Attributes:        []*v1.KeyValue{{Key: "foo", Value: &v1.AnyValue{Value: &v1.AnyValue_StringValue{StringValue: "bar"}}}},
+				}},
+			}},
+		}},
+	}
+}
+
 type testIterator struct {
+	ids    []common.ID
 	traces []*tempopb.Trace
 }
 
@@ -101,6 +213,11 @@ func newTestIterator() *testIterator {
 }
 
 func (i *testIterator) Add(tr *tempopb.Trace, start, end uint32) {
+	i.AddWithID(nil, tr)
+}
+
+func (i *testIterator) AddWithID(id common.ID, tr *tempopb.Trace) {
+	i.ids = append(i.ids, id)
 	i.traces = append(i.traces, tr)
 }
 
@@ -108,9 +225,9 @@ func (i *testIterator) Next(ctx context.Context) (common.ID, *tempopb.Trace, err
 	if len(i.traces) == 0 {
 		return nil, nil, io.EOF
 	}
-	tr := i.traces[0]
-	i.traces = i.traces[1:]
-	return nil, tr, nil
+	id, tr := i.ids[0], i.traces[0]
+	i.ids, i.traces = i.ids[1:], i.traces[1:]
+	return id, tr, nil
 }
 
 func (i *testIterator) Close() {
@@ -115,8 +115,9 @@ func (r *cachedReaderAt) SetOffsetIndexSection(offset, length int64) {
 }
 
 func (r *cachedReaderAt) ReadAt(p []byte, off int64) (int, error) {
-	// check if the offset and length is stored as a special object
-	if r.cachedObjects[off] == int64(len(p)) {
+	// check if the offset and length is stored as a special object. with page caching all reads are
+	// cached and the cache is expected to evict cold pages.
+	if r.cacheControl.Pages || r.cachedObjects[off] == int64(len(p)) {
 		return r.br.ReadAtWithCache(p, off)
 	}
 
@@ -0,0 +1,38 @@
+package vparquet
+
+import (
+	"context"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+// TraceIterator returns an iterator over the traces of a backend block converted to their proto representation
+func TraceIterator(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) (common.Iterator, error) {
+	iter, err := newBackendBlock(meta, r).Iterator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &traceIterator{iter: iter}, nil
+}
+
+type traceIterator struct {
+	iter Iterator
+}
+
+var _ common.Iterator = (*traceIterator)(nil)
+
+func (i *traceIterator) Next(ctx context.Context) (common.ID, *tempopb.Trace, error) {
+	tr, err := i.iter.Next(ctx)
+	if err != nil || tr == nil {
+		return nil, nil, err
+	}
+
+	return tr.TraceID, parquetTraceToTempopbTrace(tr), nil
+}
+
+func (i *traceIterator) Close() {
+	i.iter.Close()
+}
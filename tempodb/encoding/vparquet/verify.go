@@ -0,0 +1,52 @@
+package vparquet
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/segmentio/parquet-go"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const verifyBatchSize = 100
+
+// VerifyBlock opens the parquet file of the block and reads all rows. It checks that all pages can be
+// decoded and that the number of rows matches the meta.
+func VerifyBlock(ctx context.Context, meta *backend.BlockMeta, r backend.Reader) error {
+	b := newBackendBlock(meta, r)
+
+	pf, rr, err := b.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer rr.Close()
+
+	if pf.NumRows() != int64(meta.TotalObjects) {
+		return fmt.Errorf("parquet file has %d rows, meta says %d", pf.NumRows(), meta.TotalObjects)
+	}
+
+	rows := make([]parquet.Row, verifyBatchSize)
+	count := int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := rr.ReadRows(rows)
+		count += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if count != pf.NumRows() {
+		return fmt.Errorf("read %d rows, parquet file has %d", count, pf.NumRows())
+	}
+
+	return nil
+}
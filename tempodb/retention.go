@@ -46,9 +46,12 @@ func (rw *readerWriter) retainTenant(tenantID string) {
 	level.Debug(rw.logger).Log("msg", "Performing block retention", "tenantID", tenantID, "retention", retention)
 
 	// iterate through block list.  make compacted anything that is past retention.
-	cutoff := time.Now().Add(-retention)
+	// blocks sorted by retention rules use the retention of their class.
+	rules := rw.compactorOverrides.BlockRetentionRulesForTenant(tenantID)
+	now := time.Now()
 	blocklist := rw.blocklist.Metas(tenantID)
 	for _, b := range blocklist {
+		cutoff := now.Add(-retentionForClass(rules, b.RetentionClass, retention))
 		if b.EndTime.Before(cutoff) && rw.compactorSharder.Owns(b.BlockID.String()) {
 			level.Info(rw.logger).Log("msg", "marking block for deletion", "blockID", b.BlockID, "tenantID", tenantID)
 			err := rw.c.MarkBlockCompacted(b.BlockID, tenantID)
@@ -58,23 +61,25 @@ func (rw *readerWriter) retainTenant(tenantID string) {
 			} else {
 				metricMarkedForDeletion.Inc()
 
-				rw.blocklist.Update(tenantID, nil, []*backend.BlockMeta{b}, []*backend.CompactedBlockMeta{
+				compacted := []*backend.CompactedBlockMeta{
 					{
 						BlockMeta:     *b,
 						CompactedTime: time.Now(),
 					},
-				}, nil)
+				}
+				rw.blocklist.Update(tenantID, nil, []*backend.BlockMeta{b}, compacted, nil)
+				rw.notifyBlocksCompacted(tenantID, compacted)
 			}
 		}
 	}
 
 	// iterate through compacted list looking for blocks ready to be cleared
-	cutoff = time.Now().Add(-rw.compactorCfg.CompactedBlockRetention)
+	cutoff := time.Now().Add(-rw.compactorCfg.CompactedBlockRetention)
 	compactedBlocklist := rw.blocklist.CompactedMetas(tenantID)
 	for _, b := range compactedBlocklist {
 		if b.CompactedTime.Before(cutoff) && rw.compactorSharder.Owns(b.BlockID.String()) {
 			level.Info(rw.logger).Log("msg", "deleting block", "blockID", b.BlockID, "tenantID", tenantID)
-			err := rw.c.ClearBlock(b.BlockID, tenantID)
+			err := rw.clearBlock(&b.BlockMeta)
 			if err != nil {
 				level.Error(rw.logger).Log("msg", "failed to clear compacted block during retention", "blockID", b.BlockID, "tenantID", tenantID, "err", err)
 				metricRetentionErrors.Inc()
@@ -0,0 +1,104 @@
+package tempodb
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/traceql"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// RetentionRule assigns all traces matching Query to the retention class Class. Blocks of this class are
+// retained for Retention instead of the tenant's block retention.
+type RetentionRule struct {
+	Class     string
+	Query     string
+	Retention time.Duration
+}
+
+type compiledRetentionRule struct {
+	RetentionRule
+	matcher *traceql.TraceMatcher
+}
+
+// retentionClassifier sorts traces into the retention classes of a tenant. A trace matching several rules
+// is assigned the class with the longest retention. Traces matching no rule are assigned the default class.
+type retentionClassifier struct {
+	rules []compiledRetentionRule
+}
+
+// newRetentionClassifier compiles the given rules. Invalid rules are logged and skipped so that a
+// misconfigured tenant does not halt compaction.
+func newRetentionClassifier(tenantID string, rules []RetentionRule, logger log.Logger) *retentionClassifier {
+	c := &retentionClassifier{}
+
+	for _, r := range rules {
+		if r.Class == "" || r.Class == backend.RetentionClassDefault {
+			level.Warn(logger).Log("msg", "skipping retention rule with invalid class", "tenantID", tenantID, "class", r.Class)
+			continue
+		}
+
+		m, err := traceql.NewTraceMatcher(r.Query)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping retention rule with invalid query", "tenantID", tenantID, "class", r.Class, "query", r.Query, "err", err)
+			continue
+		}
+
+		c.rules = append(c.rules, compiledRetentionRule{
+			RetentionRule: r,
+			matcher:       m,
+		})
+	}
+
+	return c
+}
+
+func (c *retentionClassifier) classify(tr *tempopb.Trace) string {
+	class := backend.RetentionClassDefault
+	var retention time.Duration
+
+	for _, r := range c.rules {
+		if class != backend.RetentionClassDefault && r.Retention <= retention {
+			continue
+		}
+		if r.matcher.Matches(tr) {
+			class = r.Class
+			retention = r.Retention
+		}
+	}
+
+	return class
+}
+
+// retentionForClass returns the retention of the given class. The default class and classes no longer
+// configured use the tenant's block retention. Unclassified blocks may hold traces of any class and are
+// kept for the longest retention of all rules.
+func retentionForClass(rules []RetentionRule, class string, blockRetention time.Duration) time.Duration {
+	if class == "" {
+		retention := blockRetention
+		for _, r := range rules {
+			if r.Retention > retention {
+				retention = r.Retention
+			}
+		}
+		return retention
+	}
+	if class == backend.RetentionClassDefault {
+		return blockRetention
+	}
+
+	var retention time.Duration
+	for _, r := range rules {
+		if r.Class == class && r.Retention > retention {
+			retention = r.Retention
+		}
+	}
+
+	if retention == 0 {
+		return blockRetention
+	}
+	return retention
+}
@@ -12,10 +12,14 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/tempo/pkg/model"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
+	"github.com/grafana/tempo/tempodb/encoding/vparquet"
 	"github.com/grafana/tempo/tempodb/wal"
 )
 
@@ -209,3 +213,109 @@ func TestBlockRetentionOverride(t *testing.T) {
 	rw.pollBlocklist()
 	require.Equal(t, 0, len(rw.blocklist.Metas(testTenantID)))
 }
+
+func TestRetentionRules(t *testing.T) {
+	testEncodings := []string{v2.VersionString, vparquet.VersionString}
+	for _, enc := range testEncodings {
+		t.Run(enc, func(t *testing.T) {
+			testRetentionRules(t, enc)
+		})
+	}
+}
+
+func testRetentionRules(t *testing.T, targetBlockVersion string) {
+	tempDir := t.TempDir()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              targetBlockVersion,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+			RowGroupSizeBytes:    30_000_000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10_000_000,
+		FlushSizeBytes:          10_000_000,
+		MaxCompactionRange:      24 * time.Hour,
+		CompactedBlockRetention: time.Hour,
+	}, &mockSharder{}, &mockOverrides{
+		blockRetention: time.Minute,
+		retentionRules: []RetentionRule{
+			{Class: "errors", Query: "{ status = error }", Retention: 30 * 24 * time.Hour},
+			{Class: "invalid", Query: "{ .foo = ", Retention: 30 * 24 * time.Hour},
+		},
+	})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	// two blocks with 10 traces each that ended an hour ago. the first trace of each block has an error
+	end := uint32(time.Now().Add(-time.Hour).Unix())
+	for i := 0; i < 2; i++ {
+		data := make([]testData, 0, 10)
+		for j := 0; j < 10; j++ {
+			id := test.ValidTraceID(nil)
+			tr := test.MakeTrace(2, id)
+			if j == 0 {
+				tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0].Status = &v1_trace.Status{Code: v1_trace.Status_STATUS_CODE_ERROR}
+			}
+			data = append(data, testData{id: id, t: tr, start: end - 10, end: end})
+		}
+		cutTestBlockWithTraces(t, w, testTenantID, data)
+	}
+	rw.pollBlocklist()
+
+	blocks := rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 2)
+	for _, b := range blocks {
+		require.Equal(t, "", b.RetentionClass)
+	}
+
+	// unclassified blocks may hold traces of any class and are kept for the longest retention
+	rw.doRetention()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+
+	// compaction sorts traces into blocks by retention class
+	require.NoError(t, rw.compact(blocks, testTenantID))
+
+	objectsByClass := map[string]int{}
+	for _, b := range rw.blocklist.Metas(testTenantID) {
+		objectsByClass[b.RetentionClass] += b.TotalObjects
+	}
+	require.Equal(t, map[string]int{"errors": 2, backend.RetentionClassDefault: 18}, objectsByClass)
+
+	// retention only removes the default class
+	rw.doRetention()
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 1)
+	require.Equal(t, "errors", blocks[0].RetentionClass)
+}
+
+func TestRetentionForClass(t *testing.T) {
+	rules := []RetentionRule{
+		{Class: "errors", Retention: 30 * time.Hour},
+		{Class: "errors", Retention: 40 * time.Hour},
+		{Class: "noretention"},
+	}
+
+	assert.Equal(t, time.Hour, retentionForClass(nil, "", time.Hour))
+	assert.Equal(t, 40*time.Hour, retentionForClass(rules, "", time.Hour))
+	assert.Equal(t, time.Hour, retentionForClass(rules, backend.RetentionClassDefault, time.Hour))
+	assert.Equal(t, time.Hour, retentionForClass(rules, "unknown", time.Hour))
+	assert.Equal(t, time.Hour, retentionForClass(rules, "noretention", time.Hour))
+	assert.Equal(t, 40*time.Hour, retentionForClass(rules, "errors", time.Hour))
+}
@@ -0,0 +1,204 @@
+package tempodb
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/model/trace"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+var (
+	metricCompactionTracesSampled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_traces_sampled_total",
+		Help:      "Total number of traces dropped by compaction time sampling.",
+	}, []string{"tenant"})
+	metricCompactionSpansSampled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_spans_sampled_total",
+		Help:      "Total number of spans dropped by compaction time sampling.",
+	}, []string{"tenant"})
+)
+
+// SamplingPolicy describes which traces are kept when blocks are compacted to CompactionLevel or higher.
+// Traces with errors (if KeepErrors is set) and traces lasting at least LatencyThreshold are always kept.
+// All other traces are kept with the probability SampleRate, or the rate in ServiceSampleRates of the
+// trace's root service. A SampleRate of 0 keeps all traces while a service rate of 0 drops all traces of
+// the service. Services without a rate use SampleRate. Sampling is based on the trace id and therefore
+// consistent across compactors and repeated compactions.
+type SamplingPolicy struct {
+	CompactionLevel    uint8
+	SampleRate         float64
+	ServiceSampleRates map[string]float64
+	KeepErrors         bool
+	LatencyThreshold   time.Duration
+}
+
+// appliesTo returns true if the policy samples traces in blocks of the given compaction level
+func (p SamplingPolicy) appliesTo(compactionLevel uint8) bool {
+	if p.CompactionLevel == 0 || compactionLevel < p.CompactionLevel {
+		return false
+	}
+
+	return p.SampleRate > 0 || len(p.ServiceSampleRates) > 0
+}
+
+// keep returns true if the trace is kept by the policy
+func (p SamplingPolicy) keep(id common.ID, tr *tempopb.Trace) bool {
+	if tr == nil {
+		return true
+	}
+
+	rate := p.SampleRate
+	if rate <= 0 {
+		rate = 1
+	}
+	if len(p.ServiceSampleRates) > 0 {
+		if r, ok := p.ServiceSampleRates[rootServiceName(tr)]; ok {
+			rate = r
+		}
+	}
+	if rate >= 1 {
+		return true
+	}
+
+	if p.KeepErrors && hasErrorSpan(tr) {
+		return true
+	}
+	if p.LatencyThreshold > 0 && time.Duration(common.TraceDuration(tr)) >= p.LatencyThreshold {
+		return true
+	}
+
+	return float64(util.TokenForTraceID(id)) < rate*math.MaxUint32
+}
+
+// compactionSampler applies a sampling policy to the traces of a single compaction and computes the
+// effective sample rate of its output blocks.
+type compactionSampler struct {
+	tenantID  string
+	policy    SamplingPolicy
+	sampling  bool
+	inputRate float64
+}
+
+// newCompactionSampler returns a sampler for the given input blocks. The policy is only applied if the
+// output level is high enough. Nil is returned if the output neither is sampled nor inherits a sample rate
+// from its inputs.
+func newCompactionSampler(tenantID string, policy SamplingPolicy, inputs []*backend.BlockMeta, outputLevel uint8) *compactionSampler {
+	s := &compactionSampler{
+		tenantID:  tenantID,
+		inputRate: inputSampleRate(inputs),
+	}
+
+	if policy.appliesTo(outputLevel) {
+		s.policy = policy
+		s.sampling = true
+	} else if s.inputRate == 0 {
+		return nil
+	}
+
+	return s
+}
+
+// keep returns true if the trace is kept and records sampled traces in the metrics
+func (s *compactionSampler) keep(id common.ID, tr *tempopb.Trace) bool {
+	if s.policy.keep(id, tr) {
+		return true
+	}
+
+	metricCompactionTracesSampled.WithLabelValues(s.tenantID).Inc()
+	metricCompactionSpansSampled.WithLabelValues(s.tenantID).Add(float64(spanCount(tr)))
+	return false
+}
+
+// sampleRate returns the fraction of the original traces that are written to an output block that kept
+// and dropped the given number of traces
+func (s *compactionSampler) sampleRate(kept, dropped int) float64 {
+	rate := s.inputRate
+	if rate == 0 {
+		rate = 1
+	}
+
+	if total := kept + dropped; total > 0 {
+		rate *= float64(kept) / float64(total)
+	}
+
+	return rate
+}
+
+// inputSampleRate returns the sample rate of the input blocks weighted by their objects. 0 is returned if
+// none of the inputs were sampled.
+func inputSampleRate(inputs []*backend.BlockMeta) float64 {
+	var (
+		sampled  bool
+		objects  float64
+		original float64
+	)
+
+	for _, m := range inputs {
+		rate := m.SampleRate
+		if rate > 0 {
+			sampled = true
+		} else {
+			rate = 1
+		}
+
+		objects += float64(m.TotalObjects)
+		original += float64(m.TotalObjects) / rate
+	}
+
+	if !sampled {
+		return 0
+	}
+	if original == 0 {
+		return 1
+	}
+	return objects / original
+}
+
+func rootServiceName(tr *tempopb.Trace) string {
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if len(s.ParentSpanId) == 0 && b.Resource != nil {
+					return trace.ServiceName(b)
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func hasErrorSpan(tr *tempopb.Trace) bool {
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if s.Status != nil && s.Status.Code == v1.Status_STATUS_CODE_ERROR {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func spanCount(tr *tempopb.Trace) int {
+	count := 0
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+
+	return count
+}
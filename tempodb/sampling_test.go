@@ -0,0 +1,335 @@
+package tempodb
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
+	"github.com/grafana/tempo/tempodb/encoding/vparquet"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestSamplingPolicyKeep(t *testing.T) {
+	makeTrace := func(service string, duration time.Duration, status v1_trace.Status_StatusCode) *tempopb.Trace {
+		return &tempopb.Trace{
+			Batches: []*v1_trace.ResourceSpans{
+				{
+					Resource: &v1_resource.Resource{
+						Attributes: []*v1_common.KeyValue{
+							{Key: "service.name", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}}},
+						},
+					},
+					InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+						{
+							Spans: []*v1_trace.Span{
+								{
+									StartTimeUnixNano: 1_000,
+									EndTimeUnixNano:   1_000 + uint64(duration),
+									Status:            &v1_trace.Status{Code: status},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// rates of 0.000001 drop practically every trace id
+	id := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+	tests := []struct {
+		name     string
+		policy   SamplingPolicy
+		tr       *tempopb.Trace
+		expected bool
+	}{
+		{
+			name:     "no rate keeps everything",
+			policy:   SamplingPolicy{},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: true,
+		},
+		{
+			name:     "sampled",
+			policy:   SamplingPolicy{SampleRate: 0.000001},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: false,
+		},
+		{
+			name:     "rate 1",
+			policy:   SamplingPolicy{SampleRate: 1},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: true,
+		},
+		{
+			name:     "keep errors",
+			policy:   SamplingPolicy{SampleRate: 0.000001, KeepErrors: true},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_ERROR),
+			expected: true,
+		},
+		{
+			name:     "errors dropped without keep errors",
+			policy:   SamplingPolicy{SampleRate: 0.000001},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_ERROR),
+			expected: false,
+		},
+		{
+			name:     "latency threshold",
+			policy:   SamplingPolicy{SampleRate: 0.000001, LatencyThreshold: time.Second},
+			tr:       makeTrace("foo", 2*time.Second, v1_trace.Status_STATUS_CODE_OK),
+			expected: true,
+		},
+		{
+			name:     "below latency threshold",
+			policy:   SamplingPolicy{SampleRate: 0.000001, LatencyThreshold: time.Second},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: false,
+		},
+		{
+			name:     "service rate overrides rate",
+			policy:   SamplingPolicy{SampleRate: 0.000001, ServiceSampleRates: map[string]float64{"bar": 1}},
+			tr:       makeTrace("bar", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: true,
+		},
+		{
+			name:     "service rate",
+			policy:   SamplingPolicy{ServiceSampleRates: map[string]float64{"bar": 0.000001}},
+			tr:       makeTrace("bar", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: false,
+		},
+		{
+			name:     "service rate 0 drops",
+			policy:   SamplingPolicy{ServiceSampleRates: map[string]float64{"bar": 0}},
+			tr:       makeTrace("bar", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: false,
+		},
+		{
+			name:     "service rate 0 keeps errors",
+			policy:   SamplingPolicy{ServiceSampleRates: map[string]float64{"bar": 0}, KeepErrors: true},
+			tr:       makeTrace("bar", time.Millisecond, v1_trace.Status_STATUS_CODE_ERROR),
+			expected: true,
+		},
+		{
+			name:     "services without a rate inherit the rate",
+			policy:   SamplingPolicy{SampleRate: 0.000001, ServiceSampleRates: map[string]float64{"bar": 0}},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: false,
+		},
+		{
+			name:     "other services are not sampled",
+			policy:   SamplingPolicy{ServiceSampleRates: map[string]float64{"bar": 0.000001}},
+			tr:       makeTrace("foo", time.Millisecond, v1_trace.Status_STATUS_CODE_OK),
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.policy.keep(id, tc.tr))
+		})
+	}
+}
+
+func TestInputSampleRate(t *testing.T) {
+	assert.Equal(t, 0.0, inputSampleRate([]*backend.BlockMeta{{TotalObjects: 10}, {TotalObjects: 10}}))
+	assert.Equal(t, 0.5, inputSampleRate([]*backend.BlockMeta{{TotalObjects: 10, SampleRate: 0.5}, {TotalObjects: 10, SampleRate: 0.5}}))
+	// 10 of 20 and 10 of 10 originally ingested traces
+	assert.InDelta(t, 20.0/30.0, inputSampleRate([]*backend.BlockMeta{{TotalObjects: 10, SampleRate: 0.5}, {TotalObjects: 10}}), 0.0001)
+}
+
+func TestCompactionSampling(t *testing.T) {
+	testEncodings := []string{v2.VersionString, vparquet.VersionString}
+	for _, enc := range testEncodings {
+		t.Run(enc, func(t *testing.T) {
+			testCompactionSampling(t, enc)
+		})
+	}
+}
+
+func testCompactionSampling(t *testing.T, targetBlockVersion string) {
+	tempDir := t.TempDir()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              targetBlockVersion,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+			RowGroupSizeBytes:    30_000_000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:     10_000_000,
+		FlushSizeBytes:     10_000_000,
+		MaxCompactionRange: 24 * time.Hour,
+	}, &mockSharder{}, &mockOverrides{
+		samplingPolicy: SamplingPolicy{
+			CompactionLevel: 2,
+			SampleRate:      0.5,
+			KeepErrors:      true,
+		},
+	})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	// 4 blocks with 50 traces each. every tenth trace has an error
+	for i := 0; i < 4; i++ {
+		data := make([]testData, 0, 50)
+		for j := 0; j < 50; j++ {
+			id := test.ValidTraceID(nil)
+			tr := test.MakeTrace(1, id)
+			if j%10 == 0 {
+				tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0].Status = &v1_trace.Status{Code: v1_trace.Status_STATUS_CODE_ERROR}
+			}
+			data = append(data, testData{id: id, t: tr})
+		}
+		cutTestBlockWithTraces(t, w, testTenantID, data)
+	}
+	rw.pollBlocklist()
+
+	blocks := rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 4)
+
+	// level 1 is below the policy level and keeps all traces
+	require.NoError(t, rw.compact(blocks[:2], testTenantID))
+	require.NoError(t, rw.compact(blocks[2:], testTenantID))
+
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 2)
+	for _, b := range blocks {
+		require.Equal(t, 100, b.TotalObjects)
+		require.Equal(t, 0.0, b.SampleRate)
+	}
+
+	// level 2 samples
+	require.NoError(t, rw.compact(blocks, testTenantID))
+
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 1)
+	sampled := blocks[0]
+	require.Less(t, sampled.TotalObjects, 200)
+	require.Greater(t, sampled.TotalObjects, 20) // all errors are kept
+	require.InDelta(t, float64(sampled.TotalObjects)/200, sampled.SampleRate, 0.0001)
+
+	// the sample rate is carried over in later compactions
+	rate := sampled.SampleRate
+	require.NoError(t, rw.compact(blocks, testTenantID))
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 1)
+	require.Equal(t, sampled.TotalObjects, blocks[0].TotalObjects)
+	require.InDelta(t, rate, blocks[0].SampleRate, 0.0001)
+}
+
+func TestCompactionSamplingByClass(t *testing.T) {
+	testEncodings := []string{v2.VersionString, vparquet.VersionString}
+	for _, enc := range testEncodings {
+		t.Run(enc, func(t *testing.T) {
+			testCompactionSamplingByClass(t, enc)
+		})
+	}
+}
+
+func testCompactionSamplingByClass(t *testing.T, targetBlockVersion string) {
+	tempDir := t.TempDir()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              targetBlockVersion,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+			RowGroupSizeBytes:    30_000_000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:     10_000_000,
+		FlushSizeBytes:     10_000_000,
+		MaxCompactionRange: 24 * time.Hour,
+	}, &mockSharder{}, &mockOverrides{
+		samplingPolicy: SamplingPolicy{
+			CompactionLevel: 1,
+			SampleRate:      0.5,
+			KeepErrors:      true,
+		},
+		retentionRules: []RetentionRule{
+			{Class: "errors", Query: "{ status = error }", Retention: 30 * 24 * time.Hour},
+		},
+	})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	// 2 blocks with 50 traces each. every tenth trace has an error
+	for i := 0; i < 2; i++ {
+		data := make([]testData, 0, 50)
+		for j := 0; j < 50; j++ {
+			id := test.ValidTraceID(nil)
+			tr := test.MakeTrace(1, id)
+			if j%10 == 0 {
+				tr.Batches[0].InstrumentationLibrarySpans[0].Spans[0].Status = &v1_trace.Status{Code: v1_trace.Status_STATUS_CODE_ERROR}
+			}
+			data = append(data, testData{id: id, t: tr})
+		}
+		cutTestBlockWithTraces(t, w, testTenantID, data)
+	}
+	rw.pollBlocklist()
+
+	blocks := rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 2)
+	require.NoError(t, rw.compact(blocks, testTenantID))
+
+	// each output block records the sample rate of its own traces
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 2)
+	for _, b := range blocks {
+		switch b.RetentionClass {
+		case "errors":
+			require.Equal(t, 10, b.TotalObjects)
+			require.Equal(t, 1.0, b.SampleRate)
+		case backend.RetentionClassDefault:
+			require.Less(t, b.TotalObjects, 90)
+			require.InDelta(t, float64(b.TotalObjects)/90, b.SampleRate, 0.0001)
+		default:
+			require.FailNow(t, "unexpected retention class", b.RetentionClass)
+		}
+	}
+}
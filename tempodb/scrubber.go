@@ -0,0 +1,143 @@
+package tempodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	DefaultScrubInterval          = 10 * time.Minute
+	DefaultScrubMaxBlocksPerCycle = 10
+	DefaultScrubReverifyPeriod    = 7 * 24 * time.Hour
+
+	scrubResultOK      = "ok"
+	scrubResultCorrupt = "corrupt"
+	scrubResultFailed  = "failed"
+)
+
+var (
+	metricScrubbedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "scrubber_blocks_total",
+		Help:      "Total number of blocks verified by the scrubber by result.",
+	}, []string{"result"})
+	metricQuarantinedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "scrubber_quarantined_blocks_total",
+		Help:      "Total number of corrupt blocks quarantined by the scrubber.",
+	}, []string{"tenant"})
+)
+
+// ScrubberConfig configures the background verification of blocks owned by the compactor. Every cycle
+// verifies up to MaxBlocksPerCycle blocks that haven't been verified within ReverifyPeriod.
+type ScrubberConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Interval          time.Duration `yaml:"interval"`
+	MaxBlocksPerCycle int           `yaml:"max_blocks_per_cycle"`
+	ReverifyPeriod    time.Duration `yaml:"reverify_period"`
+	// Quarantine corrupt blocks. If false corrupt blocks are only reported.
+	Quarantine bool `yaml:"quarantine"`
+}
+
+// scrubber remembers when blocks were last verified
+type scrubber struct {
+	mtx      sync.Mutex
+	verified map[uuid.UUID]time.Time
+}
+
+func newScrubber() *scrubber {
+	return &scrubber{
+		verified: map[uuid.UUID]time.Time{},
+	}
+}
+
+func (rw *readerWriter) scrubLoop() {
+	ticker := time.NewTicker(rw.compactorCfg.Scrubber.Interval)
+	for range ticker.C {
+		rw.doScrub(context.Background(), time.Now())
+	}
+}
+
+// doScrub verifies the owned blocks that are due
+func (rw *readerWriter) doScrub(ctx context.Context, now time.Time) {
+	cfg := rw.compactorCfg.Scrubber
+
+	rw.scrubber.mtx.Lock()
+	defer rw.scrubber.mtx.Unlock()
+
+	due := []*backend.BlockMeta{}
+	known := map[uuid.UUID]struct{}{}
+	for _, tenantID := range rw.blocklist.Tenants() {
+		for _, meta := range rw.blocklist.Metas(tenantID) {
+			known[meta.BlockID] = struct{}{}
+
+			if !rw.compactorSharder.Owns(meta.BlockID.String()) {
+				continue
+			}
+			if verifiedAt, ok := rw.scrubber.verified[meta.BlockID]; ok && now.Sub(verifiedAt) < cfg.ReverifyPeriod {
+				continue
+			}
+			due = append(due, meta)
+		}
+	}
+
+	// forget blocks that are gone
+	for id := range rw.scrubber.verified {
+		if _, ok := known[id]; !ok {
+			delete(rw.scrubber.verified, id)
+		}
+	}
+
+	if len(due) > cfg.MaxBlocksPerCycle {
+		due = due[:cfg.MaxBlocksPerCycle]
+	}
+
+	for _, meta := range due {
+		if rw.scrubBlock(ctx, meta) {
+			rw.scrubber.verified[meta.BlockID] = now
+		}
+	}
+}
+
+// scrubBlock verifies the block and quarantines it if it is corrupt. Returns true if the block was verified.
+func (rw *readerWriter) scrubBlock(ctx context.Context, meta *backend.BlockMeta) bool {
+	// read through the uncached reader so that the backend is verified and not the cache
+	err := VerifyBlock(ctx, rw.uncachedReader, meta)
+	if err == nil {
+		metricScrubbedBlocks.WithLabelValues(scrubResultOK).Inc()
+		return true
+	}
+
+	if !errors.Is(err, ErrBlockCorrupt) {
+		level.Warn(rw.logger).Log("msg", "failed to verify block", "blockID", meta.BlockID, "tenantID", meta.TenantID, "err", err)
+		metricScrubbedBlocks.WithLabelValues(scrubResultFailed).Inc()
+		return false
+	}
+
+	metricScrubbedBlocks.WithLabelValues(scrubResultCorrupt).Inc()
+	level.Error(rw.logger).Log("msg", "block is corrupt", "blockID", meta.BlockID, "tenantID", meta.TenantID, "err", err)
+
+	if !rw.compactorCfg.Scrubber.Quarantine {
+		return true
+	}
+
+	if err := QuarantineBlock(ctx, rw.w, meta, err.Error()); err != nil {
+		level.Error(rw.logger).Log("msg", "failed to quarantine block", "blockID", meta.BlockID, "tenantID", meta.TenantID, "err", err)
+		return false
+	}
+
+	level.Info(rw.logger).Log("msg", "quarantined block", "blockID", meta.BlockID, "tenantID", meta.TenantID)
+	metricQuarantinedBlocks.WithLabelValues(meta.TenantID).Inc()
+	rw.blocklist.Update(meta.TenantID, nil, []*backend.BlockMeta{meta}, nil, nil)
+
+	return true
+}
@@ -0,0 +1,60 @@
+package tempodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestScrubber(t *testing.T) {
+	r, w, c, tempDir := testConfig(t, backend.EncNone, 0)
+	rw := r.(*readerWriter)
+	ctx := context.Background()
+
+	c.EnableCompaction(&CompactorConfig{
+		Scrubber: ScrubberConfig{
+			Enabled:           true,
+			MaxBlocksPerCycle: 2,
+			Quarantine:        true,
+		},
+	}, &mockSharder{}, &mockOverrides{})
+	r.EnablePolling(&mockJobSharder{})
+
+	blocks := cutTestBlocks(t, w, testTenantID, 3, 10)
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 3)
+
+	// every block is verified once within the reverify period
+	now := time.Now()
+	rw.doScrub(ctx, now)
+	assert.Len(t, rw.scrubber.verified, 2)
+	rw.doScrub(ctx, now)
+	assert.Len(t, rw.scrubber.verified, 3)
+
+	// corrupt blocks are quarantined once they are due again
+	corrupt, err := rw.r.BlockMeta(ctx, blocks[0].BlockMeta().BlockID, testTenantID)
+	require.NoError(t, err)
+	corruptObject(t, tempDir, corrupt, "data.parquet")
+
+	rw.doScrub(ctx, now.Add(time.Hour))
+	assert.Len(t, rw.blocklist.Metas(testTenantID), 3)
+
+	rw.doScrub(ctx, now.Add(DefaultScrubReverifyPeriod))
+	rw.doScrub(ctx, now.Add(DefaultScrubReverifyPeriod))
+	for _, m := range rw.blocklist.Metas(testTenantID) {
+		assert.NotEqual(t, corrupt.BlockID, m.BlockID)
+	}
+
+	// and stay out of the blocklist
+	rw.pollBlocklist()
+	assert.Len(t, rw.blocklist.Metas(testTenantID), 2)
+
+	// blocks that are gone are forgotten
+	rw.doScrub(ctx, now.Add(DefaultScrubReverifyPeriod))
+	assert.Len(t, rw.scrubber.verified, 2)
+}
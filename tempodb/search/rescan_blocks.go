@@ -7,14 +7,17 @@ import (
 	"time"
 
 	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+
 	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/util/log"
 	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
 )
 
-// RescanBlocks scans through the search directory in the WAL folder and replays files
+// RescanBlocks scans through the search directory in the WAL folder and replays the files of the given blocks. Files
+// of other blocks, e.g. of head blocks created after the ids were listed, are left alone.
 // todo: copied from wal.RescanBlocks(), see if we can reduce duplication?
-func RescanBlocks(walPath string) ([]*StreamingSearchBlock, error) {
+func RescanBlocks(walPath string, blockIDs []uuid.UUID) ([]*StreamingSearchBlock, error) {
 	searchFilepath := filepath.Join(walPath, "search")
 	files, err := os.ReadDir(searchFilepath)
 	if err != nil {
@@ -23,8 +26,20 @@ func RescanBlocks(walPath string) ([]*StreamingSearchBlock, error) {
 		return nil, nil
 	}
 
+	replay := make(map[uuid.UUID]struct{}, len(blockIDs))
+	for _, id := range blockIDs {
+		replay[id] = struct{}{}
+	}
+
 	blocks := make([]*StreamingSearchBlock, 0, len(files))
 	for _, f := range files {
+		// files that are not search wal files fail to replay below and are removed
+		if id, _, _, _, _, err := v2.ParseFilename(f.Name()); err == nil {
+			if _, ok := replay[id]; !ok {
+				continue
+			}
+		}
+
 		info, err := f.Info()
 
 		if err != nil {
@@ -22,6 +22,7 @@ type Results struct {
 	bytesInspected  atomic.Uint64
 	blocksInspected atomic.Uint32
 	blocksSkipped   atomic.Uint32
+	tracesSkipped   atomic.Uint32
 }
 
 func NewResults() *Results {
@@ -138,3 +139,12 @@ func (sr *Results) AddBlockSkipped() {
 func (sr *Results) BlocksSkipped() uint32 {
 	return sr.blocksSkipped.Load()
 }
+
+// AddTraceSkipped records a trace that was not searched, e.g. because of a limit. The results are partial.
+func (sr *Results) AddTraceSkipped() {
+	sr.tracesSkipped.Inc()
+}
+
+func (sr *Results) TracesSkipped() uint32 {
+	return sr.tracesSkipped.Load()
+}
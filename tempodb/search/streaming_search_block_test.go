@@ -69,8 +69,13 @@ func TestStreamingSearchBlockReplay(t *testing.T) {
 			// close the old file handler
 			assert.NoError(t, sb.Close())
 
+			// files of other blocks are left alone
+			blocks, err := RescanBlocks(walDir, []uuid.UUID{uuid.New()})
+			assert.NoError(t, err)
+			require.Len(t, blocks, 0)
+
 			// create new block from the same wal file
-			blocks, err := RescanBlocks(walDir)
+			blocks, err = RescanBlocks(walDir, []uuid.UUID{uuid.MustParse("1c505e8b-26cd-4621-ba7d-792bb55282d5")})
 			assert.NoError(t, err)
 			require.Len(t, blocks, 1)
 			assert.Equal(t, traceCount, len(blocks[0].appender.Records()))
@@ -59,4 +59,7 @@ func CombineSearchResults(existing *tempopb.TraceSearchMetadata, incoming *tempo
 	if existing.DurationMs < incoming.DurationMs {
 		existing.DurationMs = incoming.DurationMs
 	}
+
+	// Complete if any source saw the complete trace
+	existing.Complete = existing.Complete || incoming.Complete
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"os"
 	"time"
 
 	gkLog "github.com/go-kit/log"
@@ -21,8 +22,10 @@ import (
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/azure"
 	"github.com/grafana/tempo/tempodb/backend/cache"
+	"github.com/grafana/tempo/tempodb/backend/cache/disk"
 	"github.com/grafana/tempo/tempodb/backend/cache/memcached"
 	"github.com/grafana/tempo/tempodb/backend/cache/redis"
+	"github.com/grafana/tempo/tempodb/backend/encryption"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/backend/s3"
@@ -71,6 +74,13 @@ type Writer interface {
 	CompleteBlockWithBackend(ctx context.Context, block common.WALBlock, r backend.Reader, w backend.Writer) (common.BackendBlock, error)
 	CompleteSearchBlockWithBackend(block *search.StreamingSearchBlock, blockID uuid.UUID, tenantID string, r backend.Reader, w backend.Writer) (*search.BackendSearchBlock, error)
 	WAL() *wal.WAL
+	SetBlocklistNotifier(n BlocklistNotifier)
+}
+
+// BlocklistNotifier is told about blocks that were added to or compacted in the backend by this process
+type BlocklistNotifier interface {
+	BlocksAdded(tenantID string, metas []*backend.BlockMeta)
+	BlocksCompacted(tenantID string, metas []*backend.CompactedBlockMeta)
 }
 
 type IterateObjectCallback func(id common.ID, obj []byte) bool
@@ -79,13 +89,18 @@ type Reader interface {
 	Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, timeStart int64, timeEnd int64) ([]*tempopb.Trace, []error, error)
 	Search(ctx context.Context, meta *backend.BlockMeta, req *tempopb.SearchRequest, opts common.SearchOptions) (*tempopb.SearchResponse, error)
 	BlockMetas(tenantID string) []*backend.BlockMeta
+	CompactedBlockMetas(tenantID string) []*backend.CompactedBlockMeta
 	EnablePolling(sharder blocklist.JobSharder)
+	UpdateBlocklist(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta)
 
 	Shutdown()
 }
 
 type Compactor interface {
 	EnableCompaction(cfg *CompactorConfig, sharder CompactorSharder, overrides CompactorOverrides)
+	AddDeletionRequest(ctx context.Context, req *backend.DeletionRequest) error
+	DeletionRequests(ctx context.Context, tenantID string) ([]*backend.DeletionRequest, error)
+	CompactionJobs(ctx context.Context, tenantID string) ([]*CompactionJobState, error)
 }
 
 type CompactorSharder interface {
@@ -97,6 +112,9 @@ type CompactorSharder interface {
 type CompactorOverrides interface {
 	BlockRetentionForTenant(tenantID string) time.Duration
 	MaxBytesPerTraceForTenant(tenantID string) int
+	BlockRetentionRulesForTenant(tenantID string) []RetentionRule
+	CompactionSamplingPolicyForTenant(tenantID string) SamplingPolicy
+	BlockSelectorForTenant(tenantID string) string
 }
 
 type WriteableBlock interface {
@@ -112,19 +130,27 @@ type readerWriter struct {
 	uncachedReader backend.Reader
 	uncachedWriter backend.Writer
 
+	// set if tiering is enabled
+	coldReader    backend.Reader
+	coldWriter    backend.Writer
+	coldCompactor backend.Compactor
+
 	wal  *wal.WAL
 	pool *pool.Pool
 
 	logger gkLog.Logger
 	cfg    *Config
 
-	blocklistPoller *blocklist.Poller
-	blocklist       *blocklist.List
+	blocklistPoller   *blocklist.Poller
+	blocklist         *blocklist.List
+	blocklistNotifier BlocklistNotifier
 
-	compactorCfg          *CompactorConfig
-	compactorSharder      CompactorSharder
-	compactorOverrides    CompactorOverrides
-	compactorTenantOffset uint
+	compactorCfg       *CompactorConfig
+	compactorSharder   CompactorSharder
+	compactorOverrides CompactorOverrides
+	compactorScheduler *compactionScheduler
+	compactorLeases    *compactionLeases
+	scrubber           *scrubber
 }
 
 // New creates a new tempodb
@@ -138,25 +164,29 @@ func New(cfg *Config, logger gkLog.Logger) (Reader, Writer, Compactor, error) {
 		return nil, nil, nil, fmt.Errorf("invalid config while creating tempodb: %w", err)
 	}
 
-	switch cfg.Backend {
-	case "local":
-		rawR, rawW, c, err = local.New(cfg.Local)
-	case "gcs":
-		rawR, rawW, c, err = gcs.New(cfg.GCS)
-	case "s3":
-		rawR, rawW, c, err = s3.New(cfg.S3)
-	case "azure":
-		rawR, rawW, c, err = azure.New(cfg.Azure)
-	default:
-		err = fmt.Errorf("unknown backend %s", cfg.Backend)
-	}
-
+	rawR, rawW, c, err = newRawBackend(cfg.Backend, cfg.Local, cfg.GCS, cfg.S3, cfg.Azure)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	uncachedReader := backend.NewReader(rawR)
-	uncachedWriter := backend.NewWriter(rawW)
+	// encryption wraps the cache so that only encrypted data leaves the process
+	var keys encryption.KeyProvider
+	if cfg.Encryption.Enabled() {
+		keys, err = encryption.NewKeyProvider(cfg.Encryption)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	uncachedRawR, uncachedRawW := rawR, rawW
+	if keys != nil {
+		uncachedRawR, uncachedRawW, err = encryption.NewEncryption(rawR, rawW, keys, cfg.Encryption.ChunkSizeBytes)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	uncachedReader := backend.NewReader(uncachedRawR)
+	uncachedWriter := backend.NewWriter(uncachedRawW)
 
 	var cacheBackend pkg_cache.Cache
 
@@ -165,6 +195,11 @@ func New(cfg *Config, logger gkLog.Logger) (Reader, Writer, Compactor, error) {
 		cacheBackend = redis.NewClient(cfg.Redis, cfg.BackgroundCache, logger)
 	case "memcached":
 		cacheBackend = memcached.NewClient(cfg.Memcached, cfg.BackgroundCache, logger)
+	case "disk":
+		cacheBackend, err = disk.NewClient(cfg.Disk, cfg.BackgroundCache, logger)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
 	if cacheBackend != nil {
@@ -174,13 +209,47 @@ func New(cfg *Config, logger gkLog.Logger) (Reader, Writer, Compactor, error) {
 		}
 	}
 
+	if keys != nil {
+		rawR, rawW, err = encryption.NewEncryption(rawR, rawW, keys, cfg.Encryption.ChunkSizeBytes)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	r := backend.NewReader(rawR)
 	w := backend.NewWriter(rawW)
+
+	// cold tier, reads of block objects fall back to it
+	var coldReader backend.Reader
+	var coldWriter backend.Writer
+	var coldCompactor backend.Compactor
+	if cfg.Tiering.Enabled {
+		coldRawR, coldRawW, coldC, err := newRawBackend(cfg.Tiering.Backend, cfg.Tiering.Local, cfg.Tiering.GCS, cfg.Tiering.S3, cfg.Tiering.Azure)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error creating cold tier backend: %w", err)
+		}
+		if keys != nil {
+			coldRawR, coldRawW, err = encryption.NewEncryption(coldRawR, coldRawW, keys, cfg.Encryption.ChunkSizeBytes)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		coldReader, coldWriter, coldCompactor = backend.NewReader(coldRawR), backend.NewWriter(coldRawW), coldC
+
+		if !cfg.Tiering.RewriteInPlace {
+			r = NewTieredReader(r, coldReader)
+			uncachedReader = NewTieredReader(uncachedReader, coldReader)
+		}
+	}
+
 	rw := &readerWriter{
 		c:              c,
 		r:              r,
 		uncachedReader: uncachedReader,
 		uncachedWriter: uncachedWriter,
+		coldReader:     coldReader,
+		coldWriter:     coldWriter,
+		coldCompactor:  coldCompactor,
 		w:              w,
 		cfg:            cfg,
 		logger:         logger,
@@ -196,9 +265,31 @@ func New(cfg *Config, logger gkLog.Logger) (Reader, Writer, Compactor, error) {
 	return rw, rw, rw, nil
 }
 
+// newRawBackend creates the raw reader, writer and compactor of the named backend
+func newRawBackend(name string, localCfg *local.Config, gcsCfg *gcs.Config, s3Cfg *s3.Config, azureCfg *azure.Config) (backend.RawReader, backend.RawWriter, backend.Compactor, error) {
+	switch name {
+	case "local":
+		return local.New(localCfg)
+	case "gcs":
+		return gcs.New(gcsCfg)
+	case "s3":
+		return s3.New(s3Cfg)
+	case "azure":
+		return azure.New(azureCfg)
+	}
+
+	return nil, nil, nil, fmt.Errorf("unknown backend %s", name)
+}
+
 func (rw *readerWriter) WriteBlock(ctx context.Context, c WriteableBlock) error {
 	w := rw.getWriterForBlock(c.BlockMeta(), time.Now())
-	return c.Write(ctx, w)
+	err := c.Write(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	rw.notifyBlocksAdded(c.BlockMeta().TenantID, []*backend.BlockMeta{c.BlockMeta()})
+	return nil
 }
 
 // CompleteBlock iterates the given WAL block and flushes it to the TempoDB backend.
@@ -268,6 +359,10 @@ func (rw *readerWriter) BlockMetas(tenantID string) []*backend.BlockMeta {
 	return rw.blocklist.Metas(tenantID)
 }
 
+func (rw *readerWriter) CompactedBlockMetas(tenantID string) []*backend.CompactedBlockMeta {
+	return rw.blocklist.CompactedMetas(tenantID)
+}
+
 func (rw *readerWriter) Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, timeStart int64, timeEnd int64) ([]*tempopb.Trace, []error, error) {
 	// tracing instrumentation
 	logger := log.WithContext(ctx, log.Logger)
@@ -354,7 +449,7 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id common.ID,
 // Search the given block.  This method takes the pre-loaded block meta instead of a block ID, which
 // eliminates a read per search request.
 func (rw *readerWriter) Search(ctx context.Context, meta *backend.BlockMeta, req *tempopb.SearchRequest, opts common.SearchOptions) (*tempopb.SearchResponse, error) {
-	block, err := encoding.OpenBlock(meta, rw.r)
+	block, err := encoding.OpenBlock(meta, rw.readerForTier(meta, rw.r))
 	if err != nil {
 		return nil, err
 	}
@@ -377,8 +472,39 @@ func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharde
 	}
 
 	rw.compactorCfg = cfg
+	rw.compactorScheduler = newCompactionScheduler(cfg.TenantScheduling)
 	rw.compactorSharder = c
 	rw.compactorOverrides = overrides
+	rw.scrubber = newScrubber()
+
+	if rw.cfg.Tiering.Enabled && rw.cfg.Tiering.MaxBlocksPerCycle == 0 {
+		rw.cfg.Tiering.MaxBlocksPerCycle = DefaultTieringMaxBlocksPerCycle
+	}
+	if rw.cfg.Tiering.Enabled && rw.cfg.Tiering.RecoveryInterval == 0 {
+		rw.cfg.Tiering.RecoveryInterval = DefaultTieringRecoveryInterval
+	}
+
+	if cfg.Scrubber.Enabled {
+		if cfg.Scrubber.Interval == 0 {
+			cfg.Scrubber.Interval = DefaultScrubInterval
+		}
+		if cfg.Scrubber.MaxBlocksPerCycle == 0 {
+			cfg.Scrubber.MaxBlocksPerCycle = DefaultScrubMaxBlocksPerCycle
+		}
+		if cfg.Scrubber.ReverifyPeriod == 0 {
+			cfg.Scrubber.ReverifyPeriod = DefaultScrubReverifyPeriod
+		}
+	}
+
+	if cfg.JobQueue.Enabled {
+		if cfg.JobQueue.LeaseDuration == 0 {
+			cfg.JobQueue.LeaseDuration = DefaultCompactionJobLeaseDuration
+		}
+		if cfg.JobQueue.InstanceID == "" {
+			cfg.JobQueue.InstanceID, _ = os.Hostname()
+		}
+		rw.compactorLeases = newCompactionLeases(cfg.JobQueue.InstanceID, rw.r, rw.w)
+	}
 
 	if rw.cfg.BlocklistPoll == 0 {
 		level.Info(rw.logger).Log("msg", "polling cycle unset. compaction and retention disabled")
@@ -389,6 +515,17 @@ func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharde
 		level.Info(rw.logger).Log("msg", "compaction and retention enabled.")
 		go rw.compactionLoop()
 		go rw.retentionLoop()
+		go rw.deletionLoop()
+
+		if cfg.Scrubber.Enabled {
+			level.Info(rw.logger).Log("msg", "block scrubber enabled.")
+			go rw.scrubLoop()
+		}
+
+		if rw.cfg.Tiering.Enabled {
+			level.Info(rw.logger).Log("msg", "block tiering enabled.", "backend", rw.cfg.Tiering.Backend)
+			go rw.tieringLoop()
+		}
 	}
 }
 
@@ -431,6 +568,38 @@ func (rw *readerWriter) EnablePolling(sharder blocklist.JobSharder) {
 	go rw.pollingLoop()
 }
 
+// SetBlocklistNotifier sets the notifier that is told about blocks added or compacted by this process.
+// It must be called before any blocks are written.
+func (rw *readerWriter) SetBlocklistNotifier(n BlocklistNotifier) {
+	rw.blocklistNotifier = n
+}
+
+// UpdateBlocklist applies blocks added or compacted by other components to the in-memory blocklist. Like
+// local changes they are kept for one additional polling cycle, after which polling is authoritative again.
+func (rw *readerWriter) UpdateBlocklist(tenantID string, added []*backend.BlockMeta, compacted []*backend.CompactedBlockMeta) {
+	removed := make([]*backend.BlockMeta, 0, len(compacted))
+	for _, c := range compacted {
+		meta := c.BlockMeta
+		removed = append(removed, &meta)
+	}
+
+	rw.blocklist.Update(tenantID, added, removed, compacted, nil)
+}
+
+func (rw *readerWriter) notifyBlocksAdded(tenantID string, metas []*backend.BlockMeta) {
+	if rw.blocklistNotifier == nil || len(metas) == 0 {
+		return
+	}
+	rw.blocklistNotifier.BlocksAdded(tenantID, metas)
+}
+
+func (rw *readerWriter) notifyBlocksCompacted(tenantID string, metas []*backend.CompactedBlockMeta) {
+	if rw.blocklistNotifier == nil || len(metas) == 0 {
+		return
+	}
+	rw.blocklistNotifier.BlocksCompacted(tenantID, metas)
+}
+
 func (rw *readerWriter) pollingLoop() {
 	ticker := time.NewTicker(rw.cfg.BlocklistPoll)
 	for range ticker.C {
@@ -464,6 +633,11 @@ func (rw *readerWriter) shouldCache(meta *backend.BlockMeta, curTime time.Time)
 }
 
 func (rw *readerWriter) getReaderForBlock(meta *backend.BlockMeta, curTime time.Time) backend.Reader {
+	// blocks in the cold tier are not cached
+	if meta.Tier == backend.TierCold && rw.coldReader != nil {
+		return rw.coldReader
+	}
+
 	if rw.shouldCache(meta, curTime) {
 		return rw.r
 	}
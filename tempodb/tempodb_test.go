@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/blocklist"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
 	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
@@ -779,3 +780,52 @@ func BenchmarkCompleteBlock(b *testing.B) {
 		require.NoError(b, err)
 	}
 }
+
+type mockNotifier struct {
+	added     []*backend.BlockMeta
+	compacted []*backend.CompactedBlockMeta
+}
+
+func (m *mockNotifier) BlocksAdded(tenantID string, metas []*backend.BlockMeta) {
+	m.added = append(m.added, metas...)
+}
+
+func (m *mockNotifier) BlocksCompacted(tenantID string, metas []*backend.CompactedBlockMeta) {
+	m.compacted = append(m.compacted, metas...)
+}
+
+type mockWriteableBlock struct {
+	meta *backend.BlockMeta
+}
+
+func (m *mockWriteableBlock) BlockMeta() *backend.BlockMeta                     { return m.meta }
+func (m *mockWriteableBlock) Write(ctx context.Context, w backend.Writer) error { return nil }
+
+func TestBlocklistNotifier(t *testing.T) {
+	r, w, _, _ := testConfig(t, backend.EncNone, 0)
+	rw := r.(*readerWriter)
+
+	notifier := &mockNotifier{}
+	w.SetBlocklistNotifier(notifier)
+
+	// written blocks are announced
+	written := backend.NewBlockMeta(testTenantID, uuid.New(), "v2", backend.EncNone, "")
+	require.NoError(t, w.WriteBlock(context.Background(), &mockWriteableBlock{meta: written}))
+	require.Equal(t, []*backend.BlockMeta{written}, notifier.added)
+
+	// compactions are announced
+	old := backend.NewBlockMeta(testTenantID, uuid.New(), "v2", backend.EncNone, "")
+	markCompacted(rw, testTenantID, []*backend.BlockMeta{old}, []*backend.BlockMeta{written})
+	require.Len(t, notifier.added, 2)
+	require.Len(t, notifier.compacted, 1)
+	require.Equal(t, old.BlockID, notifier.compacted[0].BlockID)
+
+	// and can be applied by other readers
+	r2, _, _, _ := testConfig(t, backend.EncNone, 0)
+	rw2 := r2.(*readerWriter)
+	rw2.blocklist.ApplyPollResults(blocklist.PerTenant{testTenantID: {old}}, blocklist.PerTenantCompacted{})
+
+	r2.UpdateBlocklist(testTenantID, []*backend.BlockMeta{written}, notifier.compacted)
+	require.Equal(t, []*backend.BlockMeta{written}, rw2.blocklist.Metas(testTenantID))
+	require.Len(t, rw2.blocklist.CompactedMetas(testTenantID), 1)
+}
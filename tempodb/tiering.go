@@ -0,0 +1,326 @@
+package tempodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/gcs"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/backend/s3"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const (
+	DefaultTieringMaxBlocksPerCycle = 10
+	DefaultTieringRecoveryInterval  = 24 * time.Hour
+)
+
+var (
+	metricTieringBlocksMoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tiering_blocks_moved_total",
+		Help:      "Total number of blocks moved to the cold tier.",
+	}, []string{"tenant"})
+	metricTieringBytesMoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tiering_bytes_moved_total",
+		Help:      "Total number of bytes of the data objects of blocks moved to the cold tier.",
+	}, []string{"tenant"})
+	metricTieringBlocksRecovered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tiering_blocks_recovered_total",
+		Help:      "Total number of cold blocks whose meta was restored in the primary backend after an interrupted move.",
+	})
+	metricTieringErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "tiering_errors_total",
+		Help:      "Total number of errors occurring while moving blocks to the cold tier.",
+	})
+)
+
+// TieringConfig configures moving old blocks to a cold backend. Blocks are moved by the compactor that owns them
+// once they are older than MinBlockAge or have reached MinCompactionLevel. Metas and tenant indexes always stay in
+// the primary backend, the tier of a block is recorded in its meta.
+type TieringConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	MinBlockAge        time.Duration `yaml:"min_block_age"`
+	MinCompactionLevel uint8         `yaml:"min_compaction_level"`
+	MaxBlocksPerCycle  int           `yaml:"max_blocks_per_cycle"`
+	// RecoveryInterval is how often the cold backend is listed for blocks whose move was interrupted
+	RecoveryInterval time.Duration `yaml:"recovery_interval"`
+	// RewriteInPlace rewrites the objects of a block at their current location through the cold backend instead
+	// of moving them. Use it with a cold s3 backend pointing to the same bucket with a different storage class.
+	RewriteInPlace bool `yaml:"rewrite_in_place"`
+
+	// cold backend
+	Backend string        `yaml:"backend"`
+	Local   *local.Config `yaml:"local"`
+	GCS     *gcs.Config   `yaml:"gcs"`
+	S3      *s3.Config    `yaml:"s3"`
+	Azure   *azure.Config `yaml:"azure"`
+}
+
+func validateTieringConfig(cfg *TieringConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.MinBlockAge <= 0 && cfg.MinCompactionLevel == 0 {
+		return errors.New("tiering requires min_block_age or min_compaction_level")
+	}
+
+	if cfg.RewriteInPlace && cfg.Backend != "s3" {
+		return errors.New("tiering can only rewrite blocks in place with the s3 backend")
+	}
+
+	return nil
+}
+
+// readerForTier returns the cold reader for blocks in the cold tier and r otherwise
+func (rw *readerWriter) readerForTier(meta *backend.BlockMeta, r backend.Reader) backend.Reader {
+	if meta.Tier == backend.TierCold && rw.coldReader != nil {
+		return rw.coldReader
+	}
+	return r
+}
+
+// tieredReader reads the objects of blocks from the cold backend if they don't exist in the primary backend. This
+// keeps reads working for metas that don't carry the tier, like the ones rebuilt from search requests, and for
+// blocks moved since the last poll. Metas are never read from the cold backend.
+type tieredReader struct {
+	backend.Reader
+	cold backend.Reader
+}
+
+// NewTieredReader returns a reader that reads the objects of blocks from cold if they don't exist in hot
+func NewTieredReader(hot, cold backend.Reader) backend.Reader {
+	return &tieredReader{
+		Reader: hot,
+		cold:   cold,
+	}
+}
+
+func (r *tieredReader) Read(ctx context.Context, name string, blockID uuid.UUID, tenantID string, shouldCache bool) ([]byte, error) {
+	b, err := r.Reader.Read(ctx, name, blockID, tenantID, shouldCache)
+	if fallbackToCold(name, err) {
+		return r.cold.Read(ctx, name, blockID, tenantID, shouldCache)
+	}
+	return b, err
+}
+
+func (r *tieredReader) StreamReader(ctx context.Context, name string, blockID uuid.UUID, tenantID string) (io.ReadCloser, int64, error) {
+	rc, size, err := r.Reader.StreamReader(ctx, name, blockID, tenantID)
+	if fallbackToCold(name, err) {
+		return r.cold.StreamReader(ctx, name, blockID, tenantID)
+	}
+	return rc, size, err
+}
+
+func (r *tieredReader) ReadRange(ctx context.Context, name string, blockID uuid.UUID, tenantID string, offset uint64, buffer []byte, shouldCache bool) error {
+	err := r.Reader.ReadRange(ctx, name, blockID, tenantID, offset, buffer, shouldCache)
+	if fallbackToCold(name, err) {
+		return r.cold.ReadRange(ctx, name, blockID, tenantID, offset, buffer, shouldCache)
+	}
+	return err
+}
+
+func fallbackToCold(name string, err error) bool {
+	if !errors.Is(err, backend.ErrDoesNotExist) {
+		return false
+	}
+	return name != backend.MetaName && name != backend.CompactedMetaName
+}
+
+// tieringLoop moves blocks every blocklist poll. Interrupted moves are recovered on the first cycle, once the
+// blocklist is populated, and every RecoveryInterval after that since it lists the whole cold backend.
+// todo: pass a context/chan in to cancel this cleanly
+func (rw *readerWriter) tieringLoop() {
+	var lastRecovery time.Time
+
+	ticker := time.NewTicker(rw.cfg.BlocklistPoll)
+	for now := range ticker.C {
+		if !rw.cfg.Tiering.RewriteInPlace && now.Sub(lastRecovery) >= rw.cfg.Tiering.RecoveryInterval {
+			rw.doTieringRecovery(context.Background())
+			lastRecovery = now
+		}
+		rw.doTiering(context.Background(), now)
+	}
+}
+
+// doTieringRecovery restores the metas of blocks of all tenants whose move to the cold tier was interrupted
+func (rw *readerWriter) doTieringRecovery(ctx context.Context) {
+	for _, tenantID := range rw.blocklist.Tenants() {
+		rw.recoverTieredBlocks(ctx, tenantID)
+	}
+}
+
+// doTiering moves up to MaxBlocksPerCycle owned blocks that are due to the cold tier
+func (rw *readerWriter) doTiering(ctx context.Context, now time.Time) {
+	cfg := rw.cfg.Tiering
+	moved := 0
+
+	for _, tenantID := range rw.blocklist.Tenants() {
+		for _, meta := range rw.blocklist.Metas(tenantID) {
+			if moved >= cfg.MaxBlocksPerCycle {
+				return
+			}
+			if !rw.dueForTiering(meta, now) || !rw.compactorSharder.Owns(meta.BlockID.String()) {
+				continue
+			}
+
+			ok, err := rw.tierBlock(ctx, meta)
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to move block to cold tier", "blockID", meta.BlockID, "tenantID", tenantID, "err", err)
+				metricTieringErrors.Inc()
+				continue
+			}
+			if ok {
+				moved++
+			}
+		}
+	}
+}
+
+func (rw *readerWriter) dueForTiering(meta *backend.BlockMeta, now time.Time) bool {
+	cfg := rw.cfg.Tiering
+
+	if meta.Tier != "" {
+		return false
+	}
+	if cfg.MinBlockAge > 0 && now.Sub(meta.EndTime) >= cfg.MinBlockAge {
+		return true
+	}
+	return cfg.MinCompactionLevel > 0 && meta.CompactionLevel >= cfg.MinCompactionLevel
+}
+
+// tierBlock copies the block to the cold backend, clears it from the primary backend and writes its meta with the
+// cold tier back to the primary backend. The in-memory blocklist picks up the new tier with the next poll. Returns
+// false if the block was compacted or moved in the meantime.
+func (rw *readerWriter) tierBlock(ctx context.Context, meta *backend.BlockMeta) (bool, error) {
+	current, err := rw.r.BlockMeta(ctx, meta.BlockID, meta.TenantID)
+	if errors.Is(err, backend.ErrDoesNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.Tier != "" {
+		return false, nil
+	}
+
+	enc, err := encoding.FromVersion(current.Version)
+	if err != nil {
+		return false, err
+	}
+
+	level.Info(rw.logger).Log("msg", "moving block to cold tier", "blockID", meta.BlockID, "tenantID", meta.TenantID, "inPlace", rw.cfg.Tiering.RewriteInPlace)
+
+	cold := *current
+	cold.Tier = backend.TierCold
+	err = enc.CopyBlock(ctx, &cold, rw.uncachedReader, rw.coldWriter)
+	if err != nil {
+		return false, fmt.Errorf("error copying block: %w", err)
+	}
+
+	if !rw.cfg.Tiering.RewriteInPlace {
+		// a failure after this point is recovered by recoverTieredBlocks
+		err = rw.c.ClearBlock(meta.BlockID, meta.TenantID)
+		if err != nil {
+			return false, fmt.Errorf("error clearing block from primary backend: %w", err)
+		}
+	}
+
+	// the meta is (re)written through the primary backend so it keeps its storage class when rewriting in place
+	err = rw.w.WriteBlockMeta(ctx, &cold)
+	if err != nil {
+		return false, fmt.Errorf("error writing block meta: %w", err)
+	}
+
+	metricTieringBlocksMoved.WithLabelValues(meta.TenantID).Inc()
+	metricTieringBytesMoved.WithLabelValues(meta.TenantID).Add(float64(meta.Size))
+	return true, nil
+}
+
+// recoverTieredBlocks restores the metas of owned blocks that were cleared from the primary backend after being
+// copied to the cold backend but before their meta was written back
+func (rw *readerWriter) recoverTieredBlocks(ctx context.Context, tenantID string) {
+	blockIDs, err := rw.coldReader.Blocks(ctx, tenantID)
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to list blocks of cold tier", "tenantID", tenantID, "err", err)
+		metricTieringErrors.Inc()
+		return
+	}
+
+	known := map[uuid.UUID]struct{}{}
+	for _, m := range rw.blocklist.Metas(tenantID) {
+		known[m.BlockID] = struct{}{}
+	}
+	for _, m := range rw.blocklist.CompactedMetas(tenantID) {
+		known[m.BlockID] = struct{}{}
+	}
+
+	for _, id := range blockIDs {
+		if _, ok := known[id]; ok || !rw.compactorSharder.Owns(id.String()) {
+			continue
+		}
+
+		err := rw.recoverTieredBlock(ctx, id, tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to recover block of cold tier", "blockID", id, "tenantID", tenantID, "err", err)
+			metricTieringErrors.Inc()
+		}
+	}
+}
+
+func (rw *readerWriter) recoverTieredBlock(ctx context.Context, blockID uuid.UUID, tenantID string) error {
+	// the block may be missing from the blocklist because it hasn't been polled yet
+	_, err := rw.r.BlockMeta(ctx, blockID, tenantID)
+	if !errors.Is(err, backend.ErrDoesNotExist) {
+		return err
+	}
+	_, err = rw.c.CompactedBlockMeta(blockID, tenantID)
+	if !errors.Is(err, backend.ErrDoesNotExist) {
+		return err
+	}
+
+	// the meta is written last, cold blocks without one are incomplete copies
+	meta, err := rw.coldReader.BlockMeta(ctx, blockID, tenantID)
+	if errors.Is(err, backend.ErrDoesNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	level.Info(rw.logger).Log("msg", "restoring meta of block in cold tier", "blockID", blockID, "tenantID", tenantID)
+	err = rw.w.WriteBlockMeta(ctx, meta)
+	if err != nil {
+		return err
+	}
+
+	metricTieringBlocksRecovered.Inc()
+	return nil
+}
+
+// clearBlock removes the block from the primary backend and from the cold backend if it was moved there. The cold
+// copy is removed first so that recoverTieredBlocks never finds a cold block whose meta is gone.
+func (rw *readerWriter) clearBlock(meta *backend.BlockMeta) error {
+	if meta.Tier == backend.TierCold && rw.coldCompactor != nil && !rw.cfg.Tiering.RewriteInPlace {
+		err := rw.coldCompactor.ClearBlock(meta.BlockID, meta.TenantID)
+		if err != nil {
+			return fmt.Errorf("error clearing block from cold backend: %w", err)
+		}
+	}
+
+	return rw.c.ClearBlock(meta.BlockID, meta.TenantID)
+}
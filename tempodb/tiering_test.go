@@ -0,0 +1,143 @@
+package tempodb
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestTiering(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &common.BlockConfig{
+			IndexDownsampleBytes: 17,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Version:              encoding.DefaultEncoding().Version(),
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		Tiering: TieringConfig{
+			Enabled:     true,
+			MinBlockAge: time.Hour,
+			Backend:     "local",
+			Local: &local.Config{
+				Path: path.Join(tempDir, "cold"),
+			},
+		},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+	rw := r.(*readerWriter)
+
+	c.EnableCompaction(&CompactorConfig{
+		MaxCompactionRange:   time.Hour,
+		MaxCompactionObjects: 1000,
+		MaxBlockBytes:        100_000_000,
+	}, &mockSharder{}, &mockOverrides{})
+	r.EnablePolling(&mockJobSharder{})
+
+	blocks := cutTestBlocks(t, w, testTenantID, 2, 10)
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+
+	// blocks are moved once they are old enough
+	now := time.Now()
+	rw.doTiering(ctx, now)
+	for _, b := range blocks {
+		meta, err := rw.r.BlockMeta(ctx, b.BlockMeta().BlockID, testTenantID)
+		require.NoError(t, err)
+		assert.Empty(t, meta.Tier)
+	}
+
+	rw.doTiering(ctx, now.Add(2*time.Hour))
+	for _, b := range blocks {
+		meta, err := rw.r.BlockMeta(ctx, b.BlockMeta().BlockID, testTenantID)
+		require.NoError(t, err)
+		assert.Equal(t, backend.TierCold, meta.Tier)
+		assert.NotEmpty(t, meta.Checksums)
+
+		// only the meta stays in the primary backend
+		entries, err := os.ReadDir(path.Join(tempDir, "traces", testTenantID, meta.BlockID.String()))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, backend.MetaName, entries[0].Name())
+
+		_, err = os.Stat(path.Join(tempDir, "cold", testTenantID, meta.BlockID.String(), "data.parquet"))
+		assert.NoError(t, err)
+	}
+
+	// traces are found through metas that don't know the tier yet and after polling
+	findTrace := func() {
+		trs, errs, err := r.Find(ctx, testTenantID, makeTraceID(1, 5), BlockIDMin, BlockIDMax, 0, 0)
+		require.NoError(t, err)
+		require.Empty(t, errs)
+		require.Len(t, trs, 1)
+		assert.NotNil(t, trs[0])
+	}
+	findTrace()
+
+	rw.pollBlocklist()
+	for _, m := range rw.blocklist.Metas(testTenantID) {
+		assert.Equal(t, backend.TierCold, m.Tier)
+	}
+	findTrace()
+
+	// cold blocks are not compacted
+	selected, _ := rw.newBlockSelector(testTenantID, rw.blocklist.Metas(testTenantID)).BlocksToCompact()
+	assert.Empty(t, selected)
+
+	// a cold block whose meta is missing from the primary backend is restored
+	lost := blocks[0].BlockMeta().BlockID
+	require.NoError(t, os.Remove(path.Join(tempDir, "traces", testTenantID, lost.String(), backend.MetaName)))
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 1)
+
+	// moving blocks doesn't list the cold backend
+	rw.doTiering(ctx, now.Add(2*time.Hour))
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 1)
+
+	rw.doTieringRecovery(ctx)
+	rw.pollBlocklist()
+	require.Len(t, rw.blocklist.Metas(testTenantID), 2)
+	findTrace()
+
+	// clearing a cold block removes it from both backends
+	meta, err := rw.r.BlockMeta(ctx, lost, testTenantID)
+	require.NoError(t, err)
+	require.NoError(t, rw.clearBlock(meta))
+
+	_, err = os.Stat(path.Join(tempDir, "traces", testTenantID, lost.String()))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(path.Join(tempDir, "cold", testTenantID, lost.String()))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidateTieringConfig(t *testing.T) {
+	assert.NoError(t, validateTieringConfig(&TieringConfig{}))
+	assert.Error(t, validateTieringConfig(&TieringConfig{Enabled: true, Backend: "local"}))
+	assert.NoError(t, validateTieringConfig(&TieringConfig{Enabled: true, Backend: "local", MinCompactionLevel: 3}))
+	assert.Error(t, validateTieringConfig(&TieringConfig{Enabled: true, Backend: "local", MinBlockAge: time.Hour, RewriteInPlace: true}))
+	assert.NoError(t, validateTieringConfig(&TieringConfig{Enabled: true, Backend: "s3", MinBlockAge: time.Hour, RewriteInPlace: true}))
+}
@@ -0,0 +1,60 @@
+package tempodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// ErrBlockCorrupt is wrapped by the errors VerifyBlock returns for corrupt blocks
+var ErrBlockCorrupt = errors.New("block corrupt")
+
+// VerifyBlock validates the checksums recorded in the meta and reads the entire block to check its structure.
+// Errors wrapping ErrBlockCorrupt mean the block is corrupt, other errors are failures to read it.
+func VerifyBlock(ctx context.Context, r backend.Reader, meta *backend.BlockMeta) error {
+	names := make([]string, 0, len(meta.Checksums))
+	for name := range meta.Checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sum, err := backend.ObjectChecksum(ctx, r, name, meta.BlockID, meta.TenantID)
+		if errors.Is(err, backend.ErrDoesNotExist) {
+			return fmt.Errorf("%w: object %s does not exist", ErrBlockCorrupt, name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %w", name, err)
+		}
+		if sum != meta.Checksums[name] {
+			return fmt.Errorf("%w: checksum mismatch of object %s: expected %08x, got %08x", ErrBlockCorrupt, name, meta.Checksums[name], sum)
+		}
+	}
+
+	err := encoding.VerifyBlock(ctx, meta, r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", ErrBlockCorrupt, err)
+	}
+
+	return nil
+}
+
+// QuarantineBlock records the reason in the meta of the block. Pollers leave quarantined blocks out of the
+// blocklist, so they are neither queried nor compacted or deleted.
+func QuarantineBlock(ctx context.Context, w backend.Writer, meta *backend.BlockMeta, reason string) error {
+	quarantined := *meta
+	quarantined.Quarantine = &backend.Quarantine{
+		Reason: reason,
+		Time:   time.Now(),
+	}
+
+	return w.WriteBlockMeta(ctx, &quarantined)
+}
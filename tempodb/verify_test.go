@@ -0,0 +1,80 @@
+package tempodb
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func corruptObject(t *testing.T, tempDir string, meta *backend.BlockMeta, name string) {
+	p := path.Join(tempDir, "traces", meta.TenantID, meta.BlockID.String(), name)
+
+	buff, err := os.ReadFile(p)
+	require.NoError(t, err)
+	buff[len(buff)/2] ^= 0xFF
+	require.NoError(t, os.WriteFile(p, buff, 0600))
+}
+
+func TestVerifyBlock(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	rw := r.(*readerWriter)
+	ctx := context.Background()
+
+	blocks := cutTestBlocks(t, w, testTenantID, 2, 10)
+	meta := blocks[0].BlockMeta()
+
+	// the meta in the backend has the checksums of all objects
+	backendMeta, err := rw.r.BlockMeta(ctx, meta.BlockID, testTenantID)
+	require.NoError(t, err)
+	require.NotEmpty(t, backendMeta.Checksums)
+	require.Contains(t, backendMeta.Checksums, "data.parquet")
+	require.NoError(t, VerifyBlock(ctx, rw.r, backendMeta))
+
+	corruptObject(t, tempDir, backendMeta, "data.parquet")
+	err = VerifyBlock(ctx, rw.r, backendMeta)
+	assert.ErrorIs(t, err, ErrBlockCorrupt)
+
+	// without checksums the structure is still verified
+	otherMeta, err := rw.r.BlockMeta(ctx, blocks[1].BlockMeta().BlockID, testTenantID)
+	require.NoError(t, err)
+	p := path.Join(tempDir, "traces", testTenantID, otherMeta.BlockID.String(), "data.parquet")
+	buff, err := os.ReadFile(p)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(p, buff[:len(buff)/2], 0600))
+
+	noChecksums := *otherMeta
+	noChecksums.Checksums = nil
+	err = VerifyBlock(ctx, rw.r, &noChecksums)
+	assert.ErrorIs(t, err, ErrBlockCorrupt)
+
+	// missing objects
+	require.NoError(t, os.Remove(p))
+	err = VerifyBlock(ctx, rw.r, otherMeta)
+	assert.ErrorIs(t, err, ErrBlockCorrupt)
+}
+
+func TestQuarantinedBlocksAreNotPolled(t *testing.T) {
+	r, w, _, _ := testConfig(t, backend.EncNone, 0)
+	rw := r.(*readerWriter)
+	ctx := context.Background()
+	r.EnablePolling(&mockJobSharder{})
+
+	blocks := cutTestBlocks(t, w, testTenantID, 1, 10)
+	meta := blocks[0].BlockMeta()
+	checkBlocklists(t, meta.BlockID, 1, 0, rw)
+
+	require.NoError(t, QuarantineBlock(ctx, rw.w, meta, "test"))
+	checkBlocklists(t, meta.BlockID, 0, 0, rw)
+
+	// the reason is recorded in the meta
+	quarantined, err := rw.r.BlockMeta(ctx, meta.BlockID, testTenantID)
+	require.NoError(t, err)
+	require.NotNil(t, quarantined.Quarantine)
+	assert.Equal(t, "test", quarantined.Quarantine.Reason)
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/google/uuid"
+	"go.uber.org/atomic"
 
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
@@ -83,62 +86,168 @@ func New(c *Config) (*WAL, error) {
 
 // RescanBlocks returns a slice of append blocks from the wal folder
 func (w *WAL) RescanBlocks(additionalStartSlack time.Duration, log log.Logger) ([]common.WALBlock, error) {
-	files, err := os.ReadDir(w.c.Filepath)
+	files, err := w.blockFiles()
 	if err != nil {
 		return nil, err
 	}
 
-	// todo: rescan blocks will need to detect if this is a vParquet or v2 wal file and choose the appropriate encoding
-	v, err := encoding.FromVersion(v2.VersionString)
+	var (
+		mtx    sync.Mutex
+		blocks []common.WALBlock
+	)
+
+	err = w.replayFiles(files, additionalStartSlack, 1, func(b common.WALBlock) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		blocks = append(blocks, b)
+		return nil
+	}, log)
 	if err != nil {
-		return nil, fmt.Errorf("from version v2 failed %w", err)
+		return nil, err
 	}
 
-	blocks := make([]common.WALBlock, 0, len(files))
+	return blocks, nil
+}
+
+// ReplayBlocks replays the append blocks with the given ids with the given number of workers and passes each
+// replayed block to onBlock as soon as it is ready. onBlock may be called concurrently. Blocks that fail to replay
+// or are empty are removed. The first error stops the replay of the remaining blocks. Append blocks created after
+// the ids were listed by BlockIDs are not replayed.
+func (w *WAL) ReplayBlocks(blockIDs []uuid.UUID, additionalStartSlack time.Duration, concurrency int, onBlock func(common.WALBlock) error, log log.Logger) error {
+	files, err := w.blockFiles()
+	if err != nil {
+		return err
+	}
+
+	replay := make(map[uuid.UUID]struct{}, len(blockIDs))
+	for _, id := range blockIDs {
+		replay[id] = struct{}{}
+	}
+
+	filtered := files[:0]
 	for _, f := range files {
-		if f.IsDir() {
-			continue
+		id, _, _, _, _, err := v2.ParseFilename(f.Name())
+		if err == nil {
+			if _, ok := replay[id]; !ok {
+				continue
+			}
 		}
+		// files that are not wal files are passed on to be removed
+		filtered = append(filtered, f)
+	}
 
-		start := time.Now()
-		fileInfo, err := f.Info()
-		if err != nil {
-			return nil, err
-		}
+	return w.replayFiles(filtered, additionalStartSlack, concurrency, onBlock, log)
+}
 
-		level.Info(log).Log("msg", "beginning replay", "file", f.Name(), "size", fileInfo.Size())
-		b, warning, err := v.OpenWALBlock(f.Name(), w.c.Filepath, w.c.IngestionSlack, additionalStartSlack)
+// BlockIDs returns the ids of the append blocks in the wal folder without replaying them
+func (w *WAL) BlockIDs() ([]uuid.UUID, error) {
+	files, err := w.blockFiles()
+	if err != nil {
+		return nil, err
+	}
 
-		remove := false
+	ids := make([]uuid.UUID, 0, len(files))
+	for _, f := range files {
+		id, _, _, _, _, err := v2.ParseFilename(f.Name())
 		if err != nil {
-			// wal replay failed, clear and warn
-			level.Warn(log).Log("msg", "failed to replay block. removing.", "file", f.Name(), "err", err)
-			remove = true
+			// not a wal file. replay removes it
+			continue
 		}
+		ids = append(ids, id)
+	}
 
-		if b != nil && b.Length() == 0 {
-			level.Warn(log).Log("msg", "empty wal file. ignoring.", "file", f.Name(), "err", err)
-			remove = true
-		}
+	return ids, nil
+}
+
+func (w *WAL) replayFiles(files []os.DirEntry, additionalStartSlack time.Duration, concurrency int, onBlock func(common.WALBlock) error, log log.Logger) error {
+	// todo: rescan blocks will need to detect if this is a vParquet or v2 wal file and choose the appropriate encoding
+	v, err := encoding.FromVersion(v2.VersionString)
+	if err != nil {
+		return fmt.Errorf("from version v2 failed %w", err)
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
-		if warning != nil {
-			level.Warn(log).Log("msg", "received warning while replaying block. partial replay likely.", "file", f.Name(), "warning", warning, "length", b.DataLength())
+	bg := boundedwaitgroup.New(uint(concurrency))
+	anyError := atomic.Error{}
+
+	for _, f := range files {
+		if anyError.Load() != nil {
+			break
 		}
 
-		if remove {
-			err = os.Remove(filepath.Join(w.c.Filepath, f.Name()))
+		bg.Add(1)
+		go func(f os.DirEntry) {
+			defer bg.Done()
+
+			b, err := w.replayBlock(v, f, additionalStartSlack, log)
+			if err == nil && b != nil {
+				err = onBlock(b)
+			}
 			if err != nil {
-				return nil, err
+				anyError.Store(err)
 			}
-			continue
+		}(f)
+	}
+
+	bg.Wait()
+
+	return anyError.Load()
+}
+
+func (w *WAL) blockFiles() ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(w.c.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]os.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e)
 		}
+	}
 
-		level.Info(log).Log("msg", "replay complete", "file", f.Name(), "duration", time.Since(start))
+	return files, nil
+}
 
-		blocks = append(blocks, b)
+// replayBlock opens the append block of the file. Returns a nil block if the file was removed.
+func (w *WAL) replayBlock(v encoding.VersionedEncoding, f os.DirEntry, additionalStartSlack time.Duration, log log.Logger) (common.WALBlock, error) {
+	start := time.Now()
+	fileInfo, err := f.Info()
+	if err != nil {
+		return nil, err
 	}
 
-	return blocks, nil
+	level.Info(log).Log("msg", "beginning replay", "file", f.Name(), "size", fileInfo.Size())
+	b, warning, err := v.OpenWALBlock(f.Name(), w.c.Filepath, w.c.IngestionSlack, additionalStartSlack)
+
+	remove := false
+	if err != nil {
+		// wal replay failed, clear and warn
+		level.Warn(log).Log("msg", "failed to replay block. removing.", "file", f.Name(), "err", err)
+		remove = true
+	}
+
+	if b != nil && b.Length() == 0 {
+		level.Warn(log).Log("msg", "empty wal file. ignoring.", "file", f.Name(), "err", err)
+		remove = true
+	}
+
+	if warning != nil {
+		level.Warn(log).Log("msg", "received warning while replaying block. partial replay likely.", "file", f.Name(), "warning", warning, "length", b.DataLength())
+	}
+
+	if remove {
+		return nil, os.Remove(filepath.Join(w.c.Filepath, f.Name()))
+	}
+
+	level.Info(log).Log("msg", "replay complete", "file", f.Name(), "duration", time.Since(start))
+
+	return b, nil
 }
 
 func (w *WAL) NewBlock(id uuid.UUID, tenantID string, dataEncoding string) (common.WALBlock, error) {
@@ -3,11 +3,13 @@ package wal
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -217,6 +219,58 @@ func TestInvalidFiles(t *testing.T) {
 	require.NoFileExists(t, filepath.Join(tempDir, "fe0b83eb-a86b-4b6c-9a74-dc272cd5700e:blerg:v2:gzip"))
 }
 
+func TestReplayBlocks(t *testing.T) {
+	wal, err := New(&Config{
+		Filepath: t.TempDir(),
+		Encoding: backend.EncNone,
+	})
+	require.NoError(t, err, "unexpected error creating temp wal")
+
+	blockCount := 10
+	expected := make([]uuid.UUID, 0, blockCount)
+	for i := 0; i < blockCount; i++ {
+		block, err := wal.NewBlock(uuid.New(), testTenantID, model.CurrentEncoding)
+		require.NoError(t, err)
+
+		id := make([]byte, 16)
+		rand.Read(id)
+		b, err := model.MustNewSegmentDecoder(model.CurrentEncoding).PrepareForWrite(test.MakeTrace(10, id), 0, 0)
+		require.NoError(t, err)
+		err = block.Append(id, b, 0, 0)
+		require.NoError(t, err)
+
+		expected = append(expected, block.BlockMeta().BlockID)
+	}
+
+	ids, err := wal.BlockIDs()
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, ids)
+
+	// blocks created after listing the ids are not replayed
+	_, err = wal.NewBlock(uuid.New(), testTenantID, model.CurrentEncoding)
+	require.NoError(t, err)
+
+	var (
+		mtx      sync.Mutex
+		replayed []uuid.UUID
+	)
+	err = wal.ReplayBlocks(ids, 0, 4, func(b common.WALBlock) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		replayed = append(replayed, b.BlockMeta().BlockID)
+		return nil
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, replayed)
+
+	// the first error stops the replay
+	errReplay := errors.New("replay failed")
+	err = wal.ReplayBlocks(ids, 0, 1, func(b common.WALBlock) error {
+		return errReplay
+	}, log.NewNopLogger())
+	require.ErrorIs(t, err, errReplay)
+}
+
 func BenchmarkWALNone(b *testing.B) {
 	benchmarkWriteFindReplay(b, backend.EncNone)
 }